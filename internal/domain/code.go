@@ -0,0 +1,61 @@
+package domain
+
+import "time"
+
+// CodeQuota 发送验证码的限额，0 表示不限制
+type CodeQuota struct {
+	// PhonePerDay 同一个手机号或者邮箱每天最多发多少条
+	PhonePerDay int
+	// IPPerHour 同一个 IP 每小时最多发多少条
+	IPPerHour int
+	// GlobalPerDay 这个业务每天一共最多发多少条
+	GlobalPerDay int
+	// TotalPerDay 所有业务加起来每天最多发多少条，不能按业务配置
+	TotalPerDay int
+}
+
+// CodeQuotaUsage 占用的一次发送额度，归还的时候原样传回来，
+// 跨过整点或者零点也能还到占用的那个计数上
+type CodeQuotaUsage struct {
+	Biz string
+	// Phone 手机号或者邮箱
+	Phone string
+	IP    string
+	Quota CodeQuota
+	At    time.Time
+}
+
+// CodeAudit 被拦截的发送验证码请求
+type CodeAudit struct {
+	Biz string
+	// Phone 手机号或者邮箱
+	Phone  string
+	IP     string
+	Reason string
+	Ctime  time.Time
+}
+
+// Captcha 人机验证的挑战，Id 验证的时候要带回来
+type Captcha struct {
+	Id     string
+	Type   string
+	Images map[string]string
+	// Y 滑块的纵坐标
+	Y int
+}
+
+// CodePolicy 验证码的生成和校验策略
+type CodePolicy struct {
+	// Channel 通过什么渠道发送，sms 或者 email
+	Channel string
+	Length  int
+	// Alphabet 验证码使用的字符
+	Alphabet string
+	// TTL 验证码的有效期
+	TTL time.Duration
+	// ResendInterval 两次发送之间至少间隔多久
+	ResendInterval time.Duration
+	MaxAttempts    int
+	// Templates 模板 ID，key 是服务商
+	Templates map[string]string
+}
@@ -0,0 +1,48 @@
+package domain
+
+type IdentityType string
+
+const (
+	IdentityEmail  IdentityType = "email"
+	IdentityPhone  IdentityType = "phone"
+	IdentityWechat IdentityType = "wechat"
+	IdentityOAuth2 IdentityType = "oauth2"
+)
+
+// Identity 用户可以用来登录的身份，一个用户可以同时绑定多个，但是至少要保留一个。
+// 邮箱、手机号和微信还是 users 表上的列，每种只能绑定一个，
+// 只有第三方账号在 external_identities 里面，可以绑定多个。
+// Identity 只是把这些汇总起来给前端展示，不是统一的身份表
+type Identity struct {
+	Type IdentityType
+	// Provider 只有第三方账号有，比如 github
+	Provider string
+	// Value 邮箱，手机号，微信的 openid 或者第三方账号的 subject
+	Value string
+}
+
+func (u User) Identities() []Identity {
+	res := make([]Identity, 0, 3+len(u.OAuth2Identities))
+	if u.Email != "" {
+		res = append(res, Identity{Type: IdentityEmail, Value: u.Email})
+	}
+	if u.Phone != "" {
+		res = append(res, Identity{Type: IdentityPhone, Value: u.Phone})
+	}
+	if u.WechatInfo.OpenId != "" {
+		res = append(res, Identity{Type: IdentityWechat, Value: u.WechatInfo.OpenId})
+	}
+	for _, info := range u.OAuth2Identities {
+		res = append(res, Identity{Type: IdentityOAuth2, Provider: info.Provider, Value: info.Subject})
+	}
+	return res
+}
+
+// ConflictsWith 两个账号绑定了同一种登录方式，但是值不一样，不能合并
+func (u User) ConflictsWith(other User) bool {
+	conflict := func(a, b string) bool {
+		return a != "" && b != "" && a != b
+	}
+	return conflict(u.Email, other.Email) || conflict(u.Phone, other.Phone) ||
+		conflict(u.WechatInfo.OpenId, other.WechatInfo.OpenId)
+}
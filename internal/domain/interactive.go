@@ -7,3 +7,9 @@ type Interactive struct {
 	Liked      bool
 	Collected  bool
 }
+
+// BizRef 点赞、收藏的对象
+type BizRef struct {
+	Biz   string
+	BizId int64
+}
@@ -0,0 +1,49 @@
+package domain
+
+import "time"
+
+// LoginScope 登录失败按照账号和 IP 分别计数
+type LoginScope string
+
+const (
+	LoginScopeAccount LoginScope = "account"
+	LoginScopeIP      LoginScope = "ip"
+)
+
+// LoginPolicy 密码登录失败之后的处理，失败次数都是 Window 内累计的
+type LoginPolicy struct {
+	// ChallengeAfter 账号或者 IP 失败这么多次之后，要先通过额外的验证才能继续尝试，0 表示不要求
+	ChallengeAfter int
+	// AccountLockAfter 同一个账号失败这么多次就锁定，0 表示不锁定
+	AccountLockAfter int
+	IPLockAfter      int
+	Window           time.Duration
+	// BaseLock 第一次锁定的时长，之后每次锁定翻倍，最长 MaxLock
+	BaseLock time.Duration
+	MaxLock  time.Duration
+	// BackoffReset 这么久没有再被锁定，锁定时长回到 BaseLock
+	BackoffReset time.Duration
+}
+
+// LoginAttempt 一个账号或者 IP 的失败记录
+type LoginAttempt struct {
+	Failures int
+	// LockedFor 剩余的锁定时间，0 表示没有锁定
+	LockedFor time.Duration
+	// Level 最近被锁定过几次，决定下一次锁多久
+	Level int
+	// JustLocked 这一次失败触发了锁定
+	JustLocked bool
+}
+
+// LoginAudit 登录相关的安全事件，比如账号被锁定
+type LoginAudit struct {
+	Scope LoginScope
+	// Subject 账号或者 IP
+	Subject string
+	IP      string
+	Event   string
+	Level   int
+	Lock    time.Duration
+	Ctime   time.Time
+}
@@ -0,0 +1,16 @@
+package domain
+
+// OAuth2ProviderWechat 微信的账号记在用户表里面，和别的第三方分开处理
+const OAuth2ProviderWechat = "wechat"
+
+// OAuth2Info 第三方登录拿到的用户信息，Provider 加 Subject 唯一确定一个第三方账号
+type OAuth2Info struct {
+	Provider string
+	Subject  string
+	// UnionId 只有微信有
+	UnionId string
+	Email   string
+	// EmailVerified 第三方没有验证过的邮箱不能当作用户的邮箱
+	EmailVerified bool
+	Name          string
+}
@@ -0,0 +1,50 @@
+package domain
+
+import "time"
+
+type SecurityEventType string
+
+const (
+	SecurityEventSignup         SecurityEventType = "signup"
+	SecurityEventLogin          SecurityEventType = "login"
+	SecurityEventLoginFailed    SecurityEventType = "login_failed"
+	SecurityEventLogout         SecurityEventType = "logout"
+	SecurityEventTokenRefresh   SecurityEventType = "token_refresh"
+	SecurityEventPasswordChange SecurityEventType = "password_change"
+	SecurityEventPasswordReset  SecurityEventType = "password_reset"
+	SecurityEventSessionRevoke  SecurityEventType = "session_revoke"
+)
+
+// 登录和注册的方式，微信以外的第三方登录直接用 provider 的名字，比如 github
+const (
+	LoginMethodPassword = "password"
+	LoginMethodSMS      = "sms"
+	LoginMethodEmail    = "email"
+	LoginMethodWechat   = "wechat"
+	// LoginMethodTOTP 密码之后的第二步
+	LoginMethodTOTP = "totp"
+)
+
+// SecurityEvent 和账号安全相关的操作，登录失败的时候可能不知道是哪个用户，Uid 是 0
+type SecurityEvent struct {
+	Id     int64
+	Uid    int64
+	Type   SecurityEventType
+	Method string
+	// Account 登录用的邮箱或者手机号，失败的时候用来排查撞库
+	Account   string
+	IP        string
+	UserAgent string
+	Ssid      string
+	// Detail 失败原因之类的补充信息
+	Detail string
+	Ctime  time.Time
+}
+
+// SecurityEventQuery Uid 和 IP 至少要有一个，按照时间倒序，BeforeId 用来翻页
+type SecurityEventQuery struct {
+	Uid      int64
+	IP       string
+	BeforeId int64
+	Limit    int
+}
@@ -0,0 +1,18 @@
+package domain
+
+// TwoFactor 用户的 TOTP 两步验证，Enabled 为 false 的时候是扫码之后还没确认
+type TwoFactor struct {
+	Uid    int64
+	Secret []byte
+	// RecoveryCodes 恢复码的哈希，丢了手机的时候用来登录，每个只能用一次
+	RecoveryCodes []string
+	Enabled       bool
+	// LastStep 最后一次通过验证的 TOTP 周期，同一个验证码不能用两次
+	LastStep int64
+}
+
+// TOTPEnrollment 开启两步验证的时候返回给用户，URI 渲染成二维码给验证器 App 扫
+type TOTPEnrollment struct {
+	Secret string
+	URI    string
+}
@@ -3,10 +3,12 @@ package domain
 import "time"
 
 type User struct {
-	Id       int64
-	Email    string
-	Phone    string
-	Password string
+	Id    int64
+	Email string
+	// EmailVerified 用验证码证明过邮箱是自己的
+	EmailVerified bool
+	Phone         string
+	Password      string
 	//昵称
 	Nickname   string
 	WechatInfo WechatInfo
@@ -14,6 +16,8 @@ type User struct {
 	Birthday time.Time
 	//个人简介
 	AboutMe string
+	// OAuth2Identities GitHub，OIDC 这些第三方账号，不在缓存里面，需要的时候单独加载
+	OAuth2Identities []OAuth2Info
 	// UTC 0 的时区
 	Ctime time.Time
 }
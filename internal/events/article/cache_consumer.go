@@ -0,0 +1,67 @@
+package article
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/repository"
+	"webok/internal/repository/cache"
+	"webok/pkg/cachex"
+	"webok/pkg/logger"
+	"webok/pkg/samarax"
+)
+
+const groupArticleCache = "article_cache"
+
+// CacheInvalidationConsumer 文章发表或者撤回之后，删除线上库的缓存，
+// 避免读者看到旧的内容或者已经撤回的文章。线上库的文章还有每个实例的本地缓存，
+// 要通过 pub 删除，这样所有实例的本地缓存都会收到失效通知
+type CacheInvalidationConsumer struct {
+	cache    cache.ArticleCache
+	pub      *cachex.TwoLevel[domain.Article]
+	broker   samarax.Broker
+	registry *samarax.Registry
+	l        logger.Logger
+}
+
+func NewCacheInvalidationConsumer(cache cache.ArticleCache, pub *cachex.TwoLevel[domain.Article],
+	broker samarax.Broker, registry *samarax.Registry, l logger.Logger) *CacheInvalidationConsumer {
+	return &CacheInvalidationConsumer{cache: cache, pub: pub, broker: broker, registry: registry, l: l}
+}
+
+func (c *CacheInvalidationConsumer) Start() error {
+	cg, err := c.broker.ConsumerGroup(groupArticleCache)
+	if err != nil {
+		return err
+	}
+	h := samarax.NewDispatchHandler(c.l, c.registry)
+	samarax.Route[PublishedEvent](h, c.ConsumePublished)
+	samarax.Route[WithdrawnEvent](h, c.ConsumeWithdrawn)
+	go func() {
+		er := cg.Consume(context.Background(),
+			[]string{TopicPublishedEvent, TopicWithdrawnEvent}, h)
+		if er != nil {
+			c.l.Error("退出消费", logger.Error(er))
+		}
+	}()
+	return nil
+}
+
+func (c *CacheInvalidationConsumer) ConsumePublished(msg *sarama.ConsumerMessage, evt PublishedEvent) error {
+	return c.invalidate(evt.Aid, evt.AuthorId)
+}
+
+func (c *CacheInvalidationConsumer) ConsumeWithdrawn(msg *sarama.ConsumerMessage, evt WithdrawnEvent) error {
+	return c.invalidate(evt.Aid, evt.AuthorId)
+}
+
+func (c *CacheInvalidationConsumer) invalidate(aid int64, authorId int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := c.pub.Del(ctx, repository.PubArticleKey(aid))
+	if err != nil {
+		return err
+	}
+	return c.cache.DelFirstPage(ctx, authorId)
+}
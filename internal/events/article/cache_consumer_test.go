@@ -0,0 +1,94 @@
+package article
+
+import (
+	"context"
+	"errors"
+	"github.com/IBM/sarama"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/repository"
+	"webok/internal/repository/cache"
+	cachemocks "webok/internal/repository/cache/mock"
+	"webok/pkg/cachex"
+	"webok/pkg/logger"
+)
+
+func TestCacheInvalidationConsumer(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) cache.ArticleCache
+		consume func(c *CacheInvalidationConsumer) error
+
+		wantErr error
+	}{
+		{
+			name: "发表之后删除缓存",
+			mock: func(ctrl *gomock.Controller) cache.ArticleCache {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				// 重复投递的时候再删一次
+				c.EXPECT().DelFirstPage(gomock.Any(), int64(123)).Return(nil).Times(2)
+				return c
+			},
+			consume: func(c *CacheInvalidationConsumer) error {
+				return c.ConsumePublished(&sarama.ConsumerMessage{}, PublishedEvent{Aid: 1, AuthorId: 123})
+			},
+		},
+		{
+			name: "撤回之后删除缓存",
+			mock: func(ctrl *gomock.Controller) cache.ArticleCache {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().DelFirstPage(gomock.Any(), int64(123)).Return(nil).Times(2)
+				return c
+			},
+			consume: func(c *CacheInvalidationConsumer) error {
+				return c.ConsumeWithdrawn(&sarama.ConsumerMessage{}, WithdrawnEvent{Aid: 1, AuthorId: 123})
+			},
+		},
+		{
+			name: "删除列表缓存失败，返回错误等重试",
+			mock: func(ctrl *gomock.Controller) cache.ArticleCache {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().DelFirstPage(gomock.Any(), int64(123)).Return(errors.New("redis error")).Times(2)
+				return c
+			},
+			consume: func(c *CacheInvalidationConsumer) error {
+				return c.ConsumeWithdrawn(&sarama.ConsumerMessage{}, WithdrawnEvent{Aid: 1, AuthorId: 123})
+			},
+			wantErr: errors.New("redis error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := miniredis.RunT(t)
+			pub := repository.NewPubArticleCache(redis.NewClient(&redis.Options{Addr: s.Addr()}), nil,
+				cachex.NewLocalInvalidator(time.Minute), logger.NewNopLogger())
+			ctx := context.Background()
+			key := repository.PubArticleKey(1)
+			require.NoError(t, pub.Set(ctx, key, domain.Article{Id: 1, Title: "旧标题"}))
+			c := NewCacheInvalidationConsumer(tc.mock(ctrl), pub, nil, nil, logger.NewNopLogger())
+
+			// 同一条消息消费两次，结果一样
+			for i := 0; i < 2; i++ {
+				err := tc.consume(c)
+				assert.Equal(t, tc.wantErr, err)
+				assert.False(t, s.Exists(key))
+				loaded := false
+				_, err = pub.Get(ctx, key, func(ctx context.Context) (domain.Article, error) {
+					loaded = true
+					return domain.Article{}, errors.New("not loaded")
+				})
+				assert.Error(t, err)
+				assert.True(t, loaded)
+			}
+		})
+	}
+}
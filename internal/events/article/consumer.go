@@ -9,26 +9,32 @@ import (
 	"webok/pkg/samarax"
 )
 
+const groupInteractive = "interactive"
+
 type InteractiveReadEventConsumer struct {
-	repo   repository.InteractiveRepository
-	client sarama.Client
-	l      logger.Logger
+	repo     repository.InteractiveRepository
+	broker   samarax.Broker
+	registry *samarax.Registry
+	store    samarax.IdempotencyStore
+	l        logger.Logger
 }
 
 func NewInteractiveReadEventConsumer(repo repository.InteractiveRepository,
-	client sarama.Client, l logger.Logger) *InteractiveReadEventConsumer {
-	return &InteractiveReadEventConsumer{repo: repo, client: client, l: l}
+	broker samarax.Broker, registry *samarax.Registry,
+	store samarax.IdempotencyStore, l logger.Logger) *InteractiveReadEventConsumer {
+	return &InteractiveReadEventConsumer{repo: repo, broker: broker, registry: registry, store: store, l: l}
 }
 
 func (i *InteractiveReadEventConsumer) Start() error {
-	cg, err := sarama.NewConsumerGroupFromClient("interactive", i.client)
+	cg, err := i.broker.ConsumerGroup(groupInteractive)
 	if err != nil {
 		return err
 	}
 	go func() {
 		er := cg.Consume(context.Background(),
 			[]string{TopicReadEvent},
-			samarax.NewBatchHandler[ReadEvent](i.l, i.BatchConsume))
+			samarax.NewEnvelopeBatchHandler[ReadEvent](i.l, i.registry,
+				samarax.BatchIdempotent[ReadEvent](i.store, groupInteractive, i.l, i.BatchConsume)))
 		if er != nil {
 			i.l.Error("退出消费", logger.Error(er))
 		}
@@ -0,0 +1,125 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: producer.go
+//
+// Generated by this command:
+//
+//	mockgen -source=producer.go -package=evtmocks -destination=./mock/producer.mock.go
+//
+
+// Package evtmocks is a generated GoMock package.
+package evtmocks
+
+import (
+	reflect "reflect"
+	article "webok/internal/events/article"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockProducer is a mock of Producer interface.
+type MockProducer struct {
+	ctrl     *gomock.Controller
+	recorder *MockProducerMockRecorder
+	isgomock struct{}
+}
+
+// MockProducerMockRecorder is the mock recorder for MockProducer.
+type MockProducerMockRecorder struct {
+	mock *MockProducer
+}
+
+// NewMockProducer creates a new mock instance.
+func NewMockProducer(ctrl *gomock.Controller) *MockProducer {
+	mock := &MockProducer{ctrl: ctrl}
+	mock.recorder = &MockProducerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockProducer) EXPECT() *MockProducerMockRecorder {
+	return m.recorder
+}
+
+// ProduceCollectedEvent mocks base method.
+func (m *MockProducer) ProduceCollectedEvent(evt article.CollectedEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceCollectedEvent", evt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ProduceCollectedEvent indicates an expected call of ProduceCollectedEvent.
+func (mr *MockProducerMockRecorder) ProduceCollectedEvent(evt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceCollectedEvent", reflect.TypeOf((*MockProducer)(nil).ProduceCollectedEvent), evt)
+}
+
+// ProduceLikedEvent mocks base method.
+func (m *MockProducer) ProduceLikedEvent(evt article.LikedEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceLikedEvent", evt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ProduceLikedEvent indicates an expected call of ProduceLikedEvent.
+func (mr *MockProducerMockRecorder) ProduceLikedEvent(evt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceLikedEvent", reflect.TypeOf((*MockProducer)(nil).ProduceLikedEvent), evt)
+}
+
+// ProducePublishedEvent mocks base method.
+func (m *MockProducer) ProducePublishedEvent(evt article.PublishedEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProducePublishedEvent", evt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ProducePublishedEvent indicates an expected call of ProducePublishedEvent.
+func (mr *MockProducerMockRecorder) ProducePublishedEvent(evt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProducePublishedEvent", reflect.TypeOf((*MockProducer)(nil).ProducePublishedEvent), evt)
+}
+
+// ProduceReadEvent mocks base method.
+func (m *MockProducer) ProduceReadEvent(evt article.ReadEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceReadEvent", evt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ProduceReadEvent indicates an expected call of ProduceReadEvent.
+func (mr *MockProducerMockRecorder) ProduceReadEvent(evt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceReadEvent", reflect.TypeOf((*MockProducer)(nil).ProduceReadEvent), evt)
+}
+
+// ProduceUnlikedEvent mocks base method.
+func (m *MockProducer) ProduceUnlikedEvent(evt article.UnlikedEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceUnlikedEvent", evt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ProduceUnlikedEvent indicates an expected call of ProduceUnlikedEvent.
+func (mr *MockProducerMockRecorder) ProduceUnlikedEvent(evt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceUnlikedEvent", reflect.TypeOf((*MockProducer)(nil).ProduceUnlikedEvent), evt)
+}
+
+// ProduceWithdrawnEvent mocks base method.
+func (m *MockProducer) ProduceWithdrawnEvent(evt article.WithdrawnEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProduceWithdrawnEvent", evt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ProduceWithdrawnEvent indicates an expected call of ProduceWithdrawnEvent.
+func (mr *MockProducerMockRecorder) ProduceWithdrawnEvent(evt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProduceWithdrawnEvent", reflect.TypeOf((*MockProducer)(nil).ProduceWithdrawnEvent), evt)
+}
@@ -1,17 +1,42 @@
 package article
 
 import (
-	"encoding/json"
 	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+	"webok/pkg/samarax"
 )
 
-const TopicReadEvent = "article_read"
+const (
+	TopicReadEvent      = "article_read"
+	TopicPublishedEvent = "article_published"
+	TopicWithdrawnEvent = "article_withdrawn"
+	TopicLikedEvent     = "article_liked"
+	TopicUnlikedEvent   = "article_unliked"
+	TopicCollectedEvent = "article_collected"
+)
+
+const (
+	EventTypeRead      = "article.read"
+	EventTypePublished = "article.published"
+	EventTypeWithdrawn = "article.withdrawn"
+	EventTypeLiked     = "article.liked"
+	EventTypeUnliked   = "article.unliked"
+	EventTypeCollected = "article.collected"
+)
 
+//go:generate mockgen -source=producer.go -package=evtmocks -destination=./mock/producer.mock.go
 type Producer interface {
 	ProduceReadEvent(evt ReadEvent) error
+	ProducePublishedEvent(evt PublishedEvent) error
+	ProduceWithdrawnEvent(evt WithdrawnEvent) error
+	ProduceLikedEvent(evt LikedEvent) error
+	ProduceUnlikedEvent(evt UnlikedEvent) error
+	ProduceCollectedEvent(evt CollectedEvent) error
 }
 
 type ReadEvent struct {
+	// Id 事件 ID，由生产者生成，消费者用来去重
+	Id  string
 	Aid int64
 	Uid int64
 }
@@ -21,22 +46,102 @@ type BatchReadEvent struct {
 	Uids []int64
 }
 
+// PublishedEvent 文章发表，重新发表也是这个事件
+type PublishedEvent struct {
+	Aid      int64
+	AuthorId int64
+	Title    string
+}
+
+// WithdrawnEvent 文章撤回，变成仅自己可见
+type WithdrawnEvent struct {
+	Aid      int64
+	AuthorId int64
+}
+
+type LikedEvent struct {
+	Aid int64
+	Uid int64
+}
+
+type UnlikedEvent struct {
+	Aid int64
+	Uid int64
+}
+
+type CollectedEvent struct {
+	Aid int64
+	// 收藏夹 ID
+	Cid int64
+	Uid int64
+}
+
+// RegisterEvents 登记文章相关的事件，修改事件结构的时候记得升级版本并且注册升级函数
+func RegisterEvents(r *samarax.Registry) {
+	samarax.Register[ReadEvent](r, EventTypeRead, 1)
+	samarax.Register[PublishedEvent](r, EventTypePublished, 1)
+	samarax.Register[WithdrawnEvent](r, EventTypeWithdrawn, 1)
+	samarax.Register[LikedEvent](r, EventTypeLiked, 1)
+	samarax.Register[UnlikedEvent](r, EventTypeUnliked, 1)
+	samarax.Register[CollectedEvent](r, EventTypeCollected, 1)
+	// 引入 Envelope 之前发出去的阅读事件
+	r.RegisterLegacy(TopicReadEvent, EventTypeRead)
+}
+
 type SaramaSyncProducer struct {
 	producer sarama.SyncProducer
+	registry *samarax.Registry
 }
 
-func NewSaramaSyncProducer(producer sarama.SyncProducer) Producer {
-	return &SaramaSyncProducer{producer: producer}
+func NewSaramaSyncProducer(producer sarama.SyncProducer, registry *samarax.Registry) Producer {
+	return &SaramaSyncProducer{producer: producer, registry: registry}
 }
 
 func (s *SaramaSyncProducer) ProduceReadEvent(evt ReadEvent) error {
-	val, err := json.Marshal(evt)
+	if evt.Id == "" {
+		evt.Id = uuid.New().String()
+	}
+	env, err := s.registry.Wrap(evt)
+	if err != nil {
+		return err
+	}
+	env.Id = evt.Id
+	return s.send(TopicReadEvent, env)
+}
+
+func (s *SaramaSyncProducer) ProducePublishedEvent(evt PublishedEvent) error {
+	return s.produce(TopicPublishedEvent, evt)
+}
+
+func (s *SaramaSyncProducer) ProduceWithdrawnEvent(evt WithdrawnEvent) error {
+	return s.produce(TopicWithdrawnEvent, evt)
+}
+
+func (s *SaramaSyncProducer) ProduceLikedEvent(evt LikedEvent) error {
+	return s.produce(TopicLikedEvent, evt)
+}
+
+func (s *SaramaSyncProducer) ProduceUnlikedEvent(evt UnlikedEvent) error {
+	return s.produce(TopicUnlikedEvent, evt)
+}
+
+func (s *SaramaSyncProducer) ProduceCollectedEvent(evt CollectedEvent) error {
+	return s.produce(TopicCollectedEvent, evt)
+}
+
+func (s *SaramaSyncProducer) produce(topic string, evt any) error {
+	env, err := s.registry.Wrap(evt)
+	if err != nil {
+		return err
+	}
+	return s.send(topic, env)
+}
+
+func (s *SaramaSyncProducer) send(topic string, env samarax.Envelope) error {
+	msg, err := samarax.NewEnvelopeMessage(topic, env)
 	if err != nil {
 		return err
 	}
-	_, _, err = s.producer.SendMessage(&sarama.ProducerMessage{
-		Topic: TopicReadEvent,
-		Value: sarama.StringEncoder(val),
-	})
+	_, _, err = s.producer.SendMessage(msg)
 	return err
 }
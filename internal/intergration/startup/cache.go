@@ -0,0 +1,11 @@
+package startup
+
+import (
+	"time"
+	"webok/pkg/cachex"
+)
+
+// InitInvalidator 测试只有一个实例，不需要广播
+func InitInvalidator() cachex.Invalidator {
+	return cachex.NewLocalInvalidator(time.Minute)
+}
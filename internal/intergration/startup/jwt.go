@@ -0,0 +1,24 @@
+package startup
+
+import (
+	"webok/pkg/cryptox"
+	"webok/pkg/jwtx"
+)
+
+// InitJWTKeySet 集成测试不读配置，每次随机生成密钥
+func InitJWTKeySet() *jwtx.KeySet {
+	ks, err := jwtx.NewEphemeralKeySet("test")
+	if err != nil {
+		panic(err)
+	}
+	return ks
+}
+
+// InitTwoFactorCipher 两步验证的密钥也是每次随机生成
+func InitTwoFactorCipher() *cryptox.Cipher {
+	c, err := cryptox.NewEphemeralCipher()
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
@@ -2,20 +2,28 @@ package startup
 
 import (
 	"github.com/IBM/sarama"
+	"webok/pkg/samarax"
+	"webok/pkg/samarax/membus"
 )
 
-func InitSaramaClient() sarama.Client {
+// InitBroker 测试默认使用进程内的消息总线，不需要启动 Kafka
+func InitBroker() samarax.Broker {
+	return membus.NewBus()
+}
+
+// InitKafkaBroker 需要连真的 Kafka 的时候，在 wire 里面替换掉 InitBroker
+func InitKafkaBroker() samarax.Broker {
 	scfg := sarama.NewConfig()
 	scfg.Producer.Return.Successes = true
 	client, err := sarama.NewClient([]string{"localhost:9094"}, scfg)
 	if err != nil {
 		panic(err)
 	}
-	return client
+	return samarax.NewSaramaBroker(client)
 }
 
-func InitSyncProducer(c sarama.Client) sarama.SyncProducer {
-	p, err := sarama.NewSyncProducerFromClient(c)
+func InitSyncProducer(b samarax.Broker) sarama.SyncProducer {
+	p, err := b.SyncProducer()
 	if err != nil {
 		panic(err)
 	}
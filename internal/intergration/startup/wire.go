@@ -13,22 +13,32 @@ import (
 	"webok/internal/web"
 	ijwt "webok/internal/web/jwt"
 	"webok/ioc"
+	"webok/pkg/samarax"
 )
 
 var thirdPartySet = wire.NewSet(
-	InitDB, InitRedis, InitLogger,
-	InitSaramaClient,
+	InitDB, InitRedis, InitLogger, InitInvalidator,
+	ioc.InitRedisHealth,
+)
+
+var eventSet = wire.NewSet(
+	ioc.InitEventRegistry,
+	InitBroker,
 	InitSyncProducer,
+	article.NewSaramaSyncProducer,
 )
 var userSvcProvider = wire.NewSet(
 	dao.NewGormUserDAO,
 	cache.NewUserCache,
 	repository.NewCachedUserRepository,
+	repository.NewUserProfileCache,
 	service.NewNormalUserService)
 
 var articleSvcProvider = wire.NewSet(
 	repository.NewCachedArticleRepository,
+	repository.NewPubArticleCache,
 	cache.NewArticleRedisCache,
+	cache.NewArticleBloomFilters,
 	dao.NewArticleGORMDAO,
 	service.NewArticleService)
 
@@ -36,6 +46,7 @@ var interactiveSvcSet = wire.NewSet(
 	dao.NewInteractiveGORMDAO,
 	cache.NewRedisInteractiveCache,
 	repository.NewCachedInteractiveRepository,
+	repository.NewInteractiveLocalCache,
 	service.NewInteractiveService,
 )
 
@@ -43,23 +54,51 @@ func InitWebServer() *gin.Engine {
 	wire.Build(
 		//第三方依赖
 		thirdPartySet,
+		eventSet,
 		userSvcProvider,
 		articleSvcProvider,
 		interactiveSvcSet,
 		// CACHE
 		cache.NewCodeRedisCache,
+		cache.NewCodeQuotaRedisCache,
+		cache.NewCaptchaRedisCache,
+		cache.NewLoginAttemptRedisCache,
+		// DAO
+		dao.NewGORMCodeAuditDAO,
+		InitTwoFactorCipher, dao.NewGORMTwoFactorDAO,
+		dao.NewGORMLoginAuditDAO,
+		dao.NewGORMSecurityEventDAO,
 		// REPO
 		repository.NewCodeRepository,
-		article.NewSaramaSyncProducer,
+		repository.NewCodeQuotaRepository,
+		repository.NewCaptchaRepository,
+		repository.NewTwoFactorRepository,
+		repository.NewLoginAttemptRepository,
+		repository.NewSecurityEventRepository,
 		// Service
-		ioc.InitSMSService,
+		ioc.InitSMSService, ioc.InitEmailService, ioc.InitChannels,
+		ioc.InitCodeQuotaConfig,
+		ioc.InitCodePolicyConfig,
 		service.NewCodeService,
-		ioc.InitWechatService,
+		ioc.InitCaptchaConfig,
+		service.NewCaptchaService,
+		ioc.InitTwoFactorConfig,
+		service.NewTwoFactorService,
+		ioc.InitLoginPolicy,
+		ioc.InitLoginChallenge,
+		service.NewLoginGuardService,
+		ioc.InitSecurityEventConfig,
+		service.NewSecurityEventService,
+		ioc.InitWechatProvider,
+		ioc.InitOAuth2Registry,
 		// Handler
 		web.NewUserHandler,
 		web.NewOAuth2WechatHandler,
-		ijwt.NewRedisHandler,
+		web.NewOAuth2Handler,
+		ioc.InitJWTConfig, InitJWTKeySet, ijwt.NewRedisHandler,
 		web.NewArticleHandler,
+		web.NewCaptchaHandler, web.NewJWKSHandler, web.NewTwoFactorHandler,
+		ioc.InitAdminMiddleware, web.NewSecurityEventHandler,
 		ioc.InitGinMiddlewares,
 		ioc.InitWebServer,
 	)
@@ -69,17 +108,29 @@ func InitWebServer() *gin.Engine {
 func InitArticleHandler(dao dao.ArticleDAO) *web.ArticleHandler {
 	wire.Build(
 		thirdPartySet,
+		eventSet,
 		userSvcProvider,
 		interactiveSvcSet,
 		repository.NewCachedArticleRepository,
+		repository.NewPubArticleCache,
 		cache.NewArticleRedisCache,
+		cache.NewArticleBloomFilters,
 		service.NewArticleService,
-		article.NewSaramaSyncProducer,
 		web.NewArticleHandler)
 	return &web.ArticleHandler{}
 }
 
+// InitInteractiveReadEventConsumer 和 InitArticleHandler 共用同一个 broker，
+// 才能在测试里面消费到文章服务发出来的事件
+func InitInteractiveReadEventConsumer(broker samarax.Broker) *article.InteractiveReadEventConsumer {
+	wire.Build(thirdPartySet, interactiveSvcSet,
+		ioc.InitIdempotencyStore,
+		ioc.InitEventRegistry,
+		article.NewInteractiveReadEventConsumer)
+	return &article.InteractiveReadEventConsumer{}
+}
+
 func InitInteractiveService() service.InteractiveService {
-	wire.Build(thirdPartySet, interactiveSvcSet)
-	return service.NewInteractiveService(nil, nil)
+	wire.Build(thirdPartySet, eventSet, interactiveSvcSet)
+	return service.NewInteractiveService(nil, nil, nil)
 }
@@ -9,6 +9,7 @@ package startup
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/wire"
+	"webok/internal/events/article"
 	"webok/internal/repository"
 	"webok/internal/repository/cache"
 	"webok/internal/repository/dao"
@@ -16,37 +17,83 @@ import (
 	"webok/internal/web"
 	"webok/internal/web/jwt"
 	"webok/ioc"
+	"webok/pkg/samarax"
 )
 
 // Injectors from wire.go:
 
 func InitWebServer() *gin.Engine {
 	cmdable := InitRedis()
-	handler := jwt.NewRedisHandler(cmdable)
 	logger := InitLogger()
-	v := ioc.InitGinMiddlewares(cmdable, handler, logger)
+	healthMonitor := ioc.InitRedisHealth(cmdable, logger)
+	keySet := InitJWTKeySet()
+	config := ioc.InitJWTConfig()
+	handler := jwt.NewRedisHandler(cmdable, healthMonitor, keySet, config, logger)
+	v := ioc.InitGinMiddlewares(cmdable, healthMonitor, handler, logger)
 	db := InitDB()
 	userDAO := dao.NewGormUserDAO(db)
 	userCache := cache.NewUserCache(cmdable)
-	userRepository := repository.NewCachedUserRepository(userDAO, userCache)
-	userService := service.NewNormalUserService(userRepository)
-	codeCache := cache.NewCodeRedisCache(cmdable)
-	codeRepository := repository.NewCodeRepository(codeCache)
-	smsService := ioc.InitSMSService()
-	codeService := service.NewCodeService(codeRepository, smsService)
-	userHandler := web.NewUserHandler(userService, codeService, handler, logger)
-	wechatService := ioc.InitWechatService()
-	oAuth2WechatHandler := web.NewOAuth2WechatHandler(wechatService, userService, handler, logger)
+	invalidator := InitInvalidator()
+	twoLevel := repository.NewUserProfileCache(invalidator, logger)
+	userRepository := repository.NewCachedUserRepository(userDAO, userCache, twoLevel, healthMonitor)
 	articleDAO := dao.NewArticleGORMDAO(db)
 	articleCache := cache.NewArticleRedisCache(cmdable)
-	articleRepository := repository.NewCachedArticleRepository(articleDAO, db, articleCache, userRepository)
-	articleService := service.NewArticleService(articleRepository)
+	cachexTwoLevel := repository.NewPubArticleCache(cmdable, healthMonitor, invalidator, logger)
+	articleBloomFilters := cache.NewArticleBloomFilters(cmdable)
+	articleRepository := repository.NewCachedArticleRepository(articleDAO, db, articleCache, userRepository, cachexTwoLevel, articleBloomFilters)
 	interactiveDao := dao.NewInteractiveGORMDAO(db)
 	interactiveCache := cache.NewRedisInteractiveCache(cmdable)
-	interactiveRepository := repository.NewCachedInteractiveRepository(interactiveDao, interactiveCache, logger)
-	interactiveService := service.NewInteractiveService(interactiveRepository, logger)
+	twoLevel2 := repository.NewInteractiveLocalCache(invalidator, logger)
+	interactiveRepository := repository.NewCachedInteractiveRepository(interactiveDao, interactiveCache, twoLevel2, logger)
+	userService := service.NewNormalUserService(userRepository, articleRepository, interactiveRepository, logger)
+	codeCache := cache.NewCodeRedisCache(cmdable)
+	codeRepository := repository.NewCodeRepository(codeCache)
+	codeQuotaCache := cache.NewCodeQuotaRedisCache(cmdable)
+	codeAuditDAO := dao.NewGORMCodeAuditDAO(db)
+	codeQuotaRepository := repository.NewCodeQuotaRepository(codeQuotaCache, codeAuditDAO)
+	smsService := ioc.InitSMSService()
+	emailService := ioc.InitEmailService()
+	v2 := ioc.InitChannels(smsService, emailService)
+	codeQuotaConfig := ioc.InitCodeQuotaConfig()
+	codePolicyConfig := ioc.InitCodePolicyConfig()
+	codeService := service.NewCodeService(codeRepository, codeQuotaRepository, v2, codeQuotaConfig, codePolicyConfig, logger)
+	captchaCache := cache.NewCaptchaRedisCache(cmdable)
+	captchaRepository := repository.NewCaptchaRepository(captchaCache)
+	captchaConfig := ioc.InitCaptchaConfig()
+	captchaService := service.NewCaptchaService(captchaRepository, captchaConfig, logger)
+	cipher := InitTwoFactorCipher()
+	twoFactorDAO := dao.NewGORMTwoFactorDAO(db, cipher)
+	twoFactorRepository := repository.NewTwoFactorRepository(twoFactorDAO)
+	twoFactorConfig := ioc.InitTwoFactorConfig()
+	twoFactorService := service.NewTwoFactorService(twoFactorRepository, twoFactorConfig)
+	loginAttemptCache := cache.NewLoginAttemptRedisCache(cmdable)
+	loginAuditDAO := dao.NewGORMLoginAuditDAO(db)
+	loginAttemptRepository := repository.NewLoginAttemptRepository(loginAttemptCache, loginAuditDAO)
+	loginChallenge := ioc.InitLoginChallenge(captchaService)
+	loginPolicy := ioc.InitLoginPolicy()
+	loginGuardService := service.NewLoginGuardService(loginAttemptRepository, loginChallenge, loginPolicy, logger)
+	securityEventDAO := dao.NewGORMSecurityEventDAO(db)
+	securityEventRepository := repository.NewSecurityEventRepository(securityEventDAO)
+	securityEventConfig := ioc.InitSecurityEventConfig()
+	securityEventService := service.NewSecurityEventService(securityEventRepository, userRepository, securityEventConfig, logger)
+	userHandler := web.NewUserHandler(userService, codeService, captchaService, twoFactorService, loginGuardService, securityEventService, handler, logger)
+	provider := ioc.InitWechatProvider()
+	oAuth2WechatHandler := web.NewOAuth2WechatHandler(provider, userService, twoFactorService, loginGuardService, handler, keySet, securityEventService, logger)
+	broker := InitBroker()
+	syncProducer := InitSyncProducer(broker)
+	registry := ioc.InitEventRegistry()
+	producer := article.NewSaramaSyncProducer(syncProducer, registry)
+	articleService := service.NewArticleService(articleRepository, producer, logger)
+	interactiveService := service.NewInteractiveService(interactiveRepository, producer, logger)
 	articleHandler := web.NewArticleHandler(articleService, logger, interactiveService)
-	engine := ioc.InitWebServer(v, userHandler, oAuth2WechatHandler, articleHandler)
+	captchaHandler := web.NewCaptchaHandler(captchaService, logger)
+	jwksHandler := web.NewJWKSHandler(keySet)
+	twoFactorHandler := web.NewTwoFactorHandler(twoFactorService, userService, loginGuardService, securityEventService, handler, logger)
+	adminMiddlewareBuilder := ioc.InitAdminMiddleware()
+	securityEventHandler := web.NewSecurityEventHandler(securityEventService, adminMiddlewareBuilder)
+	outh2Registry := ioc.InitOAuth2Registry(provider, logger)
+	oAuth2Handler := web.NewOAuth2Handler(outh2Registry, userService, twoFactorService, loginGuardService, handler, keySet, securityEventService, logger)
+	engine := ioc.InitWebServer(v, userHandler, oAuth2WechatHandler, articleHandler, captchaHandler, jwksHandler, twoFactorHandler, securityEventHandler, oAuth2Handler)
 	return engine
 }
 
@@ -56,37 +103,74 @@ func InitArticleHandler(dao2 dao.ArticleDAO) *web.ArticleHandler {
 	articleCache := cache.NewArticleRedisCache(cmdable)
 	userDAO := dao.NewGormUserDAO(db)
 	userCache := cache.NewUserCache(cmdable)
-	userRepository := repository.NewCachedUserRepository(userDAO, userCache)
-	articleRepository := repository.NewCachedArticleRepository(dao2, db, articleCache, userRepository)
-	articleService := service.NewArticleService(articleRepository)
+	invalidator := InitInvalidator()
 	logger := InitLogger()
+	twoLevel := repository.NewUserProfileCache(invalidator, logger)
+	healthMonitor := ioc.InitRedisHealth(cmdable, logger)
+	userRepository := repository.NewCachedUserRepository(userDAO, userCache, twoLevel, healthMonitor)
+	cachexTwoLevel := repository.NewPubArticleCache(cmdable, healthMonitor, invalidator, logger)
+	articleBloomFilters := cache.NewArticleBloomFilters(cmdable)
+	articleRepository := repository.NewCachedArticleRepository(dao2, db, articleCache, userRepository, cachexTwoLevel, articleBloomFilters)
+	broker := InitBroker()
+	syncProducer := InitSyncProducer(broker)
+	registry := ioc.InitEventRegistry()
+	producer := article.NewSaramaSyncProducer(syncProducer, registry)
+	articleService := service.NewArticleService(articleRepository, producer, logger)
 	interactiveDao := dao.NewInteractiveGORMDAO(db)
 	interactiveCache := cache.NewRedisInteractiveCache(cmdable)
-	interactiveRepository := repository.NewCachedInteractiveRepository(interactiveDao, interactiveCache, logger)
-	interactiveService := service.NewInteractiveService(interactiveRepository, logger)
+	twoLevel2 := repository.NewInteractiveLocalCache(invalidator, logger)
+	interactiveRepository := repository.NewCachedInteractiveRepository(interactiveDao, interactiveCache, twoLevel2, logger)
+	interactiveService := service.NewInteractiveService(interactiveRepository, producer, logger)
 	articleHandler := web.NewArticleHandler(articleService, logger, interactiveService)
 	return articleHandler
 }
 
+// InitInteractiveReadEventConsumer 和 InitArticleHandler 共用同一个 broker，
+// 才能在测试里面消费到文章服务发出来的事件
+func InitInteractiveReadEventConsumer(broker samarax.Broker) *article.InteractiveReadEventConsumer {
+	db := InitDB()
+	interactiveDao := dao.NewInteractiveGORMDAO(db)
+	cmdable := InitRedis()
+	interactiveCache := cache.NewRedisInteractiveCache(cmdable)
+	invalidator := InitInvalidator()
+	logger := InitLogger()
+	twoLevel := repository.NewInteractiveLocalCache(invalidator, logger)
+	interactiveRepository := repository.NewCachedInteractiveRepository(interactiveDao, interactiveCache, twoLevel, logger)
+	registry := ioc.InitEventRegistry()
+	idempotencyStore := ioc.InitIdempotencyStore(cmdable)
+	interactiveReadEventConsumer := article.NewInteractiveReadEventConsumer(interactiveRepository, broker, registry, idempotencyStore, logger)
+	return interactiveReadEventConsumer
+}
+
 func InitInteractiveService() service.InteractiveService {
 	db := InitDB()
 	interactiveDao := dao.NewInteractiveGORMDAO(db)
 	cmdable := InitRedis()
 	interactiveCache := cache.NewRedisInteractiveCache(cmdable)
+	invalidator := InitInvalidator()
 	logger := InitLogger()
-	interactiveRepository := repository.NewCachedInteractiveRepository(interactiveDao, interactiveCache, logger)
-	interactiveService := service.NewInteractiveService(interactiveRepository, logger)
+	twoLevel := repository.NewInteractiveLocalCache(invalidator, logger)
+	interactiveRepository := repository.NewCachedInteractiveRepository(interactiveDao, interactiveCache, twoLevel, logger)
+	broker := InitBroker()
+	syncProducer := InitSyncProducer(broker)
+	registry := ioc.InitEventRegistry()
+	producer := article.NewSaramaSyncProducer(syncProducer, registry)
+	interactiveService := service.NewInteractiveService(interactiveRepository, producer, logger)
 	return interactiveService
 }
 
 // wire.go:
 
 var thirdPartySet = wire.NewSet(
-	InitDB, InitRedis, InitLogger,
+	InitDB, InitRedis, InitLogger, InitInvalidator, ioc.InitRedisHealth,
+)
+
+var eventSet = wire.NewSet(ioc.InitEventRegistry, InitBroker,
+	InitSyncProducer, article.NewSaramaSyncProducer,
 )
 
-var userSvcProvider = wire.NewSet(dao.NewGormUserDAO, cache.NewUserCache, repository.NewCachedUserRepository, service.NewNormalUserService)
+var userSvcProvider = wire.NewSet(dao.NewGormUserDAO, cache.NewUserCache, repository.NewCachedUserRepository, repository.NewUserProfileCache, service.NewNormalUserService)
 
-var articleSvcProvider = wire.NewSet(repository.NewCachedArticleRepository, cache.NewArticleRedisCache, dao.NewArticleGORMDAO, service.NewArticleService)
+var articleSvcProvider = wire.NewSet(repository.NewCachedArticleRepository, repository.NewPubArticleCache, cache.NewArticleRedisCache, cache.NewArticleBloomFilters, dao.NewArticleGORMDAO, service.NewArticleService)
 
-var interactiveSvcSet = wire.NewSet(dao.NewInteractiveGORMDAO, cache.NewRedisInteractiveCache, repository.NewCachedInteractiveRepository, service.NewInteractiveService)
+var interactiveSvcSet = wire.NewSet(dao.NewInteractiveGORMDAO, cache.NewRedisInteractiveCache, repository.NewCachedInteractiveRepository, repository.NewInteractiveLocalCache, service.NewInteractiveService)
@@ -3,12 +3,17 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 	"webok/internal/domain"
 	"webok/internal/repository/cache"
 	"webok/internal/repository/dao"
+	"webok/pkg/cachex"
 	"webok/pkg/logger"
 )
 
+const interactivePrefix = "interactive:"
+
 //go:generate mockgen -source=Interactive.go -package=repomocks -destination=./mock/Interactive.mock.go
 type InteractiveRepository interface {
 	IncrReadCnt(ctx context.Context, biz string, id int64) error
@@ -20,14 +25,27 @@ type InteractiveRepository interface {
 	Get(ctx context.Context, biz string, id int64) (domain.Interactive, error)
 	Liked(ctx context.Context, biz string, id int64, uid int64) (bool, error)
 	Collected(ctx context.Context, biz string, id int64, uid int64) (bool, error)
+	// Invalidate 计数在别的地方被改了，比如合并账号的时候去掉了重复的点赞，删除两级缓存
+	Invalidate(ctx context.Context, refs ...domain.BizRef) error
 }
 
 type CachedInteractiveRepository struct {
 	dao   dao.InteractiveDao
 	cache cache.InteractiveCache
+	local *cachex.TwoLevel[domain.Interactive]
 	l     logger.Logger
 }
 
+// NewInteractiveLocalCache 计数的本地缓存，只是为了挡住热门文章的并发查询。
+// 计数修改之后会删除本地缓存，过期时间短一点，兜底失效通知丢失的情况
+func NewInteractiveLocalCache(inv cachex.Invalidator, l logger.Logger) *cachex.TwoLevel[domain.Interactive] {
+	return cachex.NewTwoLevel[domain.Interactive](nil, nil, inv, l, interactivePrefix, cachex.TwoLevelConfig{
+		Capacity: 10000,
+		LocalTTL: 3 * time.Second,
+		Jitter:   0.5,
+	})
+}
+
 func (c *CachedInteractiveRepository) Liked(ctx context.Context, biz string, id int64, uid int64) (bool, error) {
 	_, err := c.dao.GetLikedInfo(ctx, biz, id, uid)
 	switch {
@@ -53,6 +71,42 @@ func (c *CachedInteractiveRepository) Collected(ctx context.Context, biz string,
 }
 
 func (c *CachedInteractiveRepository) Get(ctx context.Context, biz string, id int64) (domain.Interactive, error) {
+	return c.local.Get(ctx, c.localKey(biz, id), func(ctx context.Context) (domain.Interactive, error) {
+		return c.get(ctx, biz, id)
+	})
+}
+
+func (c *CachedInteractiveRepository) Invalidate(ctx context.Context, refs ...domain.BizRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	err := c.cache.Del(ctx, refs...)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		keys = append(keys, c.localKey(ref.Biz, ref.BizId))
+	}
+	return c.local.Del(ctx, keys...)
+}
+
+func (c *CachedInteractiveRepository) localKey(biz string, id int64) string {
+	return fmt.Sprintf("%s%s:%d", interactivePrefix, biz, id)
+}
+
+// evictLocal 计数变了，删除本地缓存，不然几秒之内读到的还是旧的计数。
+// 数据库已经改成功了，删除失败只记录日志
+func (c *CachedInteractiveRepository) evictLocal(ctx context.Context, biz string, id int64) {
+	if err := c.local.Del(ctx, c.localKey(biz, id)); err != nil {
+		c.l.Warn("删除计数的本地缓存失败",
+			logger.Int64("id", id),
+			logger.String("biz", biz),
+			logger.Error(err))
+	}
+}
+
+func (c *CachedInteractiveRepository) get(ctx context.Context, biz string, id int64) (domain.Interactive, error) {
 	intr, err := c.cache.Get(ctx, biz, id)
 
 	if err == nil {
@@ -79,7 +133,9 @@ func (c *CachedInteractiveRepository) AddCollectionItem(ctx context.Context, biz
 	if err != nil {
 		return err
 	}
-	return c.cache.IncrCollectionCntIfPresent(ctx, biz, id)
+	err = c.cache.IncrCollectionCntIfPresent(ctx, biz, id)
+	c.evictLocal(ctx, biz, id)
+	return err
 }
 
 func (c *CachedInteractiveRepository) IncrLickCnt(ctx context.Context, biz string, id int64, uid int64) error {
@@ -87,7 +143,9 @@ func (c *CachedInteractiveRepository) IncrLickCnt(ctx context.Context, biz strin
 	if err != nil {
 		return err
 	}
-	return c.cache.IncrLikeCntIfPresent(ctx, biz, id)
+	err = c.cache.IncrLikeCntIfPresent(ctx, biz, id)
+	c.evictLocal(ctx, biz, id)
+	return err
 }
 
 func (c *CachedInteractiveRepository) DecrLickCnt(ctx context.Context, biz string, id int64, uid int64) error {
@@ -95,7 +153,9 @@ func (c *CachedInteractiveRepository) DecrLickCnt(ctx context.Context, biz strin
 	if err != nil {
 		return err
 	}
-	return c.cache.DecrLikeCntIfPresent(ctx, biz, id)
+	err = c.cache.DecrLikeCntIfPresent(ctx, biz, id)
+	c.evictLocal(ctx, biz, id)
+	return err
 }
 
 func (c *CachedInteractiveRepository) IncrReadCnt(ctx context.Context, biz string, id int64) error {
@@ -103,7 +163,9 @@ func (c *CachedInteractiveRepository) IncrReadCnt(ctx context.Context, biz strin
 	if err != nil {
 		return err
 	}
-	return c.cache.IncrReadCntIfPresent(ctx, biz, id)
+	err = c.cache.IncrReadCntIfPresent(ctx, biz, id)
+	c.evictLocal(ctx, biz, id)
+	return err
 }
 
 func (c *CachedInteractiveRepository) BatchIncrReadCnt(ctx context.Context, biz []string, bizId []int64) error {
@@ -112,11 +174,16 @@ func (c *CachedInteractiveRepository) BatchIncrReadCnt(ctx context.Context, biz
 		return err
 	}
 	go func() {
+		keys := make([]string, 0, len(biz))
 		for i := 0; i < len(biz); i++ {
 			er := c.cache.IncrReadCntIfPresent(ctx, biz[i], bizId[i])
 			if er != nil {
 				// 记录日志
 			}
+			keys = append(keys, c.localKey(biz[i], bizId[i]))
+		}
+		if er := c.local.Del(ctx, keys...); er != nil {
+			c.l.Warn("删除计数的本地缓存失败", logger.Error(er))
 		}
 	}()
 	return nil
@@ -132,10 +199,12 @@ func (c *CachedInteractiveRepository) toDomain(ie dao.Interactive) domain.Intera
 	}
 }
 
-func NewCachedInteractiveRepository(dao dao.InteractiveDao, cache cache.InteractiveCache, log logger.Logger) InteractiveRepository {
+func NewCachedInteractiveRepository(dao dao.InteractiveDao, cache cache.InteractiveCache,
+	local *cachex.TwoLevel[domain.Interactive], log logger.Logger) InteractiveRepository {
 	return &CachedInteractiveRepository{
 		dao:   dao,
 		cache: cache,
+		local: local,
 		l:     log,
 	}
 }
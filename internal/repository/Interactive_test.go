@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webok/internal/domain"
+	cachemocks "webok/internal/repository/cache/mock"
+	daomocks "webok/internal/repository/dao/mock"
+	"webok/pkg/cachex"
+	"webok/pkg/logger"
+)
+
+func TestCachedInteractiveRepository_evictLocal(t *testing.T) {
+	testCases := []struct {
+		name  string
+		mock  func(d *daomocks.MockInteractiveDao, c *cachemocks.MockInteractiveCache)
+		write func(repo InteractiveRepository) error
+
+		wantErr error
+	}{
+		{
+			name: "点赞",
+			mock: func(d *daomocks.MockInteractiveDao, c *cachemocks.MockInteractiveCache) {
+				d.EXPECT().IncrLickCnt(gomock.Any(), "article", int64(1), int64(123)).Return(nil)
+				c.EXPECT().IncrLikeCntIfPresent(gomock.Any(), "article", int64(1)).Return(nil)
+			},
+			write: func(repo InteractiveRepository) error {
+				return repo.IncrLickCnt(context.Background(), "article", 1, 123)
+			},
+		},
+		{
+			name: "取消点赞",
+			mock: func(d *daomocks.MockInteractiveDao, c *cachemocks.MockInteractiveCache) {
+				d.EXPECT().DecrLickCnt(gomock.Any(), "article", int64(1), int64(123)).Return(nil)
+				c.EXPECT().DecrLikeCntIfPresent(gomock.Any(), "article", int64(1)).Return(nil)
+			},
+			write: func(repo InteractiveRepository) error {
+				return repo.DecrLickCnt(context.Background(), "article", 1, 123)
+			},
+		},
+		{
+			name: "收藏",
+			mock: func(d *daomocks.MockInteractiveDao, c *cachemocks.MockInteractiveCache) {
+				d.EXPECT().InsertCollectionBiz(gomock.Any(), "article", int64(1), int64(2), int64(123)).Return(nil)
+				c.EXPECT().IncrCollectionCntIfPresent(gomock.Any(), "article", int64(1)).Return(nil)
+			},
+			write: func(repo InteractiveRepository) error {
+				return repo.AddCollectionItem(context.Background(), "article", 1, 2, 123)
+			},
+		},
+		{
+			name: "阅读，Redis 失败也要删除本地缓存",
+			mock: func(d *daomocks.MockInteractiveDao, c *cachemocks.MockInteractiveCache) {
+				d.EXPECT().IncrReadCnt(gomock.Any(), "article", int64(1)).Return(nil)
+				c.EXPECT().IncrReadCntIfPresent(gomock.Any(), "article", int64(1)).Return(errors.New("redis error"))
+			},
+			write: func(repo InteractiveRepository) error {
+				return repo.IncrReadCnt(context.Background(), "article", 1)
+			},
+			wantErr: errors.New("redis error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			d := daomocks.NewMockInteractiveDao(ctrl)
+			c := cachemocks.NewMockInteractiveCache(ctrl)
+			// 写之前和写之后各读一次，第二次不能命中本地缓存
+			c.EXPECT().Get(gomock.Any(), "article", int64(1)).Return(domain.Interactive{LikeCnt: 1}, nil)
+			c.EXPECT().Get(gomock.Any(), "article", int64(1)).Return(domain.Interactive{LikeCnt: 2}, nil)
+			tc.mock(d, c)
+			local := NewInteractiveLocalCache(cachex.NewLocalInvalidator(time.Minute), logger.NewNopLogger())
+			repo := NewCachedInteractiveRepository(d, c, local, logger.NewNopLogger())
+
+			intr, err := repo.Get(context.Background(), "article", 1)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(1), intr.LikeCnt)
+			err = tc.write(repo)
+			assert.Equal(t, tc.wantErr, err)
+			intr, err = repo.Get(context.Background(), "article", 1)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), intr.LikeCnt)
+		})
+	}
+}
@@ -3,13 +3,34 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"strconv"
+	"sync/atomic"
 	"time"
 	"webok/internal/domain"
 	"webok/internal/repository/cache"
 	"webok/internal/repository/dao"
+	"webok/pkg/cachex"
+	"webok/pkg/logger"
+	"webok/pkg/redisx"
 )
 
+const pubArticlePrefix = "article:pub:"
+
+// NewPubArticleCache 线上库文章的两级缓存，热门文章过期的时候只有一个请求会回源，
+// Redis 熔断的时候只用本地缓存
+func NewPubArticleCache(cmd redis.Cmdable, health redisx.HealthMonitor, inv cachex.Invalidator,
+	l logger.Logger) *cachex.TwoLevel[domain.Article] {
+	return cachex.NewTwoLevel[domain.Article](cmd, health, inv, l, pubArticlePrefix, cachex.TwoLevelConfig{
+		Capacity:  10000,
+		LocalTTL:  time.Minute,
+		RemoteTTL: 10 * time.Minute,
+		Jitter:    0.2,
+	})
+}
+
 //go:generate mockgen -source=article.go -package=repomocks -destination=./mock/article.mock.go
 type ArticleRepository interface {
 	Create(ctx context.Context, art domain.Article) (int64, error)
@@ -19,8 +40,9 @@ type ArticleRepository interface {
 	SyncStatus(ctx context.Context, uid int64, articleId int64) error
 	GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]domain.Article, error)
 	GetById(ctx context.Context, id int64) (domain.Article, error)
-	preCache(ctx context.Context, arts []domain.Article)
 	GetPubById(ctx context.Context, id int64) (domain.Article, error)
+	// TransferAuthor 合并账号的时候把 source 的文章都转给 target
+	TransferAuthor(ctx context.Context, sourceId, targetId int64) error
 }
 
 type CachedArticleRepository struct {
@@ -35,34 +57,139 @@ type CachedArticleRepository struct {
 	db *gorm.DB
 
 	cache cache.ArticleCache
+	pub   *cachex.TwoLevel[domain.Article]
+
+	filters         cache.ArticleBloomFilters
+	rebuildingDraft atomic.Bool
+	rebuildingPub   atomic.Bool
 }
 
 func (c *CachedArticleRepository) GetPubById(ctx context.Context, id int64) (domain.Article, error) {
-	res, err := c.cache.GetPub(ctx, id)
-	if err == nil {
-		return res, err
-	}
-	art, err := c.dao.GetPubById(ctx, id)
+	return c.pub.Get(ctx, c.pubKey(id), func(ctx context.Context) (domain.Article, error) {
+		if !c.mightExist(ctx, c.filters.Pub, id, &c.rebuildingPub, c.dao.ListPubIds) {
+			return domain.Article{}, ErrRecordNotFound
+		}
+		missing, err := c.cache.PubMissing(ctx, id)
+		if err == nil && missing {
+			return domain.Article{}, ErrRecordNotFound
+		}
+		art, err := c.dao.GetPubById(ctx, id)
+		if err != nil {
+			if errors.Is(err, dao.ErrRecordNotFound) {
+				_ = c.cache.SetPubMissing(ctx, id)
+			}
+			return domain.Article{}, err
+		}
+		res := c.ToDoMain(dao.Article(art))
+		// 查询Author Name
+		user, err := c.userRepo.FindById(ctx, res.Author.Id)
+		if err != nil {
+			return domain.Article{}, err
+		}
+		res.Author.Name = user.Nickname
+		return res, nil
+	})
+}
+
+func (c *CachedArticleRepository) TransferAuthor(ctx context.Context, sourceId, targetId int64) error {
+	ids, err := c.dao.TransferAuthor(ctx, sourceId, targetId)
 	if err != nil {
-		return domain.Article{}, err
+		return err
 	}
-	res = c.ToDoMain(dao.Article(art))
-	// 查询Author Name
-	user, err := c.userRepo.FindById(ctx, res.Author.Id)
-	if err != nil {
-		// log
-		return res, err
+	// 作者变了，两个人的列表、文章本身和线上库的缓存都要删掉。
+	// 文章已经转移了，删除失败也不返回 error，重试的时候查不到这些文章
+	for _, uid := range []int64{sourceId, targetId} {
+		er := c.cache.DelFirstPage(ctx, uid)
+		if er != nil {
+			// 记录日志
+		}
 	}
-	res.Author.Name = user.Nickname
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-		defer cancel()
-		er := c.cache.SetPub(ctx, res)
+	if len(ids) == 0 {
+		return nil
+	}
+	er := c.cache.Del(ctx, ids...)
+	if er != nil {
+		// 记录日志
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, c.pubKey(id))
+	}
+	er = c.pub.Del(ctx, keys...)
+	if er != nil {
+		// 记录日志
+	}
+	return nil
+}
+
+func (c *CachedArticleRepository) pubKey(id int64) string {
+	return PubArticleKey(id)
+}
+
+// PubArticleKey 线上库文章在两级缓存里面的 key，消费者删缓存的时候也要用同一个 key
+func PubArticleKey(id int64) string {
+	return fmt.Sprintf("%s%d", pubArticlePrefix, id)
+}
+
+// mightExist 布隆过滤器判断文章是否可能存在，出错的时候当成存在。
+// 过滤器还没有构建的时候在后台构建
+func (c *CachedArticleRepository) mightExist(ctx context.Context, f cache.BloomFilter, id int64,
+	rebuilding *atomic.Bool, list func(ctx context.Context, fromId int64, limit int) ([]int64, error)) bool {
+	ok, err := f.MightContain(ctx, strconv.FormatInt(id, 10))
+	if errors.Is(err, cache.ErrBloomFilterNotReady) && rebuilding.CompareAndSwap(false, true) {
+		go func() {
+			defer rebuilding.Store(false)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute*10)
+			defer cancel()
+			er := c.rebuildFilter(ctx, f, list)
+			if er != nil {
+				// 记录日志
+			}
+		}()
+	}
+	return ok
+}
+
+// rebuildFilter 分批读出全部 ID 重建布隆过滤器
+func (c *CachedArticleRepository) rebuildFilter(ctx context.Context, f cache.BloomFilter,
+	list func(ctx context.Context, fromId int64, limit int) ([]int64, error)) error {
+	const batchSize = 1000
+	return f.Rebuild(ctx, func(ctx context.Context, add func(items ...string) error) error {
+		var fromId int64
+		for {
+			ids, err := list(ctx, fromId, batchSize)
+			if err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				return nil
+			}
+			err = add(cache.Int64Items(ids)...)
+			if err != nil {
+				return err
+			}
+			fromId = ids[len(ids)-1]
+		}
+	})
+}
+
+// markExist 新建或者发表了文章，加到布隆过滤器里面，并且删除不存在的标记
+func (c *CachedArticleRepository) markExist(ctx context.Context, id int64, pub bool) {
+	item := strconv.FormatInt(id, 10)
+	er := c.filters.Draft.Add(ctx, item)
+	if er != nil {
+		// 记录日志
+	}
+	if pub {
+		er = c.filters.Pub.Add(ctx, item)
 		if er != nil {
-			// log
+			// 记录日志
 		}
-	}()
-	return res, nil
+	}
+	er = c.cache.DelMissing(ctx, id)
+	if er != nil {
+		// 记录日志
+	}
 }
 
 func (c *CachedArticleRepository) GetById(ctx context.Context, id int64) (domain.Article, error) {
@@ -70,8 +197,18 @@ func (c *CachedArticleRepository) GetById(ctx context.Context, id int64) (domain
 	if err == nil {
 		return res, nil
 	}
+	if !c.mightExist(ctx, c.filters.Draft, id, &c.rebuildingDraft, c.dao.ListIds) {
+		return domain.Article{}, ErrRecordNotFound
+	}
+	missing, err := c.cache.Missing(ctx, id)
+	if err == nil && missing {
+		return domain.Article{}, ErrRecordNotFound
+	}
 	article, err := c.dao.GetById(ctx, id)
 	if err != nil {
+		if errors.Is(err, dao.ErrRecordNotFound) {
+			_ = c.cache.SetMissing(ctx, id)
+		}
 		return domain.Article{}, err
 	}
 	res = c.ToDoMain(article)
@@ -86,26 +223,32 @@ func (c *CachedArticleRepository) GetById(ctx context.Context, id int64) (domain
 
 func (c *CachedArticleRepository) Sync(ctx context.Context, art domain.Article) (int64, error) {
 	id, err := c.dao.Sync(ctx, c.ToEntity(art))
-	if err == nil {
-		er := c.cache.DelFirstPage(ctx, art.Author.Id)
-		if er != nil {
-			// 记录日志
-		}
+	if err != nil {
+		return id, err
+	}
+	c.markExist(ctx, id, true)
+	er := c.cache.DelFirstPage(ctx, art.Author.Id)
+	if er != nil {
+		// 记录日志
 	}
 	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
 		// 可以灵活设置过期时间
 		user, er := c.userRepo.FindById(ctx, art.Author.Id)
 		if er != nil {
-			//log
+			// 预热失败，删掉旧的缓存，等读的时候再加载
+			_ = c.pub.Del(ctx, c.pubKey(id))
+			return
 		}
+		art.Id = id
 		art.Author.Name = user.Nickname
-		art.Author.Id = user.Id
-		er = c.cache.SetPub(ctx, art)
+		er = c.pub.Set(ctx, c.pubKey(id), art)
 		if er != nil {
 			//log
 		}
 	}()
-	return id, err
+	return id, nil
 }
 
 // SyncV1 Repo不使用事务，保证数据一致性
@@ -170,15 +313,20 @@ func (c *CachedArticleRepository) SyncV2(ctx context.Context, art domain.Article
 	return entity.ID, nil
 }
 
-func NewCachedArticleRepository(d dao.ArticleDAO, db *gorm.DB, c cache.ArticleCache, ur UserRepository) ArticleRepository {
+func NewCachedArticleRepository(d dao.ArticleDAO, db *gorm.DB, c cache.ArticleCache, ur UserRepository,
+	pub *cachex.TwoLevel[domain.Article], filters cache.ArticleBloomFilters) ArticleRepository {
 	return &CachedArticleRepository{
 		dao:      d,
 		db:       db,
 		cache:    c,
 		userRepo: ur,
+		pub:      pub,
+		filters:  filters,
 	}
 }
 
+// NewCachedArticleRepositoryV1 只有制作库和线上库两个 DAO，只能调用 SyncV1，
+// Sync 和查询方法要用 NewCachedArticleRepository 创建
 func NewCachedArticleRepositoryV1(a dao.ArticleAuthorDAO, r dao.ArticleReaderDAO) ArticleRepository {
 	return &CachedArticleRepository{
 		authorDAO: a,
@@ -189,6 +337,7 @@ func NewCachedArticleRepositoryV1(a dao.ArticleAuthorDAO, r dao.ArticleReaderDAO
 func (c *CachedArticleRepository) Create(ctx context.Context, article domain.Article) (int64, error) {
 	id, err := c.dao.Insert(ctx, c.ToEntity(article))
 	if err == nil {
+		c.markExist(ctx, id, false)
 		er := c.cache.DelFirstPage(ctx, article.Author.Id)
 		if er != nil {
 			// 记录日志
@@ -239,6 +388,11 @@ func (c *CachedArticleRepository) SyncStatus(ctx context.Context, uid int64, art
 		if er != nil {
 			// 记录日志
 		}
+		// 撤回之后读者不能再看到
+		er = c.pub.Del(ctx, c.pubKey(articleId))
+		if er != nil {
+			// 记录日志
+		}
 	}
 	return err
 }
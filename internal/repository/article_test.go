@@ -2,15 +2,22 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"testing"
+	"time"
 	"webok/internal/domain"
+	"webok/internal/repository/cache"
+	cachemocks "webok/internal/repository/cache/mock"
 	"webok/internal/repository/dao"
 	daomocks "webok/internal/repository/dao/mock"
+	repomocks "webok/internal/repository/mock"
+	"webok/pkg/cachex"
+	"webok/pkg/logger"
 )
 
-func TestCachedArticleRepository_Sync(t *testing.T) {
+func TestCachedArticleRepository_SyncV1(t *testing.T) {
 	testCases := []struct {
 		name    string
 		mock    func(ctrl *gomock.Controller) (dao.ArticleAuthorDAO, dao.ArticleReaderDAO)
@@ -86,10 +93,346 @@ func TestCachedArticleRepository_Sync(t *testing.T) {
 			defer ctrl.Finish()
 			a, r := tc.mock(ctrl)
 			repo := NewCachedArticleRepositoryV1(a, r)
-			gotId, gotErr := repo.Sync(context.Background(), tc.art)
+			gotId, gotErr := repo.SyncV1(context.Background(), tc.art)
 			assert.Equal(t, tc.wantErr, gotErr)
 			assert.Equal(t, tc.wantId, gotId)
 
 		})
 	}
 }
+
+func TestCachedArticleRepository_Sync(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.ArticleBloomFilters, UserRepository)
+
+		wantId  int64
+		wantErr error
+		// 发表之后线上库缓存里面的文章，nil 表示缓存被删掉了
+		wantPub *domain.Article
+	}{
+		{
+			name: "发表成功，预热线上库缓存",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.ArticleBloomFilters, UserRepository) {
+				d := daomocks.NewMockArticleDAO(ctrl)
+				d.EXPECT().Sync(gomock.Any(), dao.Article{Title: "标题", AuthorId: 123}).Return(int64(1), nil)
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().DelMissing(gomock.Any(), int64(1)).Return(nil)
+				c.EXPECT().DelFirstPage(gomock.Any(), int64(123)).Return(nil)
+				draft := cachemocks.NewMockBloomFilter(ctrl)
+				draft.EXPECT().Add(gomock.Any(), "1").Return(nil)
+				pub := cachemocks.NewMockBloomFilter(ctrl)
+				pub.EXPECT().Add(gomock.Any(), "1").Return(nil)
+				ur := repomocks.NewMockUserRepository(ctrl)
+				ur.EXPECT().FindById(gomock.Any(), int64(123)).Return(&domain.User{Nickname: "作者"}, nil)
+				return d, c, cache.ArticleBloomFilters{Draft: draft, Pub: pub}, ur
+			},
+			wantId:  1,
+			wantPub: &domain.Article{Id: 1, Title: "标题", Author: domain.Author{Id: 123, Name: "作者"}},
+		},
+		{
+			name: "查询作者失败，删除旧的线上库缓存",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.ArticleBloomFilters, UserRepository) {
+				d := daomocks.NewMockArticleDAO(ctrl)
+				d.EXPECT().Sync(gomock.Any(), dao.Article{Title: "标题", AuthorId: 123}).Return(int64(1), nil)
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().DelMissing(gomock.Any(), int64(1)).Return(nil)
+				c.EXPECT().DelFirstPage(gomock.Any(), int64(123)).Return(errors.New("redis error"))
+				draft := cachemocks.NewMockBloomFilter(ctrl)
+				draft.EXPECT().Add(gomock.Any(), "1").Return(nil)
+				pub := cachemocks.NewMockBloomFilter(ctrl)
+				pub.EXPECT().Add(gomock.Any(), "1").Return(nil)
+				ur := repomocks.NewMockUserRepository(ctrl)
+				ur.EXPECT().FindById(gomock.Any(), int64(123)).Return(nil, errors.New("db error"))
+				return d, c, cache.ArticleBloomFilters{Draft: draft, Pub: pub}, ur
+			},
+			wantId: 1,
+		},
+		{
+			name: "数据库失败",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.ArticleBloomFilters, UserRepository) {
+				d := daomocks.NewMockArticleDAO(ctrl)
+				d.EXPECT().Sync(gomock.Any(), dao.Article{Title: "标题", AuthorId: 123}).Return(int64(0), errors.New("db error"))
+				return d, cachemocks.NewMockArticleCache(ctrl), cache.ArticleBloomFilters{}, repomocks.NewMockUserRepository(ctrl)
+			},
+			wantErr: errors.New("db error"),
+			wantPub: &domain.Article{Id: 1, Title: "旧标题"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			d, c, filters, ur := tc.mock(ctrl)
+			pub := NewPubArticleCache(nil, nil, cachex.NewLocalInvalidator(time.Minute), logger.NewNopLogger())
+			err := pub.Set(context.Background(), PubArticleKey(1), domain.Article{Id: 1, Title: "旧标题"})
+			assert.NoError(t, err)
+			repo := NewCachedArticleRepository(d, nil, c, ur, pub, filters)
+			id, err := repo.Sync(context.Background(), domain.Article{Title: "标题", Author: domain.Author{Id: 123}})
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantId, id)
+
+			// 预热在后台进行，加载函数返回错误，这样读缓存不会把结果写回去
+			notLoaded := errors.New("not loaded")
+			assert.Eventually(t, func() bool {
+				art, err := pub.Get(context.Background(), PubArticleKey(1), func(ctx context.Context) (domain.Article, error) {
+					return domain.Article{}, notLoaded
+				})
+				if tc.wantPub == nil {
+					return err == notLoaded
+				}
+				return err == nil && art == *tc.wantPub
+			}, time.Second, time.Millisecond*10)
+		})
+	}
+}
+
+func TestCachedArticleRepository_GetPubById(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter, UserRepository)
+
+		wantArt domain.Article
+		wantErr error
+	}{
+		{
+			name: "查询成功，带上作者名字",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter, UserRepository) {
+				f := cachemocks.NewMockBloomFilter(ctrl)
+				f.EXPECT().MightContain(gomock.Any(), "1").Return(true, nil)
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().PubMissing(gomock.Any(), int64(1)).Return(false, nil)
+				d := daomocks.NewMockArticleDAO(ctrl)
+				d.EXPECT().GetPubById(gomock.Any(), int64(1)).
+					Return(dao.PublishedArticle{ID: 1, Title: "标题", AuthorId: 123}, nil)
+				ur := repomocks.NewMockUserRepository(ctrl)
+				ur.EXPECT().FindById(gomock.Any(), int64(123)).Return(&domain.User{Nickname: "作者"}, nil)
+				return d, c, f, ur
+			},
+			wantArt: domain.Article{Id: 1, Title: "标题", Author: domain.Author{Id: 123, Name: "作者"}},
+		},
+		{
+			name: "布隆过滤器判断不存在",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter, UserRepository) {
+				f := cachemocks.NewMockBloomFilter(ctrl)
+				f.EXPECT().MightContain(gomock.Any(), "1").Return(false, nil)
+				return daomocks.NewMockArticleDAO(ctrl), cachemocks.NewMockArticleCache(ctrl), f,
+					repomocks.NewMockUserRepository(ctrl)
+			},
+			wantErr: ErrRecordNotFound,
+		},
+		{
+			name: "命中不存在的缓存",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter, UserRepository) {
+				f := cachemocks.NewMockBloomFilter(ctrl)
+				f.EXPECT().MightContain(gomock.Any(), "1").Return(true, nil)
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().PubMissing(gomock.Any(), int64(1)).Return(true, nil)
+				return daomocks.NewMockArticleDAO(ctrl), c, f, repomocks.NewMockUserRepository(ctrl)
+			},
+			wantErr: ErrRecordNotFound,
+		},
+		{
+			name: "数据库不存在，缓存不存在的结果",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter, UserRepository) {
+				f := cachemocks.NewMockBloomFilter(ctrl)
+				f.EXPECT().MightContain(gomock.Any(), "1").Return(true, nil)
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().PubMissing(gomock.Any(), int64(1)).Return(false, nil)
+				c.EXPECT().SetPubMissing(gomock.Any(), int64(1)).Return(nil)
+				d := daomocks.NewMockArticleDAO(ctrl)
+				d.EXPECT().GetPubById(gomock.Any(), int64(1)).Return(dao.PublishedArticle{}, dao.ErrRecordNotFound)
+				return d, c, f, repomocks.NewMockUserRepository(ctrl)
+			},
+			wantErr: dao.ErrRecordNotFound,
+		},
+		{
+			name: "查询作者失败",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter, UserRepository) {
+				f := cachemocks.NewMockBloomFilter(ctrl)
+				f.EXPECT().MightContain(gomock.Any(), "1").Return(true, nil)
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().PubMissing(gomock.Any(), int64(1)).Return(false, nil)
+				d := daomocks.NewMockArticleDAO(ctrl)
+				d.EXPECT().GetPubById(gomock.Any(), int64(1)).
+					Return(dao.PublishedArticle{ID: 1, Title: "标题", AuthorId: 123}, nil)
+				ur := repomocks.NewMockUserRepository(ctrl)
+				ur.EXPECT().FindById(gomock.Any(), int64(123)).Return(nil, errors.New("db error"))
+				return d, c, f, ur
+			},
+			wantErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			d, c, f, ur := tc.mock(ctrl)
+			pub := NewPubArticleCache(nil, nil, cachex.NewLocalInvalidator(time.Minute), logger.NewNopLogger())
+			repo := NewCachedArticleRepository(d, nil, c, ur, pub, cache.ArticleBloomFilters{Pub: f})
+			art, err := repo.GetPubById(context.Background(), 1)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantArt, art)
+			if err == nil {
+				// 第二次直接命中本地缓存，不会再查数据库
+				art, err = repo.GetPubById(context.Background(), 1)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.wantArt, art)
+			}
+		})
+	}
+}
+
+func TestCachedArticleRepository_GetById(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter)
+
+		wantArt domain.Article
+		wantErr error
+	}{
+		{
+			name: "缓存命中",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter) {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.Article{Id: 1, Title: "标题"}, nil)
+				return daomocks.NewMockArticleDAO(ctrl), c, cachemocks.NewMockBloomFilter(ctrl)
+			},
+			wantArt: domain.Article{Id: 1, Title: "标题"},
+		},
+		{
+			name: "布隆过滤器判断不存在",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter) {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.Article{}, cache.ErrKeyNotExist)
+				f := cachemocks.NewMockBloomFilter(ctrl)
+				f.EXPECT().MightContain(gomock.Any(), "1").Return(false, nil)
+				return daomocks.NewMockArticleDAO(ctrl), c, f
+			},
+			wantErr: ErrRecordNotFound,
+		},
+		{
+			name: "命中不存在的缓存",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter) {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.Article{}, cache.ErrKeyNotExist)
+				c.EXPECT().Missing(gomock.Any(), int64(1)).Return(true, nil)
+				f := cachemocks.NewMockBloomFilter(ctrl)
+				f.EXPECT().MightContain(gomock.Any(), "1").Return(true, nil)
+				return daomocks.NewMockArticleDAO(ctrl), c, f
+			},
+			wantErr: ErrRecordNotFound,
+		},
+		{
+			name: "数据库不存在，缓存不存在的结果",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter) {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.Article{}, cache.ErrKeyNotExist)
+				c.EXPECT().Missing(gomock.Any(), int64(1)).Return(false, nil)
+				c.EXPECT().SetMissing(gomock.Any(), int64(1)).Return(nil)
+				f := cachemocks.NewMockBloomFilter(ctrl)
+				f.EXPECT().MightContain(gomock.Any(), "1").Return(true, nil)
+				d := daomocks.NewMockArticleDAO(ctrl)
+				d.EXPECT().GetById(gomock.Any(), int64(1)).Return(dao.Article{}, dao.ErrRecordNotFound)
+				return d, c, f
+			},
+			wantErr: dao.ErrRecordNotFound,
+		},
+		{
+			name: "Redis 出错，查询数据库",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache, cache.BloomFilter) {
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().Get(gomock.Any(), int64(1)).Return(domain.Article{}, errors.New("mock redis error"))
+				c.EXPECT().Missing(gomock.Any(), int64(1)).Return(false, errors.New("mock redis error"))
+				c.EXPECT().Set(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+				f := cachemocks.NewMockBloomFilter(ctrl)
+				f.EXPECT().MightContain(gomock.Any(), "1").Return(true, errors.New("mock redis error"))
+				d := daomocks.NewMockArticleDAO(ctrl)
+				d.EXPECT().GetById(gomock.Any(), int64(1)).Return(dao.Article{ID: 1, Title: "标题"}, nil)
+				return d, c, f
+			},
+			wantArt: domain.Article{Id: 1, Title: "标题"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			d, c, f := tc.mock(ctrl)
+			repo := NewCachedArticleRepository(d, nil, c, nil, nil, cache.ArticleBloomFilters{Draft: f})
+			art, err := repo.GetById(context.Background(), 1)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantArt, art)
+		})
+	}
+}
+
+func TestCachedArticleRepository_TransferAuthor(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache)
+		wantErr error
+		// 转移之后线上库文章的本地缓存应该被删掉
+		wantEvicted bool
+	}{
+		{
+			name: "转移成功",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache) {
+				d := daomocks.NewMockArticleDAO(ctrl)
+				d.EXPECT().TransferAuthor(gomock.Any(), int64(2), int64(1)).Return([]int64{3}, nil)
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().DelFirstPage(gomock.Any(), int64(2)).Return(nil)
+				c.EXPECT().DelFirstPage(gomock.Any(), int64(1)).Return(nil)
+				c.EXPECT().Del(gomock.Any(), int64(3)).Return(nil)
+				return d, c
+			},
+			wantEvicted: true,
+		},
+		{
+			name: "删除缓存失败",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache) {
+				d := daomocks.NewMockArticleDAO(ctrl)
+				d.EXPECT().TransferAuthor(gomock.Any(), int64(2), int64(1)).Return([]int64{3}, nil)
+				c := cachemocks.NewMockArticleCache(ctrl)
+				c.EXPECT().DelFirstPage(gomock.Any(), gomock.Any()).Return(errors.New("redis error")).Times(2)
+				c.EXPECT().Del(gomock.Any(), int64(3)).Return(errors.New("redis error"))
+				return d, c
+			},
+			wantEvicted: true,
+		},
+		{
+			name: "数据库失败",
+			mock: func(ctrl *gomock.Controller) (dao.ArticleDAO, cache.ArticleCache) {
+				d := daomocks.NewMockArticleDAO(ctrl)
+				d.EXPECT().TransferAuthor(gomock.Any(), int64(2), int64(1)).Return(nil, errors.New("db error"))
+				return d, cachemocks.NewMockArticleCache(ctrl)
+			},
+			wantErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			d, c := tc.mock(ctrl)
+			pub := NewPubArticleCache(nil, nil, cachex.NewLocalInvalidator(time.Minute), logger.NewNopLogger())
+			err := pub.Set(context.Background(), PubArticleKey(3), domain.Article{Id: 3})
+			assert.NoError(t, err)
+			repo := NewCachedArticleRepository(d, nil, c, nil, pub, cache.ArticleBloomFilters{})
+			err = repo.TransferAuthor(context.Background(), 2, 1)
+			assert.Equal(t, tc.wantErr, err)
+
+			loaded := false
+			_, err = pub.Get(context.Background(), PubArticleKey(3), func(ctx context.Context) (domain.Article, error) {
+				loaded = true
+				return domain.Article{Id: 3}, nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantEvicted, loaded)
+		})
+	}
+}
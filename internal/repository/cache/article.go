@@ -9,23 +9,60 @@ import (
 	"webok/internal/domain"
 )
 
+//go:generate mockgen -source=article.go -package=cachemocks -destination=./mock/article.mock.go
 type ArticleCache interface {
 	GetFirstPage(ctx context.Context, authorId int64) ([]domain.Article, error)
 	SetFirstPage(ctx context.Context, authorId int64, authorList []domain.Article) error
 	DelFirstPage(ctx context.Context, authorId int64) error
 	Get(ctx context.Context, id int64) (domain.Article, error)
 	Set(ctx context.Context, art domain.Article) error
-	GetPub(ctx context.Context, id int64) (domain.Article, error)
-	SetPub(ctx context.Context, res domain.Article) error
-	DelPub(ctx context.Context, id int64) error
+	// Del 删除制作库文章的缓存
+	Del(ctx context.Context, ids ...int64) error
+
+	// SetMissing 记录数据库里面不存在的文章，防止缓存穿透，只保留很短的时间
+	SetMissing(ctx context.Context, id int64) error
+	Missing(ctx context.Context, id int64) (bool, error)
+	SetPubMissing(ctx context.Context, id int64) error
+	PubMissing(ctx context.Context, id int64) (bool, error)
+	// DelMissing 文章创建或者发表之后删除
+	DelMissing(ctx context.Context, id int64) error
 }
 
+// missingExpiration 不存在的文章缓存多久，太长的话新发表的文章会有一段时间看不到
+const missingExpiration = time.Minute
+
 type ArticleRedisCache struct {
 	cmd redis.Cmdable
 }
 
-func (a *ArticleRedisCache) DelPub(ctx context.Context, id int64) error {
-	return a.cmd.Del(ctx, a.pubArticleKey(id)).Err()
+func (a *ArticleRedisCache) SetMissing(ctx context.Context, id int64) error {
+	return a.cmd.Set(ctx, a.missingKey(id), 1, missingExpiration).Err()
+}
+
+func (a *ArticleRedisCache) Missing(ctx context.Context, id int64) (bool, error) {
+	cnt, err := a.cmd.Exists(ctx, a.missingKey(id)).Result()
+	return cnt > 0, err
+}
+
+func (a *ArticleRedisCache) SetPubMissing(ctx context.Context, id int64) error {
+	return a.cmd.Set(ctx, a.pubMissingKey(id), 1, missingExpiration).Err()
+}
+
+func (a *ArticleRedisCache) PubMissing(ctx context.Context, id int64) (bool, error) {
+	cnt, err := a.cmd.Exists(ctx, a.pubMissingKey(id)).Result()
+	return cnt > 0, err
+}
+
+func (a *ArticleRedisCache) DelMissing(ctx context.Context, id int64) error {
+	return a.cmd.Del(ctx, a.missingKey(id), a.pubMissingKey(id)).Err()
+}
+
+func (a *ArticleRedisCache) missingKey(id int64) string {
+	return fmt.Sprintf("article:missing:%d", id)
+}
+
+func (a *ArticleRedisCache) pubMissingKey(id int64) string {
+	return fmt.Sprintf("article:missing:pub:%d", id)
 }
 
 func NewArticleRedisCache(cmd redis.Cmdable) ArticleCache {
@@ -86,26 +123,13 @@ func (a *ArticleRedisCache) Set(ctx context.Context, art domain.Article) error {
 	return a.cmd.Set(ctx, key, data, 10*time.Minute).Err()
 }
 
-func (a *ArticleRedisCache) pubArticleKey(id int64) string {
-	return fmt.Sprintf("article:pub:%d", id)
-}
-
-func (a *ArticleRedisCache) GetPub(ctx context.Context, id int64) (domain.Article, error) {
-
-	res, err := a.cmd.Get(ctx, a.pubArticleKey(id)).Bytes()
-	if err != nil {
-		return domain.Article{}, err
+func (a *ArticleRedisCache) Del(ctx context.Context, ids ...int64) error {
+	if len(ids) == 0 {
+		return nil
 	}
-	var art domain.Article
-	err = json.Unmarshal(res, &art)
-	if err != nil {
-		return domain.Article{}, err
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, a.articleKey(id))
 	}
-	return art, nil
-}
-
-func (a *ArticleRedisCache) SetPub(ctx context.Context, res domain.Article) error {
-	key := a.pubArticleKey(res.Id)
-	err := a.cmd.Set(ctx, key, res, 10*time.Minute).Err()
-	return err
+	return a.cmd.Del(ctx, keys...).Err()
 }
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	//go:embed lua/bloom_add.lua
+	luaBloomAdd string
+	//go:embed lua/bloom_exists.lua
+	luaBloomExists string
+
+	// ErrBloomFilterNotReady 布隆过滤器还没有构建，这个时候只能认为元素可能存在
+	ErrBloomFilterNotReady = errors.New("布隆过滤器还没有构建")
+)
+
+//go:generate mockgen -source=bloom.go -package=cachemocks -destination=./mock/bloom.mock.go
+type BloomFilter interface {
+	Add(ctx context.Context, items ...string) error
+	// MightContain 返回 false 的时候一定不存在
+	MightContain(ctx context.Context, item string) (bool, error)
+	// Rebuild 从头构建，load 调用 add 把全部元素加进来。
+	// 别的实例正在重建的时候直接返回
+	Rebuild(ctx context.Context, load func(ctx context.Context, add func(items ...string) error) error) error
+}
+
+// RedisBloomFilter 用 Redis 的 bitmap 实现，哈希在 Lua 脚本里面算
+type RedisBloomFilter struct {
+	cmd redis.Cmdable
+	key string
+	// 位数和哈希函数的个数
+	m uint64
+	k int
+}
+
+// NewRedisBloomFilter n 是预计的元素个数，p 是可以接受的误判率
+func NewRedisBloomFilter(cmd redis.Cmdable, key string, n uint64, p float64) *RedisBloomFilter {
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	k := int(math.Round(float64(m) / float64(n) * math.Ln2))
+	return &RedisBloomFilter{
+		cmd: cmd,
+		key: key,
+		m:   m,
+		k:   max(k, 1),
+	}
+}
+
+func (b *RedisBloomFilter) Add(ctx context.Context, items ...string) error {
+	return b.add(ctx, b.key, items)
+}
+
+func (b *RedisBloomFilter) add(ctx context.Context, key string, items []string) error {
+	if len(items) == 0 {
+		return nil
+	}
+	args := make([]any, 0, len(items)+2)
+	args = append(args, b.m, b.k)
+	for _, item := range items {
+		args = append(args, item)
+	}
+	return b.cmd.Eval(ctx, luaBloomAdd, []string{key, b.rebuildingKey()}, args...).Err()
+}
+
+func (b *RedisBloomFilter) MightContain(ctx context.Context, item string) (bool, error) {
+	res, err := b.cmd.Eval(ctx, luaBloomExists, []string{b.key}, b.m, b.k, item).Int()
+	if err != nil {
+		return true, err
+	}
+	switch res {
+	case -1:
+		return true, ErrBloomFilterNotReady
+	case 0:
+		return false, nil
+	default:
+		return true, nil
+	}
+}
+
+func (b *RedisBloomFilter) Rebuild(ctx context.Context,
+	load func(ctx context.Context, add func(items ...string) error) error) error {
+	// 同一时间只允许一个实例重建
+	ok, err := b.cmd.SetNX(ctx, b.lockKey(), 1, time.Minute*10).Result()
+	if err != nil || !ok {
+		return err
+	}
+	defer b.cmd.Del(context.WithoutCancel(ctx), b.lockKey())
+
+	tmp := b.rebuildingKey()
+	// 先把 bitmap 建出来，重建期间新增的元素也会写进来
+	err = b.cmd.Del(ctx, tmp).Err()
+	if err != nil {
+		return err
+	}
+	err = b.cmd.SetBit(ctx, tmp, int64(b.m-1), 0).Err()
+	if err != nil {
+		return err
+	}
+	err = load(ctx, func(items ...string) error {
+		return b.add(ctx, tmp, items)
+	})
+	if err != nil {
+		b.cmd.Del(ctx, tmp)
+		return err
+	}
+	return b.cmd.Rename(ctx, tmp, b.key).Err()
+}
+
+func (b *RedisBloomFilter) rebuildingKey() string {
+	return b.key + ":rebuilding"
+}
+
+func (b *RedisBloomFilter) lockKey() string {
+	return b.key + ":lock"
+}
+
+// Int64Items 把 ID 转成布隆过滤器的元素
+func Int64Items(ids []int64) []string {
+	items := make([]string, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, strconv.FormatInt(id, 10))
+	}
+	return items
+}
+
+// ArticleBloomFilters 制作库和线上库的文章 ID
+type ArticleBloomFilters struct {
+	Draft BloomFilter
+	Pub   BloomFilter
+}
+
+func NewArticleBloomFilters(cmd redis.Cmdable) ArticleBloomFilters {
+	return ArticleBloomFilters{
+		Draft: NewRedisBloomFilter(cmd, "bloom:article", 1000000, 0.01),
+		Pub:   NewRedisBloomFilter(cmd, "bloom:article:pub", 1000000, 0.01),
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"strings"
+	"time"
+)
+
+var ErrCaptchaNotFound = errors.New("验证码不存在、已经过期或者已经使用过")
+
+//go:generate mockgen -source=captcha.go -package=cachemocks -destination=./mock/captcha.mock.go
+type CaptchaCache interface {
+	SetAnswer(ctx context.Context, biz, id, typ, answer string, ttl time.Duration) error
+	// TakeAnswer 取出答案的同时删除，不管对不对都只能验证一次
+	TakeAnswer(ctx context.Context, biz, id string) (typ string, answer string, err error)
+	// SetTicket 票据按挑战的 id 保存，一个挑战只对应一个票据
+	SetTicket(ctx context.Context, biz, id, secret string, ttl time.Duration) error
+	// TakeTicket 票据只能用一次，id 对不上或者 secret 不对都不通过
+	TakeTicket(ctx context.Context, biz, id, secret string) (bool, error)
+	IncrSuspicious(ctx context.Context, biz, ip string, window time.Duration) error
+	Suspicious(ctx context.Context, biz, ip string) (int64, error)
+}
+
+type CaptchaRedisCache struct {
+	cmd redis.Cmdable
+}
+
+func NewCaptchaRedisCache(cmd redis.Cmdable) CaptchaCache {
+	return &CaptchaRedisCache{cmd: cmd}
+}
+
+func (c *CaptchaRedisCache) SetAnswer(ctx context.Context, biz, id, typ, answer string, ttl time.Duration) error {
+	return c.cmd.Set(ctx, c.answerKey(biz, id), typ+":"+answer, ttl).Err()
+}
+
+func (c *CaptchaRedisCache) TakeAnswer(ctx context.Context, biz, id string) (string, string, error) {
+	val, err := c.cmd.GetDel(ctx, c.answerKey(biz, id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", "", ErrCaptchaNotFound
+	}
+	if err != nil {
+		return "", "", err
+	}
+	typ, answer, ok := strings.Cut(val, ":")
+	if !ok {
+		return "", "", fmt.Errorf("验证码答案格式错误 %s", val)
+	}
+	return typ, answer, nil
+}
+
+func (c *CaptchaRedisCache) SetTicket(ctx context.Context, biz, id, secret string, ttl time.Duration) error {
+	return c.cmd.Set(ctx, c.ticketKey(biz, id), secret, ttl).Err()
+}
+
+func (c *CaptchaRedisCache) TakeTicket(ctx context.Context, biz, id, secret string) (bool, error) {
+	// 不管 secret 对不对都删掉，猜错一次这个票据就作废了
+	val, err := c.cmd.GetDel(ctx, c.ticketKey(biz, id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(val), []byte(secret)) == 1, nil
+}
+
+func (c *CaptchaRedisCache) IncrSuspicious(ctx context.Context, biz, ip string, window time.Duration) error {
+	key := c.suspiciousKey(biz, ip)
+	pipe := c.cmd.TxPipeline()
+	pipe.Incr(ctx, key)
+	// 每次可疑行为都顺延，一直有可疑行为就一直要求验证
+	pipe.Expire(ctx, key, window)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *CaptchaRedisCache) Suspicious(ctx context.Context, biz, ip string) (int64, error) {
+	cnt, err := c.cmd.Get(ctx, c.suspiciousKey(biz, ip)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return cnt, err
+}
+
+func (c *CaptchaRedisCache) answerKey(biz, id string) string {
+	return fmt.Sprintf("captcha:answer:%s:%s", biz, id)
+}
+
+func (c *CaptchaRedisCache) ticketKey(biz, id string) string {
+	return fmt.Sprintf("captcha:ticket:%s:%s", biz, id)
+}
+
+func (c *CaptchaRedisCache) suspiciousKey(biz, ip string) string {
+	return fmt.Sprintf("captcha:suspicious:%s:%s", biz, ip)
+}
@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestCaptchaRedisCache_Ticket(t *testing.T) {
+	s := miniredis.RunT(t)
+	c := NewCaptchaRedisCache(redis.NewClient(&redis.Options{Addr: s.Addr()}))
+	ctx := context.Background()
+
+	require.NoError(t, c.SetTicket(ctx, "login", "id1", "secret", time.Minute))
+	// 换了业务或者挑战都不行
+	ok, err := c.TakeTicket(ctx, "login_email", "id1", "secret")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = c.TakeTicket(ctx, "login", "id2", "secret")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = c.TakeTicket(ctx, "login", "id1", "secret")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	// 只能用一次
+	ok, err = c.TakeTicket(ctx, "login", "id1", "secret")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// secret 猜错一次票据就作废
+	require.NoError(t, c.SetTicket(ctx, "login", "id3", "secret", time.Minute))
+	ok, err = c.TakeTicket(ctx, "login", "id3", "wrong")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = c.TakeTicket(ctx, "login", "id3", "secret")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
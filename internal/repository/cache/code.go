@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/redis/go-redis/v9"
-	"log"
 	"sync"
 	"time"
 	localMemCache "webok/pkg"
@@ -26,7 +25,8 @@ var (
 
 //go:generate mockgen  -package=redismocks -destination=./redismock/cmd.mock.go github.com/redis/go-redis/v9 Cmdable
 type CodeCache interface {
-	Set(ctx context.Context, biz, phone, code string) error
+	// Set ttl 是验证码的有效期，interval 内不能重复发送，最多可以验证 attempts 次
+	Set(ctx context.Context, biz, phone, code string, ttl, interval time.Duration, attempts int) error
 	Verify(ctx context.Context, biz, phone, code string) (bool, error)
 }
 
@@ -41,8 +41,10 @@ func NewCodeRedisCache(cmd redis.Cmdable) CodeCache {
 }
 
 // Set 会返回ErrCodeSendTooMany错误
-func (c *CodeRedisCache) Set(ctx context.Context, biz, phone, code string) error {
-	res, err := c.cmd.Eval(ctx, luaSetCode, []string{key(biz, phone)}, code).Int()
+func (c *CodeRedisCache) Set(ctx context.Context, biz, phone, code string,
+	ttl, interval time.Duration, attempts int) error {
+	res, err := c.cmd.Eval(ctx, luaSetCode, []string{key(biz, phone)},
+		code, int64(ttl.Seconds()), int64(interval.Seconds()), attempts).Int()
 	if err != nil {
 		// 调用 redis 出了问题
 		return err
@@ -79,11 +81,18 @@ func key(biz, phone string) string {
 
 type CodeLocalMemCache struct {
 	sync.Mutex
-	cache *localMemCache.LocalMemCache
+	cache *localMemCache.Cache[string, codeItem]
 }
 
-func NewCodeLocalMemCache(cache *localMemCache.LocalMemCache) *CodeLocalMemCache {
-	return &CodeLocalMemCache{cache: cache}
+// NewCodeLocalMemCache 单机部署的时候用，最多保存 maxEntries 个验证码
+func NewCodeLocalMemCache(maxEntries int) *CodeLocalMemCache {
+	return &CodeLocalMemCache{
+		cache: localMemCache.NewCache[string, codeItem](localMemCache.Config[string, codeItem]{
+			MaxEntries:    maxEntries,
+			DefaultTTL:    time.Minute * 10,
+			CleanInterval: time.Minute,
+		}),
+	}
 }
 
 type codeItem struct {
@@ -92,32 +101,21 @@ type codeItem struct {
 	cnt int
 }
 
-func (c *CodeLocalMemCache) Set(_ context.Context, biz, phone, code string) error {
+func (c *CodeLocalMemCache) Set(_ context.Context, biz, phone, code string,
+	ttl, interval time.Duration, attempts int) error {
 	c.Lock()
 	defer c.Unlock()
 	cKey := key(biz, phone)
-	res, ok := c.cache.Get(cKey)
-	if !ok {
-		err := c.cache.Add(cKey, codeItem{
-			code: code,
-			cnt:  3,
-		}, time.Minute*10)
-		if err != nil {
-			return err
-		}
-		return nil
-	}
-
-	if res.Expire.Sub(time.Now()) > time.Minute*9 {
-		log.Printf("ErrCodeSendTooMany\n")
+	_, remain, ok := c.cache.GetWithTTL(cKey)
+	// interval 之内只能发送一次
+	if ok && remain > ttl-interval {
 		return ErrCodeSendTooMany
 	}
-
-	//满足发送间隔，发送
-	return c.cache.Add(cKey, codeItem{
+	c.cache.Set(cKey, codeItem{
 		code: code,
-		cnt:  3,
-	}, time.Minute*10)
+		cnt:  attempts,
+	}, ttl)
+	return nil
 }
 
 func (c *CodeLocalMemCache) Verify(_ context.Context, biz, phone, code string) (bool, error) {
@@ -125,15 +123,10 @@ func (c *CodeLocalMemCache) Verify(_ context.Context, biz, phone, code string) (
 	defer c.Unlock()
 
 	cKey := key(biz, phone)
-	res, ok := c.cache.Get(cKey)
-	if !ok || res.Expire.Sub(time.Now()) > time.Minute*10 {
-		return false, nil
-	}
-	item, ok := res.Data.(codeItem)
+	item, ok := c.cache.Get(cKey)
 	if !ok {
-		return false, errors.New("系统错误")
+		return false, nil
 	}
-
 	if item.cnt <= 0 {
 		return false, ErrCodeVerifyTooMany
 	}
@@ -146,6 +139,10 @@ func (c *CodeLocalMemCache) Verify(_ context.Context, biz, phone, code string) (
 		}
 		return false, nil
 	}
-	c.cache.Del(cKey)
+	c.cache.Delete(cKey)
 	return true, nil
 }
+
+func (c *CodeLocalMemCache) Close() error {
+	return c.cache.Close()
+}
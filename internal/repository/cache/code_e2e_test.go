@@ -170,7 +170,7 @@ func TestRedisLuaCodeCache_Set_e2e(t *testing.T) {
 			defer tc.after(t)
 
 			c := cache.NewCodeRedisCache(rdb)
-			err := c.Set(tc.ctx, tc.biz, tc.phone, tc.code)
+			err := c.Set(tc.ctx, tc.biz, tc.phone, tc.code, time.Minute*10, time.Minute, 3)
 			assert.Equal(t, tc.wantErr, err)
 		})
 	}
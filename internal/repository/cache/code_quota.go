@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+	"webok/internal/domain"
+)
+
+var (
+	//go:embed lua/code_quota.lua
+	luaCodeQuota string
+	//go:embed lua/code_quota_release.lua
+	luaCodeQuotaRelease string
+
+	ErrCodeQuotaExceeded = errors.New("超过验证码发送限额")
+	ErrCodePhoneQuota    = fmt.Errorf("%w: 手机号当天", ErrCodeQuotaExceeded)
+	ErrCodeIPQuota       = fmt.Errorf("%w: IP 一小时内", ErrCodeQuotaExceeded)
+	ErrCodeGlobalQuota   = fmt.Errorf("%w: 业务当天", ErrCodeQuotaExceeded)
+	ErrCodeTotalQuota    = fmt.Errorf("%w: 所有业务当天", ErrCodeQuotaExceeded)
+)
+
+//go:generate mockgen -source=code_quota.go -package=cachemocks -destination=./mock/code_quota.mock.go
+type CodeQuotaCache interface {
+	// Acquire 检查并占用一次发送额度，超过的时候返回 ErrCodePhoneQuota 之类的错误
+	Acquire(ctx context.Context, biz, phone, ip string, quota domain.CodeQuota) (domain.CodeQuotaUsage, error)
+	// Release 归还 Acquire 占用的额度
+	Release(ctx context.Context, u domain.CodeQuotaUsage) error
+}
+
+type CodeQuotaRedisCache struct {
+	cmd redis.Cmdable
+	now func() time.Time
+}
+
+func NewCodeQuotaRedisCache(cmd redis.Cmdable) CodeQuotaCache {
+	return &CodeQuotaRedisCache{
+		cmd: cmd,
+		now: time.Now,
+	}
+}
+
+func (c *CodeQuotaRedisCache) Acquire(ctx context.Context, biz, phone, ip string,
+	quota domain.CodeQuota) (domain.CodeQuotaUsage, error) {
+	u := domain.CodeQuotaUsage{Biz: biz, Phone: phone, IP: ip, Quota: quota, At: c.now()}
+	// 多留一点，避免时钟误差导致提前过期
+	day, hour := int64((time.Hour * 25).Seconds()), int64((time.Hour * 2).Seconds())
+	res, err := c.cmd.Eval(ctx, luaCodeQuota, c.keys(u),
+		quota.PhonePerDay, quota.IPPerHour, quota.GlobalPerDay, quota.TotalPerDay,
+		day, hour, day, day).Int()
+	if err != nil {
+		return domain.CodeQuotaUsage{}, err
+	}
+	switch res {
+	case 0:
+		return u, nil
+	case 1:
+		return domain.CodeQuotaUsage{}, ErrCodePhoneQuota
+	case 2:
+		return domain.CodeQuotaUsage{}, ErrCodeIPQuota
+	case 3:
+		return domain.CodeQuotaUsage{}, ErrCodeGlobalQuota
+	case 4:
+		return domain.CodeQuotaUsage{}, ErrCodeTotalQuota
+	default:
+		return domain.CodeQuotaUsage{}, fmt.Errorf("未知的返回值 %d", res)
+	}
+}
+
+func (c *CodeQuotaRedisCache) Release(ctx context.Context, u domain.CodeQuotaUsage) error {
+	return c.cmd.Eval(ctx, luaCodeQuotaRelease, c.keys(u),
+		u.Quota.PhonePerDay, u.Quota.IPPerHour, u.Quota.GlobalPerDay, u.Quota.TotalPerDay).Err()
+}
+
+// keys 计数按照占用额度时候的自然日和自然小时分桶，最后一个不区分业务
+func (c *CodeQuotaRedisCache) keys(u domain.CodeQuotaUsage) []string {
+	day := u.At.Format("20060102")
+	return []string{
+		fmt.Sprintf("code_quota:phone:%s:%s:%s", u.Biz, u.Phone, day),
+		fmt.Sprintf("code_quota:ip:%s:%s:%s", u.Biz, u.IP, u.At.Format("2006010215")),
+		fmt.Sprintf("code_quota:global:%s:%s", u.Biz, day),
+		fmt.Sprintf("code_quota:total:%s", day),
+	}
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"webok/internal/domain"
+)
+
+func TestCodeQuotaRedisCache(t *testing.T) {
+	s := miniredis.RunT(t)
+	c := NewCodeQuotaRedisCache(redis.NewClient(&redis.Options{Addr: s.Addr()})).(*CodeQuotaRedisCache)
+	now := time.Date(2026, 10, 19, 10, 59, 59, 0, time.Local)
+	c.now = func() time.Time {
+		return now
+	}
+	ctx := context.Background()
+	quota := domain.CodeQuota{PhonePerDay: 2, IPPerHour: 3, GlobalPerDay: 10, TotalPerDay: 4}
+
+	u, err := c.Acquire(ctx, "login", "15212345678", "127.0.0.1", quota)
+	require.NoError(t, err)
+	assert.Equal(t, now, u.At)
+	_, err = c.Acquire(ctx, "login", "15212345678", "127.0.0.1", quota)
+	require.NoError(t, err)
+	// 同一个手机号超过限额，其它计数不会加一
+	_, err = c.Acquire(ctx, "login", "15212345678", "127.0.0.1", quota)
+	assert.Equal(t, ErrCodePhoneQuota, err)
+	_, err = c.Acquire(ctx, "login", "15287654321", "127.0.0.1", quota)
+	require.NoError(t, err)
+	_, err = c.Acquire(ctx, "login", "15287654321", "127.0.0.1", quota)
+	assert.Equal(t, ErrCodeIPQuota, err)
+
+	// 换一个业务也绕不过所有业务共用的计数
+	_, err = c.Acquire(ctx, "reset_pwd_sms", "15200000000", "127.0.0.2", quota)
+	require.NoError(t, err)
+	_, err = c.Acquire(ctx, "bind_phone", "15200000001", "127.0.0.3", quota)
+	assert.Equal(t, ErrCodeTotalQuota, err)
+
+	// 过了整点之后归还，还是还到占用的那个小时
+	now = now.Add(time.Second)
+	require.NoError(t, c.Release(ctx, u))
+	ipCnt, err := s.Get("code_quota:ip:login:127.0.0.1:2026101910")
+	require.NoError(t, err)
+	assert.Equal(t, "2", ipCnt)
+	assert.False(t, s.Exists("code_quota:ip:login:127.0.0.1:2026101911"))
+	totalCnt, err := s.Get("code_quota:total:20261019")
+	require.NoError(t, err)
+	assert.Equal(t, "3", totalCnt)
+}
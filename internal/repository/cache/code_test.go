@@ -8,6 +8,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 	"testing"
+	"time"
 	redismocks "webok/internal/repository/cache/redismock"
 )
 
@@ -35,7 +36,7 @@ func TestCodeRedisCache_Set(t *testing.T) {
 				resCmd.SetErr(nil)
 				cmd.EXPECT().
 					Eval(gomock.Any(), luaSetCode,
-						[]string{keyFunc("test", "12312345678")}, []any{"123456"}).
+						[]string{keyFunc("test", "12312345678")}, []any{"123456", int64(600), int64(60), 3}).
 					Return(resCmd)
 				return cmd
 			},
@@ -54,7 +55,7 @@ func TestCodeRedisCache_Set(t *testing.T) {
 				resCmd.SetErr(errors.New("redis error"))
 				cmd.EXPECT().
 					Eval(gomock.Any(), luaSetCode,
-						[]string{keyFunc("test", "12312345678")}, []any{"123456"}).
+						[]string{keyFunc("test", "12312345678")}, []any{"123456", int64(600), int64(60), 3}).
 					Return(resCmd)
 				return cmd
 			},
@@ -72,7 +73,7 @@ func TestCodeRedisCache_Set(t *testing.T) {
 				resCmd.SetVal(int64(-2))
 				cmd.EXPECT().
 					Eval(gomock.Any(), luaSetCode,
-						[]string{keyFunc("test", "12312345678")}, []any{"123456"}).
+						[]string{keyFunc("test", "12312345678")}, []any{"123456", int64(600), int64(60), 3}).
 					Return(resCmd)
 				return cmd
 			},
@@ -90,7 +91,7 @@ func TestCodeRedisCache_Set(t *testing.T) {
 				resCmd.SetVal(int64(-1))
 				cmd.EXPECT().
 					Eval(gomock.Any(), luaSetCode,
-						[]string{keyFunc("test", "12312345678")}, []any{"123456"}).
+						[]string{keyFunc("test", "12312345678")}, []any{"123456", int64(600), int64(60), 3}).
 					Return(resCmd)
 				return cmd
 			},
@@ -109,7 +110,7 @@ func TestCodeRedisCache_Set(t *testing.T) {
 
 			cmd := tc.mock(ctrl)
 			c := NewCodeRedisCache(cmd)
-			err := c.Set(tc.ctx, tc.biz, tc.phone, tc.code)
+			err := c.Set(tc.ctx, tc.biz, tc.phone, tc.code, time.Minute*10, time.Minute, 3)
 			assert.Equal(t, tc.wantErr, err)
 
 		})
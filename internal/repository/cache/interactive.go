@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"time"
 	"webok/internal/domain"
+	"webok/pkg/cachex"
 )
 
 var (
@@ -27,6 +28,7 @@ type InteractiveCache interface {
 	IncrCollectionCntIfPresent(ctx context.Context, biz string, id int64) error
 	Get(ctx context.Context, biz string, id int64) (domain.Interactive, error)
 	Set(ctx context.Context, biz string, id int64, ie domain.Interactive) error
+	Del(ctx context.Context, refs ...domain.BizRef) error
 }
 
 type RedisInteractiveCache struct {
@@ -40,7 +42,7 @@ func (r *RedisInteractiveCache) Set(ctx context.Context, biz string, id int64, i
 	if err != nil {
 		return err
 	}
-	return r.cmd.Expire(ctx, r.key(biz, id), time.Minute*15).Err()
+	return r.cmd.Expire(ctx, r.key(biz, id), cachex.Jitter(time.Minute*15, 0.2)).Err()
 }
 
 func (r *RedisInteractiveCache) Get(ctx context.Context, biz string, id int64) (domain.Interactive, error) {
@@ -76,6 +78,17 @@ func (r *RedisInteractiveCache) DecrLikeCntIfPresent(ctx context.Context, biz st
 	return res
 }
 
+func (r *RedisInteractiveCache) Del(ctx context.Context, refs ...domain.BizRef) error {
+	if len(refs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		keys = append(keys, r.key(ref.Biz, ref.BizId))
+	}
+	return r.cmd.Del(ctx, keys...).Err()
+}
+
 func NewRedisInteractiveCache(cmd redis.Cmdable) InteractiveCache {
 	return &RedisInteractiveCache{cmd: cmd}
 }
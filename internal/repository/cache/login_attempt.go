@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+	"webok/internal/domain"
+)
+
+//go:embed lua/login_fail.lua
+var luaLoginFail string
+
+//go:generate mockgen -source=login_attempt.go -package=cachemocks -destination=./mock/login_attempt.mock.go
+type LoginAttemptCache interface {
+	Get(ctx context.Context, scope domain.LoginScope, subject string) (domain.LoginAttempt, error)
+	// Fail 记一次失败，达到 lockAfter 次的时候锁定，锁定时长按照最近锁定过的次数翻倍
+	Fail(ctx context.Context, scope domain.LoginScope, subject string, lockAfter int, p domain.LoginPolicy) (domain.LoginAttempt, error)
+	// Reset 清掉失败次数和锁定级别，不会解除已经生效的锁定
+	Reset(ctx context.Context, scope domain.LoginScope, subject string) error
+}
+
+type LoginAttemptRedisCache struct {
+	cmd redis.Cmdable
+}
+
+func NewLoginAttemptRedisCache(cmd redis.Cmdable) LoginAttemptCache {
+	return &LoginAttemptRedisCache{cmd: cmd}
+}
+
+func (c *LoginAttemptRedisCache) Get(ctx context.Context, scope domain.LoginScope, subject string) (domain.LoginAttempt, error) {
+	keys := c.keys(scope, subject)
+	pipe := c.cmd.Pipeline()
+	fails := pipe.Get(ctx, keys[0])
+	ttl := pipe.PTTL(ctx, keys[1])
+	level := pipe.Get(ctx, keys[2])
+	_, err := pipe.Exec(ctx)
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return domain.LoginAttempt{}, err
+	}
+	a := domain.LoginAttempt{}
+	a.Failures, _ = fails.Int()
+	a.Level, _ = level.Int()
+	// key 不存在的时候 PTTL 返回负数
+	if ttl.Val() > 0 {
+		a.LockedFor = ttl.Val()
+	}
+	return a, nil
+}
+
+func (c *LoginAttemptRedisCache) Fail(ctx context.Context, scope domain.LoginScope, subject string,
+	lockAfter int, p domain.LoginPolicy) (domain.LoginAttempt, error) {
+	res, err := c.cmd.Eval(ctx, luaLoginFail, c.keys(scope, subject),
+		lockAfter, p.Window.Milliseconds(), p.BaseLock.Milliseconds(),
+		p.MaxLock.Milliseconds(), p.BackoffReset.Milliseconds()).Int64Slice()
+	if err != nil {
+		return domain.LoginAttempt{}, err
+	}
+	if len(res) != 4 {
+		return domain.LoginAttempt{}, fmt.Errorf("未知的返回值 %v", res)
+	}
+	return domain.LoginAttempt{
+		Failures:   int(res[0]),
+		LockedFor:  time.Duration(res[1]) * time.Millisecond,
+		Level:      int(res[2]),
+		JustLocked: res[3] == 1,
+	}, nil
+}
+
+func (c *LoginAttemptRedisCache) Reset(ctx context.Context, scope domain.LoginScope, subject string) error {
+	keys := c.keys(scope, subject)
+	return c.cmd.Del(ctx, keys[0], keys[2]).Err()
+}
+
+func (c *LoginAttemptRedisCache) keys(scope domain.LoginScope, subject string) []string {
+	tag := fmt.Sprintf("{%s:%s}", scope, subject)
+	return []string{
+		"login_attempt:fails:" + tag,
+		"login_attempt:lock:" + tag,
+		"login_attempt:level:" + tag,
+	}
+}
@@ -0,0 +1,26 @@
+-- KEYS[1] 布隆过滤器，还没有构建的时候不写，不然只有部分数据会误判成不存在
+-- KEYS[2] 正在重建的布隆过滤器，存在的时候同时写进去
+-- ARGV[1] 位数，ARGV[2] 哈希函数的个数，后面都是要加进去的元素
+local m = tonumber(ARGV[1])
+local k = tonumber(ARGV[2])
+local ready = redis.call("EXISTS", KEYS[1]) == 1
+local rebuilding = redis.call("EXISTS", KEYS[2]) == 1
+if not ready and not rebuilding then
+    return 0
+end
+for i = 3, #ARGV do
+    -- 双重哈希，用 sha1 的前 64 位当成两个哈希函数
+    local h = redis.sha1hex(ARGV[i])
+    local h1 = tonumber(string.sub(h, 1, 8), 16)
+    local h2 = tonumber(string.sub(h, 9, 16), 16)
+    for j = 0, k - 1 do
+        local offset = (h1 + j * h2) % m
+        if ready then
+            redis.call("SETBIT", KEYS[1], offset, 1)
+        end
+        if rebuilding then
+            redis.call("SETBIT", KEYS[2], offset, 1)
+        end
+    end
+end
+return 1
@@ -0,0 +1,18 @@
+-- KEYS[1] 布隆过滤器
+-- ARGV[1] 位数，ARGV[2] 哈希函数的个数，ARGV[3] 要检查的元素
+-- 返回 -1 表示还没有构建，0 表示一定不存在，1 表示可能存在
+if redis.call("EXISTS", KEYS[1]) == 0 then
+    return -1
+end
+local m = tonumber(ARGV[1])
+local k = tonumber(ARGV[2])
+local h = redis.sha1hex(ARGV[3])
+local h1 = tonumber(string.sub(h, 1, 8), 16)
+local h2 = tonumber(string.sub(h, 9, 16), 16)
+for j = 0, k - 1 do
+    local offset = (h1 + j * h2) % m
+    if redis.call("GETBIT", KEYS[1], offset) == 0 then
+        return 0
+    end
+end
+return 1
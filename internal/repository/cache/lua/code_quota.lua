@@ -0,0 +1,21 @@
+-- KEYS 依次是手机号、IP、业务、所有业务的计数
+-- ARGV 前面 #KEYS 个是对应的阈值，0 表示不限制，后面 #KEYS 个是过期时间
+-- 先检查所有的阈值，都没有超过才一起加一，返回超过的是第几个
+for i = 1, #KEYS do
+    local limit = tonumber(ARGV[i])
+    if limit > 0 then
+        local cnt = tonumber(redis.call('GET', KEYS[i]) or '0')
+        if cnt >= limit then
+            return i
+        end
+    end
+end
+for i = 1, #KEYS do
+    if tonumber(ARGV[i]) > 0 then
+        local cnt = redis.call('INCR', KEYS[i])
+        if cnt == 1 then
+            redis.call('EXPIRE', KEYS[i], ARGV[i + #KEYS])
+        end
+    end
+end
+return 0
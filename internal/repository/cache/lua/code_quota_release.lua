@@ -0,0 +1,10 @@
+-- 验证码没有发出去，把占用的额度还回去，计数不存在或者已经是 0 的不处理
+for i = 1, #KEYS do
+    if tonumber(ARGV[i]) > 0 then
+        local cnt = tonumber(redis.call('GET', KEYS[i]) or '0')
+        if cnt > 0 then
+            redis.call('DECR', KEYS[i])
+        end
+    end
+end
+return 0
@@ -0,0 +1,26 @@
+-- KEYS 依次是失败次数、锁定标记、锁定级别，用了同一个 hash tag，在集群里面也在一个节点上
+-- ARGV 依次是锁定阈值、计数窗口、第一次锁定时长、最长锁定时长、锁定级别的有效期，时间都是毫秒
+-- 返回 {失败次数, 锁定剩余毫秒, 锁定级别, 这次是否触发了锁定}
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl > 0 then
+    -- 锁定期间的失败不计数，不然锁定结束之后马上又会被锁
+    return {tonumber(redis.call('GET', KEYS[1]) or '0'), ttl, tonumber(redis.call('GET', KEYS[3]) or '0'), 0}
+end
+local fails = redis.call('INCR', KEYS[1])
+if fails == 1 then
+    redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+local threshold = tonumber(ARGV[1])
+if threshold <= 0 or fails < threshold then
+    return {fails, 0, tonumber(redis.call('GET', KEYS[3]) or '0'), 0}
+end
+local level = redis.call('INCR', KEYS[3])
+redis.call('PEXPIRE', KEYS[3], ARGV[5])
+-- 指数退避，每多锁一次时长翻倍
+local lock = tonumber(ARGV[4])
+if level <= 32 then
+    lock = math.min(tonumber(ARGV[3]) * 2 ^ (level - 1), lock)
+end
+redis.call('SET', KEYS[2], level, 'PX', string.format('%d', lock))
+redis.call('DEL', KEYS[1])
+return {fails, lock, level, 1}
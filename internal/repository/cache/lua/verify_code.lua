@@ -12,8 +12,12 @@ if cnt == nil or cnt <= 0 then
 end
 
 if code == expectedCode then
+    -- 验证码只能用一次，计数和验证码一起过期
     redis.call("set", cntKey, 0)
-    redis.call("expire", cntKey, 240)
+    local ttl = tonumber(redis.call("ttl", key))
+    if ttl > 0 then
+        redis.call("expire", cntKey, ttl)
+    end
     return 0
 else
     redis.call("decr", cntKey)
@@ -0,0 +1,205 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: article.go
+//
+// Generated by this command:
+//
+//	mockgen -source=article.go -package=cachemocks -destination=./mock/article.mock.go
+//
+
+// Package cachemocks is a generated GoMock package.
+package cachemocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webok/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockArticleCache is a mock of ArticleCache interface.
+type MockArticleCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockArticleCacheMockRecorder
+	isgomock struct{}
+}
+
+// MockArticleCacheMockRecorder is the mock recorder for MockArticleCache.
+type MockArticleCacheMockRecorder struct {
+	mock *MockArticleCache
+}
+
+// NewMockArticleCache creates a new mock instance.
+func NewMockArticleCache(ctrl *gomock.Controller) *MockArticleCache {
+	mock := &MockArticleCache{ctrl: ctrl}
+	mock.recorder = &MockArticleCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockArticleCache) EXPECT() *MockArticleCacheMockRecorder {
+	return m.recorder
+}
+
+// Del mocks base method.
+func (m *MockArticleCache) Del(ctx context.Context, ids ...int64) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range ids {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Del", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Del indicates an expected call of Del.
+func (mr *MockArticleCacheMockRecorder) Del(ctx any, ids ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, ids...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockArticleCache)(nil).Del), varargs...)
+}
+
+// DelFirstPage mocks base method.
+func (m *MockArticleCache) DelFirstPage(ctx context.Context, authorId int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DelFirstPage", ctx, authorId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DelFirstPage indicates an expected call of DelFirstPage.
+func (mr *MockArticleCacheMockRecorder) DelFirstPage(ctx, authorId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelFirstPage", reflect.TypeOf((*MockArticleCache)(nil).DelFirstPage), ctx, authorId)
+}
+
+// DelMissing mocks base method.
+func (m *MockArticleCache) DelMissing(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DelMissing", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DelMissing indicates an expected call of DelMissing.
+func (mr *MockArticleCacheMockRecorder) DelMissing(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DelMissing", reflect.TypeOf((*MockArticleCache)(nil).DelMissing), ctx, id)
+}
+
+// Get mocks base method.
+func (m *MockArticleCache) Get(ctx context.Context, id int64) (domain.Article, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id)
+	ret0, _ := ret[0].(domain.Article)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockArticleCacheMockRecorder) Get(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockArticleCache)(nil).Get), ctx, id)
+}
+
+// GetFirstPage mocks base method.
+func (m *MockArticleCache) GetFirstPage(ctx context.Context, authorId int64) ([]domain.Article, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFirstPage", ctx, authorId)
+	ret0, _ := ret[0].([]domain.Article)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFirstPage indicates an expected call of GetFirstPage.
+func (mr *MockArticleCacheMockRecorder) GetFirstPage(ctx, authorId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFirstPage", reflect.TypeOf((*MockArticleCache)(nil).GetFirstPage), ctx, authorId)
+}
+
+// Missing mocks base method.
+func (m *MockArticleCache) Missing(ctx context.Context, id int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Missing", ctx, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Missing indicates an expected call of Missing.
+func (mr *MockArticleCacheMockRecorder) Missing(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Missing", reflect.TypeOf((*MockArticleCache)(nil).Missing), ctx, id)
+}
+
+// PubMissing mocks base method.
+func (m *MockArticleCache) PubMissing(ctx context.Context, id int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PubMissing", ctx, id)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PubMissing indicates an expected call of PubMissing.
+func (mr *MockArticleCacheMockRecorder) PubMissing(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PubMissing", reflect.TypeOf((*MockArticleCache)(nil).PubMissing), ctx, id)
+}
+
+// Set mocks base method.
+func (m *MockArticleCache) Set(ctx context.Context, art domain.Article) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, art)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockArticleCacheMockRecorder) Set(ctx, art any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockArticleCache)(nil).Set), ctx, art)
+}
+
+// SetFirstPage mocks base method.
+func (m *MockArticleCache) SetFirstPage(ctx context.Context, authorId int64, authorList []domain.Article) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetFirstPage", ctx, authorId, authorList)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetFirstPage indicates an expected call of SetFirstPage.
+func (mr *MockArticleCacheMockRecorder) SetFirstPage(ctx, authorId, authorList any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetFirstPage", reflect.TypeOf((*MockArticleCache)(nil).SetFirstPage), ctx, authorId, authorList)
+}
+
+// SetMissing mocks base method.
+func (m *MockArticleCache) SetMissing(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetMissing", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetMissing indicates an expected call of SetMissing.
+func (mr *MockArticleCacheMockRecorder) SetMissing(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetMissing", reflect.TypeOf((*MockArticleCache)(nil).SetMissing), ctx, id)
+}
+
+// SetPubMissing mocks base method.
+func (m *MockArticleCache) SetPubMissing(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetPubMissing", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetPubMissing indicates an expected call of SetPubMissing.
+func (mr *MockArticleCacheMockRecorder) SetPubMissing(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPubMissing", reflect.TypeOf((*MockArticleCache)(nil).SetPubMissing), ctx, id)
+}
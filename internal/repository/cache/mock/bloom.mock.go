@@ -0,0 +1,89 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: bloom.go
+//
+// Generated by this command:
+//
+//	mockgen -source=bloom.go -package=cachemocks -destination=./mock/bloom.mock.go
+//
+
+// Package cachemocks is a generated GoMock package.
+package cachemocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockBloomFilter is a mock of BloomFilter interface.
+type MockBloomFilter struct {
+	ctrl     *gomock.Controller
+	recorder *MockBloomFilterMockRecorder
+	isgomock struct{}
+}
+
+// MockBloomFilterMockRecorder is the mock recorder for MockBloomFilter.
+type MockBloomFilterMockRecorder struct {
+	mock *MockBloomFilter
+}
+
+// NewMockBloomFilter creates a new mock instance.
+func NewMockBloomFilter(ctrl *gomock.Controller) *MockBloomFilter {
+	mock := &MockBloomFilter{ctrl: ctrl}
+	mock.recorder = &MockBloomFilterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBloomFilter) EXPECT() *MockBloomFilterMockRecorder {
+	return m.recorder
+}
+
+// Add mocks base method.
+func (m *MockBloomFilter) Add(ctx context.Context, items ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range items {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Add", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Add indicates an expected call of Add.
+func (mr *MockBloomFilterMockRecorder) Add(ctx any, items ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, items...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockBloomFilter)(nil).Add), varargs...)
+}
+
+// MightContain mocks base method.
+func (m *MockBloomFilter) MightContain(ctx context.Context, item string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MightContain", ctx, item)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MightContain indicates an expected call of MightContain.
+func (mr *MockBloomFilterMockRecorder) MightContain(ctx, item any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MightContain", reflect.TypeOf((*MockBloomFilter)(nil).MightContain), ctx, item)
+}
+
+// Rebuild mocks base method.
+func (m *MockBloomFilter) Rebuild(ctx context.Context, load func(context.Context, func(...string) error) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rebuild", ctx, load)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rebuild indicates an expected call of Rebuild.
+func (mr *MockBloomFilterMockRecorder) Rebuild(ctx, load any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rebuild", reflect.TypeOf((*MockBloomFilter)(nil).Rebuild), ctx, load)
+}
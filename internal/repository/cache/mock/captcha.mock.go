@@ -0,0 +1,130 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: captcha.go
+//
+// Generated by this command:
+//
+//	mockgen -source=captcha.go -package=cachemocks -destination=./mock/captcha.mock.go
+//
+
+// Package cachemocks is a generated GoMock package.
+package cachemocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCaptchaCache is a mock of CaptchaCache interface.
+type MockCaptchaCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockCaptchaCacheMockRecorder
+	isgomock struct{}
+}
+
+// MockCaptchaCacheMockRecorder is the mock recorder for MockCaptchaCache.
+type MockCaptchaCacheMockRecorder struct {
+	mock *MockCaptchaCache
+}
+
+// NewMockCaptchaCache creates a new mock instance.
+func NewMockCaptchaCache(ctrl *gomock.Controller) *MockCaptchaCache {
+	mock := &MockCaptchaCache{ctrl: ctrl}
+	mock.recorder = &MockCaptchaCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCaptchaCache) EXPECT() *MockCaptchaCacheMockRecorder {
+	return m.recorder
+}
+
+// IncrSuspicious mocks base method.
+func (m *MockCaptchaCache) IncrSuspicious(ctx context.Context, biz, ip string, window time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrSuspicious", ctx, biz, ip, window)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrSuspicious indicates an expected call of IncrSuspicious.
+func (mr *MockCaptchaCacheMockRecorder) IncrSuspicious(ctx, biz, ip, window any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrSuspicious", reflect.TypeOf((*MockCaptchaCache)(nil).IncrSuspicious), ctx, biz, ip, window)
+}
+
+// SetAnswer mocks base method.
+func (m *MockCaptchaCache) SetAnswer(ctx context.Context, biz, id, typ, answer string, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAnswer", ctx, biz, id, typ, answer, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAnswer indicates an expected call of SetAnswer.
+func (mr *MockCaptchaCacheMockRecorder) SetAnswer(ctx, biz, id, typ, answer, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAnswer", reflect.TypeOf((*MockCaptchaCache)(nil).SetAnswer), ctx, biz, id, typ, answer, ttl)
+}
+
+// SetTicket mocks base method.
+func (m *MockCaptchaCache) SetTicket(ctx context.Context, biz, id, secret string, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTicket", ctx, biz, id, secret, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTicket indicates an expected call of SetTicket.
+func (mr *MockCaptchaCacheMockRecorder) SetTicket(ctx, biz, id, secret, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTicket", reflect.TypeOf((*MockCaptchaCache)(nil).SetTicket), ctx, biz, id, secret, ttl)
+}
+
+// Suspicious mocks base method.
+func (m *MockCaptchaCache) Suspicious(ctx context.Context, biz, ip string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Suspicious", ctx, biz, ip)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Suspicious indicates an expected call of Suspicious.
+func (mr *MockCaptchaCacheMockRecorder) Suspicious(ctx, biz, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Suspicious", reflect.TypeOf((*MockCaptchaCache)(nil).Suspicious), ctx, biz, ip)
+}
+
+// TakeAnswer mocks base method.
+func (m *MockCaptchaCache) TakeAnswer(ctx context.Context, biz, id string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeAnswer", ctx, biz, id)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// TakeAnswer indicates an expected call of TakeAnswer.
+func (mr *MockCaptchaCacheMockRecorder) TakeAnswer(ctx, biz, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeAnswer", reflect.TypeOf((*MockCaptchaCache)(nil).TakeAnswer), ctx, biz, id)
+}
+
+// TakeTicket mocks base method.
+func (m *MockCaptchaCache) TakeTicket(ctx context.Context, biz, id, secret string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeTicket", ctx, biz, id, secret)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeTicket indicates an expected call of TakeTicket.
+func (mr *MockCaptchaCacheMockRecorder) TakeTicket(ctx, biz, id, secret any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeTicket", reflect.TypeOf((*MockCaptchaCache)(nil).TakeTicket), ctx, biz, id, secret)
+}
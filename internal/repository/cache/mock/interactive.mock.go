@@ -12,6 +12,7 @@ package cachemocks
 import (
 	context "context"
 	reflect "reflect"
+	domain "webok/internal/domain"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -54,6 +55,54 @@ func (mr *MockInteractiveCacheMockRecorder) DecrLikeCntIfPresent(ctx, biz, id an
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecrLikeCntIfPresent", reflect.TypeOf((*MockInteractiveCache)(nil).DecrLikeCntIfPresent), ctx, biz, id)
 }
 
+// Del mocks base method.
+func (m *MockInteractiveCache) Del(ctx context.Context, refs ...domain.BizRef) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range refs {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Del", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Del indicates an expected call of Del.
+func (mr *MockInteractiveCacheMockRecorder) Del(ctx any, refs ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, refs...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockInteractiveCache)(nil).Del), varargs...)
+}
+
+// Get mocks base method.
+func (m *MockInteractiveCache) Get(ctx context.Context, biz string, id int64) (domain.Interactive, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, biz, id)
+	ret0, _ := ret[0].(domain.Interactive)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockInteractiveCacheMockRecorder) Get(ctx, biz, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockInteractiveCache)(nil).Get), ctx, biz, id)
+}
+
+// IncrCollectionCntIfPresent mocks base method.
+func (m *MockInteractiveCache) IncrCollectionCntIfPresent(ctx context.Context, biz string, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrCollectionCntIfPresent", ctx, biz, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrCollectionCntIfPresent indicates an expected call of IncrCollectionCntIfPresent.
+func (mr *MockInteractiveCacheMockRecorder) IncrCollectionCntIfPresent(ctx, biz, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrCollectionCntIfPresent", reflect.TypeOf((*MockInteractiveCache)(nil).IncrCollectionCntIfPresent), ctx, biz, id)
+}
+
 // IncrLikeCntIfPresent mocks base method.
 func (m *MockInteractiveCache) IncrLikeCntIfPresent(ctx context.Context, biz string, id int64) error {
 	m.ctrl.T.Helper()
@@ -81,3 +130,17 @@ func (mr *MockInteractiveCacheMockRecorder) IncrReadCntIfPresent(ctx, biz, id an
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrReadCntIfPresent", reflect.TypeOf((*MockInteractiveCache)(nil).IncrReadCntIfPresent), ctx, biz, id)
 }
+
+// Set mocks base method.
+func (m *MockInteractiveCache) Set(ctx context.Context, biz string, id int64, ie domain.Interactive) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Set", ctx, biz, id, ie)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Set indicates an expected call of Set.
+func (mr *MockInteractiveCacheMockRecorder) Set(ctx, biz, id, ie any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockInteractiveCache)(nil).Set), ctx, biz, id, ie)
+}
@@ -0,0 +1,86 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: login_attempt.go
+//
+// Generated by this command:
+//
+//	mockgen -source=login_attempt.go -package=cachemocks -destination=./mock/login_attempt.mock.go
+//
+
+// Package cachemocks is a generated GoMock package.
+package cachemocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webok/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLoginAttemptCache is a mock of LoginAttemptCache interface.
+type MockLoginAttemptCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginAttemptCacheMockRecorder
+	isgomock struct{}
+}
+
+// MockLoginAttemptCacheMockRecorder is the mock recorder for MockLoginAttemptCache.
+type MockLoginAttemptCacheMockRecorder struct {
+	mock *MockLoginAttemptCache
+}
+
+// NewMockLoginAttemptCache creates a new mock instance.
+func NewMockLoginAttemptCache(ctrl *gomock.Controller) *MockLoginAttemptCache {
+	mock := &MockLoginAttemptCache{ctrl: ctrl}
+	mock.recorder = &MockLoginAttemptCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginAttemptCache) EXPECT() *MockLoginAttemptCacheMockRecorder {
+	return m.recorder
+}
+
+// Fail mocks base method.
+func (m *MockLoginAttemptCache) Fail(ctx context.Context, scope domain.LoginScope, subject string, lockAfter int, p domain.LoginPolicy) (domain.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fail", ctx, scope, subject, lockAfter, p)
+	ret0, _ := ret[0].(domain.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Fail indicates an expected call of Fail.
+func (mr *MockLoginAttemptCacheMockRecorder) Fail(ctx, scope, subject, lockAfter, p any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*MockLoginAttemptCache)(nil).Fail), ctx, scope, subject, lockAfter, p)
+}
+
+// Get mocks base method.
+func (m *MockLoginAttemptCache) Get(ctx context.Context, scope domain.LoginScope, subject string) (domain.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, scope, subject)
+	ret0, _ := ret[0].(domain.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockLoginAttemptCacheMockRecorder) Get(ctx, scope, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockLoginAttemptCache)(nil).Get), ctx, scope, subject)
+}
+
+// Reset mocks base method.
+func (m *MockLoginAttemptCache) Reset(ctx context.Context, scope domain.LoginScope, subject string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", ctx, scope, subject)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockLoginAttemptCacheMockRecorder) Reset(ctx, scope, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockLoginAttemptCache)(nil).Reset), ctx, scope, subject)
+}
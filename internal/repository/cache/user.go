@@ -7,6 +7,7 @@ import (
 	"github.com/redis/go-redis/v9"
 	"time"
 	"webok/internal/domain"
+	"webok/pkg/cachex"
 )
 
 var ErrKeyNotExist = redis.Nil
@@ -15,6 +16,7 @@ var ErrKeyNotExist = redis.Nil
 type UserCache interface {
 	Get(ctx context.Context, uid int64) (*domain.User, error)
 	Set(ctx context.Context, du *domain.User) error
+	Del(ctx context.Context, uid int64) error
 }
 type RedisUserCache struct {
 	cmd        redis.Cmdable // 为什么使用接口：1.面向接口编程，eg：如何要兼容集群怎么办？
@@ -47,7 +49,11 @@ func (c *RedisUserCache) Set(ctx context.Context, du *domain.User) error {
 	if err != nil {
 		return err
 	}
-	return c.cmd.Set(ctx, key, data, c.expiration).Err()
+	return c.cmd.Set(ctx, key, data, cachex.Jitter(c.expiration, 0.2)).Err()
+}
+
+func (c *RedisUserCache) Del(ctx context.Context, uid int64) error {
+	return c.cmd.Del(ctx, c.key(uid)).Err()
 }
 
 func (c *RedisUserCache) key(uid int64) string {
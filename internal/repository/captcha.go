@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"webok/internal/repository/cache"
+)
+
+var ErrCaptchaNotFound = cache.ErrCaptchaNotFound
+
+//go:generate mockgen -source=captcha.go -package=repomocks -destination=./mock/captcha.mock.go
+type CaptchaRepository interface {
+	SetAnswer(ctx context.Context, biz, id, typ, answer string, ttl time.Duration) error
+	TakeAnswer(ctx context.Context, biz, id string) (typ string, answer string, err error)
+	SetTicket(ctx context.Context, biz, id, secret string, ttl time.Duration) error
+	TakeTicket(ctx context.Context, biz, id, secret string) (bool, error)
+	IncrSuspicious(ctx context.Context, biz, ip string, window time.Duration) error
+	Suspicious(ctx context.Context, biz, ip string) (int64, error)
+}
+
+type CachedCaptchaRepository struct {
+	cache cache.CaptchaCache
+}
+
+func NewCaptchaRepository(cache cache.CaptchaCache) CaptchaRepository {
+	return &CachedCaptchaRepository{cache: cache}
+}
+
+func (r *CachedCaptchaRepository) SetAnswer(ctx context.Context, biz, id, typ, answer string, ttl time.Duration) error {
+	return r.cache.SetAnswer(ctx, biz, id, typ, answer, ttl)
+}
+
+func (r *CachedCaptchaRepository) TakeAnswer(ctx context.Context, biz, id string) (string, string, error) {
+	return r.cache.TakeAnswer(ctx, biz, id)
+}
+
+func (r *CachedCaptchaRepository) SetTicket(ctx context.Context, biz, id, secret string, ttl time.Duration) error {
+	return r.cache.SetTicket(ctx, biz, id, secret, ttl)
+}
+
+func (r *CachedCaptchaRepository) TakeTicket(ctx context.Context, biz, id, secret string) (bool, error) {
+	return r.cache.TakeTicket(ctx, biz, id, secret)
+}
+
+func (r *CachedCaptchaRepository) IncrSuspicious(ctx context.Context, biz, ip string, window time.Duration) error {
+	return r.cache.IncrSuspicious(ctx, biz, ip, window)
+}
+
+func (r *CachedCaptchaRepository) Suspicious(ctx context.Context, biz, ip string) (int64, error) {
+	return r.cache.Suspicious(ctx, biz, ip)
+}
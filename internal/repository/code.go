@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 	"webok/internal/repository/cache"
 )
 
@@ -10,7 +11,7 @@ var ErrCodeSendTooMany = cache.ErrCodeSendTooMany
 
 //go:generate mockgen -source=code.go -package=repomocks -destination=./mock/code.mock.go
 type CodeRepository interface {
-	Set(ctx context.Context, biz, phone, code string) error
+	Set(ctx context.Context, biz, phone, code string, ttl, interval time.Duration, attempts int) error
 	Verify(ctx context.Context, biz, phone, code string) (bool, error)
 }
 
@@ -18,8 +19,9 @@ type CachedCodeRepository struct {
 	cache cache.CodeCache
 }
 
-func (c *CachedCodeRepository) Set(ctx context.Context, biz, phone, code string) error {
-	return c.cache.Set(ctx, biz, phone, code)
+func (c *CachedCodeRepository) Set(ctx context.Context, biz, phone, code string,
+	ttl, interval time.Duration, attempts int) error {
+	return c.cache.Set(ctx, biz, phone, code, ttl, interval, attempts)
 }
 
 func (c *CachedCodeRepository) Verify(ctx context.Context, biz, phone, code string) (bool, error) {
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"webok/internal/domain"
+	"webok/internal/repository/cache"
+	"webok/internal/repository/dao"
+)
+
+var (
+	ErrCodeQuotaExceeded = cache.ErrCodeQuotaExceeded
+	ErrCodePhoneQuota    = cache.ErrCodePhoneQuota
+	ErrCodeIPQuota       = cache.ErrCodeIPQuota
+	ErrCodeGlobalQuota   = cache.ErrCodeGlobalQuota
+	ErrCodeTotalQuota    = cache.ErrCodeTotalQuota
+)
+
+//go:generate mockgen -source=code_quota.go -package=repomocks -destination=./mock/code_quota.mock.go
+type CodeQuotaRepository interface {
+	Acquire(ctx context.Context, biz, phone, ip string, quota domain.CodeQuota) (domain.CodeQuotaUsage, error)
+	Release(ctx context.Context, u domain.CodeQuotaUsage) error
+	Audit(ctx context.Context, a domain.CodeAudit) error
+}
+
+type CachedCodeQuotaRepository struct {
+	cache cache.CodeQuotaCache
+	dao   dao.CodeAuditDAO
+}
+
+func NewCodeQuotaRepository(cache cache.CodeQuotaCache, dao dao.CodeAuditDAO) CodeQuotaRepository {
+	return &CachedCodeQuotaRepository{cache: cache, dao: dao}
+}
+
+func (r *CachedCodeQuotaRepository) Acquire(ctx context.Context, biz, phone, ip string,
+	quota domain.CodeQuota) (domain.CodeQuotaUsage, error) {
+	return r.cache.Acquire(ctx, biz, phone, ip, quota)
+}
+
+func (r *CachedCodeQuotaRepository) Release(ctx context.Context, u domain.CodeQuotaUsage) error {
+	return r.cache.Release(ctx, u)
+}
+
+func (r *CachedCodeQuotaRepository) Audit(ctx context.Context, a domain.CodeAudit) error {
+	return r.dao.Insert(ctx, dao.CodeAudit{
+		Biz:    a.Biz,
+		Phone:  a.Phone,
+		IP:     a.IP,
+		Reason: a.Reason,
+	})
+}
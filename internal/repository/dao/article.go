@@ -23,6 +23,7 @@ type Article struct {
 
 type PublishedArticle Article
 
+//go:generate mockgen -source=article.go -package=daomocks -destination=./mock/article.mock.go
 type ArticleDAO interface {
 	Insert(ctx context.Context, article Article) (int64, error)
 	UpdateById(ctx context.Context, entity Article) error
@@ -31,6 +32,11 @@ type ArticleDAO interface {
 	GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]Article, error)
 	GetById(ctx context.Context, id int64) (Article, error)
 	GetPubById(ctx context.Context, id int64) (PublishedArticle, error)
+	// ListIds 按照 ID 升序返回大于 fromId 的文章 ID，重建布隆过滤器用
+	ListIds(ctx context.Context, fromId int64, limit int) ([]int64, error)
+	ListPubIds(ctx context.Context, fromId int64, limit int) ([]int64, error)
+	// TransferAuthor 合并账号的时候把 source 的文章都转给 target，返回转移了的文章 ID
+	TransferAuthor(ctx context.Context, sourceId, targetId int64) ([]int64, error)
 }
 
 type ArticleGORMDAO struct {
@@ -46,6 +52,46 @@ func (a *ArticleGORMDAO) GetPubById(ctx context.Context, id int64) (PublishedArt
 	return res, nil
 }
 
+func (a *ArticleGORMDAO) ListIds(ctx context.Context, fromId int64, limit int) ([]int64, error) {
+	return a.listIds(ctx, &Article{}, fromId, limit)
+}
+
+func (a *ArticleGORMDAO) ListPubIds(ctx context.Context, fromId int64, limit int) ([]int64, error) {
+	return a.listIds(ctx, &PublishedArticle{}, fromId, limit)
+}
+
+func (a *ArticleGORMDAO) listIds(ctx context.Context, model any, fromId int64, limit int) ([]int64, error) {
+	ids := make([]int64, 0, limit)
+	err := a.db.WithContext(ctx).Model(model).
+		Where("id > ?", fromId).
+		Order("id ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (a *ArticleGORMDAO) TransferAuthor(ctx context.Context, sourceId, targetId int64) ([]int64, error) {
+	return a.transferAuthor(ctx, &PublishedArticle{}, sourceId, targetId)
+}
+
+// transferAuthor pubModel 是线上库的表，S3 版本的线上库是另外一张表
+func (a *ArticleGORMDAO) transferAuthor(ctx context.Context, pubModel any, sourceId, targetId int64) ([]int64, error) {
+	var ids []int64
+	err := a.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Article{}).Where("author_id = ?", sourceId).Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		moveTo := map[string]any{"author_id": targetId, "utime": time.Now().UnixMilli()}
+		err = tx.Model(&Article{}).Where("author_id = ?", sourceId).Updates(moveTo).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(pubModel).Where("author_id = ?", sourceId).Updates(moveTo).Error
+	})
+	return ids, err
+}
+
 func (a *ArticleGORMDAO) GetById(ctx context.Context, id int64) (Article, error) {
 	var art Article
 	err := a.db.WithContext(ctx).Where("id = ?", id).First(&art).Error
@@ -31,6 +31,14 @@ type ArticleS3DAO struct {
 func NewArticleS3DAO(db *gorm.DB, s3 *s3.S3) ArticleDAO {
 	return &ArticleS3DAO{ArticleGORMDAO: ArticleGORMDAO{db: db}, s3: s3}
 }
+func (a *ArticleS3DAO) ListPubIds(ctx context.Context, fromId int64, limit int) ([]int64, error) {
+	return a.listIds(ctx, &PublishedArticleS3{}, fromId, limit)
+}
+
+func (a *ArticleS3DAO) TransferAuthor(ctx context.Context, sourceId, targetId int64) ([]int64, error) {
+	return a.transferAuthor(ctx, &PublishedArticleS3{}, sourceId, targetId)
+}
+
 func (a *ArticleS3DAO) Sync(ctx context.Context, article Article) (int64, error) {
 	var (
 		id  int64
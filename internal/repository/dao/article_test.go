@@ -0,0 +1,93 @@
+package dao
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestArticleGORMDAO_TransferAuthor(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(t *testing.T) *sql.DB
+		wantIds []int64
+		wantErr error
+	}{
+		{
+			name: "转移成功",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT "id" FROM "articles" WHERE author_id =`).
+					WithArgs(int64(2)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3).AddRow(4))
+				mock.ExpectExec(`UPDATE "articles" SET .* WHERE author_id =`).
+					WithArgs(int64(1), sqlmock.AnyArg(), int64(2)).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(`UPDATE "published_articles" SET .* WHERE author_id =`).
+					WithArgs(int64(1), sqlmock.AnyArg(), int64(2)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			wantIds: []int64{3, 4},
+		},
+		{
+			name: "没有文章",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT "id" FROM "articles" WHERE author_id =`).
+					WithArgs(int64(2)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+				mock.ExpectCommit()
+				return db
+			},
+			wantIds: []int64{},
+		},
+		{
+			name: "线上库更新失败",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT "id" FROM "articles" WHERE author_id =`).
+					WithArgs(int64(2)).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+				mock.ExpectExec(`UPDATE "articles" SET .* WHERE author_id =`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`UPDATE "published_articles" SET .* WHERE author_id =`).
+					WillReturnError(errors.New("db error"))
+				mock.ExpectRollback()
+				return db
+			},
+			wantIds: []int64{3},
+			wantErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := gorm.Open(postgres.New(postgres.Config{
+				Conn: tc.mock(t),
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			assert.NoError(t, err)
+			dao := NewArticleGORMDAO(db)
+			ids, err := dao.TransferAuthor(context.Background(), 2, 1)
+			assert.Equal(t, tc.wantErr, err)
+			if err == nil {
+				assert.ElementsMatch(t, tc.wantIds, ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+//go:generate mockgen -source=code_audit.go -package=daomocks -destination=./mock/code_audit.mock.go
+type CodeAuditDAO interface {
+	Insert(ctx context.Context, a CodeAudit) error
+}
+
+type GORMCodeAuditDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMCodeAuditDAO(db *gorm.DB) CodeAuditDAO {
+	return &GORMCodeAuditDAO{db: db}
+}
+
+func (dao *GORMCodeAuditDAO) Insert(ctx context.Context, a CodeAudit) error {
+	a.Ctime = time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Create(&a).Error
+}
+
+// CodeAudit 被拦截的发送验证码请求，用来排查短信轰炸
+type CodeAudit struct {
+	Id     int64  `gorm:"primaryKey,autoIncrement"`
+	Biz    string `gorm:"type:varchar(64)"`
+	Phone  string `gorm:"type:varchar(32);index"`
+	IP     string `gorm:"type:varchar(64);index"`
+	Reason string `gorm:"type:varchar(32)"`
+	Ctime  int64  `gorm:"index"`
+}
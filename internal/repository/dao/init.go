@@ -13,11 +13,16 @@ func InitTables(db *gorm.DB) error {
 	// 严格来说，这个不是优秀实践
 	return db.AutoMigrate(
 		&User{},
+		&ExternalIdentity{},
 		&Article{},
 		&PublishedArticle{},
 		&Interactive{},
 		&UserLikeBiz{},
-		&UserCollectionBiz{})
+		&UserCollectionBiz{},
+		&CodeAudit{},
+		&TwoFactor{},
+		&LoginAudit{},
+		&SecurityEvent{})
 }
 
 func InitCollection(mdb *mongo.Database) {
@@ -0,0 +1,39 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"time"
+)
+
+//go:generate mockgen -source=login_audit.go -package=daomocks -destination=./mock/login_audit.mock.go
+type LoginAuditDAO interface {
+	Insert(ctx context.Context, a LoginAudit) error
+}
+
+type GORMLoginAuditDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMLoginAuditDAO(db *gorm.DB) LoginAuditDAO {
+	return &GORMLoginAuditDAO{db: db}
+}
+
+func (dao *GORMLoginAuditDAO) Insert(ctx context.Context, a LoginAudit) error {
+	a.Ctime = time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Create(&a).Error
+}
+
+// LoginAudit 登录相关的安全事件，用来排查撞库
+type LoginAudit struct {
+	Id int64 `gorm:"primaryKey,autoIncrement"`
+	// Scope account 或者 ip，Subject 是对应的账号或者 IP
+	Scope   string `gorm:"type:varchar(16)"`
+	Subject string `gorm:"type:varchar(128);index"`
+	IP      string `gorm:"type:varchar(64);index"`
+	Event   string `gorm:"type:varchar(32)"`
+	Level   int
+	// LockMs 锁定了多少毫秒
+	LockMs int64
+	Ctime  int64 `gorm:"index"`
+}
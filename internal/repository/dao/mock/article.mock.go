@@ -0,0 +1,191 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: article.go
+//
+// Generated by this command:
+//
+//	mockgen -source=article.go -package=daomocks -destination=./mock/article.mock.go
+//
+
+// Package daomocks is a generated GoMock package.
+package daomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webok/internal/domain"
+	dao "webok/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockArticleDAO is a mock of ArticleDAO interface.
+type MockArticleDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockArticleDAOMockRecorder
+	isgomock struct{}
+}
+
+// MockArticleDAOMockRecorder is the mock recorder for MockArticleDAO.
+type MockArticleDAOMockRecorder struct {
+	mock *MockArticleDAO
+}
+
+// NewMockArticleDAO creates a new mock instance.
+func NewMockArticleDAO(ctrl *gomock.Controller) *MockArticleDAO {
+	mock := &MockArticleDAO{ctrl: ctrl}
+	mock.recorder = &MockArticleDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockArticleDAO) EXPECT() *MockArticleDAOMockRecorder {
+	return m.recorder
+}
+
+// GetByAuthor mocks base method.
+func (m *MockArticleDAO) GetByAuthor(ctx context.Context, uid int64, offset, limit int) ([]dao.Article, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByAuthor", ctx, uid, offset, limit)
+	ret0, _ := ret[0].([]dao.Article)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByAuthor indicates an expected call of GetByAuthor.
+func (mr *MockArticleDAOMockRecorder) GetByAuthor(ctx, uid, offset, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByAuthor", reflect.TypeOf((*MockArticleDAO)(nil).GetByAuthor), ctx, uid, offset, limit)
+}
+
+// GetById mocks base method.
+func (m *MockArticleDAO) GetById(ctx context.Context, id int64) (dao.Article, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetById", ctx, id)
+	ret0, _ := ret[0].(dao.Article)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetById indicates an expected call of GetById.
+func (mr *MockArticleDAOMockRecorder) GetById(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetById", reflect.TypeOf((*MockArticleDAO)(nil).GetById), ctx, id)
+}
+
+// GetPubById mocks base method.
+func (m *MockArticleDAO) GetPubById(ctx context.Context, id int64) (dao.PublishedArticle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPubById", ctx, id)
+	ret0, _ := ret[0].(dao.PublishedArticle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPubById indicates an expected call of GetPubById.
+func (mr *MockArticleDAOMockRecorder) GetPubById(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPubById", reflect.TypeOf((*MockArticleDAO)(nil).GetPubById), ctx, id)
+}
+
+// Insert mocks base method.
+func (m *MockArticleDAO) Insert(ctx context.Context, article dao.Article) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", ctx, article)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockArticleDAOMockRecorder) Insert(ctx, article any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockArticleDAO)(nil).Insert), ctx, article)
+}
+
+// ListIds mocks base method.
+func (m *MockArticleDAO) ListIds(ctx context.Context, fromId int64, limit int) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListIds", ctx, fromId, limit)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListIds indicates an expected call of ListIds.
+func (mr *MockArticleDAOMockRecorder) ListIds(ctx, fromId, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListIds", reflect.TypeOf((*MockArticleDAO)(nil).ListIds), ctx, fromId, limit)
+}
+
+// ListPubIds mocks base method.
+func (m *MockArticleDAO) ListPubIds(ctx context.Context, fromId int64, limit int) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPubIds", ctx, fromId, limit)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPubIds indicates an expected call of ListPubIds.
+func (mr *MockArticleDAOMockRecorder) ListPubIds(ctx, fromId, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPubIds", reflect.TypeOf((*MockArticleDAO)(nil).ListPubIds), ctx, fromId, limit)
+}
+
+// Sync mocks base method.
+func (m *MockArticleDAO) Sync(ctx context.Context, article dao.Article) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sync", ctx, article)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Sync indicates an expected call of Sync.
+func (mr *MockArticleDAOMockRecorder) Sync(ctx, article any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sync", reflect.TypeOf((*MockArticleDAO)(nil).Sync), ctx, article)
+}
+
+// SyncStatus mocks base method.
+func (m *MockArticleDAO) SyncStatus(ctx context.Context, authorId, Id int64, status domain.ArticleStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SyncStatus", ctx, authorId, Id, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SyncStatus indicates an expected call of SyncStatus.
+func (mr *MockArticleDAOMockRecorder) SyncStatus(ctx, authorId, Id, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncStatus", reflect.TypeOf((*MockArticleDAO)(nil).SyncStatus), ctx, authorId, Id, status)
+}
+
+// TransferAuthor mocks base method.
+func (m *MockArticleDAO) TransferAuthor(ctx context.Context, sourceId, targetId int64) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferAuthor", ctx, sourceId, targetId)
+	ret0, _ := ret[0].([]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TransferAuthor indicates an expected call of TransferAuthor.
+func (mr *MockArticleDAOMockRecorder) TransferAuthor(ctx, sourceId, targetId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferAuthor", reflect.TypeOf((*MockArticleDAO)(nil).TransferAuthor), ctx, sourceId, targetId)
+}
+
+// UpdateById mocks base method.
+func (m *MockArticleDAO) UpdateById(ctx context.Context, entity dao.Article) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateById", ctx, entity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateById indicates an expected call of UpdateById.
+func (mr *MockArticleDAOMockRecorder) UpdateById(ctx, entity any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateById", reflect.TypeOf((*MockArticleDAO)(nil).UpdateById), ctx, entity)
+}
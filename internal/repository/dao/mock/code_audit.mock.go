@@ -0,0 +1,56 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: code_audit.go
+//
+// Generated by this command:
+//
+//	mockgen -source=code_audit.go -package=daomocks -destination=./mock/code_audit.mock.go
+//
+
+// Package daomocks is a generated GoMock package.
+package daomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webok/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCodeAuditDAO is a mock of CodeAuditDAO interface.
+type MockCodeAuditDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockCodeAuditDAOMockRecorder
+	isgomock struct{}
+}
+
+// MockCodeAuditDAOMockRecorder is the mock recorder for MockCodeAuditDAO.
+type MockCodeAuditDAOMockRecorder struct {
+	mock *MockCodeAuditDAO
+}
+
+// NewMockCodeAuditDAO creates a new mock instance.
+func NewMockCodeAuditDAO(ctrl *gomock.Controller) *MockCodeAuditDAO {
+	mock := &MockCodeAuditDAO{ctrl: ctrl}
+	mock.recorder = &MockCodeAuditDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCodeAuditDAO) EXPECT() *MockCodeAuditDAOMockRecorder {
+	return m.recorder
+}
+
+// Insert mocks base method.
+func (m *MockCodeAuditDAO) Insert(ctx context.Context, a dao.CodeAudit) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", ctx, a)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockCodeAuditDAOMockRecorder) Insert(ctx, a any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockCodeAuditDAO)(nil).Insert), ctx, a)
+}
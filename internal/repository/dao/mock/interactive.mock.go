@@ -0,0 +1,157 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: interactive.go
+//
+// Generated by this command:
+//
+//	mockgen -source=interactive.go -package=daomocks -destination=./mock/interactive.mock.go
+//
+
+// Package daomocks is a generated GoMock package.
+package daomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webok/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockInteractiveDao is a mock of InteractiveDao interface.
+type MockInteractiveDao struct {
+	ctrl     *gomock.Controller
+	recorder *MockInteractiveDaoMockRecorder
+	isgomock struct{}
+}
+
+// MockInteractiveDaoMockRecorder is the mock recorder for MockInteractiveDao.
+type MockInteractiveDaoMockRecorder struct {
+	mock *MockInteractiveDao
+}
+
+// NewMockInteractiveDao creates a new mock instance.
+func NewMockInteractiveDao(ctrl *gomock.Controller) *MockInteractiveDao {
+	mock := &MockInteractiveDao{ctrl: ctrl}
+	mock.recorder = &MockInteractiveDaoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockInteractiveDao) EXPECT() *MockInteractiveDaoMockRecorder {
+	return m.recorder
+}
+
+// BatchIncrReadCnt mocks base method.
+func (m *MockInteractiveDao) BatchIncrReadCnt(ctx context.Context, bizs []string, bizIds []int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchIncrReadCnt", ctx, bizs, bizIds)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BatchIncrReadCnt indicates an expected call of BatchIncrReadCnt.
+func (mr *MockInteractiveDaoMockRecorder) BatchIncrReadCnt(ctx, bizs, bizIds any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchIncrReadCnt", reflect.TypeOf((*MockInteractiveDao)(nil).BatchIncrReadCnt), ctx, bizs, bizIds)
+}
+
+// DecrLickCnt mocks base method.
+func (m *MockInteractiveDao) DecrLickCnt(ctx context.Context, biz string, id, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecrLickCnt", ctx, biz, id, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DecrLickCnt indicates an expected call of DecrLickCnt.
+func (mr *MockInteractiveDaoMockRecorder) DecrLickCnt(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecrLickCnt", reflect.TypeOf((*MockInteractiveDao)(nil).DecrLickCnt), ctx, biz, id, uid)
+}
+
+// Get mocks base method.
+func (m *MockInteractiveDao) Get(ctx context.Context, biz string, id int64) (dao.Interactive, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, biz, id)
+	ret0, _ := ret[0].(dao.Interactive)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockInteractiveDaoMockRecorder) Get(ctx, biz, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockInteractiveDao)(nil).Get), ctx, biz, id)
+}
+
+// GetCollectionInfo mocks base method.
+func (m *MockInteractiveDao) GetCollectionInfo(ctx context.Context, biz string, id, uid int64) (dao.UserCollectionBiz, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCollectionInfo", ctx, biz, id, uid)
+	ret0, _ := ret[0].(dao.UserCollectionBiz)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCollectionInfo indicates an expected call of GetCollectionInfo.
+func (mr *MockInteractiveDaoMockRecorder) GetCollectionInfo(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCollectionInfo", reflect.TypeOf((*MockInteractiveDao)(nil).GetCollectionInfo), ctx, biz, id, uid)
+}
+
+// GetLikedInfo mocks base method.
+func (m *MockInteractiveDao) GetLikedInfo(ctx context.Context, biz string, id, uid int64) (dao.UserLikeBiz, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLikedInfo", ctx, biz, id, uid)
+	ret0, _ := ret[0].(dao.UserLikeBiz)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLikedInfo indicates an expected call of GetLikedInfo.
+func (mr *MockInteractiveDaoMockRecorder) GetLikedInfo(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLikedInfo", reflect.TypeOf((*MockInteractiveDao)(nil).GetLikedInfo), ctx, biz, id, uid)
+}
+
+// IncrLickCnt mocks base method.
+func (m *MockInteractiveDao) IncrLickCnt(ctx context.Context, biz string, id, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrLickCnt", ctx, biz, id, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrLickCnt indicates an expected call of IncrLickCnt.
+func (mr *MockInteractiveDaoMockRecorder) IncrLickCnt(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrLickCnt", reflect.TypeOf((*MockInteractiveDao)(nil).IncrLickCnt), ctx, biz, id, uid)
+}
+
+// IncrReadCnt mocks base method.
+func (m *MockInteractiveDao) IncrReadCnt(ctx context.Context, biz string, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrReadCnt", ctx, biz, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrReadCnt indicates an expected call of IncrReadCnt.
+func (mr *MockInteractiveDaoMockRecorder) IncrReadCnt(ctx, biz, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrReadCnt", reflect.TypeOf((*MockInteractiveDao)(nil).IncrReadCnt), ctx, biz, id)
+}
+
+// InsertCollectionBiz mocks base method.
+func (m *MockInteractiveDao) InsertCollectionBiz(ctx context.Context, biz string, id, cid, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertCollectionBiz", ctx, biz, id, cid, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertCollectionBiz indicates an expected call of InsertCollectionBiz.
+func (mr *MockInteractiveDaoMockRecorder) InsertCollectionBiz(ctx, biz, id, cid, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertCollectionBiz", reflect.TypeOf((*MockInteractiveDao)(nil).InsertCollectionBiz), ctx, biz, id, cid, uid)
+}
@@ -0,0 +1,56 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: login_audit.go
+//
+// Generated by this command:
+//
+//	mockgen -source=login_audit.go -package=daomocks -destination=./mock/login_audit.mock.go
+//
+
+// Package daomocks is a generated GoMock package.
+package daomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webok/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLoginAuditDAO is a mock of LoginAuditDAO interface.
+type MockLoginAuditDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginAuditDAOMockRecorder
+	isgomock struct{}
+}
+
+// MockLoginAuditDAOMockRecorder is the mock recorder for MockLoginAuditDAO.
+type MockLoginAuditDAOMockRecorder struct {
+	mock *MockLoginAuditDAO
+}
+
+// NewMockLoginAuditDAO creates a new mock instance.
+func NewMockLoginAuditDAO(ctrl *gomock.Controller) *MockLoginAuditDAO {
+	mock := &MockLoginAuditDAO{ctrl: ctrl}
+	mock.recorder = &MockLoginAuditDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginAuditDAO) EXPECT() *MockLoginAuditDAOMockRecorder {
+	return m.recorder
+}
+
+// Insert mocks base method.
+func (m *MockLoginAuditDAO) Insert(ctx context.Context, a dao.LoginAudit) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Insert", ctx, a)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Insert indicates an expected call of Insert.
+func (mr *MockLoginAuditDAOMockRecorder) Insert(ctx, a any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockLoginAuditDAO)(nil).Insert), ctx, a)
+}
@@ -0,0 +1,71 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: security_event.go
+//
+// Generated by this command:
+//
+//	mockgen -source=security_event.go -package=daomocks -destination=./mock/security_event.mock.go
+//
+
+// Package daomocks is a generated GoMock package.
+package daomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	dao "webok/internal/repository/dao"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockSecurityEventDAO is a mock of SecurityEventDAO interface.
+type MockSecurityEventDAO struct {
+	ctrl     *gomock.Controller
+	recorder *MockSecurityEventDAOMockRecorder
+	isgomock struct{}
+}
+
+// MockSecurityEventDAOMockRecorder is the mock recorder for MockSecurityEventDAO.
+type MockSecurityEventDAOMockRecorder struct {
+	mock *MockSecurityEventDAO
+}
+
+// NewMockSecurityEventDAO creates a new mock instance.
+func NewMockSecurityEventDAO(ctrl *gomock.Controller) *MockSecurityEventDAO {
+	mock := &MockSecurityEventDAO{ctrl: ctrl}
+	mock.recorder = &MockSecurityEventDAOMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSecurityEventDAO) EXPECT() *MockSecurityEventDAOMockRecorder {
+	return m.recorder
+}
+
+// BatchInsert mocks base method.
+func (m *MockSecurityEventDAO) BatchInsert(ctx context.Context, events []dao.SecurityEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchInsert", ctx, events)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BatchInsert indicates an expected call of BatchInsert.
+func (mr *MockSecurityEventDAOMockRecorder) BatchInsert(ctx, events any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchInsert", reflect.TypeOf((*MockSecurityEventDAO)(nil).BatchInsert), ctx, events)
+}
+
+// Find mocks base method.
+func (m *MockSecurityEventDAO) Find(ctx context.Context, uid int64, ip string, beforeId int64, limit int) ([]dao.SecurityEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", ctx, uid, ip, beforeId, limit)
+	ret0, _ := ret[0].([]dao.SecurityEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockSecurityEventDAOMockRecorder) Find(ctx, uid, ip, beforeId, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockSecurityEventDAO)(nil).Find), ctx, uid, ip, beforeId, limit)
+}
@@ -12,6 +12,7 @@ package daomocks
 import (
 	context "context"
 	reflect "reflect"
+	domain "webok/internal/domain"
 	dao "webok/internal/repository/dao"
 
 	gomock "go.uber.org/mock/gomock"
@@ -71,6 +72,21 @@ func (mr *MockUserDAOMockRecorder) FindById(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockUserDAO)(nil).FindById), ctx, id)
 }
 
+// FindByOAuth2 mocks base method.
+func (m *MockUserDAO) FindByOAuth2(ctx context.Context, provider, subject string) (dao.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByOAuth2", ctx, provider, subject)
+	ret0, _ := ret[0].(dao.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByOAuth2 indicates an expected call of FindByOAuth2.
+func (mr *MockUserDAOMockRecorder) FindByOAuth2(ctx, provider, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByOAuth2", reflect.TypeOf((*MockUserDAO)(nil).FindByOAuth2), ctx, provider, subject)
+}
+
 // FindByPhone mocks base method.
 func (m *MockUserDAO) FindByPhone(ctx context.Context, phone string) (*dao.User, error) {
 	m.ctrl.T.Helper()
@@ -101,6 +117,21 @@ func (mr *MockUserDAOMockRecorder) FindByWechat(ctx, openId any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByWechat", reflect.TypeOf((*MockUserDAO)(nil).FindByWechat), ctx, openId)
 }
 
+// FindExternalIdentities mocks base method.
+func (m *MockUserDAO) FindExternalIdentities(ctx context.Context, uid int64) ([]dao.ExternalIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindExternalIdentities", ctx, uid)
+	ret0, _ := ret[0].([]dao.ExternalIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindExternalIdentities indicates an expected call of FindExternalIdentities.
+func (mr *MockUserDAOMockRecorder) FindExternalIdentities(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindExternalIdentities", reflect.TypeOf((*MockUserDAO)(nil).FindExternalIdentities), ctx, uid)
+}
+
 // Insert mocks base method.
 func (m *MockUserDAO) Insert(ctx context.Context, user *dao.User) error {
 	m.ctrl.T.Helper()
@@ -115,6 +146,68 @@ func (mr *MockUserDAOMockRecorder) Insert(ctx, user any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockUserDAO)(nil).Insert), ctx, user)
 }
 
+// InsertWithOAuth2 mocks base method.
+func (m *MockUserDAO) InsertWithOAuth2(ctx context.Context, u *dao.User, identity dao.ExternalIdentity) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertWithOAuth2", ctx, u, identity)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertWithOAuth2 indicates an expected call of InsertWithOAuth2.
+func (mr *MockUserDAOMockRecorder) InsertWithOAuth2(ctx, u, identity any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertWithOAuth2", reflect.TypeOf((*MockUserDAO)(nil).InsertWithOAuth2), ctx, u, identity)
+}
+
+// Merge mocks base method.
+func (m *MockUserDAO) Merge(ctx context.Context, targetId, sourceId int64) ([]domain.BizRef, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Merge", ctx, targetId, sourceId)
+	ret0, _ := ret[0].([]domain.BizRef)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Merge indicates an expected call of Merge.
+func (mr *MockUserDAOMockRecorder) Merge(ctx, targetId, sourceId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merge", reflect.TypeOf((*MockUserDAO)(nil).Merge), ctx, targetId, sourceId)
+}
+
+// Unlink mocks base method.
+func (m *MockUserDAO) Unlink(ctx context.Context, id int64, columns ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, id}
+	for _, a := range columns {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Unlink", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlink indicates an expected call of Unlink.
+func (mr *MockUserDAOMockRecorder) Unlink(ctx, id any, columns ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, id}, columns...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlink", reflect.TypeOf((*MockUserDAO)(nil).Unlink), varargs...)
+}
+
+// UnlinkOAuth2 mocks base method.
+func (m *MockUserDAO) UnlinkOAuth2(ctx context.Context, id int64, provider, subject string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlinkOAuth2", ctx, id, provider, subject)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlinkOAuth2 indicates an expected call of UnlinkOAuth2.
+func (mr *MockUserDAOMockRecorder) UnlinkOAuth2(ctx, id, provider, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlinkOAuth2", reflect.TypeOf((*MockUserDAO)(nil).UnlinkOAuth2), ctx, id, provider, subject)
+}
+
 // UpdateById mocks base method.
 func (m *MockUserDAO) UpdateById(ctx context.Context, u *dao.User) error {
 	m.ctrl.T.Helper()
@@ -128,3 +221,73 @@ func (mr *MockUserDAOMockRecorder) UpdateById(ctx, u any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateById", reflect.TypeOf((*MockUserDAO)(nil).UpdateById), ctx, u)
 }
+
+// UpdateEmail mocks base method.
+func (m *MockUserDAO) UpdateEmail(ctx context.Context, id int64, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEmail", ctx, id, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateEmail indicates an expected call of UpdateEmail.
+func (mr *MockUserDAOMockRecorder) UpdateEmail(ctx, id, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEmail", reflect.TypeOf((*MockUserDAO)(nil).UpdateEmail), ctx, id, email)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockUserDAO) UpdatePassword(ctx context.Context, id int64, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, id, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockUserDAOMockRecorder) UpdatePassword(ctx, id, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockUserDAO)(nil).UpdatePassword), ctx, id, password)
+}
+
+// UpdatePhone mocks base method.
+func (m *MockUserDAO) UpdatePhone(ctx context.Context, id int64, phone string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePhone", ctx, id, phone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePhone indicates an expected call of UpdatePhone.
+func (mr *MockUserDAOMockRecorder) UpdatePhone(ctx, id, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePhone", reflect.TypeOf((*MockUserDAO)(nil).UpdatePhone), ctx, id, phone)
+}
+
+// UpdateWechat mocks base method.
+func (m *MockUserDAO) UpdateWechat(ctx context.Context, id int64, openId, unionId string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWechat", ctx, id, openId, unionId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWechat indicates an expected call of UpdateWechat.
+func (mr *MockUserDAOMockRecorder) UpdateWechat(ctx, id, openId, unionId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWechat", reflect.TypeOf((*MockUserDAO)(nil).UpdateWechat), ctx, id, openId, unionId)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockUserDAO) VerifyEmail(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockUserDAOMockRecorder) VerifyEmail(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockUserDAO)(nil).VerifyEmail), ctx, id)
+}
@@ -23,6 +23,64 @@ func (m *MongoDBArticleDao) GetPubById(ctx context.Context, id int64) (Published
 	panic("implement me")
 }
 
+func (m *MongoDBArticleDao) ListIds(ctx context.Context, fromId int64, limit int) ([]int64, error) {
+	return m.listIds(ctx, m.col, fromId, limit)
+}
+
+func (m *MongoDBArticleDao) ListPubIds(ctx context.Context, fromId int64, limit int) ([]int64, error) {
+	return m.listIds(ctx, m.liveCol, fromId, limit)
+}
+
+func (m *MongoDBArticleDao) listIds(ctx context.Context, col *mongo.Collection, fromId int64, limit int) ([]int64, error) {
+	filter := bson.D{{Key: "id", Value: bson.D{{Key: "$gt", Value: fromId}}}}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "id", Value: 1}}).
+		SetLimit(int64(limit)).
+		SetProjection(bson.D{{Key: "id", Value: 1}})
+	cursor, err := col.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var arts []Article
+	err = cursor.All(ctx, &arts)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(arts))
+	for _, art := range arts {
+		ids = append(ids, art.ID)
+	}
+	return ids, nil
+}
+
+func (m *MongoDBArticleDao) TransferAuthor(ctx context.Context, sourceId, targetId int64) ([]int64, error) {
+	filter := bson.D{{Key: "author_id", Value: sourceId}}
+	cursor, err := m.col.Find(ctx, filter, options.Find().SetProjection(bson.D{{Key: "id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var arts []Article
+	err = cursor.All(ctx, &arts)
+	if err != nil || len(arts) == 0 {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(arts))
+	for _, art := range arts {
+		ids = append(ids, art.ID)
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "author_id", Value: targetId},
+		{Key: "utime", Value: time.Now().UnixMilli()},
+	}}}
+	// 没有事务，先改线上库，制作库还没改完的话重试的时候还能查到这些文章
+	_, err = m.liveCol.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return nil, err
+	}
+	_, err = m.col.UpdateMany(ctx, filter, update)
+	return ids, err
+}
+
 func (m *MongoDBArticleDao) GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]Article, error) {
 	//TODO implement me
 	panic("implement me")
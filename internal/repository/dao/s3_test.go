@@ -17,18 +17,17 @@ import (
 
 const region = "ap-chengdu"
 const url = "https://webook-1303500761.cos.ap-chengdu.myqcloud.com"
-const bucket = "webook-1303500761"
 
 // 你可以用这个来单独测试你的 OSS 配置对不对，有没有权限
 func TestS3(t *testing.T) {
 	// 腾讯云中对标 s3 和 OSS 的产品叫做 COS
 	cosId, ok := os.LookupEnv("COS_APP_ID")
 	if !ok {
-		panic("没有找到环境变量 COS_APP_ID ")
+		t.Skip("没有找到环境变量 COS_APP_ID ")
 	}
 	cosKey, ok := os.LookupEnv("COS_APP_SECRET")
 	if !ok {
-		panic("没有找到环境变量 COS_APP_SECRET")
+		t.Skip("没有找到环境变量 COS_APP_SECRET")
 	}
 	sess, err := session.NewSession(&aws.Config{
 		Credentials: credentials.NewStaticCredentials(cosId, cosKey, ""),
@@ -0,0 +1,55 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+//go:generate mockgen -source=security_event.go -package=daomocks -destination=./mock/security_event.mock.go
+type SecurityEventDAO interface {
+	BatchInsert(ctx context.Context, events []SecurityEvent) error
+	// Find uid 和 ip 为空的条件不生效，按照 id 倒序
+	Find(ctx context.Context, uid int64, ip string, beforeId int64, limit int) ([]SecurityEvent, error)
+}
+
+type GORMSecurityEventDAO struct {
+	db *gorm.DB
+}
+
+func NewGORMSecurityEventDAO(db *gorm.DB) SecurityEventDAO {
+	return &GORMSecurityEventDAO{db: db}
+}
+
+func (dao *GORMSecurityEventDAO) BatchInsert(ctx context.Context, events []SecurityEvent) error {
+	return dao.db.WithContext(ctx).Create(&events).Error
+}
+
+func (dao *GORMSecurityEventDAO) Find(ctx context.Context, uid int64, ip string, beforeId int64, limit int) ([]SecurityEvent, error) {
+	tx := dao.db.WithContext(ctx)
+	if uid > 0 {
+		tx = tx.Where("uid=?", uid)
+	}
+	if ip != "" {
+		tx = tx.Where("ip=?", ip)
+	}
+	if beforeId > 0 {
+		tx = tx.Where("id<?", beforeId)
+	}
+	var res []SecurityEvent
+	err := tx.Order("id DESC").Limit(limit).Find(&res).Error
+	return res, err
+}
+
+// SecurityEvent 只增不改，查询都是按照用户或者 IP 倒序翻页
+type SecurityEvent struct {
+	Id        int64  `gorm:"primaryKey,autoIncrement;index:idx_uid_id,priority:2;index:idx_ip_id,priority:2"`
+	Uid       int64  `gorm:"index:idx_uid_id,priority:1"`
+	Type      string `gorm:"type:varchar(32)"`
+	Method    string `gorm:"type:varchar(16)"`
+	Account   string `gorm:"type:varchar(128)"`
+	IP        string `gorm:"type:varchar(64);index:idx_ip_id,priority:1"`
+	UserAgent string `gorm:"type:varchar(256)"`
+	Ssid      string `gorm:"type:varchar(64)"`
+	Detail    string `gorm:"type:varchar(128)"`
+	Ctime     int64
+}
@@ -0,0 +1,199 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"webok/pkg/cryptox"
+)
+
+var (
+	ErrTwoFactorEnabled = errors.New("已经开启了两步验证")
+	// ErrTwoFactorStepUsed 这个周期或者更早的验证码已经用过了
+	ErrTwoFactorStepUsed   = errors.New("验证码已经使用过")
+	ErrRecoveryCodeInvalid = errors.New("恢复码无效")
+)
+
+//go:generate mockgen -source=two_factor.go -package=daomocks -destination=./mock/two_factor.mock.go
+type TwoFactorDAO interface {
+	FindByUid(ctx context.Context, uid int64) (TwoFactor, error)
+	// Upsert 重新扫码会覆盖还没确认的密钥，已经开启的返回 ErrTwoFactorEnabled
+	Upsert(ctx context.Context, tf TwoFactor) error
+	// Enable 用第 step 个周期的验证码确认开启
+	Enable(ctx context.Context, uid int64, step int64, recoveryCodes []string) error
+	// UseStep 记录用过的周期，step 不比上一次大的时候返回 ErrTwoFactorStepUsed
+	UseStep(ctx context.Context, uid int64, step int64) error
+	// UseRecoveryCode 用掉一个恢复码
+	UseRecoveryCode(ctx context.Context, uid int64, code string) error
+	UpdateRecoveryCodes(ctx context.Context, uid int64, codes []string) error
+	Delete(ctx context.Context, uid int64) error
+}
+
+// GORMTwoFactorDAO 密钥加密之后再落库，拖库拿到的密文没有密钥生成不了验证码。
+// 调用方看到的 TwoFactor.Secret 始终是明文
+type GORMTwoFactorDAO struct {
+	db     *gorm.DB
+	cipher *cryptox.Cipher
+}
+
+func NewGORMTwoFactorDAO(db *gorm.DB, cipher *cryptox.Cipher) TwoFactorDAO {
+	return &GORMTwoFactorDAO{db: db, cipher: cipher}
+}
+
+func (dao *GORMTwoFactorDAO) FindByUid(ctx context.Context, uid int64) (TwoFactor, error) {
+	var tf TwoFactor
+	err := dao.db.WithContext(ctx).Where("uid=?", uid).First(&tf).Error
+	if err != nil {
+		return TwoFactor{}, err
+	}
+	if !cryptox.IsEncrypted(tf.Secret) {
+		// 加密上线之前写进去的明文，顺手加密，失败了下次读的时候再来
+		dao.encryptLegacy(ctx, tf)
+		return tf, nil
+	}
+	tf.Secret, err = dao.cipher.Decrypt(tf.Secret, secretAAD(uid))
+	if err != nil {
+		return TwoFactor{}, fmt.Errorf("解密两步验证密钥失败 uid %d: %w", uid, err)
+	}
+	return tf, nil
+}
+
+func (dao *GORMTwoFactorDAO) encryptLegacy(ctx context.Context, tf TwoFactor) {
+	enc, err := dao.cipher.Encrypt(tf.Secret, secretAAD(tf.Uid))
+	if err != nil {
+		return
+	}
+	// 条件更新，并发的 Upsert 已经换了密钥的话不覆盖
+	dao.db.WithContext(ctx).Model(&TwoFactor{}).
+		Where("uid=? AND secret=?", tf.Uid, tf.Secret).
+		Update("secret", enc)
+}
+
+func (dao *GORMTwoFactorDAO) Upsert(ctx context.Context, tf TwoFactor) error {
+	var err error
+	tf.Secret, err = dao.cipher.Encrypt(tf.Secret, secretAAD(tf.Uid))
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	tf.Ctime = now
+	tf.Utime = now
+	tf.Enabled = false
+	res := dao.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "uid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "recovery_codes", "last_step", "utime"}),
+		// 已经开启的不能覆盖，不然拿到登录态的人就能换掉别人的密钥
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: "two_factors", Name: "enabled"}, Value: false},
+		}},
+	}).Create(&tf)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+func (dao *GORMTwoFactorDAO) Enable(ctx context.Context, uid int64, step int64, recoveryCodes []string) error {
+	res := dao.db.WithContext(ctx).Model(&TwoFactor{}).
+		Where("uid=? AND enabled=? AND last_step<?", uid, false, step).
+		Updates(map[string]any{
+			"enabled":        true,
+			"last_step":      step,
+			"recovery_codes": strings.Join(recoveryCodes, ","),
+			"utime":          time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	// 已经开启了，或者这个验证码刚刚被并发的请求用掉了
+	if res.RowsAffected == 0 {
+		return ErrTwoFactorStepUsed
+	}
+	return nil
+}
+
+func (dao *GORMTwoFactorDAO) UseStep(ctx context.Context, uid int64, step int64) error {
+	// 条件更新，并发的两个请求用同一个验证码只有一个能成功
+	res := dao.db.WithContext(ctx).Model(&TwoFactor{}).
+		Where("uid=? AND last_step<?", uid, step).
+		Updates(map[string]any{
+			"last_step": step,
+			"utime":     time.Now().UnixMilli(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrTwoFactorStepUsed
+	}
+	return nil
+}
+
+func (dao *GORMTwoFactorDAO) UseRecoveryCode(ctx context.Context, uid int64, code string) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tf TwoFactor
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("uid=? AND enabled=?", uid, true).First(&tf).Error
+		if err != nil {
+			return err
+		}
+		codes := tf.Codes()
+		idx := slices.Index(codes, code)
+		if idx < 0 {
+			return ErrRecoveryCodeInvalid
+		}
+		codes = slices.Delete(codes, idx, idx+1)
+		return tx.Model(&TwoFactor{}).Where("uid=?", uid).Updates(map[string]any{
+			"recovery_codes": strings.Join(codes, ","),
+			"utime":          time.Now().UnixMilli(),
+		}).Error
+	})
+}
+
+func (dao *GORMTwoFactorDAO) UpdateRecoveryCodes(ctx context.Context, uid int64, codes []string) error {
+	return dao.db.WithContext(ctx).Model(&TwoFactor{}).
+		Where("uid=? AND enabled=?", uid, true).
+		Updates(map[string]any{
+			"recovery_codes": strings.Join(codes, ","),
+			"utime":          time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *GORMTwoFactorDAO) Delete(ctx context.Context, uid int64) error {
+	return dao.db.WithContext(ctx).Where("uid=?", uid).Delete(&TwoFactor{}).Error
+}
+
+// secretAAD 密文绑定到 uid 上面，改库把别人的密文复制过来是解不开的
+func secretAAD(uid int64) []byte {
+	return []byte(strconv.FormatInt(uid, 10))
+}
+
+// TwoFactor 一个用户一条
+type TwoFactor struct {
+	Id  int64 `gorm:"primaryKey,autoIncrement"`
+	Uid int64 `gorm:"uniqueIndex"`
+	// Secret base32 编码的 TOTP 密钥，库里面存的是 cryptox 加密之后的密文
+	Secret string `gorm:"type:varchar(128)"`
+	// RecoveryCodes 逗号分隔的恢复码哈希
+	RecoveryCodes string `gorm:"type:varchar(1024)"`
+	Enabled       bool
+	LastStep      int64
+	Ctime         int64
+	Utime         int64
+}
+
+func (tf TwoFactor) Codes() []string {
+	if tf.RecoveryCodes == "" {
+		return nil
+	}
+	return strings.Split(tf.RecoveryCodes, ",")
+}
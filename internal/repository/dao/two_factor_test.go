@@ -0,0 +1,112 @@
+package dao
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"testing"
+	"webok/pkg/cryptox"
+)
+
+const testTOTPSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+// encryptedSecret 落库的参数必须是能用 uid 解开的密文
+type encryptedSecret struct {
+	cipher *cryptox.Cipher
+	uid    int64
+}
+
+func (e encryptedSecret) Match(v driver.Value) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	plain, err := e.cipher.Decrypt(s, secretAAD(e.uid))
+	return err == nil && plain == testTOTPSecret
+}
+
+func TestGORMTwoFactorDAO_FindByUid(t *testing.T) {
+	c, err := cryptox.NewEphemeralCipher()
+	require.NoError(t, err)
+	enc, err := c.Encrypt(testTOTPSecret, secretAAD(123))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		mock func(mock sqlmock.Sqlmock)
+
+		wantSecret string
+		wantErr    bool
+	}{
+		{
+			name: "解密密钥",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT .*").WillReturnRows(
+					sqlmock.NewRows([]string{"uid", "secret"}).AddRow(123, enc))
+			},
+			wantSecret: testTOTPSecret,
+		},
+		{
+			name: "加密之前的明文，读出来顺手加密",
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT .*").WillReturnRows(
+					sqlmock.NewRows([]string{"uid", "secret"}).AddRow(123, testTOTPSecret))
+				mock.ExpectExec("UPDATE .*").
+					WithArgs(encryptedSecret{cipher: c, uid: 123}, int64(123), testTOTPSecret).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantSecret: testTOTPSecret,
+		},
+		{
+			name: "别人的密文解不开",
+			mock: func(mock sqlmock.Sqlmock) {
+				other, err := c.Encrypt(testTOTPSecret, secretAAD(456))
+				require.NoError(t, err)
+				mock.ExpectQuery("SELECT .*").WillReturnRows(
+					sqlmock.NewRows([]string{"uid", "secret"}).AddRow(123, other))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dao, mock := newTwoFactorDAO(t, c)
+			tc.mock(mock)
+			tf, err := dao.FindByUid(context.Background(), 123)
+			assert.Equal(t, tc.wantErr, err != nil)
+			assert.Equal(t, tc.wantSecret, tf.Secret)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGORMTwoFactorDAO_Upsert(t *testing.T) {
+	c, err := cryptox.NewEphemeralCipher()
+	require.NoError(t, err)
+	dao, mock := newTwoFactorDAO(t, c)
+	mock.ExpectQuery("INSERT INTO .*").
+		WithArgs(int64(123), encryptedSecret{cipher: c, uid: 123},
+			sqlmock.AnyArg(), false, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	err = dao.Upsert(context.Background(), TwoFactor{Uid: 123, Secret: testTOTPSecret})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func newTwoFactorDAO(t *testing.T, c *cryptox.Cipher) (TwoFactorDAO, sqlmock.Sqlmock) {
+	mockDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: mockDB,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	require.NoError(t, err)
+	return NewGORMTwoFactorDAO(db, c), mock
+}
@@ -6,12 +6,22 @@ import (
 	"errors"
 	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"log"
+	"strings"
 	"time"
+	"webok/internal/domain"
 )
 
 var (
-	ErrDuplicateEmail = errors.New("邮箱冲突")
+	ErrDuplicateEmail  = errors.New("邮箱冲突")
+	ErrDuplicatePhone  = errors.New("手机号冲突")
+	ErrDuplicateWechat = errors.New("微信已经绑定了其他账号")
+	ErrDuplicateOAuth2 = errors.New("第三方账号已经绑定了其他账号")
+	// ErrLastIdentity 解绑之后用户就没有办法登录了
+	ErrLastIdentity = errors.New("至少要保留一种登录方式")
+	// ErrMergeConflict 两个账号绑定了同一种类型但是不同的身份，比如不同的手机号
+	ErrMergeConflict  = errors.New("两个账号的身份冲突，不能合并")
 	ErrRecordNotFound = gorm.ErrRecordNotFound
 )
 
@@ -20,9 +30,29 @@ type UserDAO interface {
 	FindByEmail(ctx context.Context, email string) (*User, error)
 	Insert(ctx context.Context, user *User) error
 	UpdateById(ctx context.Context, u *User) error
+	UpdatePassword(ctx context.Context, id int64, password string) error
+	// UpdateEmail 调用方要先验证新邮箱，所以同时标记成已验证
+	UpdateEmail(ctx context.Context, id int64, email string) error
+	// VerifyEmail 第一次用邮箱验证码登录的时候调用，注册的时候设置的密码可能是别人抢注留下的，一起作废。
+	// 已经验证过的不会再改密码
+	VerifyEmail(ctx context.Context, id int64) error
+	UpdatePhone(ctx context.Context, id int64, phone string) error
+	UpdateWechat(ctx context.Context, id int64, openId, unionId string) error
+	// Unlink column 是身份对应的列，只有还剩别的身份的时候才会解绑，第三方账号也算
+	Unlink(ctx context.Context, id int64, columns ...string) error
+	// UnlinkOAuth2 解绑一个第三方账号，同样要保留至少一种登录方式
+	UnlinkOAuth2(ctx context.Context, id int64, provider, subject string) error
+	FindExternalIdentities(ctx context.Context, uid int64) ([]ExternalIdentity, error)
+	// Merge 把 source 的身份、点赞和收藏转移到 target 上面，文章由 ArticleDAO 转移。
+	// 返回计数变了的对象，调用方要删除它们的缓存
+	Merge(ctx context.Context, targetId, sourceId int64) ([]domain.BizRef, error)
 	FindById(ctx context.Context, id int64) (*User, error)
 	FindByPhone(ctx context.Context, phone string) (*User, error)
 	FindByWechat(ctx context.Context, openId string) (User, error)
+	// FindByOAuth2 微信以外的第三方账号记在 external_identities 里面
+	FindByOAuth2(ctx context.Context, provider, subject string) (User, error)
+	// InsertWithOAuth2 创建用户的同时绑定第三方账号
+	InsertWithOAuth2(ctx context.Context, u *User, identity ExternalIdentity) error
 }
 
 type GORMUserDAO struct {
@@ -34,24 +64,41 @@ func NewGormUserDAO(db *gorm.DB) UserDAO {
 }
 
 type User struct {
-	Id       int64          `gorm:"primaryKey,autoIncrement"`
-	Email    sql.NullString `gorm:"unique"`
-	Phone    sql.NullString `gorm:"unique"`
-	Password string
-	Nickname string `gorm:"type=varchar(34)"`
-	Birthday int64
-	AboutMe  string `gorm:"type=varchar(1024)"`
+	Id    int64          `gorm:"primaryKey,autoIncrement"`
+	Email sql.NullString `gorm:"unique"`
+	// EmailVerified 用验证码证明过邮箱是自己的，用密码注册的时候没有验证
+	EmailVerified bool           `gorm:"not null;default:false"`
+	Phone         sql.NullString `gorm:"unique"`
+	Password      string
+	Nickname      string `gorm:"type=varchar(34)"`
+	Birthday      int64
+	AboutMe       string `gorm:"type=varchar(1024)"`
 	// 1 如果查询要求同时使用 openid 和 unionid，就要创建联合唯一索引
 	// 2 如果查询只用 openid，那么就在 openid 上创建唯一索引，或者 <openid, unionId> 联合索引
 	// 3 如果查询只用 unionid，那么就在 unionid 上创建唯一索引，或者 <unionid, openid> 联合索引
 	WechatOpenId  sql.NullString `gorm:"unique"`
 	WechatUnionId sql.NullString
+	// MergedInto 被合并到了哪个账号，合并之后这个账号没有任何身份，也就没法登录
+	MergedInto int64
 	// 创建时间, 时区，UTC 0 的毫秒数
 	Ctime int64
 	// 更新时间
 	Utime int64
 }
 
+// ExternalIdentity GitHub，OIDC 这些第三方账号，一个用户可以绑定多个。
+// 邮箱、手机号和微信没有迁移过来，还是 users 表上面的唯一索引
+type ExternalIdentity struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Uid      int64  `gorm:"index"`
+	Provider string `gorm:"type:varchar(64);uniqueIndex:idx_provider_subject"`
+	Subject  string `gorm:"type:varchar(255);uniqueIndex:idx_provider_subject"`
+	// Email 第三方返回的邮箱，只是记录下来，不用来登录
+	Email string
+	Ctime int64
+	Utime int64
+}
+
 func (dao *GORMUserDAO) FindByEmail(ctx context.Context, email string) (*User, error) {
 	u := new(User)
 	err := dao.db.WithContext(ctx).Where("email=?", email).First(u).Error
@@ -63,16 +110,19 @@ func (dao *GORMUserDAO) Insert(ctx context.Context, user *User) error {
 	user.Ctime = now
 	user.Utime = now
 	err := dao.db.WithContext(ctx).Create(user).Error
+	if isUniqueViolation(err) {
+		return ErrDuplicateEmail
+	}
+	return err
+}
 
+func isUniqueViolation(err error) bool {
 	var pe *pgconn.PgError
 	if errors.As(err, &pe) {
 		duplicateErr := "23505"
-		if duplicateErr == pe.Code {
-			return ErrDuplicateEmail
-		}
+		return duplicateErr == pe.Code
 	}
-
-	return err
+	return false
 }
 
 func (dao *GORMUserDAO) UpdateById(ctx context.Context, u *User) error {
@@ -84,6 +134,278 @@ func (dao *GORMUserDAO) UpdateById(ctx context.Context, u *User) error {
 	return nil
 }
 
+func (dao *GORMUserDAO) UpdatePassword(ctx context.Context, id int64, password string) error {
+	return dao.updateColumn(ctx, id, "password", password)
+}
+
+func (dao *GORMUserDAO) UpdateEmail(ctx context.Context, id int64, email string) error {
+	err := dao.db.WithContext(ctx).Model(&User{}).Where("id=?", id).
+		Updates(map[string]any{
+			"email":          email,
+			"email_verified": true,
+			"utime":          time.Now().UnixMilli(),
+		}).Error
+	if isUniqueViolation(err) {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+func (dao *GORMUserDAO) VerifyEmail(ctx context.Context, id int64) error {
+	return dao.db.WithContext(ctx).Model(&User{}).
+		Where("id=? AND email_verified=?", id, false).
+		Updates(map[string]any{
+			"email_verified": true,
+			"password":       "",
+			"utime":          time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *GORMUserDAO) UpdatePhone(ctx context.Context, id int64, phone string) error {
+	err := dao.updateColumn(ctx, id, "phone", phone)
+	if isUniqueViolation(err) {
+		return ErrDuplicatePhone
+	}
+	return err
+}
+
+func (dao *GORMUserDAO) UpdateWechat(ctx context.Context, id int64, openId, unionId string) error {
+	err := dao.db.WithContext(ctx).Model(&User{}).Where("id=?", id).
+		Updates(map[string]any{
+			"wechat_open_id":  openId,
+			"wechat_union_id": sql.NullString{String: unionId, Valid: unionId != ""},
+			"utime":           time.Now().UnixMilli(),
+		}).Error
+	if isUniqueViolation(err) {
+		return ErrDuplicateWechat
+	}
+	return err
+}
+
+// identityColumns 可以用来登录的列，微信只看 openid
+var identityColumns = []string{"email", "phone", "wechat_open_id"}
+
+// hasExternalIdentity 还绑定了 GitHub，OIDC 这些第三方账号也可以登录
+const hasExternalIdentity = "EXISTS (SELECT 1 FROM external_identities WHERE external_identities.uid = users.id)"
+
+func (dao *GORMUserDAO) Unlink(ctx context.Context, id int64, columns ...string) error {
+	updates := map[string]any{"utime": time.Now().UnixMilli()}
+	for _, col := range columns {
+		updates[col] = nil
+	}
+	if _, ok := updates["email"]; ok {
+		updates["email_verified"] = false
+	}
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 先锁住用户，和解绑第三方账号的请求串行执行，避免并发解绑把所有身份都解绑了
+		_, err := lockUser(tx, id)
+		if err != nil {
+			return err
+		}
+		rest := []string{hasExternalIdentity}
+		for _, col := range identityColumns {
+			if _, ok := updates[col]; !ok {
+				rest = append(rest, col+" IS NOT NULL")
+			}
+		}
+		res := tx.Model(&User{}).Where("id=?", id).
+			Where(strings.Join(rest, " OR ")).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrLastIdentity
+		}
+		return nil
+	})
+}
+
+func (dao *GORMUserDAO) UnlinkOAuth2(ctx context.Context, id int64, provider, subject string) error {
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		u, err := lockUser(tx, id)
+		if err != nil {
+			return err
+		}
+		res := tx.Where("uid=? AND provider=? AND subject=?", id, provider, subject).
+			Delete(&ExternalIdentity{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRecordNotFound
+		}
+		if u.Email.Valid || u.Phone.Valid || u.WechatOpenId.Valid {
+			return nil
+		}
+		var cnt int64
+		err = tx.Model(&ExternalIdentity{}).Where("uid=?", id).Count(&cnt).Error
+		if err != nil {
+			return err
+		}
+		if cnt == 0 {
+			// 回滚，第三方账号还在
+			return ErrLastIdentity
+		}
+		return nil
+	})
+}
+
+func (dao *GORMUserDAO) FindExternalIdentities(ctx context.Context, uid int64) ([]ExternalIdentity, error) {
+	var res []ExternalIdentity
+	err := dao.db.WithContext(ctx).Where("uid=?", uid).Order("id").Find(&res).Error
+	return res, err
+}
+
+// lockUser 解绑身份之前锁住用户，同一个用户的解绑排队执行
+func lockUser(tx *gorm.DB, id int64) (User, error) {
+	var u User
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id=?", id).First(&u).Error
+	return u, err
+}
+
+func (dao *GORMUserDAO) Merge(ctx context.Context, targetId, sourceId int64) ([]domain.BizRef, error) {
+	var changed []domain.BizRef
+	err := dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var users []User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []int64{targetId, sourceId}).Find(&users).Error
+		if err != nil {
+			return err
+		}
+		if len(users) != 2 {
+			return ErrRecordNotFound
+		}
+		target, source := users[0], users[1]
+		if target.Id != targetId {
+			target, source = source, target
+		}
+		moved := map[string]any{}
+		// target 没有的身份才从 source 转移过来，两边都有并且不一样就是冲突
+		for _, c := range []struct {
+			col            string
+			target, source sql.NullString
+		}{
+			{col: "email", target: target.Email, source: source.Email},
+			{col: "phone", target: target.Phone, source: source.Phone},
+			{col: "wechat_open_id", target: target.WechatOpenId, source: source.WechatOpenId},
+		} {
+			switch {
+			case !c.source.Valid:
+			case !c.target.Valid:
+				moved[c.col] = c.source
+			case c.target.String != c.source.String:
+				return ErrMergeConflict
+			}
+		}
+		if _, ok := moved["wechat_open_id"]; ok {
+			moved["wechat_union_id"] = source.WechatUnionId
+		}
+		// 邮箱转移过来的时候，密码也跟着过来。邮箱没有验证过的话密码可能是别人抢注留下的，不能转移
+		if _, ok := moved["email"]; ok {
+			moved["email_verified"] = source.EmailVerified
+			if target.Password == "" && source.EmailVerified {
+				moved["password"] = source.Password
+			}
+		}
+		now := time.Now().UnixMilli()
+		// 先清空 source，不然会违反唯一索引
+		err = tx.Model(&User{}).Where("id=?", sourceId).Updates(map[string]any{
+			"email":           nil,
+			"email_verified":  false,
+			"phone":           nil,
+			"wechat_open_id":  nil,
+			"wechat_union_id": nil,
+			"password":        "",
+			"merged_into":     targetId,
+			"utime":           now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		// 第三方账号可以绑定多个，直接全部转移
+		err = tx.Model(&ExternalIdentity{}).Where("uid=?", sourceId).Updates(map[string]any{
+			"uid":   targetId,
+			"utime": now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		changed, err = moveInteractions(tx, targetId, sourceId, now)
+		if err != nil {
+			return err
+		}
+		if len(moved) == 0 {
+			return nil
+		}
+		moved["utime"] = now
+		return tx.Model(&User{}).Where("id=?", targetId).Updates(moved).Error
+	})
+	return changed, err
+}
+
+// moveInteractions 把 source 的点赞和收藏转移给 target，返回计数变了的对象。
+// 两个账号点赞或者收藏过同一个资源的时候只保留一条，计数也要减掉重复的那一次
+func moveInteractions(tx *gorm.DB, targetId, sourceId, now int64) ([]domain.BizRef, error) {
+	var liked, collected []domain.BizRef
+	// 两边都是点赞状态的，点赞数多算了一次
+	err := tx.Raw(`UPDATE interactives SET like_cnt = like_cnt - 1, utime = ?
+FROM user_like_bizs s JOIN user_like_bizs t ON t.biz = s.biz AND t.biz_id = s.biz_id
+WHERE s.uid = ? AND t.uid = ? AND s.status = 1 AND t.status = 1
+AND interactives.biz = s.biz AND interactives.biz_id = s.biz_id
+RETURNING interactives.biz, interactives.biz_id`, now, sourceId, targetId).Scan(&liked).Error
+	if err != nil {
+		return nil, err
+	}
+	// source 点赞了，target 取消了的，合并之后还是点赞
+	err = tx.Exec(`UPDATE user_like_bizs t SET status = 1, utime = ?
+FROM user_like_bizs s
+WHERE t.uid = ? AND s.uid = ? AND s.biz = t.biz AND s.biz_id = t.biz_id
+AND s.status = 1 AND t.status = 0`, now, targetId, sourceId).Error
+	if err != nil {
+		return nil, err
+	}
+	// 删掉重复的，不然转移的时候会违反唯一索引
+	err = tx.Exec(`DELETE FROM user_like_bizs s USING user_like_bizs t
+WHERE s.uid = ? AND t.uid = ? AND s.biz = t.biz AND s.biz_id = t.biz_id`, sourceId, targetId).Error
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Model(&UserLikeBiz{}).Where("uid=?", sourceId).
+		Updates(map[string]any{"uid": targetId, "utime": now}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 收藏没有软删除，重复的直接减掉
+	err = tx.Raw(`UPDATE interactives SET collect_cnt = collect_cnt - 1, utime = ?
+FROM user_collection_bizs s JOIN user_collection_bizs t ON t.biz = s.biz AND t.biz_id = s.biz_id
+WHERE s.uid = ? AND t.uid = ?
+AND interactives.biz = s.biz AND interactives.biz_id = s.biz_id
+RETURNING interactives.biz, interactives.biz_id`, now, sourceId, targetId).Scan(&collected).Error
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Exec(`DELETE FROM user_collection_bizs s USING user_collection_bizs t
+WHERE s.uid = ? AND t.uid = ? AND s.biz = t.biz AND s.biz_id = t.biz_id`, sourceId, targetId).Error
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Model(&UserCollectionBiz{}).Where("uid=?", sourceId).
+		Updates(map[string]any{"uid": targetId, "utime": now}).Error
+	if err != nil {
+		return nil, err
+	}
+	return append(liked, collected...), nil
+}
+
+func (dao *GORMUserDAO) updateColumn(ctx context.Context, id int64, column string, val any) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id=?", id).
+		Updates(map[string]any{
+			column:  val,
+			"utime": time.Now().UnixMilli(),
+		}).Error
+}
+
 func (dao *GORMUserDAO) FindById(ctx context.Context, id int64) (*User, error) {
 	u := new(User)
 	err := dao.db.WithContext(ctx).Where("id=?", id).First(u).Error
@@ -100,3 +422,31 @@ func (dao *GORMUserDAO) FindByWechat(ctx context.Context, openId string) (User,
 	err := dao.db.WithContext(ctx).Where("wechat_open_id=?", openId).First(&u).Error
 	return u, err
 }
+
+func (dao *GORMUserDAO) FindByOAuth2(ctx context.Context, provider, subject string) (User, error) {
+	var u User
+	err := dao.db.WithContext(ctx).
+		Joins("JOIN external_identities ON external_identities.uid = users.id").
+		Where("external_identities.provider=? AND external_identities.subject=?", provider, subject).
+		First(&u).Error
+	return u, err
+}
+
+func (dao *GORMUserDAO) InsertWithOAuth2(ctx context.Context, u *User, identity ExternalIdentity) error {
+	now := time.Now().UnixMilli()
+	return dao.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		u.Ctime, u.Utime = now, now
+		err := tx.Create(u).Error
+		if err != nil {
+			return err
+		}
+		identity.Uid = u.Id
+		identity.Ctime, identity.Utime = now, now
+		err = tx.Create(&identity).Error
+		if isUniqueViolation(err) {
+			// 并发登录的时候另一个请求已经创建好了
+			return ErrDuplicateOAuth2
+		}
+		return err
+	})
+}
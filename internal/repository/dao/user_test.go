@@ -11,6 +11,7 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"testing"
+	"webok/internal/domain"
 )
 
 func TestGORMUserDAO_Insert(t *testing.T) {
@@ -86,3 +87,333 @@ func TestGORMUserDAO_Insert(t *testing.T) {
 		})
 	}
 }
+
+func TestGORMUserDAO_UpdatePhone(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(t *testing.T) *sql.DB
+		wantErr error
+	}{
+		{
+			name: "更新成功",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectExec("UPDATE .*").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				return db
+			},
+		},
+		{
+			name: "手机号冲突",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectExec("UPDATE .*").
+					WillReturnError(&pgconn.PgError{Code: "23505"})
+				return db
+			},
+			wantErr: ErrDuplicatePhone,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := gorm.Open(postgres.New(postgres.Config{
+				Conn: tc.mock(t),
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			assert.NoError(t, err)
+			dao := NewGormUserDAO(db)
+			err = dao.UpdatePhone(context.Background(), 1, "15212345678")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestGORMUserDAO_VerifyEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	// 只有没验证过的才作废密码，已经验证过的再登录不能把密码清掉
+	mock.ExpectExec(`UPDATE "users" SET .* WHERE id=\$4 AND email_verified=\$5`).
+		WithArgs(true, "", sqlmock.AnyArg(), int64(1), false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{
+		Conn: db,
+	}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+	})
+	assert.NoError(t, err)
+	err = NewGormUserDAO(gormDB).VerifyEmail(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGORMUserDAO_Unlink(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(t *testing.T) *sql.DB
+		wantErr error
+	}{
+		{
+			name: "解绑成功",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE id=\$1 .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				// 还绑定了第三方账号也可以解绑
+				mock.ExpectExec(`UPDATE "users" SET .* WHERE id=\$\d+ AND \(EXISTS \(SELECT 1 FROM external_identities ` +
+					`WHERE external_identities.uid = users.id\) OR email IS NOT NULL OR wechat_open_id IS NOT NULL\)`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+		},
+		{
+			name: "最后一种登录方式",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT \* FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(`UPDATE "users" SET .*`).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+				return db
+			},
+			wantErr: ErrLastIdentity,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := gorm.Open(postgres.New(postgres.Config{
+				Conn: tc.mock(t),
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			assert.NoError(t, err)
+			dao := NewGormUserDAO(db)
+			err = dao.Unlink(context.Background(), 1, "phone")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestGORMUserDAO_UnlinkOAuth2(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(t *testing.T) *sql.DB
+		wantErr error
+	}{
+		{
+			name: "还有手机号",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE id=\$1 .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "phone"}).AddRow(1, "15212345678"))
+				mock.ExpectExec(`DELETE FROM "external_identities" WHERE uid=\$1 AND provider=\$2 AND subject=\$3`).
+					WithArgs(1, "github", "123").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+		},
+		{
+			name: "还有别的第三方账号",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT \* FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(`DELETE FROM "external_identities"`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "external_identities" WHERE uid=\$1`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mock.ExpectCommit()
+				return db
+			},
+		},
+		{
+			name: "最后一种登录方式",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT \* FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(`DELETE FROM "external_identities"`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`SELECT count\(\*\) FROM "external_identities"`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+				mock.ExpectRollback()
+				return db
+			},
+			wantErr: ErrLastIdentity,
+		},
+		{
+			name: "没有绑定这个账号",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT \* FROM "users"`).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mock.ExpectExec(`DELETE FROM "external_identities"`).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+				return db
+			},
+			wantErr: ErrRecordNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := gorm.Open(postgres.New(postgres.Config{
+				Conn: tc.mock(t),
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			assert.NoError(t, err)
+			dao := NewGormUserDAO(db)
+			err = dao.UnlinkOAuth2(context.Background(), 1, "github", "123")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestGORMUserDAO_Merge(t *testing.T) {
+	columns := []string{"id", "email", "email_verified", "phone", "password", "wechat_open_id"}
+	// expectMerge 清空 source，转移第三方账号、点赞和收藏
+	expectMerge := func(mock sqlmock.Sqlmock) {
+		mock.ExpectExec(`UPDATE "users" SET .* WHERE id=`).
+			WithArgs(nil, false, int64(1), "", nil, sqlmock.AnyArg(), nil, nil, int64(2)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE "external_identities" SET .* WHERE uid=`).
+			WithArgs(int64(1), sqlmock.AnyArg(), int64(2)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(`UPDATE interactives SET like_cnt = like_cnt - 1`).
+			WithArgs(sqlmock.AnyArg(), int64(2), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"biz", "biz_id"}).AddRow("article", 3))
+		mock.ExpectExec(`UPDATE user_like_bizs t SET status = 1`).
+			WithArgs(sqlmock.AnyArg(), int64(1), int64(2)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`DELETE FROM user_like_bizs`).
+			WithArgs(int64(2), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`UPDATE "user_like_bizs" SET .* WHERE uid=`).
+			WithArgs(int64(1), sqlmock.AnyArg(), int64(2)).
+			WillReturnResult(sqlmock.NewResult(0, 4))
+		mock.ExpectQuery(`UPDATE interactives SET collect_cnt = collect_cnt - 1`).
+			WithArgs(sqlmock.AnyArg(), int64(2), int64(1)).
+			WillReturnRows(sqlmock.NewRows([]string{"biz", "biz_id"}))
+		mock.ExpectExec(`DELETE FROM user_collection_bizs`).
+			WithArgs(int64(2), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`UPDATE "user_collection_bizs" SET .* WHERE uid=`).
+			WithArgs(int64(1), sqlmock.AnyArg(), int64(2)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	testCases := []struct {
+		name        string
+		mock        func(t *testing.T) *sql.DB
+		wantChanged []domain.BizRef
+		wantErr     error
+	}{
+		{
+			name: "合并成功，验证过的邮箱带着密码过来",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE id IN .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, nil, false, "15212345678", "", nil).
+						AddRow(2, "123@qq.com", true, nil, "hash", "openid"))
+				expectMerge(mock)
+				mock.ExpectExec(`UPDATE "users" SET .* WHERE id=`).
+					WithArgs("123@qq.com", true, "hash", sqlmock.AnyArg(), "openid", nil, int64(1)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			wantChanged: []domain.BizRef{{Biz: "article", BizId: 3}},
+		},
+		{
+			name: "邮箱没有验证过，密码不转移",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE id IN .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, nil, false, "15212345678", "", nil).
+						AddRow(2, "123@qq.com", false, nil, "hash", nil))
+				expectMerge(mock)
+				mock.ExpectExec(`UPDATE "users" SET .* WHERE id=`).
+					WithArgs("123@qq.com", false, sqlmock.AnyArg(), int64(1)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+				return db
+			},
+			wantChanged: []domain.BizRef{{Biz: "article", BizId: 3}},
+		},
+		{
+			name: "手机号冲突",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE id IN .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, nil, false, "15212345678", "", nil).
+						AddRow(2, nil, false, "15287654321", "", nil))
+				mock.ExpectRollback()
+				return db
+			},
+			wantErr: ErrMergeConflict,
+		},
+		{
+			name: "账号不存在",
+			mock: func(t *testing.T) *sql.DB {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT \* FROM "users" WHERE id IN .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, nil, false, "15212345678", "", nil))
+				mock.ExpectRollback()
+				return db
+			},
+			wantErr: ErrRecordNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, err := gorm.Open(postgres.New(postgres.Config{
+				Conn: tc.mock(t),
+			}), &gorm.Config{
+				DisableAutomaticPing:   true,
+				SkipDefaultTransaction: true,
+			})
+			assert.NoError(t, err)
+			dao := NewGormUserDAO(db)
+			changed, err := dao.Merge(context.Background(), 1, 2)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantChanged, changed)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"webok/internal/domain"
+	"webok/internal/repository/cache"
+	"webok/internal/repository/dao"
+)
+
+//go:generate mockgen -source=login_attempt.go -package=repomocks -destination=./mock/login_attempt.mock.go
+type LoginAttemptRepository interface {
+	Get(ctx context.Context, scope domain.LoginScope, subject string) (domain.LoginAttempt, error)
+	Fail(ctx context.Context, scope domain.LoginScope, subject string, lockAfter int, p domain.LoginPolicy) (domain.LoginAttempt, error)
+	Reset(ctx context.Context, scope domain.LoginScope, subject string) error
+	Audit(ctx context.Context, a domain.LoginAudit) error
+}
+
+type CachedLoginAttemptRepository struct {
+	cache cache.LoginAttemptCache
+	dao   dao.LoginAuditDAO
+}
+
+func NewLoginAttemptRepository(cache cache.LoginAttemptCache, dao dao.LoginAuditDAO) LoginAttemptRepository {
+	return &CachedLoginAttemptRepository{cache: cache, dao: dao}
+}
+
+func (r *CachedLoginAttemptRepository) Get(ctx context.Context, scope domain.LoginScope, subject string) (domain.LoginAttempt, error) {
+	return r.cache.Get(ctx, scope, subject)
+}
+
+func (r *CachedLoginAttemptRepository) Fail(ctx context.Context, scope domain.LoginScope, subject string,
+	lockAfter int, p domain.LoginPolicy) (domain.LoginAttempt, error) {
+	return r.cache.Fail(ctx, scope, subject, lockAfter, p)
+}
+
+func (r *CachedLoginAttemptRepository) Reset(ctx context.Context, scope domain.LoginScope, subject string) error {
+	return r.cache.Reset(ctx, scope, subject)
+}
+
+func (r *CachedLoginAttemptRepository) Audit(ctx context.Context, a domain.LoginAudit) error {
+	return r.dao.Insert(ctx, dao.LoginAudit{
+		Scope:   string(a.Scope),
+		Subject: a.Subject,
+		IP:      a.IP,
+		Event:   a.Event,
+		Level:   a.Level,
+		LockMs:  a.Lock.Milliseconds(),
+	})
+}
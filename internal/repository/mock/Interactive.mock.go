@@ -0,0 +1,176 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: Interactive.go
+//
+// Generated by this command:
+//
+//	mockgen -source=Interactive.go -package=repomocks -destination=./mock/Interactive.mock.go
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webok/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockInteractiveRepository is a mock of InteractiveRepository interface.
+type MockInteractiveRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockInteractiveRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockInteractiveRepositoryMockRecorder is the mock recorder for MockInteractiveRepository.
+type MockInteractiveRepositoryMockRecorder struct {
+	mock *MockInteractiveRepository
+}
+
+// NewMockInteractiveRepository creates a new mock instance.
+func NewMockInteractiveRepository(ctrl *gomock.Controller) *MockInteractiveRepository {
+	mock := &MockInteractiveRepository{ctrl: ctrl}
+	mock.recorder = &MockInteractiveRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockInteractiveRepository) EXPECT() *MockInteractiveRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AddCollectionItem mocks base method.
+func (m *MockInteractiveRepository) AddCollectionItem(ctx context.Context, biz string, id, cid, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddCollectionItem", ctx, biz, id, cid, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddCollectionItem indicates an expected call of AddCollectionItem.
+func (mr *MockInteractiveRepositoryMockRecorder) AddCollectionItem(ctx, biz, id, cid, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCollectionItem", reflect.TypeOf((*MockInteractiveRepository)(nil).AddCollectionItem), ctx, biz, id, cid, uid)
+}
+
+// BatchIncrReadCnt mocks base method.
+func (m *MockInteractiveRepository) BatchIncrReadCnt(ctx context.Context, biz []string, bizId []int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchIncrReadCnt", ctx, biz, bizId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BatchIncrReadCnt indicates an expected call of BatchIncrReadCnt.
+func (mr *MockInteractiveRepositoryMockRecorder) BatchIncrReadCnt(ctx, biz, bizId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchIncrReadCnt", reflect.TypeOf((*MockInteractiveRepository)(nil).BatchIncrReadCnt), ctx, biz, bizId)
+}
+
+// Collected mocks base method.
+func (m *MockInteractiveRepository) Collected(ctx context.Context, biz string, id, uid int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Collected", ctx, biz, id, uid)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Collected indicates an expected call of Collected.
+func (mr *MockInteractiveRepositoryMockRecorder) Collected(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Collected", reflect.TypeOf((*MockInteractiveRepository)(nil).Collected), ctx, biz, id, uid)
+}
+
+// DecrLickCnt mocks base method.
+func (m *MockInteractiveRepository) DecrLickCnt(ctx context.Context, biz string, id, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecrLickCnt", ctx, biz, id, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DecrLickCnt indicates an expected call of DecrLickCnt.
+func (mr *MockInteractiveRepositoryMockRecorder) DecrLickCnt(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecrLickCnt", reflect.TypeOf((*MockInteractiveRepository)(nil).DecrLickCnt), ctx, biz, id, uid)
+}
+
+// Get mocks base method.
+func (m *MockInteractiveRepository) Get(ctx context.Context, biz string, id int64) (domain.Interactive, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, biz, id)
+	ret0, _ := ret[0].(domain.Interactive)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockInteractiveRepositoryMockRecorder) Get(ctx, biz, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockInteractiveRepository)(nil).Get), ctx, biz, id)
+}
+
+// IncrLickCnt mocks base method.
+func (m *MockInteractiveRepository) IncrLickCnt(ctx context.Context, biz string, id, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrLickCnt", ctx, biz, id, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrLickCnt indicates an expected call of IncrLickCnt.
+func (mr *MockInteractiveRepositoryMockRecorder) IncrLickCnt(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrLickCnt", reflect.TypeOf((*MockInteractiveRepository)(nil).IncrLickCnt), ctx, biz, id, uid)
+}
+
+// IncrReadCnt mocks base method.
+func (m *MockInteractiveRepository) IncrReadCnt(ctx context.Context, biz string, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrReadCnt", ctx, biz, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrReadCnt indicates an expected call of IncrReadCnt.
+func (mr *MockInteractiveRepositoryMockRecorder) IncrReadCnt(ctx, biz, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrReadCnt", reflect.TypeOf((*MockInteractiveRepository)(nil).IncrReadCnt), ctx, biz, id)
+}
+
+// Invalidate mocks base method.
+func (m *MockInteractiveRepository) Invalidate(ctx context.Context, refs ...domain.BizRef) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range refs {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Invalidate", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Invalidate indicates an expected call of Invalidate.
+func (mr *MockInteractiveRepositoryMockRecorder) Invalidate(ctx any, refs ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, refs...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Invalidate", reflect.TypeOf((*MockInteractiveRepository)(nil).Invalidate), varargs...)
+}
+
+// Liked mocks base method.
+func (m *MockInteractiveRepository) Liked(ctx context.Context, biz string, id, uid int64) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Liked", ctx, biz, id, uid)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Liked indicates an expected call of Liked.
+func (mr *MockInteractiveRepositoryMockRecorder) Liked(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Liked", reflect.TypeOf((*MockInteractiveRepository)(nil).Liked), ctx, biz, id, uid)
+}
@@ -0,0 +1,130 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: captcha.go
+//
+// Generated by this command:
+//
+//	mockgen -source=captcha.go -package=repomocks -destination=./mock/captcha.mock.go
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCaptchaRepository is a mock of CaptchaRepository interface.
+type MockCaptchaRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockCaptchaRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockCaptchaRepositoryMockRecorder is the mock recorder for MockCaptchaRepository.
+type MockCaptchaRepositoryMockRecorder struct {
+	mock *MockCaptchaRepository
+}
+
+// NewMockCaptchaRepository creates a new mock instance.
+func NewMockCaptchaRepository(ctrl *gomock.Controller) *MockCaptchaRepository {
+	mock := &MockCaptchaRepository{ctrl: ctrl}
+	mock.recorder = &MockCaptchaRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCaptchaRepository) EXPECT() *MockCaptchaRepositoryMockRecorder {
+	return m.recorder
+}
+
+// IncrSuspicious mocks base method.
+func (m *MockCaptchaRepository) IncrSuspicious(ctx context.Context, biz, ip string, window time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IncrSuspicious", ctx, biz, ip, window)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// IncrSuspicious indicates an expected call of IncrSuspicious.
+func (mr *MockCaptchaRepositoryMockRecorder) IncrSuspicious(ctx, biz, ip, window any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrSuspicious", reflect.TypeOf((*MockCaptchaRepository)(nil).IncrSuspicious), ctx, biz, ip, window)
+}
+
+// SetAnswer mocks base method.
+func (m *MockCaptchaRepository) SetAnswer(ctx context.Context, biz, id, typ, answer string, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAnswer", ctx, biz, id, typ, answer, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAnswer indicates an expected call of SetAnswer.
+func (mr *MockCaptchaRepositoryMockRecorder) SetAnswer(ctx, biz, id, typ, answer, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAnswer", reflect.TypeOf((*MockCaptchaRepository)(nil).SetAnswer), ctx, biz, id, typ, answer, ttl)
+}
+
+// SetTicket mocks base method.
+func (m *MockCaptchaRepository) SetTicket(ctx context.Context, biz, id, secret string, ttl time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTicket", ctx, biz, id, secret, ttl)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetTicket indicates an expected call of SetTicket.
+func (mr *MockCaptchaRepositoryMockRecorder) SetTicket(ctx, biz, id, secret, ttl any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTicket", reflect.TypeOf((*MockCaptchaRepository)(nil).SetTicket), ctx, biz, id, secret, ttl)
+}
+
+// Suspicious mocks base method.
+func (m *MockCaptchaRepository) Suspicious(ctx context.Context, biz, ip string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Suspicious", ctx, biz, ip)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Suspicious indicates an expected call of Suspicious.
+func (mr *MockCaptchaRepositoryMockRecorder) Suspicious(ctx, biz, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Suspicious", reflect.TypeOf((*MockCaptchaRepository)(nil).Suspicious), ctx, biz, ip)
+}
+
+// TakeAnswer mocks base method.
+func (m *MockCaptchaRepository) TakeAnswer(ctx context.Context, biz, id string) (string, string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeAnswer", ctx, biz, id)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(string)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// TakeAnswer indicates an expected call of TakeAnswer.
+func (mr *MockCaptchaRepositoryMockRecorder) TakeAnswer(ctx, biz, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeAnswer", reflect.TypeOf((*MockCaptchaRepository)(nil).TakeAnswer), ctx, biz, id)
+}
+
+// TakeTicket mocks base method.
+func (m *MockCaptchaRepository) TakeTicket(ctx context.Context, biz, id, secret string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeTicket", ctx, biz, id, secret)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeTicket indicates an expected call of TakeTicket.
+func (mr *MockCaptchaRepositoryMockRecorder) TakeTicket(ctx, biz, id, secret any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeTicket", reflect.TypeOf((*MockCaptchaRepository)(nil).TakeTicket), ctx, biz, id, secret)
+}
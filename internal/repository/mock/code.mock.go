@@ -12,6 +12,7 @@ package repomocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -41,17 +42,17 @@ func (m *MockCodeRepository) EXPECT() *MockCodeRepositoryMockRecorder {
 }
 
 // Set mocks base method.
-func (m *MockCodeRepository) Set(ctx context.Context, biz, phone, code string) error {
+func (m *MockCodeRepository) Set(ctx context.Context, biz, phone, code string, ttl, interval time.Duration, attempts int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Set", ctx, biz, phone, code)
+	ret := m.ctrl.Call(m, "Set", ctx, biz, phone, code, ttl, interval, attempts)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Set indicates an expected call of Set.
-func (mr *MockCodeRepositoryMockRecorder) Set(ctx, biz, phone, code any) *gomock.Call {
+func (mr *MockCodeRepositoryMockRecorder) Set(ctx, biz, phone, code, ttl, interval, attempts any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockCodeRepository)(nil).Set), ctx, biz, phone, code)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockCodeRepository)(nil).Set), ctx, biz, phone, code, ttl, interval, attempts)
 }
 
 // Verify mocks base method.
@@ -0,0 +1,85 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: code_quota.go
+//
+// Generated by this command:
+//
+//	mockgen -source=code_quota.go -package=repomocks -destination=./mock/code_quota.mock.go
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webok/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCodeQuotaRepository is a mock of CodeQuotaRepository interface.
+type MockCodeQuotaRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockCodeQuotaRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockCodeQuotaRepositoryMockRecorder is the mock recorder for MockCodeQuotaRepository.
+type MockCodeQuotaRepositoryMockRecorder struct {
+	mock *MockCodeQuotaRepository
+}
+
+// NewMockCodeQuotaRepository creates a new mock instance.
+func NewMockCodeQuotaRepository(ctrl *gomock.Controller) *MockCodeQuotaRepository {
+	mock := &MockCodeQuotaRepository{ctrl: ctrl}
+	mock.recorder = &MockCodeQuotaRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCodeQuotaRepository) EXPECT() *MockCodeQuotaRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Acquire mocks base method.
+func (m *MockCodeQuotaRepository) Acquire(ctx context.Context, biz, phone, ip string, quota domain.CodeQuota) (domain.CodeQuotaUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Acquire", ctx, biz, phone, ip, quota)
+	ret0, _ := ret[0].(domain.CodeQuotaUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Acquire indicates an expected call of Acquire.
+func (mr *MockCodeQuotaRepositoryMockRecorder) Acquire(ctx, biz, phone, ip, quota any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Acquire", reflect.TypeOf((*MockCodeQuotaRepository)(nil).Acquire), ctx, biz, phone, ip, quota)
+}
+
+// Audit mocks base method.
+func (m *MockCodeQuotaRepository) Audit(ctx context.Context, a domain.CodeAudit) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Audit", ctx, a)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Audit indicates an expected call of Audit.
+func (mr *MockCodeQuotaRepositoryMockRecorder) Audit(ctx, a any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Audit", reflect.TypeOf((*MockCodeQuotaRepository)(nil).Audit), ctx, a)
+}
+
+// Release mocks base method.
+func (m *MockCodeQuotaRepository) Release(ctx context.Context, u domain.CodeQuotaUsage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx, u)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockCodeQuotaRepositoryMockRecorder) Release(ctx, u any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockCodeQuotaRepository)(nil).Release), ctx, u)
+}
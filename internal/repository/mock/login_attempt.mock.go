@@ -0,0 +1,100 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: login_attempt.go
+//
+// Generated by this command:
+//
+//	mockgen -source=login_attempt.go -package=repomocks -destination=./mock/login_attempt.mock.go
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webok/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLoginAttemptRepository is a mock of LoginAttemptRepository interface.
+type MockLoginAttemptRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginAttemptRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockLoginAttemptRepositoryMockRecorder is the mock recorder for MockLoginAttemptRepository.
+type MockLoginAttemptRepositoryMockRecorder struct {
+	mock *MockLoginAttemptRepository
+}
+
+// NewMockLoginAttemptRepository creates a new mock instance.
+func NewMockLoginAttemptRepository(ctrl *gomock.Controller) *MockLoginAttemptRepository {
+	mock := &MockLoginAttemptRepository{ctrl: ctrl}
+	mock.recorder = &MockLoginAttemptRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginAttemptRepository) EXPECT() *MockLoginAttemptRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Audit mocks base method.
+func (m *MockLoginAttemptRepository) Audit(ctx context.Context, a domain.LoginAudit) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Audit", ctx, a)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Audit indicates an expected call of Audit.
+func (mr *MockLoginAttemptRepositoryMockRecorder) Audit(ctx, a any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Audit", reflect.TypeOf((*MockLoginAttemptRepository)(nil).Audit), ctx, a)
+}
+
+// Fail mocks base method.
+func (m *MockLoginAttemptRepository) Fail(ctx context.Context, scope domain.LoginScope, subject string, lockAfter int, p domain.LoginPolicy) (domain.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fail", ctx, scope, subject, lockAfter, p)
+	ret0, _ := ret[0].(domain.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Fail indicates an expected call of Fail.
+func (mr *MockLoginAttemptRepositoryMockRecorder) Fail(ctx, scope, subject, lockAfter, p any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*MockLoginAttemptRepository)(nil).Fail), ctx, scope, subject, lockAfter, p)
+}
+
+// Get mocks base method.
+func (m *MockLoginAttemptRepository) Get(ctx context.Context, scope domain.LoginScope, subject string) (domain.LoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, scope, subject)
+	ret0, _ := ret[0].(domain.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockLoginAttemptRepositoryMockRecorder) Get(ctx, scope, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockLoginAttemptRepository)(nil).Get), ctx, scope, subject)
+}
+
+// Reset mocks base method.
+func (m *MockLoginAttemptRepository) Reset(ctx context.Context, scope domain.LoginScope, subject string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", ctx, scope, subject)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockLoginAttemptRepositoryMockRecorder) Reset(ctx, scope, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockLoginAttemptRepository)(nil).Reset), ctx, scope, subject)
+}
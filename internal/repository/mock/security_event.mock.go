@@ -0,0 +1,71 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: security_event.go
+//
+// Generated by this command:
+//
+//	mockgen -source=security_event.go -package=repomocks -destination=./mock/security_event.mock.go
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webok/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockSecurityEventRepository is a mock of SecurityEventRepository interface.
+type MockSecurityEventRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockSecurityEventRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockSecurityEventRepositoryMockRecorder is the mock recorder for MockSecurityEventRepository.
+type MockSecurityEventRepositoryMockRecorder struct {
+	mock *MockSecurityEventRepository
+}
+
+// NewMockSecurityEventRepository creates a new mock instance.
+func NewMockSecurityEventRepository(ctrl *gomock.Controller) *MockSecurityEventRepository {
+	mock := &MockSecurityEventRepository{ctrl: ctrl}
+	mock.recorder = &MockSecurityEventRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSecurityEventRepository) EXPECT() *MockSecurityEventRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AddBatch mocks base method.
+func (m *MockSecurityEventRepository) AddBatch(ctx context.Context, events []domain.SecurityEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddBatch", ctx, events)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddBatch indicates an expected call of AddBatch.
+func (mr *MockSecurityEventRepositoryMockRecorder) AddBatch(ctx, events any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBatch", reflect.TypeOf((*MockSecurityEventRepository)(nil).AddBatch), ctx, events)
+}
+
+// Find mocks base method.
+func (m *MockSecurityEventRepository) Find(ctx context.Context, q domain.SecurityEventQuery) ([]domain.SecurityEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Find", ctx, q)
+	ret0, _ := ret[0].([]domain.SecurityEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Find indicates an expected call of Find.
+func (mr *MockSecurityEventRepositoryMockRecorder) Find(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Find", reflect.TypeOf((*MockSecurityEventRepository)(nil).Find), ctx, q)
+}
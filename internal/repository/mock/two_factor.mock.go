@@ -0,0 +1,141 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: two_factor.go
+//
+// Generated by this command:
+//
+//	mockgen -source=two_factor.go -package=repomocks -destination=./mock/two_factor.mock.go
+//
+
+// Package repomocks is a generated GoMock package.
+package repomocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webok/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockTwoFactorRepository is a mock of TwoFactorRepository interface.
+type MockTwoFactorRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockTwoFactorRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockTwoFactorRepositoryMockRecorder is the mock recorder for MockTwoFactorRepository.
+type MockTwoFactorRepositoryMockRecorder struct {
+	mock *MockTwoFactorRepository
+}
+
+// NewMockTwoFactorRepository creates a new mock instance.
+func NewMockTwoFactorRepository(ctrl *gomock.Controller) *MockTwoFactorRepository {
+	mock := &MockTwoFactorRepository{ctrl: ctrl}
+	mock.recorder = &MockTwoFactorRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTwoFactorRepository) EXPECT() *MockTwoFactorRepositoryMockRecorder {
+	return m.recorder
+}
+
+// Delete mocks base method.
+func (m *MockTwoFactorRepository) Delete(ctx context.Context, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockTwoFactorRepositoryMockRecorder) Delete(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockTwoFactorRepository)(nil).Delete), ctx, uid)
+}
+
+// Enable mocks base method.
+func (m *MockTwoFactorRepository) Enable(ctx context.Context, uid, step int64, recoveryCodes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enable", ctx, uid, step, recoveryCodes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Enable indicates an expected call of Enable.
+func (mr *MockTwoFactorRepositoryMockRecorder) Enable(ctx, uid, step, recoveryCodes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enable", reflect.TypeOf((*MockTwoFactorRepository)(nil).Enable), ctx, uid, step, recoveryCodes)
+}
+
+// FindByUid mocks base method.
+func (m *MockTwoFactorRepository) FindByUid(ctx context.Context, uid int64) (domain.TwoFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByUid", ctx, uid)
+	ret0, _ := ret[0].(domain.TwoFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByUid indicates an expected call of FindByUid.
+func (mr *MockTwoFactorRepositoryMockRecorder) FindByUid(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByUid", reflect.TypeOf((*MockTwoFactorRepository)(nil).FindByUid), ctx, uid)
+}
+
+// UpdateRecoveryCodes mocks base method.
+func (m *MockTwoFactorRepository) UpdateRecoveryCodes(ctx context.Context, uid int64, codes []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRecoveryCodes", ctx, uid, codes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRecoveryCodes indicates an expected call of UpdateRecoveryCodes.
+func (mr *MockTwoFactorRepositoryMockRecorder) UpdateRecoveryCodes(ctx, uid, codes any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRecoveryCodes", reflect.TypeOf((*MockTwoFactorRepository)(nil).UpdateRecoveryCodes), ctx, uid, codes)
+}
+
+// Upsert mocks base method.
+func (m *MockTwoFactorRepository) Upsert(ctx context.Context, tf domain.TwoFactor) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Upsert", ctx, tf)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Upsert indicates an expected call of Upsert.
+func (mr *MockTwoFactorRepositoryMockRecorder) Upsert(ctx, tf any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockTwoFactorRepository)(nil).Upsert), ctx, tf)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockTwoFactorRepository) UseRecoveryCode(ctx context.Context, uid int64, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, uid, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockTwoFactorRepositoryMockRecorder) UseRecoveryCode(ctx, uid, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockTwoFactorRepository)(nil).UseRecoveryCode), ctx, uid, code)
+}
+
+// UseStep mocks base method.
+func (m *MockTwoFactorRepository) UseStep(ctx context.Context, uid, step int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseStep", ctx, uid, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseStep indicates an expected call of UseStep.
+func (mr *MockTwoFactorRepositoryMockRecorder) UseStep(ctx, uid, step any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseStep", reflect.TypeOf((*MockTwoFactorRepository)(nil).UseStep), ctx, uid, step)
+}
@@ -44,7 +44,7 @@ func (m *MockUserRepository) EXPECT() *MockUserRepositoryMockRecorder {
 // Create mocks base method.
 func (m *MockUserRepository) Create(ctx context.Context, u *domain.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Save", ctx, u)
+	ret := m.ctrl.Call(m, "Create", ctx, u)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
@@ -52,7 +52,21 @@ func (m *MockUserRepository) Create(ctx context.Context, u *domain.User) error {
 // Create indicates an expected call of Create.
 func (mr *MockUserRepositoryMockRecorder) Create(ctx, u any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockUserRepository)(nil).Create), ctx, u)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserRepository)(nil).Create), ctx, u)
+}
+
+// CreateWithOAuth2 mocks base method.
+func (m *MockUserRepository) CreateWithOAuth2(ctx context.Context, u *domain.User, info domain.OAuth2Info) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateWithOAuth2", ctx, u, info)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateWithOAuth2 indicates an expected call of CreateWithOAuth2.
+func (mr *MockUserRepositoryMockRecorder) CreateWithOAuth2(ctx, u, info any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWithOAuth2", reflect.TypeOf((*MockUserRepository)(nil).CreateWithOAuth2), ctx, u, info)
 }
 
 // FindByEmail mocks base method.
@@ -85,6 +99,21 @@ func (mr *MockUserRepositoryMockRecorder) FindById(ctx, id any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindById", reflect.TypeOf((*MockUserRepository)(nil).FindById), ctx, id)
 }
 
+// FindByOAuth2 mocks base method.
+func (m *MockUserRepository) FindByOAuth2(ctx context.Context, provider, subject string) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByOAuth2", ctx, provider, subject)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByOAuth2 indicates an expected call of FindByOAuth2.
+func (mr *MockUserRepositoryMockRecorder) FindByOAuth2(ctx, provider, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByOAuth2", reflect.TypeOf((*MockUserRepository)(nil).FindByOAuth2), ctx, provider, subject)
+}
+
 // FindByPhone mocks base method.
 func (m *MockUserRepository) FindByPhone(ctx context.Context, phone string) (*domain.User, error) {
 	m.ctrl.T.Helper()
@@ -115,6 +144,64 @@ func (mr *MockUserRepositoryMockRecorder) FindByWechat(ctx, openId any) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByWechat", reflect.TypeOf((*MockUserRepository)(nil).FindByWechat), ctx, openId)
 }
 
+// FindOAuth2Identities mocks base method.
+func (m *MockUserRepository) FindOAuth2Identities(ctx context.Context, uid int64) ([]domain.OAuth2Info, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOAuth2Identities", ctx, uid)
+	ret0, _ := ret[0].([]domain.OAuth2Info)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOAuth2Identities indicates an expected call of FindOAuth2Identities.
+func (mr *MockUserRepositoryMockRecorder) FindOAuth2Identities(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOAuth2Identities", reflect.TypeOf((*MockUserRepository)(nil).FindOAuth2Identities), ctx, uid)
+}
+
+// Merge mocks base method.
+func (m *MockUserRepository) Merge(ctx context.Context, targetId, sourceId int64) ([]domain.BizRef, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Merge", ctx, targetId, sourceId)
+	ret0, _ := ret[0].([]domain.BizRef)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Merge indicates an expected call of Merge.
+func (mr *MockUserRepositoryMockRecorder) Merge(ctx, targetId, sourceId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merge", reflect.TypeOf((*MockUserRepository)(nil).Merge), ctx, targetId, sourceId)
+}
+
+// Unlink mocks base method.
+func (m *MockUserRepository) Unlink(ctx context.Context, id int64, typ domain.IdentityType) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unlink", ctx, id, typ)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlink indicates an expected call of Unlink.
+func (mr *MockUserRepositoryMockRecorder) Unlink(ctx, id, typ any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlink", reflect.TypeOf((*MockUserRepository)(nil).Unlink), ctx, id, typ)
+}
+
+// UnlinkOAuth2 mocks base method.
+func (m *MockUserRepository) UnlinkOAuth2(ctx context.Context, id int64, provider, subject string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlinkOAuth2", ctx, id, provider, subject)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlinkOAuth2 indicates an expected call of UnlinkOAuth2.
+func (mr *MockUserRepositoryMockRecorder) UnlinkOAuth2(ctx, id, provider, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlinkOAuth2", reflect.TypeOf((*MockUserRepository)(nil).UnlinkOAuth2), ctx, id, provider, subject)
+}
+
 // UpdateById mocks base method.
 func (m *MockUserRepository) UpdateById(ctx context.Context, u *domain.User) error {
 	m.ctrl.T.Helper()
@@ -128,3 +215,73 @@ func (mr *MockUserRepositoryMockRecorder) UpdateById(ctx, u any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateById", reflect.TypeOf((*MockUserRepository)(nil).UpdateById), ctx, u)
 }
+
+// UpdateEmail mocks base method.
+func (m *MockUserRepository) UpdateEmail(ctx context.Context, id int64, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEmail", ctx, id, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateEmail indicates an expected call of UpdateEmail.
+func (mr *MockUserRepositoryMockRecorder) UpdateEmail(ctx, id, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEmail", reflect.TypeOf((*MockUserRepository)(nil).UpdateEmail), ctx, id, email)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, id int64, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, id, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockUserRepositoryMockRecorder) UpdatePassword(ctx, id, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockUserRepository)(nil).UpdatePassword), ctx, id, password)
+}
+
+// UpdatePhone mocks base method.
+func (m *MockUserRepository) UpdatePhone(ctx context.Context, id int64, phone string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePhone", ctx, id, phone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePhone indicates an expected call of UpdatePhone.
+func (mr *MockUserRepositoryMockRecorder) UpdatePhone(ctx, id, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePhone", reflect.TypeOf((*MockUserRepository)(nil).UpdatePhone), ctx, id, phone)
+}
+
+// UpdateWechat mocks base method.
+func (m *MockUserRepository) UpdateWechat(ctx context.Context, id int64, info domain.WechatInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWechat", ctx, id, info)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWechat indicates an expected call of UpdateWechat.
+func (mr *MockUserRepositoryMockRecorder) UpdateWechat(ctx, id, info any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWechat", reflect.TypeOf((*MockUserRepository)(nil).UpdateWechat), ctx, id, info)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockUserRepository) VerifyEmail(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockUserRepositoryMockRecorder) VerifyEmail(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockUserRepository)(nil).VerifyEmail), ctx, id)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/repository/dao"
+)
+
+//go:generate mockgen -source=security_event.go -package=repomocks -destination=./mock/security_event.mock.go
+type SecurityEventRepository interface {
+	AddBatch(ctx context.Context, events []domain.SecurityEvent) error
+	Find(ctx context.Context, q domain.SecurityEventQuery) ([]domain.SecurityEvent, error)
+}
+
+type securityEventRepository struct {
+	dao dao.SecurityEventDAO
+}
+
+func NewSecurityEventRepository(dao dao.SecurityEventDAO) SecurityEventRepository {
+	return &securityEventRepository{dao: dao}
+}
+
+func (r *securityEventRepository) AddBatch(ctx context.Context, events []domain.SecurityEvent) error {
+	entities := make([]dao.SecurityEvent, 0, len(events))
+	for _, e := range events {
+		entities = append(entities, r.toEntity(e))
+	}
+	return r.dao.BatchInsert(ctx, entities)
+}
+
+func (r *securityEventRepository) Find(ctx context.Context, q domain.SecurityEventQuery) ([]domain.SecurityEvent, error) {
+	entities, err := r.dao.Find(ctx, q.Uid, q.IP, q.BeforeId, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SecurityEvent, 0, len(entities))
+	for _, e := range entities {
+		res = append(res, r.toDomain(e))
+	}
+	return res, nil
+}
+
+func (r *securityEventRepository) toEntity(e domain.SecurityEvent) dao.SecurityEvent {
+	return dao.SecurityEvent{
+		Uid:       e.Uid,
+		Type:      string(e.Type),
+		Method:    e.Method,
+		Account:   truncate(e.Account, 128),
+		IP:        e.IP,
+		UserAgent: truncate(e.UserAgent, 256),
+		Ssid:      e.Ssid,
+		Detail:    truncate(e.Detail, 128),
+		Ctime:     e.Ctime.UnixMilli(),
+	}
+}
+
+func (r *securityEventRepository) toDomain(e dao.SecurityEvent) domain.SecurityEvent {
+	return domain.SecurityEvent{
+		Id:        e.Id,
+		Uid:       e.Uid,
+		Type:      domain.SecurityEventType(e.Type),
+		Method:    e.Method,
+		Account:   e.Account,
+		IP:        e.IP,
+		UserAgent: e.UserAgent,
+		Ssid:      e.Ssid,
+		Detail:    e.Detail,
+		Ctime:     time.UnixMilli(e.Ctime),
+	}
+}
+
+// truncate 都是客户端可以随便填的内容，超长会让整批写入失败
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"webok/internal/domain"
+	"webok/internal/repository/dao"
+	"webok/pkg/totp"
+)
+
+var (
+	ErrTwoFactorEnabled    = dao.ErrTwoFactorEnabled
+	ErrTwoFactorStepUsed   = dao.ErrTwoFactorStepUsed
+	ErrRecoveryCodeInvalid = dao.ErrRecoveryCodeInvalid
+)
+
+//go:generate mockgen -source=two_factor.go -package=repomocks -destination=./mock/two_factor.mock.go
+type TwoFactorRepository interface {
+	FindByUid(ctx context.Context, uid int64) (domain.TwoFactor, error)
+	Upsert(ctx context.Context, tf domain.TwoFactor) error
+	Enable(ctx context.Context, uid int64, step int64, recoveryCodes []string) error
+	UseStep(ctx context.Context, uid int64, step int64) error
+	UseRecoveryCode(ctx context.Context, uid int64, code string) error
+	UpdateRecoveryCodes(ctx context.Context, uid int64, codes []string) error
+	Delete(ctx context.Context, uid int64) error
+}
+
+type twoFactorRepository struct {
+	dao dao.TwoFactorDAO
+}
+
+func NewTwoFactorRepository(dao dao.TwoFactorDAO) TwoFactorRepository {
+	return &twoFactorRepository{dao: dao}
+}
+
+func (r *twoFactorRepository) FindByUid(ctx context.Context, uid int64) (domain.TwoFactor, error) {
+	tf, err := r.dao.FindByUid(ctx, uid)
+	if err != nil {
+		return domain.TwoFactor{}, err
+	}
+	return r.toDomain(tf)
+}
+
+func (r *twoFactorRepository) Upsert(ctx context.Context, tf domain.TwoFactor) error {
+	return r.dao.Upsert(ctx, dao.TwoFactor{
+		Uid:    tf.Uid,
+		Secret: totp.EncodeSecret(tf.Secret),
+	})
+}
+
+func (r *twoFactorRepository) Enable(ctx context.Context, uid int64, step int64, recoveryCodes []string) error {
+	return r.dao.Enable(ctx, uid, step, recoveryCodes)
+}
+
+func (r *twoFactorRepository) UseStep(ctx context.Context, uid int64, step int64) error {
+	return r.dao.UseStep(ctx, uid, step)
+}
+
+func (r *twoFactorRepository) UseRecoveryCode(ctx context.Context, uid int64, code string) error {
+	return r.dao.UseRecoveryCode(ctx, uid, code)
+}
+
+func (r *twoFactorRepository) UpdateRecoveryCodes(ctx context.Context, uid int64, codes []string) error {
+	return r.dao.UpdateRecoveryCodes(ctx, uid, codes)
+}
+
+func (r *twoFactorRepository) Delete(ctx context.Context, uid int64) error {
+	return r.dao.Delete(ctx, uid)
+}
+
+func (r *twoFactorRepository) toDomain(tf dao.TwoFactor) (domain.TwoFactor, error) {
+	secret, err := totp.DecodeSecret(tf.Secret)
+	if err != nil {
+		return domain.TwoFactor{}, err
+	}
+	return domain.TwoFactor{
+		Uid:           tf.Uid,
+		Secret:        secret,
+		RecoveryCodes: tf.Codes(),
+		Enabled:       tf.Enabled,
+		LastStep:      tf.LastStep,
+	}, nil
+}
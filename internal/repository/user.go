@@ -4,35 +4,81 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 	"time"
 	"webok/internal/domain"
 	"webok/internal/repository/cache"
 	"webok/internal/repository/dao"
+	"webok/pkg/cachex"
+	"webok/pkg/logger"
+	"webok/pkg/redisx"
 )
 
+const userProfilePrefix = "user:profile:"
+
 var (
-	ErrDuplicate      = dao.ErrDuplicateEmail
-	ErrRecordNotFound = dao.ErrRecordNotFound
+	ErrDuplicate       = dao.ErrDuplicateEmail
+	ErrDuplicatePhone  = dao.ErrDuplicatePhone
+	ErrDuplicateWechat = dao.ErrDuplicateWechat
+	ErrDuplicateOAuth2 = dao.ErrDuplicateOAuth2
+	ErrLastIdentity    = dao.ErrLastIdentity
+	ErrMergeConflict   = dao.ErrMergeConflict
+	ErrRecordNotFound  = dao.ErrRecordNotFound
 )
 
 //go:generate mockgen -source=user.go -package=repomocks -destination=./mock/user.mock.go
 type UserRepository interface {
 	Create(ctx context.Context, u *domain.User) error
 	UpdateById(ctx context.Context, u *domain.User) error
+	// UpdatePassword password 是加密之后的密码
+	UpdatePassword(ctx context.Context, id int64, password string) error
+	UpdateEmail(ctx context.Context, id int64, email string) error
+	// VerifyEmail 标记邮箱已经验证过，同时作废注册时候的密码，已经验证过的不会再改密码
+	VerifyEmail(ctx context.Context, id int64) error
+	UpdatePhone(ctx context.Context, id int64, phone string) error
+	UpdateWechat(ctx context.Context, id int64, info domain.WechatInfo) error
+	Unlink(ctx context.Context, id int64, typ domain.IdentityType) error
+	UnlinkOAuth2(ctx context.Context, id int64, provider, subject string) error
+	// Merge 返回点赞、收藏计数变了的对象
+	Merge(ctx context.Context, targetId, sourceId int64) ([]domain.BizRef, error)
 	FindByEmail(ctx context.Context, email string) (*domain.User, error)
 	FindById(ctx context.Context, id int64) (*domain.User, error)
 	FindByPhone(ctx context.Context, phone string) (*domain.User, error)
 	FindByWechat(ctx context.Context, openId string) (domain.User, error)
+	FindByOAuth2(ctx context.Context, provider, subject string) (domain.User, error)
+	// CreateWithOAuth2 创建用户并且绑定第三方账号，两个一起成功或者失败
+	CreateWithOAuth2(ctx context.Context, u *domain.User, info domain.OAuth2Info) error
+	// FindOAuth2Identities 用户绑定的第三方账号，不走缓存
+	FindOAuth2Identities(ctx context.Context, uid int64) ([]domain.OAuth2Info, error)
 }
 
 type CachedUserRepository struct {
 	dao   dao.UserDAO
 	cache cache.UserCache
+	local *cachex.TwoLevel[domain.User]
+	// Redis 不可用的时候跳过缓存，直接查数据库
+	health redisx.HealthMonitor
+}
+
+// NewUserProfileCache 用户信息的本地缓存，Redis 那一级还是 UserCache
+func NewUserProfileCache(inv cachex.Invalidator, l logger.Logger) *cachex.TwoLevel[domain.User] {
+	return cachex.NewTwoLevel[domain.User](nil, nil, inv, l, userProfilePrefix, cachex.TwoLevelConfig{
+		Capacity: 10000,
+		LocalTTL: time.Minute,
+		Jitter:   0.2,
+	})
 }
 
+// Create 成功之后 u.Id 是新用户的 id
 func (ur *CachedUserRepository) Create(ctx context.Context, u *domain.User) error {
-	return ur.dao.Insert(ctx, ur.toEntity(u))
+	entity := ur.toEntity(u)
+	err := ur.dao.Insert(ctx, entity)
+	if err != nil {
+		return err
+	}
+	u.Id = entity.Id
+	return nil
 }
 
 func (ur *CachedUserRepository) FindByEmail(ctx context.Context, email string) (*domain.User, error) {
@@ -44,6 +90,28 @@ func (ur *CachedUserRepository) FindByEmail(ctx context.Context, email string) (
 }
 
 func (ur *CachedUserRepository) FindById(ctx context.Context, id int64) (*domain.User, error) {
+	u, err := ur.local.Get(ctx, ur.profileKey(id), func(ctx context.Context) (domain.User, error) {
+		du, err := ur.findById(ctx, id)
+		if err != nil {
+			return domain.User{}, err
+		}
+		return *du, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (ur *CachedUserRepository) findById(ctx context.Context, id int64) (*domain.User, error) {
+	if !ur.health.Healthy() {
+		// 降级，Redis 恢复之前不读也不写缓存
+		u, err := ur.dao.FindById(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return ur.toDomain(u), nil
+	}
 	du, err := ur.cache.Get(ctx, id)
 	// 只要 err 为 nil，就返回
 	switch {
@@ -73,23 +141,121 @@ func (ur *CachedUserRepository) UpdateById(ctx context.Context, u *domain.User)
 	if err != nil {
 		return err
 	}
+	ur.invalidate(ctx, u.Id)
+	return nil
+}
+
+func (ur *CachedUserRepository) UpdatePassword(ctx context.Context, id int64, password string) error {
+	err := ur.dao.UpdatePassword(ctx, id, password)
+	if err != nil {
+		return err
+	}
+	ur.invalidate(ctx, id)
+	return nil
+}
+
+func (ur *CachedUserRepository) UpdateEmail(ctx context.Context, id int64, email string) error {
+	err := ur.dao.UpdateEmail(ctx, id, email)
+	if err != nil {
+		return err
+	}
+	ur.invalidate(ctx, id)
 	return nil
 }
 
-func NewCachedUserRepository(dao dao.UserDAO, cache cache.UserCache) UserRepository {
-	return &CachedUserRepository{dao: dao, cache: cache}
+func (ur *CachedUserRepository) VerifyEmail(ctx context.Context, id int64) error {
+	err := ur.dao.VerifyEmail(ctx, id)
+	if err != nil {
+		return err
+	}
+	ur.invalidate(ctx, id)
+	return nil
+}
+
+func (ur *CachedUserRepository) UpdatePhone(ctx context.Context, id int64, phone string) error {
+	err := ur.dao.UpdatePhone(ctx, id, phone)
+	if err != nil {
+		return err
+	}
+	ur.invalidate(ctx, id)
+	return nil
+}
+
+func (ur *CachedUserRepository) UpdateWechat(ctx context.Context, id int64, info domain.WechatInfo) error {
+	err := ur.dao.UpdateWechat(ctx, id, info.OpenId, info.UnionId)
+	if err != nil {
+		return err
+	}
+	ur.invalidate(ctx, id)
+	return nil
+}
+
+func (ur *CachedUserRepository) Unlink(ctx context.Context, id int64, typ domain.IdentityType) error {
+	var columns []string
+	switch typ {
+	case domain.IdentityEmail:
+		columns = []string{"email"}
+	case domain.IdentityPhone:
+		columns = []string{"phone"}
+	case domain.IdentityWechat:
+		columns = []string{"wechat_open_id", "wechat_union_id"}
+	default:
+		return fmt.Errorf("未知的身份类型 %s", typ)
+	}
+	err := ur.dao.Unlink(ctx, id, columns...)
+	if err != nil {
+		return err
+	}
+	ur.invalidate(ctx, id)
+	return nil
+}
+
+// UnlinkOAuth2 第三方账号不在用户缓存里面，不需要删除缓存
+func (ur *CachedUserRepository) UnlinkOAuth2(ctx context.Context, id int64, provider, subject string) error {
+	return ur.dao.UnlinkOAuth2(ctx, id, provider, subject)
+}
+
+func (ur *CachedUserRepository) Merge(ctx context.Context, targetId, sourceId int64) ([]domain.BizRef, error) {
+	changed, err := ur.dao.Merge(ctx, targetId, sourceId)
+	if err != nil {
+		return nil, err
+	}
+	ur.invalidate(ctx, targetId)
+	ur.invalidate(ctx, sourceId)
+	return changed, nil
+}
+
+// invalidate 修改之后两级缓存都要删掉，不然 Redis 里面还是旧数据。
+// 熔断的时候也要删 Redis，跳过的话 Redis 恢复之后会读到修改之前的数据
+func (ur *CachedUserRepository) invalidate(ctx context.Context, id int64) {
+	if err := ur.cache.Del(ctx, id); err != nil {
+		log.Println(err)
+	}
+	if err := ur.local.Del(ctx, ur.profileKey(id)); err != nil {
+		log.Println(err)
+	}
+}
+
+func (ur *CachedUserRepository) profileKey(id int64) string {
+	return fmt.Sprintf("%s%d", userProfilePrefix, id)
+}
+
+func NewCachedUserRepository(dao dao.UserDAO, cache cache.UserCache, local *cachex.TwoLevel[domain.User],
+	health redisx.HealthMonitor) UserRepository {
+	return &CachedUserRepository{dao: dao, cache: cache, local: local, health: health}
 }
 
 func (ur *CachedUserRepository) toDomain(u *dao.User) *domain.User {
 	return &domain.User{
-		Id:       u.Id,
-		Email:    u.Email.String,
-		Phone:    u.Phone.String,
-		Password: u.Password,
-		Nickname: u.Nickname,
-		Birthday: time.UnixMilli(u.Birthday),
-		AboutMe:  u.AboutMe,
-		Ctime:    time.UnixMilli(u.Ctime),
+		Id:            u.Id,
+		Email:         u.Email.String,
+		EmailVerified: u.EmailVerified,
+		Phone:         u.Phone.String,
+		Password:      u.Password,
+		Nickname:      u.Nickname,
+		Birthday:      time.UnixMilli(u.Birthday),
+		AboutMe:       u.AboutMe,
+		Ctime:         time.UnixMilli(u.Ctime),
 		WechatInfo: domain.WechatInfo{
 			OpenId:  u.WechatOpenId.String,
 			UnionId: u.WechatUnionId.String,
@@ -104,6 +270,7 @@ func (ur *CachedUserRepository) toEntity(u *domain.User) *dao.User {
 			String: u.Email,
 			Valid:  u.Email != "",
 		},
+		EmailVerified: u.EmailVerified,
 		Phone: sql.NullString{
 			String: u.Phone,
 			Valid:  u.Phone != "",
@@ -138,3 +305,37 @@ func (repo *CachedUserRepository) FindByWechat(ctx context.Context, openId strin
 	}
 	return *repo.toDomain(&ue), nil
 }
+
+func (repo *CachedUserRepository) FindByOAuth2(ctx context.Context, provider, subject string) (domain.User, error) {
+	ue, err := repo.dao.FindByOAuth2(ctx, provider, subject)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return *repo.toDomain(&ue), nil
+}
+
+func (repo *CachedUserRepository) CreateWithOAuth2(ctx context.Context, u *domain.User, info domain.OAuth2Info) error {
+	entity := repo.toEntity(u)
+	err := repo.dao.InsertWithOAuth2(ctx, entity, dao.ExternalIdentity{
+		Provider: info.Provider,
+		Subject:  info.Subject,
+		Email:    info.Email,
+	})
+	if err != nil {
+		return err
+	}
+	u.Id = entity.Id
+	return nil
+}
+
+func (repo *CachedUserRepository) FindOAuth2Identities(ctx context.Context, uid int64) ([]domain.OAuth2Info, error) {
+	ids, err := repo.dao.FindExternalIdentities(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.OAuth2Info, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, domain.OAuth2Info{Provider: id.Provider, Subject: id.Subject, Email: id.Email})
+	}
+	return res, nil
+}
@@ -13,12 +13,16 @@ import (
 	cachemocks "webok/internal/repository/cache/mock"
 	"webok/internal/repository/dao"
 	daomocks "webok/internal/repository/dao/mock"
+	"webok/pkg/cachex"
+	"webok/pkg/logger"
+	"webok/pkg/redisx"
+	redisxmocks "webok/pkg/redisx/mock"
 )
 
 func TestCachedUserRepository_FindById(t *testing.T) {
 	testCases := []struct {
 		name string
-		mock func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO)
+		mock func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO, redisx.HealthMonitor)
 		ctx  context.Context
 		uid  int64
 
@@ -27,7 +31,7 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 	}{
 		{
 			name: "查找成功，缓存为命中",
-			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO) {
+			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO, redisx.HealthMonitor) {
 				uid := int64(1)
 				c := cachemocks.NewMockUserCache(ctrl)
 				d := daomocks.NewMockUserDAO(ctrl)
@@ -57,7 +61,7 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 					Phone:    "15212345678",
 					Ctime:    time.UnixMilli(123),
 				}).Return(nil)
-				return c, d
+				return c, d, healthy(ctrl, true)
 			},
 			ctx: context.Background(),
 			uid: 1,
@@ -74,7 +78,7 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 		},
 		{
 			name: "查找成功，缓存命中",
-			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO) {
+			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO, redisx.HealthMonitor) {
 				uid := int64(1)
 				c := cachemocks.NewMockUserCache(ctrl)
 				d := daomocks.NewMockUserDAO(ctrl)
@@ -87,7 +91,7 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 					Phone:    "15212345678",
 					Ctime:    time.UnixMilli(123),
 				}, nil)
-				return c, d
+				return c, d, healthy(ctrl, true)
 			},
 			ctx: context.Background(),
 			uid: 1,
@@ -104,13 +108,13 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 		},
 		{
 			name: "未找到用户",
-			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO) {
+			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO, redisx.HealthMonitor) {
 				uid := int64(1)
 				c := cachemocks.NewMockUserCache(ctrl)
 				d := daomocks.NewMockUserDAO(ctrl)
 				c.EXPECT().Get(gomock.Any(), uid).Return(nil, cache.ErrKeyNotExist)
 				d.EXPECT().FindById(gomock.Any(), uid).Return(nil, ErrRecordNotFound)
-				return c, d
+				return c, d, healthy(ctrl, true)
 			},
 			ctx:      context.Background(),
 			uid:      1,
@@ -119,7 +123,7 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 		},
 		{
 			name: "回写缓存失败",
-			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO) {
+			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO, redisx.HealthMonitor) {
 				uid := int64(1)
 				c := cachemocks.NewMockUserCache(ctrl)
 				d := daomocks.NewMockUserDAO(ctrl)
@@ -149,7 +153,7 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 					Phone:    "15212345678",
 					Ctime:    time.UnixMilli(123),
 				}).Return(errors.New("redis error"))
-				return c, d
+				return c, d, healthy(ctrl, true)
 			},
 			ctx: context.Background(),
 			uid: 1,
@@ -164,14 +168,39 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "Redis 熔断，直接查数据库",
+			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO, redisx.HealthMonitor) {
+				uid := int64(1)
+				c := cachemocks.NewMockUserCache(ctrl)
+				d := daomocks.NewMockUserDAO(ctrl)
+				d.EXPECT().FindById(gomock.Any(), uid).Return(&dao.User{
+					Id:       1,
+					Password: "1231231",
+					Birthday: 1000,
+					Ctime:    123,
+				}, nil)
+				return c, d, healthy(ctrl, false)
+			},
+			ctx: context.Background(),
+			uid: 1,
+			wantUser: &domain.User{
+				Id:       1,
+				Password: "1231231",
+				Birthday: time.UnixMilli(1000),
+				Ctime:    time.UnixMilli(123),
+			},
+			wantErr: nil,
+		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
-			userCache, userDao := tc.mock(ctrl)
-			userRepo := NewCachedUserRepository(userDao, userCache)
+			userCache, userDao, health := tc.mock(ctrl)
+			local := NewUserProfileCache(cachex.NewLocalInvalidator(time.Minute), logger.NewNopLogger())
+			userRepo := NewCachedUserRepository(userDao, userCache, local, health)
 			gotUser, err := userRepo.FindById(tc.ctx, tc.uid)
 			assert.Equal(t, tc.wantErr, err)
 			assert.Equal(t, tc.wantUser, gotUser)
@@ -179,3 +208,71 @@ func TestCachedUserRepository_FindById(t *testing.T) {
 	}
 
 }
+
+func TestCachedUserRepository_UpdatePassword(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO, redisx.HealthMonitor)
+
+		wantErr error
+	}{
+		{
+			name: "更新成功，删除缓存",
+			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO, redisx.HealthMonitor) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				d := daomocks.NewMockUserDAO(ctrl)
+				d.EXPECT().UpdatePassword(gomock.Any(), int64(1), "hash").Return(nil)
+				c.EXPECT().Del(gomock.Any(), int64(1)).Return(nil)
+				return c, d, healthy(ctrl, true)
+			},
+		},
+		{
+			name: "删除缓存失败",
+			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO, redisx.HealthMonitor) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				d := daomocks.NewMockUserDAO(ctrl)
+				d.EXPECT().UpdatePassword(gomock.Any(), int64(1), "hash").Return(nil)
+				c.EXPECT().Del(gomock.Any(), int64(1)).Return(errors.New("redis error"))
+				return c, d, healthy(ctrl, true)
+			},
+		},
+		{
+			name: "Redis 熔断，还是要删除 Redis 缓存",
+			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO, redisx.HealthMonitor) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				d := daomocks.NewMockUserDAO(ctrl)
+				d.EXPECT().UpdatePassword(gomock.Any(), int64(1), "hash").Return(nil)
+				c.EXPECT().Del(gomock.Any(), int64(1)).Return(nil)
+				return c, d, healthy(ctrl, false)
+			},
+		},
+		{
+			name: "数据库错误",
+			mock: func(ctrl *gomock.Controller) (cache.UserCache, dao.UserDAO, redisx.HealthMonitor) {
+				c := cachemocks.NewMockUserCache(ctrl)
+				d := daomocks.NewMockUserDAO(ctrl)
+				d.EXPECT().UpdatePassword(gomock.Any(), int64(1), "hash").Return(errors.New("db error"))
+				return c, d, healthy(ctrl, true)
+			},
+			wantErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			userCache, userDao, health := tc.mock(ctrl)
+			local := NewUserProfileCache(cachex.NewLocalInvalidator(time.Minute), logger.NewNopLogger())
+			userRepo := NewCachedUserRepository(userDao, userCache, local, health)
+			err := userRepo.UpdatePassword(context.Background(), 1, "hash")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func healthy(ctrl *gomock.Controller, ok bool) redisx.HealthMonitor {
+	h := redisxmocks.NewMockHealthMonitor(ctrl)
+	h.EXPECT().Healthy().Return(ok).AnyTimes()
+	return h
+}
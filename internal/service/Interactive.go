@@ -4,6 +4,7 @@ import (
 	"context"
 	"golang.org/x/sync/errgroup"
 	"webok/internal/domain"
+	"webok/internal/events/article"
 	"webok/internal/repository"
 	"webok/pkg/logger"
 )
@@ -17,9 +18,13 @@ type InteractiveService interface {
 	Get(ctx context.Context, biz string, id int64, uid int64) (domain.Interactive, error)
 }
 
+// bizArticle 目前只有文章的互动会发事件
+const bizArticle = "article"
+
 type interactiveService struct {
-	repo repository.InteractiveRepository
-	l    logger.Logger
+	repo     repository.InteractiveRepository
+	producer article.Producer
+	l        logger.Logger
 }
 
 func (i *interactiveService) Get(ctx context.Context, biz string, id int64, uid int64) (domain.Interactive, error) {
@@ -53,21 +58,57 @@ func (i *interactiveService) Get(ctx context.Context, biz string, id int64, uid
 
 func (i *interactiveService) Collect(ctx context.Context, biz string, id int64, cid int64, uid int64) error {
 	err := i.repo.AddCollectionItem(ctx, biz, id, cid, uid)
-	return err
+	if err != nil || biz != bizArticle {
+		return err
+	}
+	i.logProduceErr(i.producer.ProduceCollectedEvent(article.CollectedEvent{
+		Aid: id,
+		Cid: cid,
+		Uid: uid,
+	}), "CollectedEvent", id, uid)
+	return nil
 }
 
 func (i *interactiveService) Like(ctx context.Context, biz string, id int64, uid int64) error {
-	return i.repo.IncrLickCnt(ctx, biz, id, uid)
+	err := i.repo.IncrLickCnt(ctx, biz, id, uid)
+	if err != nil || biz != bizArticle {
+		return err
+	}
+	i.logProduceErr(i.producer.ProduceLikedEvent(article.LikedEvent{
+		Aid: id,
+		Uid: uid,
+	}), "LikedEvent", id, uid)
+	return nil
 }
 
 func (i *interactiveService) CancelLike(ctx context.Context, biz string, id int64, uid int64) error {
-	return i.repo.DecrLickCnt(ctx, biz, id, uid)
+	err := i.repo.DecrLickCnt(ctx, biz, id, uid)
+	if err != nil || biz != bizArticle {
+		return err
+	}
+	i.logProduceErr(i.producer.ProduceUnlikedEvent(article.UnlikedEvent{
+		Aid: id,
+		Uid: uid,
+	}), "UnlikedEvent", id, uid)
+	return nil
+}
+
+// logProduceErr 数据已经改成功了，消息发送失败不影响业务，记录日志
+func (i *interactiveService) logProduceErr(err error, evt string, id int64, uid int64) {
+	if err == nil {
+		return
+	}
+	i.l.Error("发送 "+evt+" 失败",
+		logger.Int64("aid", id),
+		logger.Int64("uid", uid),
+		logger.Error(err))
 }
 
-func NewInteractiveService(repo repository.InteractiveRepository, log logger.Logger) InteractiveService {
+func NewInteractiveService(repo repository.InteractiveRepository, producer article.Producer, log logger.Logger) InteractiveService {
 	return &interactiveService{
-		repo: repo,
-		l:    log,
+		repo:     repo,
+		producer: producer,
+		l:        log,
 	}
 }
 
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"webok/internal/events/article"
+	evtmocks "webok/internal/events/article/mock"
+	"webok/internal/repository"
+	repomocks "webok/internal/repository/mock"
+	"webok/pkg/logger"
+)
+
+func Test_interactiveService_Like(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer)
+		biz  string
+
+		wantErr error
+	}{
+		{
+			name: "点赞成功，发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().IncrLickCnt(gomock.Any(), "article", int64(1), int64(123)).Return(nil)
+				producer := evtmocks.NewMockProducer(ctrl)
+				producer.EXPECT().ProduceLikedEvent(article.LikedEvent{Aid: 1, Uid: 123}).Return(nil)
+				return repo, producer
+			},
+			biz: "article",
+		},
+		{
+			name: "发送事件失败，不影响点赞",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().IncrLickCnt(gomock.Any(), "article", int64(1), int64(123)).Return(nil)
+				producer := evtmocks.NewMockProducer(ctrl)
+				producer.EXPECT().ProduceLikedEvent(article.LikedEvent{Aid: 1, Uid: 123}).
+					Return(errors.New("mock error"))
+				return repo, producer
+			},
+			biz: "article",
+		},
+		{
+			name: "点赞失败，不发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().IncrLickCnt(gomock.Any(), "article", int64(1), int64(123)).
+					Return(errors.New("mock db error"))
+				producer := evtmocks.NewMockProducer(ctrl)
+				return repo, producer
+			},
+			biz:     "article",
+			wantErr: errors.New("mock db error"),
+		},
+		{
+			name: "其它业务不发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().IncrLickCnt(gomock.Any(), "video", int64(1), int64(123)).Return(nil)
+				producer := evtmocks.NewMockProducer(ctrl)
+				return repo, producer
+			},
+			biz: "video",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, producer := tc.mock(ctrl)
+			svc := NewInteractiveService(repo, producer, logger.NewNopLogger())
+			err := svc.Like(context.Background(), tc.biz, 1, 123)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_interactiveService_CancelLike(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer)
+		biz  string
+
+		wantErr error
+	}{
+		{
+			name: "取消点赞成功，发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().DecrLickCnt(gomock.Any(), "article", int64(1), int64(123)).Return(nil)
+				producer := evtmocks.NewMockProducer(ctrl)
+				producer.EXPECT().ProduceUnlikedEvent(article.UnlikedEvent{Aid: 1, Uid: 123}).Return(nil)
+				return repo, producer
+			},
+			biz: "article",
+		},
+		{
+			name: "发送事件失败，不影响取消点赞",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().DecrLickCnt(gomock.Any(), "article", int64(1), int64(123)).Return(nil)
+				producer := evtmocks.NewMockProducer(ctrl)
+				producer.EXPECT().ProduceUnlikedEvent(article.UnlikedEvent{Aid: 1, Uid: 123}).
+					Return(errors.New("mock error"))
+				return repo, producer
+			},
+			biz: "article",
+		},
+		{
+			name: "取消点赞失败，不发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().DecrLickCnt(gomock.Any(), "article", int64(1), int64(123)).
+					Return(errors.New("mock db error"))
+				return repo, evtmocks.NewMockProducer(ctrl)
+			},
+			biz:     "article",
+			wantErr: errors.New("mock db error"),
+		},
+		{
+			name: "其它业务不发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().DecrLickCnt(gomock.Any(), "video", int64(1), int64(123)).Return(nil)
+				return repo, evtmocks.NewMockProducer(ctrl)
+			},
+			biz: "video",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, producer := tc.mock(ctrl)
+			svc := NewInteractiveService(repo, producer, logger.NewNopLogger())
+			err := svc.CancelLike(context.Background(), tc.biz, 1, 123)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func Test_interactiveService_Collect(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer)
+		biz  string
+
+		wantErr error
+	}{
+		{
+			name: "收藏成功，发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().AddCollectionItem(gomock.Any(), "article", int64(1), int64(2), int64(123)).Return(nil)
+				producer := evtmocks.NewMockProducer(ctrl)
+				producer.EXPECT().ProduceCollectedEvent(article.CollectedEvent{Aid: 1, Cid: 2, Uid: 123}).Return(nil)
+				return repo, producer
+			},
+			biz: "article",
+		},
+		{
+			name: "发送事件失败，不影响收藏",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().AddCollectionItem(gomock.Any(), "article", int64(1), int64(2), int64(123)).Return(nil)
+				producer := evtmocks.NewMockProducer(ctrl)
+				producer.EXPECT().ProduceCollectedEvent(article.CollectedEvent{Aid: 1, Cid: 2, Uid: 123}).
+					Return(errors.New("mock error"))
+				return repo, producer
+			},
+			biz: "article",
+		},
+		{
+			name: "收藏失败，不发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().AddCollectionItem(gomock.Any(), "article", int64(1), int64(2), int64(123)).
+					Return(errors.New("mock db error"))
+				return repo, evtmocks.NewMockProducer(ctrl)
+			},
+			biz:     "article",
+			wantErr: errors.New("mock db error"),
+		},
+		{
+			name: "其它业务不发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.InteractiveRepository, article.Producer) {
+				repo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().AddCollectionItem(gomock.Any(), "video", int64(1), int64(2), int64(123)).Return(nil)
+				return repo, evtmocks.NewMockProducer(ctrl)
+			},
+			biz: "video",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, producer := tc.mock(ctrl)
+			svc := NewInteractiveService(repo, producer, logger.NewNopLogger())
+			err := svc.Collect(context.Background(), tc.biz, 1, 2, 123)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
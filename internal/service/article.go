@@ -41,7 +41,7 @@ func (a *articleService) GetPubById(ctx context.Context, id, uid int64) (domain.
 				a.l.Error("发送 ReadEvent 失败",
 					logger.Int64("aid", id),
 					logger.Int64("uid", uid),
-					logger.Error(err))
+					logger.Error(er))
 			}
 		}
 	}()
@@ -53,15 +53,32 @@ func (a *articleService) GetById(ctx context.Context, id int64) (domain.Article,
 	return a.repo.GetById(ctx, id)
 }
 
-func (a *articleService) Publish(ctx context.Context, article domain.Article) (int64, error) {
-	article.Status = domain.ArticleStatusPublished
-	return a.repo.Sync(ctx, article)
+func (a *articleService) Publish(ctx context.Context, art domain.Article) (int64, error) {
+	art.Status = domain.ArticleStatusPublished
+	id, err := a.repo.Sync(ctx, art)
+	if err != nil {
+		return id, err
+	}
+	// 数据已经落库了，消息发送失败只记录日志
+	er := a.producer.ProducePublishedEvent(article.PublishedEvent{
+		Aid:      id,
+		AuthorId: art.Author.Id,
+		Title:    art.Title,
+	})
+	if er != nil {
+		a.l.Error("发送 PublishedEvent 失败",
+			logger.Int64("aid", id),
+			logger.Int64("uid", art.Author.Id),
+			logger.Error(er))
+	}
+	return id, nil
 }
 
-func NewArticleService(repo repository.ArticleRepository, producer article.Producer) ArticleService {
+func NewArticleService(repo repository.ArticleRepository, producer article.Producer, l logger.Logger) ArticleService {
 	return &articleService{
 		repo:     repo,
 		producer: producer,
+		l:        l,
 	}
 }
 
@@ -121,7 +138,21 @@ func (a *articleService) Save(ctx context.Context, article domain.Article) (int6
 }
 
 func (a *articleService) Withdraw(ctx context.Context, uid int64, articleId int64) error {
-	return a.repo.SyncStatus(ctx, uid, articleId)
+	err := a.repo.SyncStatus(ctx, uid, articleId)
+	if err != nil {
+		return err
+	}
+	er := a.producer.ProduceWithdrawnEvent(article.WithdrawnEvent{
+		Aid:      articleId,
+		AuthorId: uid,
+	})
+	if er != nil {
+		a.l.Error("发送 WithdrawnEvent 失败",
+			logger.Int64("aid", articleId),
+			logger.Int64("uid", uid),
+			logger.Error(er))
+	}
+	return nil
 }
 
 func (a *articleService) GetByAuthor(ctx context.Context, uid int64, offset int, limit int) ([]domain.Article, error) {
@@ -7,6 +7,8 @@ import (
 	"go.uber.org/mock/gomock"
 	"testing"
 	"webok/internal/domain"
+	"webok/internal/events/article"
+	evtmocks "webok/internal/events/article/mock"
 	"webok/internal/repository"
 	repomocks "webok/internal/repository/mock"
 	"webok/pkg/logger"
@@ -31,6 +33,7 @@ func Test_articleService_Publish(t *testing.T) {
 						Id:   123,
 						Name: "name",
 					},
+					Status: domain.ArticleStatusPublished,
 				}).Return(int64(1), nil)
 
 				reader := repomocks.NewMockArticleReaderRepository(ctrl)
@@ -42,6 +45,7 @@ func Test_articleService_Publish(t *testing.T) {
 						Id:   123,
 						Name: "name",
 					},
+					Status: domain.ArticleStatusPublished,
 				}).Return(nil)
 
 				return author, reader
@@ -68,6 +72,7 @@ func Test_articleService_Publish(t *testing.T) {
 						Id:   123,
 						Name: "name",
 					},
+					Status: domain.ArticleStatusPublished,
 				}).Return(int64(1), nil)
 
 				reader := repomocks.NewMockArticleReaderRepository(ctrl)
@@ -79,6 +84,7 @@ func Test_articleService_Publish(t *testing.T) {
 						Id:   123,
 						Name: "name",
 					},
+					Status: domain.ArticleStatusPublished,
 				}).Return(errors.New("publish error")).MaxTimes(3)
 
 				return author, reader
@@ -106,6 +112,7 @@ func Test_articleService_Publish(t *testing.T) {
 						Id:   123,
 						Name: "name",
 					},
+					Status: domain.ArticleStatusPublished,
 				}).Return(nil)
 
 				reader := repomocks.NewMockArticleReaderRepository(ctrl)
@@ -117,6 +124,7 @@ func Test_articleService_Publish(t *testing.T) {
 						Id:   123,
 						Name: "name",
 					},
+					Status: domain.ArticleStatusPublished,
 				}).Return(errors.New("publish error")).Times(3)
 
 				return author, reader
@@ -145,6 +153,7 @@ func Test_articleService_Publish(t *testing.T) {
 						Id:   123,
 						Name: "name",
 					},
+					Status: domain.ArticleStatusPublished,
 				}).Return(nil)
 
 				reader := repomocks.NewMockArticleReaderRepository(ctrl)
@@ -156,6 +165,7 @@ func Test_articleService_Publish(t *testing.T) {
 						Id:   123,
 						Name: "name",
 					},
+					Status: domain.ArticleStatusPublished,
 				}).Return(errors.New("publish error")).Times(2)
 				reader.EXPECT().Save(gomock.Any(), domain.Article{
 					Id:      1,
@@ -165,6 +175,7 @@ func Test_articleService_Publish(t *testing.T) {
 						Id:   123,
 						Name: "name",
 					},
+					Status: domain.ArticleStatusPublished,
 				}).Return(nil)
 
 				return author, reader
@@ -193,6 +204,7 @@ func Test_articleService_Publish(t *testing.T) {
 						Id:   123,
 						Name: "name",
 					},
+					Status: domain.ArticleStatusPublished,
 				}).Return(int64(0), errors.New("create error"))
 
 				return author, nil
@@ -216,10 +228,119 @@ func Test_articleService_Publish(t *testing.T) {
 			defer ctrl.Finish()
 
 			authorRepo, readerRepo := tc.mock(ctrl)
-			svc := NewArticleServiceV1(readerRepo, authorRepo, l)
+			svc := NewArticleServiceV1(readerRepo, authorRepo, l, nil)
 			gotId, err := svc.PublishV1(context.Background(), tc.art)
 			assert.Equal(t, tc.wantErr, err)
 			assert.Equal(t, tc.wantId, gotId)
 		})
 	}
 }
+
+func Test_articleService_PublishEvent(t *testing.T) {
+	art := domain.Article{Title: "标题", Author: domain.Author{Id: 123}}
+	synced := domain.Article{Title: "标题", Author: domain.Author{Id: 123}, Status: domain.ArticleStatusPublished}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (repository.ArticleRepository, article.Producer)
+
+		wantId  int64
+		wantErr error
+	}{
+		{
+			name: "发表成功，发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.ArticleRepository, article.Producer) {
+				repo := repomocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().Sync(gomock.Any(), synced).Return(int64(1), nil)
+				producer := evtmocks.NewMockProducer(ctrl)
+				producer.EXPECT().ProducePublishedEvent(article.PublishedEvent{Aid: 1, AuthorId: 123, Title: "标题"}).
+					Return(nil)
+				return repo, producer
+			},
+			wantId: 1,
+		},
+		{
+			name: "发送事件失败，不影响发表",
+			mock: func(ctrl *gomock.Controller) (repository.ArticleRepository, article.Producer) {
+				repo := repomocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().Sync(gomock.Any(), synced).Return(int64(1), nil)
+				producer := evtmocks.NewMockProducer(ctrl)
+				producer.EXPECT().ProducePublishedEvent(article.PublishedEvent{Aid: 1, AuthorId: 123, Title: "标题"}).
+					Return(errors.New("mock error"))
+				return repo, producer
+			},
+			wantId: 1,
+		},
+		{
+			name: "发表失败，不发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.ArticleRepository, article.Producer) {
+				repo := repomocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().Sync(gomock.Any(), synced).Return(int64(0), errors.New("mock db error"))
+				return repo, evtmocks.NewMockProducer(ctrl)
+			},
+			wantErr: errors.New("mock db error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, producer := tc.mock(ctrl)
+			svc := NewArticleService(repo, producer, logger.NewNopLogger())
+			id, err := svc.Publish(context.Background(), art)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantId, id)
+		})
+	}
+}
+
+func Test_articleService_Withdraw(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (repository.ArticleRepository, article.Producer)
+
+		wantErr error
+	}{
+		{
+			name: "撤回成功，发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.ArticleRepository, article.Producer) {
+				repo := repomocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().SyncStatus(gomock.Any(), int64(123), int64(1)).Return(nil)
+				producer := evtmocks.NewMockProducer(ctrl)
+				producer.EXPECT().ProduceWithdrawnEvent(article.WithdrawnEvent{Aid: 1, AuthorId: 123}).Return(nil)
+				return repo, producer
+			},
+		},
+		{
+			name: "发送事件失败，不影响撤回",
+			mock: func(ctrl *gomock.Controller) (repository.ArticleRepository, article.Producer) {
+				repo := repomocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().SyncStatus(gomock.Any(), int64(123), int64(1)).Return(nil)
+				producer := evtmocks.NewMockProducer(ctrl)
+				producer.EXPECT().ProduceWithdrawnEvent(article.WithdrawnEvent{Aid: 1, AuthorId: 123}).
+					Return(errors.New("mock error"))
+				return repo, producer
+			},
+		},
+		{
+			name: "撤回失败，不发送事件",
+			mock: func(ctrl *gomock.Controller) (repository.ArticleRepository, article.Producer) {
+				repo := repomocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().SyncStatus(gomock.Any(), int64(123), int64(1)).Return(errors.New("mock db error"))
+				return repo, evtmocks.NewMockProducer(ctrl)
+			},
+			wantErr: errors.New("mock db error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, producer := tc.mock(ctrl)
+			svc := NewArticleService(repo, producer, logger.NewNopLogger())
+			err := svc.Withdraw(context.Background(), 123, 1)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
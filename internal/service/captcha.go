@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/repository"
+	"webok/pkg/captcha"
+	"webok/pkg/logger"
+)
+
+var ErrCaptchaInvalid = errors.New("人机验证失败")
+
+// ticketSep 票据里面分隔挑战 id 和 secret，uuid 里面没有点
+const ticketSep = "."
+
+const (
+	// CaptchaModeOff 不需要人机验证
+	CaptchaModeOff = "off"
+	// CaptchaModeAlways 每次都要验证
+	CaptchaModeAlways = "always"
+	// CaptchaModeSuspicious 出现可疑行为之后才要求验证
+	CaptchaModeSuspicious = "suspicious"
+)
+
+//go:generate mockgen -source=captcha.go -package=svcmocks -destination=./mock/captcha.mock.go
+type CaptchaService interface {
+	Generate(ctx context.Context, biz string) (domain.Captcha, error)
+	// Verify 验证通过返回票据，发送验证码之类的操作要带上票据。
+	// 票据是挑战的 id 加上随机的 secret，只能换一次，而且只对这个挑战有效
+	Verify(ctx context.Context, biz, id, answer string) (string, error)
+	// Required ip 在 biz 上是否需要先通过人机验证
+	Required(ctx context.Context, biz, ip string) (bool, error)
+	// CheckTicket 校验并且作废票据
+	CheckTicket(ctx context.Context, biz, ticket string) (bool, error)
+	// MarkSuspicious 记录一次可疑行为，比如发送太频繁
+	MarkSuspicious(ctx context.Context, biz, ip string)
+}
+
+type CaptchaBizConfig struct {
+	Mode string
+	// Type digits 或者 slider
+	Type string
+}
+
+type CaptchaConfig struct {
+	AnswerTTL time.Duration
+	TicketTTL time.Duration
+	// SuspiciousWindow 内出现 SuspiciousThreshold 次可疑行为之后，suspicious 模式的业务要求验证
+	SuspiciousThreshold int64
+	SuspiciousWindow    time.Duration
+	Biz                 map[string]CaptchaBizConfig
+}
+
+type captchaService struct {
+	repo       repository.CaptchaRepository
+	cfg        CaptchaConfig
+	generators map[string]captcha.Generator
+	l          logger.Logger
+}
+
+func NewCaptchaService(repo repository.CaptchaRepository, cfg CaptchaConfig, l logger.Logger) CaptchaService {
+	return &captchaService{
+		repo: repo,
+		cfg:  cfg,
+		generators: map[string]captcha.Generator{
+			captcha.TypeDigits: captcha.NewDigitGenerator(),
+			captcha.TypeSlider: captcha.NewSliderGenerator(),
+		},
+		l: l,
+	}
+}
+
+func (svc *captchaService) Generate(ctx context.Context, biz string) (domain.Captcha, error) {
+	typ := svc.cfg.Biz[biz].Type
+	if typ == "" {
+		typ = captcha.TypeDigits
+	}
+	g, ok := svc.generators[typ]
+	if !ok {
+		return domain.Captcha{}, fmt.Errorf("未知的人机验证类型 %s", typ)
+	}
+	c, answer, err := g.Generate()
+	if err != nil {
+		return domain.Captcha{}, err
+	}
+	id := uuid.New().String()
+	err = svc.repo.SetAnswer(ctx, biz, id, typ, answer, svc.cfg.AnswerTTL)
+	if err != nil {
+		return domain.Captcha{}, err
+	}
+	return domain.Captcha{Id: id, Type: c.Type, Images: c.Images, Y: c.Y}, nil
+}
+
+func (svc *captchaService) Verify(ctx context.Context, biz, id, answer string) (string, error) {
+	typ, want, err := svc.repo.TakeAnswer(ctx, biz, id)
+	if errors.Is(err, repository.ErrCaptchaNotFound) {
+		return "", ErrCaptchaInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+	g, ok := svc.generators[typ]
+	if !ok || !g.Match(want, answer) {
+		return "", ErrCaptchaInvalid
+	}
+	secret := uuid.New().String()
+	err = svc.repo.SetTicket(ctx, biz, id, secret, svc.cfg.TicketTTL)
+	if err != nil {
+		return "", err
+	}
+	return id + ticketSep + secret, nil
+}
+
+func (svc *captchaService) Required(ctx context.Context, biz, ip string) (bool, error) {
+	switch svc.cfg.Biz[biz].Mode {
+	case CaptchaModeAlways:
+		return true, nil
+	case CaptchaModeSuspicious:
+		cnt, err := svc.repo.Suspicious(ctx, biz, ip)
+		if err != nil {
+			return false, err
+		}
+		return cnt >= svc.cfg.SuspiciousThreshold, nil
+	default:
+		return false, nil
+	}
+}
+
+func (svc *captchaService) CheckTicket(ctx context.Context, biz, ticket string) (bool, error) {
+	id, secret, ok := strings.Cut(ticket, ticketSep)
+	if !ok || id == "" || secret == "" {
+		return false, nil
+	}
+	return svc.repo.TakeTicket(ctx, biz, id, secret)
+}
+
+func (svc *captchaService) MarkSuspicious(ctx context.Context, biz, ip string) {
+	if svc.cfg.Biz[biz].Mode != CaptchaModeSuspicious {
+		return
+	}
+	err := svc.repo.IncrSuspicious(ctx, biz, ip, svc.cfg.SuspiciousWindow)
+	if err != nil {
+		svc.l.Error("记录可疑行为失败",
+			logger.String("biz", biz),
+			logger.String("ip", ip),
+			logger.Error(err))
+	}
+}
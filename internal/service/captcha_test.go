@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"strings"
+	"testing"
+	"time"
+	"webok/internal/repository"
+	repomocks "webok/internal/repository/mock"
+	"webok/pkg/logger"
+)
+
+func TestCaptchaService_Verify(t *testing.T) {
+	testCases := []struct {
+		name   string
+		mock   func(ctrl *gomock.Controller) repository.CaptchaRepository
+		answer string
+
+		wantTicket bool
+		wantErr    error
+	}{
+		{
+			name: "验证通过",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().TakeAnswer(gomock.Any(), "login", "id1").Return("digits", "12345", nil)
+				repo.EXPECT().SetTicket(gomock.Any(), "login", "id1", gomock.Any(), time.Minute).Return(nil)
+				return repo
+			},
+			answer:     "12345",
+			wantTicket: true,
+		},
+		{
+			name: "答案错误",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().TakeAnswer(gomock.Any(), "login", "id1").Return("digits", "12345", nil)
+				return repo
+			},
+			answer:  "54321",
+			wantErr: ErrCaptchaInvalid,
+		},
+		{
+			name: "已经用过或者过期",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().TakeAnswer(gomock.Any(), "login", "id1").Return("", "", repository.ErrCaptchaNotFound)
+				return repo
+			},
+			answer:  "12345",
+			wantErr: ErrCaptchaInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewCaptchaService(tc.mock(ctrl), CaptchaConfig{TicketTTL: time.Minute}, logger.NewNopLogger())
+			ticket, err := svc.Verify(context.Background(), "login", "id1", tc.answer)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantTicket, ticket != "")
+			if tc.wantTicket {
+				// 票据带着挑战的 id
+				assert.True(t, strings.HasPrefix(ticket, "id1"+ticketSep))
+			}
+		})
+	}
+}
+
+func TestCaptchaService_CheckTicket(t *testing.T) {
+	testCases := []struct {
+		name   string
+		mock   func(ctrl *gomock.Controller) repository.CaptchaRepository
+		ticket string
+
+		want bool
+	}{
+		{
+			name: "票据有效",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().TakeTicket(gomock.Any(), "login", "id1", "secret").Return(true, nil)
+				return repo
+			},
+			ticket: "id1.secret",
+			want:   true,
+		},
+		{
+			name: "票据无效",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().TakeTicket(gomock.Any(), "login", "id2", "secret").Return(false, nil)
+				return repo
+			},
+			ticket: "id2.secret",
+		},
+		{
+			name: "没有票据",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				return repomocks.NewMockCaptchaRepository(ctrl)
+			},
+		},
+		{
+			name: "没有挑战 id 的旧票据",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				return repomocks.NewMockCaptchaRepository(ctrl)
+			},
+			ticket: "f47ac10b-58cc-4372-a567-0e02b2c3d479",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewCaptchaService(tc.mock(ctrl), CaptchaConfig{}, logger.NewNopLogger())
+			ok, err := svc.CheckTicket(context.Background(), "login", tc.ticket)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, ok)
+		})
+	}
+}
+
+func TestCaptchaService_Required(t *testing.T) {
+	cfg := CaptchaConfig{
+		SuspiciousThreshold: 3,
+		Biz: map[string]CaptchaBizConfig{
+			"login":  {Mode: CaptchaModeSuspicious},
+			"signup": {Mode: CaptchaModeAlways},
+		},
+	}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.CaptchaRepository
+		biz  string
+
+		want bool
+	}{
+		{
+			name: "总是需要",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				return repomocks.NewMockCaptchaRepository(ctrl)
+			},
+			biz:  "signup",
+			want: true,
+		},
+		{
+			name: "没有配置的业务不需要",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				return repomocks.NewMockCaptchaRepository(ctrl)
+			},
+			biz: "reset",
+		},
+		{
+			name: "可疑行为没有达到阈值",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().Suspicious(gomock.Any(), "login", "127.0.0.1").Return(int64(2), nil)
+				return repo
+			},
+			biz: "login",
+		},
+		{
+			name: "可疑行为达到阈值",
+			mock: func(ctrl *gomock.Controller) repository.CaptchaRepository {
+				repo := repomocks.NewMockCaptchaRepository(ctrl)
+				repo.EXPECT().Suspicious(gomock.Any(), "login", "127.0.0.1").Return(int64(3), nil)
+				return repo
+			},
+			biz:  "login",
+			want: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewCaptchaService(tc.mock(ctrl), cfg, logger.NewNopLogger())
+			got, err := svc.Required(context.Background(), tc.biz, "127.0.0.1")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"webok/internal/service/email"
+)
+
+// EmailTemplate 邮件模板，参数按照顺序用 {{index . 0}} 引用
+type EmailTemplate struct {
+	Subject string
+	Body    string
+}
+
+type emailTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// EmailChannel 邮件没有服务商的模板，模板 ID 对应本地配置的模板
+type EmailChannel struct {
+	svc       email.Service
+	provider  string
+	templates map[string]emailTemplate
+}
+
+func NewEmailChannel(svc email.Service, provider string, templates map[string]EmailTemplate) (*EmailChannel, error) {
+	c := &EmailChannel{svc: svc, provider: provider, templates: make(map[string]emailTemplate, len(templates))}
+	for id, tpl := range templates {
+		subject, err := template.New(id + ":subject").Parse(tpl.Subject)
+		if err != nil {
+			return nil, err
+		}
+		body, err := template.New(id + ":body").Parse(tpl.Body)
+		if err != nil {
+			return nil, err
+		}
+		c.templates[id] = emailTemplate{subject: subject, body: body}
+	}
+	return c, nil
+}
+
+func (c *EmailChannel) Type() string {
+	return TypeEmail
+}
+
+func (c *EmailChannel) Provider() string {
+	return c.provider
+}
+
+func (c *EmailChannel) Send(ctx context.Context, tplId string, args []string, to ...string) error {
+	tpl, ok := c.templates[tplId]
+	if !ok {
+		return fmt.Errorf("邮件模板 %s 不存在", tplId)
+	}
+	var subject, body strings.Builder
+	if err := tpl.subject.Execute(&subject, args); err != nil {
+		return err
+	}
+	if err := tpl.body.Execute(&body, args); err != nil {
+		return err
+	}
+	return c.svc.Send(ctx, subject.String(), body.String(), to...)
+}
@@ -0,0 +1,88 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: types.go
+//
+// Generated by this command:
+//
+//	mockgen -source=types.go -package=channelmocks -destination=./mock/channel.mock.go
+//
+
+// Package channelmocks is a generated GoMock package.
+package channelmocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockChannel is a mock of Channel interface.
+type MockChannel struct {
+	ctrl     *gomock.Controller
+	recorder *MockChannelMockRecorder
+	isgomock struct{}
+}
+
+// MockChannelMockRecorder is the mock recorder for MockChannel.
+type MockChannelMockRecorder struct {
+	mock *MockChannel
+}
+
+// NewMockChannel creates a new mock instance.
+func NewMockChannel(ctrl *gomock.Controller) *MockChannel {
+	mock := &MockChannel{ctrl: ctrl}
+	mock.recorder = &MockChannelMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockChannel) EXPECT() *MockChannelMockRecorder {
+	return m.recorder
+}
+
+// Provider mocks base method.
+func (m *MockChannel) Provider() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Provider")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Provider indicates an expected call of Provider.
+func (mr *MockChannelMockRecorder) Provider() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Provider", reflect.TypeOf((*MockChannel)(nil).Provider))
+}
+
+// Send mocks base method.
+func (m *MockChannel) Send(ctx context.Context, tplId string, args []string, to ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, tplId, args}
+	for _, a := range to {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Send", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockChannelMockRecorder) Send(ctx, tplId, args any, to ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, tplId, args}, to...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockChannel)(nil).Send), varargs...)
+}
+
+// Type mocks base method.
+func (m *MockChannel) Type() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Type")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Type indicates an expected call of Type.
+func (mr *MockChannelMockRecorder) Type() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Type", reflect.TypeOf((*MockChannel)(nil).Type))
+}
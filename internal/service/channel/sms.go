@@ -0,0 +1,27 @@
+package channel
+
+import (
+	"context"
+	"webok/internal/service/sms"
+)
+
+type SMSChannel struct {
+	svc      sms.Service
+	provider string
+}
+
+func NewSMSChannel(svc sms.Service, provider string) *SMSChannel {
+	return &SMSChannel{svc: svc, provider: provider}
+}
+
+func (c *SMSChannel) Type() string {
+	return TypeSMS
+}
+
+func (c *SMSChannel) Provider() string {
+	return c.provider
+}
+
+func (c *SMSChannel) Send(ctx context.Context, tplId string, args []string, to ...string) error {
+	return c.svc.Send(ctx, tplId, args, to...)
+}
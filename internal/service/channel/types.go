@@ -0,0 +1,19 @@
+package channel
+
+import "context"
+
+const (
+	TypeSMS   = "sms"
+	TypeEmail = "email"
+)
+
+// Channel 发送验证码之类的消息的渠道，屏蔽短信和邮件的差异
+//
+//go:generate mockgen -source=types.go -package=channelmocks -destination=./mock/channel.mock.go
+type Channel interface {
+	// Type sms 或者 email
+	Type() string
+	// Provider 服务商，不同服务商的模板 ID 不一样
+	Provider() string
+	Send(ctx context.Context, tplId string, args []string, to ...string) error
+}
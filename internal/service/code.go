@@ -2,58 +2,245 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
-	"math/rand"
+	"math/big"
+	"time"
+	"webok/internal/domain"
 	"webok/internal/repository"
-	"webok/internal/service/sms"
+	"webok/internal/service/channel"
+	"webok/pkg/logger"
 )
 
-var ErrCodeSendTooMany = repository.ErrCodeSendTooMany
+// codeAuditBuffer 被刷的时候审计日志可能比数据库写得快，缓冲区满了直接丢掉
+const codeAuditBuffer = 1024
+
+var (
+	ErrCodeSendTooMany   = repository.ErrCodeSendTooMany
+	ErrCodeVerifyTooMany = repository.ErrCodeVerifyTooMany
+	ErrCodeQuotaExceeded = repository.ErrCodeQuotaExceeded
+)
 
 //go:generate mockgen -source=code.go -package=svcmocks -destination=./mock/code.mock.go
 type CodeService interface {
-	generate() string
-	Verify(ctx context.Context, biz, phone, inputCode string) (bool, error)
-	Send(ctx context.Context, biz, phone string) error
+	// Verify target 是手机号或者邮箱，取决于 biz 配置的渠道。
+	// 验证次数太多返回 ErrCodeVerifyTooMany，调用方可以当成验证失败
+	Verify(ctx context.Context, biz, target, inputCode string) (bool, error)
+	// Send ip 是请求方的 IP，用来限制同一个 IP 发送的数量
+	Send(ctx context.Context, biz, target, ip string) error
+}
+
+// CodeQuotaConfig 每个业务的发送限额，业务里面没有配置的字段使用 Default 的。
+// 0 表示不限制，所以业务不能把 Default 里面的限制去掉，只能改成别的值
+type CodeQuotaConfig struct {
+	Default domain.CodeQuota
+	Biz     map[string]domain.CodeQuota
+	// TotalPerDay 所有业务共用的限额，防止攻击者换着业务刷
+	TotalPerDay int
+}
+
+func (c CodeQuotaConfig) For(biz string) domain.CodeQuota {
+	q, ok := c.Biz[biz]
+	if !ok {
+		q = c.Default
+		q.TotalPerDay = c.TotalPerDay
+		return q
+	}
+	q.TotalPerDay = c.TotalPerDay
+	if q.PhonePerDay <= 0 {
+		q.PhonePerDay = c.Default.PhonePerDay
+	}
+	if q.IPPerHour <= 0 {
+		q.IPPerHour = c.Default.IPPerHour
+	}
+	if q.GlobalPerDay <= 0 {
+		q.GlobalPerDay = c.Default.GlobalPerDay
+	}
+	return q
+}
+
+// CodePolicyConfig 每个业务的验证码策略，业务里面没有配置的字段使用 Default 的
+type CodePolicyConfig struct {
+	Default domain.CodePolicy
+	Biz     map[string]domain.CodePolicy
+}
+
+func (c CodePolicyConfig) For(biz string) domain.CodePolicy {
+	p, ok := c.Biz[biz]
+	if !ok {
+		return c.Default
+	}
+	if p.Channel == "" {
+		p.Channel = c.Default.Channel
+	}
+	if p.Length <= 0 {
+		p.Length = c.Default.Length
+	}
+	if p.Alphabet == "" {
+		p.Alphabet = c.Default.Alphabet
+	}
+	if p.TTL <= 0 {
+		p.TTL = c.Default.TTL
+	}
+	if p.ResendInterval <= 0 {
+		p.ResendInterval = c.Default.ResendInterval
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = c.Default.MaxAttempts
+	}
+	if len(p.Templates) == 0 {
+		p.Templates = c.Default.Templates
+	}
+	return p
 }
 
 type NormalCodeService struct {
-	repo repository.CodeRepository
-	sms  sms.Service
+	repo     repository.CodeRepository
+	quota    repository.CodeQuotaRepository
+	channels map[string]channel.Channel
+	quotas   CodeQuotaConfig
+	policies CodePolicyConfig
+	audits   chan domain.CodeAudit
+	l        logger.Logger
 }
 
-func (svc *NormalCodeService) Send(ctx context.Context, biz, phone string) error {
-	code := svc.generate()
-	err := svc.repo.Set(ctx, biz, phone, code)
+func (svc *NormalCodeService) Send(ctx context.Context, biz, target, ip string) error {
+	policy := svc.policies.For(biz)
+	ch, ok := svc.channels[policy.Channel]
+	if !ok {
+		return fmt.Errorf("业务 %s 的发送渠道 %s 不存在", biz, policy.Channel)
+	}
+	tplId, ok := policy.Templates[ch.Provider()]
+	if !ok {
+		return fmt.Errorf("业务 %s 没有配置 %s 的模板", biz, ch.Provider())
+	}
+	usage, err := svc.quota.Acquire(ctx, biz, target, ip, svc.quotas.For(biz))
+	if err != nil {
+		if errors.Is(err, ErrCodeQuotaExceeded) {
+			svc.audit(ctx, biz, target, ip, err)
+		}
+		return err
+	}
+	code, err := svc.generate(policy)
+	if err != nil {
+		svc.release(ctx, usage)
+		return err
+	}
+	err = svc.repo.Set(ctx, biz, target, code, policy.TTL, policy.ResendInterval, policy.MaxAttempts)
 	// 你在这儿，是不是要开始发送验证码了？
 	if err != nil {
+		if errors.Is(err, ErrCodeSendTooMany) {
+			svc.audit(ctx, biz, target, ip, err)
+		}
+		svc.release(ctx, usage)
 		return err
 	}
-	// 很少改，可以使用模板
-	const codeTplId = "1877556"
-	return svc.sms.Send(ctx, codeTplId, []string{code}, phone)
+	err = ch.Send(ctx, tplId, []string{code}, target)
+	if err != nil {
+		svc.release(ctx, usage)
+	}
+	return err
 }
 
-func (svc *NormalCodeService) Verify(ctx context.Context,
-	biz, phone, inputCode string) (bool, error) {
-	ok, err := svc.repo.Verify(ctx, biz, phone, inputCode)
-	if errors.Is(err, repository.ErrCodeVerifyTooMany) {
-		// 相当于，我们对外面屏蔽了验证次数过多的错误，我们就是告诉调用者，你这个不对
-		return false, nil
+func (svc *NormalCodeService) release(ctx context.Context, u domain.CodeQuotaUsage) {
+	err := svc.quota.Release(ctx, u)
+	if err != nil {
+		svc.l.Error("归还验证码发送额度失败",
+			logger.String("biz", u.Biz),
+			logger.String("phone", u.Phone),
+			logger.Error(err))
 	}
-	return ok, err
 }
 
-func (svc *NormalCodeService) generate() string {
-	// 0-999999
-	code := rand.Intn(1000000)
-	return fmt.Sprintf("%06d", code)
+// audit 异步记录被拦截的请求，被刷的时候不能拖慢接口
+func (svc *NormalCodeService) audit(ctx context.Context, biz, phone, ip string, reason error) {
+	a := domain.CodeAudit{
+		Biz:    biz,
+		Phone:  phone,
+		IP:     ip,
+		Reason: auditReason(reason),
+		Ctime:  time.Now(),
+	}
+	svc.l.Warn("拦截发送验证码请求",
+		logger.String("biz", biz),
+		logger.String("phone", phone),
+		logger.String("ip", ip),
+		logger.String("reason", a.Reason))
+	select {
+	case svc.audits <- a:
+	default:
+		svc.l.Warn("验证码审计日志缓冲区已满，丢弃", logger.String("biz", biz), logger.String("ip", ip))
+	}
 }
 
-func NewCodeService(repo repository.CodeRepository, smsSvc sms.Service) CodeService {
-	return &NormalCodeService{
-		repo: repo,
-		sms:  smsSvc,
+func (svc *NormalCodeService) auditLoop() {
+	for a := range svc.audits {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := svc.quota.Audit(ctx, a)
+		cancel()
+		if err != nil {
+			svc.l.Error("记录验证码审计日志失败", logger.Error(err))
+		}
+	}
+}
+
+func auditReason(err error) string {
+	switch {
+	case errors.Is(err, repository.ErrCodePhoneQuota):
+		return "phone_daily"
+	case errors.Is(err, repository.ErrCodeIPQuota):
+		return "ip_hourly"
+	case errors.Is(err, repository.ErrCodeGlobalQuota):
+		return "global_daily"
+	case errors.Is(err, repository.ErrCodeTotalQuota):
+		return "total_daily"
+	case errors.Is(err, ErrCodeSendTooMany):
+		return "interval"
+	default:
+		return "unknown"
+	}
+}
+
+func (svc *NormalCodeService) Verify(ctx context.Context,
+	biz, target, inputCode string) (bool, error) {
+	return svc.repo.Verify(ctx, biz, target, inputCode)
+}
+
+// generate 用 crypto/rand 生成，验证码不能被预测
+func (svc *NormalCodeService) generate(policy domain.CodePolicy) (string, error) {
+	alphabet := []rune(policy.Alphabet)
+	if policy.Length <= 0 || len(alphabet) == 0 {
+		return "", errors.New("验证码策略错误，长度和字符集不能为空")
+	}
+	code := make([]rune, policy.Length)
+	n := big.NewInt(int64(len(alphabet)))
+	for i := range code {
+		idx, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		code[i] = alphabet[idx.Int64()]
+	}
+	return string(code), nil
+}
+
+// NewCodeService 会启动一个后台写审计日志的 goroutine
+func NewCodeService(repo repository.CodeRepository, quota repository.CodeQuotaRepository,
+	channels []channel.Channel, quotas CodeQuotaConfig, policies CodePolicyConfig, l logger.Logger) CodeService {
+	chs := make(map[string]channel.Channel, len(channels))
+	for _, ch := range channels {
+		chs[ch.Type()] = ch
+	}
+	svc := &NormalCodeService{
+		repo:     repo,
+		quota:    quota,
+		channels: chs,
+		quotas:   quotas,
+		policies: policies,
+		audits:   make(chan domain.CodeAudit, codeAuditBuffer),
+		l:        l,
 	}
+	go svc.auditLoop()
+	return svc
 }
@@ -1 +1,242 @@
 package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"strings"
+	"testing"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/repository"
+	repomocks "webok/internal/repository/mock"
+	"webok/internal/service/channel"
+	channelmocks "webok/internal/service/channel/mock"
+	"webok/pkg/logger"
+)
+
+func TestNormalCodeService_Send(t *testing.T) {
+	loginQuota := domain.CodeQuota{PhonePerDay: 5, IPPerHour: 10, GlobalPerDay: 100, TotalPerDay: 1000}
+	quotas := CodeQuotaConfig{
+		Default:     domain.CodeQuota{PhonePerDay: 10},
+		Biz:         map[string]domain.CodeQuota{"login": {PhonePerDay: 5, IPPerHour: 10, GlobalPerDay: 100}},
+		TotalPerDay: 1000,
+	}
+	// 占用额度的时候是 59 分，归还的时候要还到同一个小时的计数上
+	usage := domain.CodeQuotaUsage{Biz: "login", Phone: "15212345678", IP: "127.0.0.1", Quota: loginQuota,
+		At: time.Date(2026, 10, 19, 10, 59, 59, 0, time.Local)}
+	policies := CodePolicyConfig{
+		Default: domain.CodePolicy{
+			Length:         6,
+			Alphabet:       "0123456789",
+			TTL:            time.Minute * 10,
+			ResendInterval: time.Minute,
+			MaxAttempts:    3,
+			Channel:        channel.TypeSMS,
+			Templates:      map[string]string{"local": "tpl"},
+		},
+		Biz: map[string]domain.CodePolicy{"login": {TTL: time.Minute * 5}},
+	}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller, audited chan domain.CodeAudit) (repository.CodeRepository,
+			repository.CodeQuotaRepository, channel.Channel)
+		biz string
+
+		wantErr    error
+		wantReason string
+	}{
+		{
+			name: "发送成功",
+			mock: func(ctrl *gomock.Controller, audited chan domain.CodeAudit) (repository.CodeRepository,
+				repository.CodeQuotaRepository, channel.Channel) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				quota := repomocks.NewMockCodeQuotaRepository(ctrl)
+				smsSvc := newSMSChannel(ctrl)
+				quota.EXPECT().Acquire(gomock.Any(), "login", "15212345678", "127.0.0.1", loginQuota).Return(usage, nil)
+				repo.EXPECT().Set(gomock.Any(), "login", "15212345678", gomock.Any(), time.Minute*5, time.Minute, 3).Return(nil)
+				smsSvc.EXPECT().Send(gomock.Any(), "tpl", gomock.Any(), "15212345678").Return(nil)
+				return repo, quota, smsSvc
+			},
+			biz: "login",
+		},
+		{
+			name: "没有单独配置的业务用默认限额",
+			mock: func(ctrl *gomock.Controller, audited chan domain.CodeAudit) (repository.CodeRepository,
+				repository.CodeQuotaRepository, channel.Channel) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				quota := repomocks.NewMockCodeQuotaRepository(ctrl)
+				smsSvc := newSMSChannel(ctrl)
+				quota.EXPECT().Acquire(gomock.Any(), "reset", "15212345678", "127.0.0.1",
+					domain.CodeQuota{PhonePerDay: 10, TotalPerDay: 1000}).Return(domain.CodeQuotaUsage{}, nil)
+				repo.EXPECT().Set(gomock.Any(), "reset", "15212345678", gomock.Any(), time.Minute*10, time.Minute, 3).Return(nil)
+				smsSvc.EXPECT().Send(gomock.Any(), "tpl", gomock.Any(), "15212345678").Return(nil)
+				return repo, quota, smsSvc
+			},
+			biz: "reset",
+		},
+		{
+			name: "IP 超过限额",
+			mock: func(ctrl *gomock.Controller, audited chan domain.CodeAudit) (repository.CodeRepository,
+				repository.CodeQuotaRepository, channel.Channel) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				quota := repomocks.NewMockCodeQuotaRepository(ctrl)
+				smsSvc := newSMSChannel(ctrl)
+				quota.EXPECT().Acquire(gomock.Any(), "login", "15212345678", "127.0.0.1", loginQuota).
+					Return(domain.CodeQuotaUsage{}, repository.ErrCodeIPQuota)
+				quota.EXPECT().Audit(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, a domain.CodeAudit) error {
+						audited <- a
+						return nil
+					})
+				return repo, quota, smsSvc
+			},
+			biz:        "login",
+			wantErr:    ErrCodeQuotaExceeded,
+			wantReason: "ip_hourly",
+		},
+		{
+			name: "所有业务加起来超过限额",
+			mock: func(ctrl *gomock.Controller, audited chan domain.CodeAudit) (repository.CodeRepository,
+				repository.CodeQuotaRepository, channel.Channel) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				quota := repomocks.NewMockCodeQuotaRepository(ctrl)
+				smsSvc := newSMSChannel(ctrl)
+				quota.EXPECT().Acquire(gomock.Any(), "login", "15212345678", "127.0.0.1", loginQuota).
+					Return(domain.CodeQuotaUsage{}, repository.ErrCodeTotalQuota)
+				quota.EXPECT().Audit(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, a domain.CodeAudit) error {
+						audited <- a
+						return nil
+					})
+				return repo, quota, smsSvc
+			},
+			biz:        "login",
+			wantErr:    ErrCodeQuotaExceeded,
+			wantReason: "total_daily",
+		},
+		{
+			name: "发送太频繁，归还额度",
+			mock: func(ctrl *gomock.Controller, audited chan domain.CodeAudit) (repository.CodeRepository,
+				repository.CodeQuotaRepository, channel.Channel) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				quota := repomocks.NewMockCodeQuotaRepository(ctrl)
+				smsSvc := newSMSChannel(ctrl)
+				quota.EXPECT().Acquire(gomock.Any(), "login", "15212345678", "127.0.0.1", loginQuota).Return(usage, nil)
+				repo.EXPECT().Set(gomock.Any(), "login", "15212345678", gomock.Any(), time.Minute*5, time.Minute, 3).Return(repository.ErrCodeSendTooMany)
+				quota.EXPECT().Release(gomock.Any(), usage).Return(nil)
+				quota.EXPECT().Audit(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, a domain.CodeAudit) error {
+						audited <- a
+						return nil
+					})
+				return repo, quota, smsSvc
+			},
+			biz:        "login",
+			wantErr:    ErrCodeSendTooMany,
+			wantReason: "interval",
+		},
+		{
+			name: "短信发送失败，归还额度",
+			mock: func(ctrl *gomock.Controller, audited chan domain.CodeAudit) (repository.CodeRepository,
+				repository.CodeQuotaRepository, channel.Channel) {
+				repo := repomocks.NewMockCodeRepository(ctrl)
+				quota := repomocks.NewMockCodeQuotaRepository(ctrl)
+				smsSvc := newSMSChannel(ctrl)
+				quota.EXPECT().Acquire(gomock.Any(), "login", "15212345678", "127.0.0.1", loginQuota).Return(usage, nil)
+				repo.EXPECT().Set(gomock.Any(), "login", "15212345678", gomock.Any(), time.Minute*5, time.Minute, 3).Return(nil)
+				smsSvc.EXPECT().Send(gomock.Any(), "tpl", gomock.Any(), "15212345678").
+					Return(errors.New("sms error"))
+				quota.EXPECT().Release(gomock.Any(), usage).Return(nil)
+				return repo, quota, smsSvc
+			},
+			biz:     "login",
+			wantErr: errors.New("sms error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			audited := make(chan domain.CodeAudit, 1)
+			repo, quota, ch := tc.mock(ctrl, audited)
+			svc := NewCodeService(repo, quota, []channel.Channel{ch}, quotas, policies, logger.NewNopLogger())
+			err := svc.Send(context.Background(), tc.biz, "15212345678", "127.0.0.1")
+			if tc.wantErr != nil && errors.Is(err, tc.wantErr) {
+				err = tc.wantErr
+			}
+			assert.Equal(t, tc.wantErr, err)
+			if tc.wantReason != "" {
+				a := <-audited
+				assert.Equal(t, tc.wantReason, a.Reason)
+				assert.Equal(t, "127.0.0.1", a.IP)
+			}
+		})
+	}
+}
+
+func newSMSChannel(ctrl *gomock.Controller) *channelmocks.MockChannel {
+	ch := channelmocks.NewMockChannel(ctrl)
+	ch.EXPECT().Type().Return(channel.TypeSMS).AnyTimes()
+	ch.EXPECT().Provider().Return("local").AnyTimes()
+	return ch
+}
+
+func TestNormalCodeService_generate(t *testing.T) {
+	svc := &NormalCodeService{}
+	code, err := svc.generate(domain.CodePolicy{Length: 8, Alphabet: "ABC"})
+	assert.NoError(t, err)
+	assert.Len(t, code, 8)
+	assert.Empty(t, strings.Trim(code, "ABC"))
+	_, err = svc.generate(domain.CodePolicy{Length: 6})
+	assert.Error(t, err)
+}
+
+func TestNormalCodeService_audit(t *testing.T) {
+	// 没有启动后台写入，缓冲区满了之后不能阻塞
+	svc := &NormalCodeService{audits: make(chan domain.CodeAudit, 1), l: logger.NewNopLogger()}
+	svc.audit(context.Background(), "login", "15212345678", "127.0.0.1", repository.ErrCodeIPQuota)
+	svc.audit(context.Background(), "login", "15212345678", "127.0.0.1", repository.ErrCodeIPQuota)
+	assert.Len(t, svc.audits, 1)
+	a := <-svc.audits
+	assert.Equal(t, "ip_hourly", a.Reason)
+}
+
+func TestCodeQuotaConfig_For(t *testing.T) {
+	quotas := CodeQuotaConfig{
+		Default: domain.CodeQuota{PhonePerDay: 10, IPPerHour: 20, GlobalPerDay: 1000},
+		Biz: map[string]domain.CodeQuota{
+			"login":  {PhonePerDay: 5},
+			"signup": {PhonePerDay: 3, IPPerHour: 6, GlobalPerDay: 100, TotalPerDay: 1},
+		},
+		TotalPerDay: 5000,
+	}
+	testCases := []struct {
+		name string
+		biz  string
+		want domain.CodeQuota
+	}{
+		{
+			name: "没有单独配置",
+			biz:  "reset",
+			want: domain.CodeQuota{PhonePerDay: 10, IPPerHour: 20, GlobalPerDay: 1000, TotalPerDay: 5000},
+		},
+		{
+			name: "没有配置的字段用默认值，不是不限制",
+			biz:  "login",
+			want: domain.CodeQuota{PhonePerDay: 5, IPPerHour: 20, GlobalPerDay: 1000, TotalPerDay: 5000},
+		},
+		{
+			name: "全部覆盖，所有业务的限额不能覆盖",
+			biz:  "signup",
+			want: domain.CodeQuota{PhonePerDay: 3, IPPerHour: 6, GlobalPerDay: 100, TotalPerDay: 5000},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, quotas.For(tc.biz))
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileService 开发环境用，把邮件写到 dir 下面的 .eml 文件里面，可以直接用邮件客户端打开
+type FileService struct {
+	dir  string
+	from string
+}
+
+func NewFileService(dir string, from string) (*FileService, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileService{dir: dir, from: from}, nil
+}
+
+func (s *FileService) Send(_ context.Context, subject, body string, to ...string) error {
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.ReplaceAll(strings.Join(to, "_"), "@", "_at_"))
+	return os.WriteFile(filepath.Join(s.dir, filepath.Base(name)), buildMessage(s.from, to, subject, body), 0o644)
+}
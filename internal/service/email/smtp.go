@@ -0,0 +1,86 @@
+package email
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+)
+
+type SMTPConfig struct {
+	// Addr host:port
+	Addr     string
+	Username string
+	Password string
+	From     string
+	// InsecureSkipVerify 只在测试环境使用
+	InsecureSkipVerify bool
+}
+
+// SMTPService 服务器支持 STARTTLS 的时候会升级成 TLS 连接
+type SMTPService struct {
+	cfg  SMTPConfig
+	host string
+}
+
+func NewSMTPService(cfg SMTPConfig) (*SMTPService, error) {
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	return &SMTPService{cfg: cfg, host: host}, nil
+}
+
+func (s *SMTPService) Send(ctx context.Context, subject, body string, to ...string) error {
+	if len(to) == 0 {
+		return errors.New("没有收件人")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.cfg.Addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: s.host, InsecureSkipVerify: s.cfg.InsecureSkipVerify})
+		if err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		// PlainAuth 只允许在 TLS 或者 localhost 上发送密码
+		err = c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.host))
+		if err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err = c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buildMessage(s.cfg.From, to, subject, body))
+	if err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,133 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mime"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer 只实现发送一封邮件需要的命令，记录收到的内容
+type fakeSMTPServer struct {
+	ln   net.Listener
+	from string
+	rcpt []string
+	auth string
+	data string
+	done chan struct{}
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeSMTPServer{ln: ln, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { _ = ln.Close() })
+	return s
+}
+
+func (s *fakeSMTPServer) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) {
+		_, _ = conn.Write([]byte(line + "\r\n"))
+	}
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			s.auth = line
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			s.from = line
+			reply("250 OK")
+		case "RCPT":
+			s.rcpt = append(s.rcpt, line)
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var sb strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				sb.WriteString(l)
+			}
+			s.data = sb.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPService_Send(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	svc, err := NewSMTPService(SMTPConfig{
+		Addr:     server.ln.Addr().String(),
+		Username: "user",
+		Password: "pwd",
+		From:     "noreply@webook.com",
+	})
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	err = svc.Send(ctx, "登录验证码", "你的验证码是 123456", "a@qq.com", "b@qq.com")
+	require.NoError(t, err)
+	<-server.done
+
+	assert.Equal(t, "MAIL FROM:<noreply@webook.com>", server.from)
+	assert.Equal(t, []string{"RCPT TO:<a@qq.com>", "RCPT TO:<b@qq.com>"}, server.rcpt)
+	assert.Equal(t, "AUTH PLAIN "+base64.StdEncoding.EncodeToString([]byte("\x00user\x00pwd")), server.auth)
+	header, body, ok := strings.Cut(server.data, "\r\n\r\n")
+	require.True(t, ok)
+	assert.Contains(t, header, "To: a@qq.com, b@qq.com")
+	subject := mime.BEncoding.Encode("UTF-8", "登录验证码")
+	assert.Contains(t, header, "Subject: "+subject)
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(body, "\r\n", ""))
+	require.NoError(t, err)
+	assert.Equal(t, "你的验证码是 123456", string(decoded))
+}
+
+func TestFileService_Send(t *testing.T) {
+	dir := t.TempDir()
+	svc, err := NewFileService(dir, "noreply@webook.com")
+	require.NoError(t, err)
+	err = svc.Send(context.Background(), "登录验证码", "123456", "a@qq.com")
+	require.NoError(t, err)
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "To: a@qq.com")
+}
@@ -0,0 +1,38 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Service 发送邮件的抽象，屏蔽 SMTP 和本地开发用的实现
+//
+//go:generate mockgen -source=types.go -package=emailmocks -destination=./mock/email.mock.go
+type Service interface {
+	Send(ctx context.Context, subject, body string, to ...string) error
+}
+
+// buildMessage 纯文本邮件，正文用 base64 编码，避免中文乱码
+func buildMessage(from string, to []string, subject, body string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(body))
+	// 每行不能超过 76 个字符
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes()
+}
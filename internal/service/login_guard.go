@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/repository"
+	"webok/pkg/logger"
+)
+
+var (
+	ErrLoginLocked = errors.New("登录失败次数太多，暂时锁定")
+	// ErrLoginChallengeRequired 失败次数多了，要先完成额外的验证
+	ErrLoginChallengeRequired = errors.New("需要先完成验证")
+)
+
+const loginEventLock = "lock"
+
+//go:generate mockgen -source=login_guard.go -package=svcmocks -destination=./mock/login_guard.mock.go
+type LoginGuardService interface {
+	// Check 尝试登录之前调用，锁定的时候返回 ErrLoginLocked，
+	// 需要额外的验证但是 proof 没通过的时候返回 ErrLoginChallengeRequired 和要完成的验证
+	Check(ctx context.Context, account, ip, proof string) (string, error)
+	// Fail 账号或者密码不对
+	Fail(ctx context.Context, account, ip string) error
+	// Succeed 登录成功，清掉账号的失败记录
+	Succeed(ctx context.Context, account, ip string) error
+}
+
+// LoginChallenge 失败次数多了之后要求的额外验证，默认是人机验证，可以换成别的实现
+type LoginChallenge interface {
+	// Biz 前端要完成的验证
+	Biz() string
+	// Verify proof 是完成验证之后拿到的凭证
+	Verify(ctx context.Context, proof string) (bool, error)
+}
+
+type captchaLoginChallenge struct {
+	svc CaptchaService
+	biz string
+}
+
+// NewCaptchaLoginChallenge 先调用 /captcha/generate 和 /captcha/verify 拿到 biz 的票据
+func NewCaptchaLoginChallenge(svc CaptchaService, biz string) LoginChallenge {
+	return &captchaLoginChallenge{svc: svc, biz: biz}
+}
+
+func (c *captchaLoginChallenge) Biz() string {
+	return c.biz
+}
+
+func (c *captchaLoginChallenge) Verify(ctx context.Context, proof string) (bool, error) {
+	if proof == "" {
+		return false, nil
+	}
+	return c.svc.CheckTicket(ctx, c.biz, proof)
+}
+
+type loginGuardService struct {
+	repo      repository.LoginAttemptRepository
+	challenge LoginChallenge
+	policy    domain.LoginPolicy
+	l         logger.Logger
+}
+
+func NewLoginGuardService(repo repository.LoginAttemptRepository, challenge LoginChallenge,
+	policy domain.LoginPolicy, l logger.Logger) LoginGuardService {
+	return &loginGuardService{
+		repo:      repo,
+		challenge: challenge,
+		policy:    policy,
+		l:         l,
+	}
+}
+
+func (svc *loginGuardService) Check(ctx context.Context, account, ip, proof string) (string, error) {
+	acc, err := svc.repo.Get(ctx, domain.LoginScopeAccount, normalizeAccount(account))
+	if err != nil {
+		return "", err
+	}
+	src, err := svc.repo.Get(ctx, domain.LoginScopeIP, ip)
+	if err != nil {
+		return "", err
+	}
+	if acc.LockedFor > 0 || src.LockedFor > 0 {
+		return "", ErrLoginLocked
+	}
+	if !svc.needChallenge(acc) && !svc.needChallenge(src) {
+		return "", nil
+	}
+	ok, err := svc.challenge.Verify(ctx, proof)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return svc.challenge.Biz(), ErrLoginChallengeRequired
+	}
+	return "", nil
+}
+
+// needChallenge 被锁过的，解锁之后也一直要验证，直到锁定级别过期
+func (svc *loginGuardService) needChallenge(a domain.LoginAttempt) bool {
+	if svc.policy.ChallengeAfter <= 0 {
+		return false
+	}
+	return a.Failures >= svc.policy.ChallengeAfter || a.Level > 0
+}
+
+func (svc *loginGuardService) Fail(ctx context.Context, account, ip string) error {
+	account = normalizeAccount(account)
+	acc, err := svc.repo.Fail(ctx, domain.LoginScopeAccount, account, svc.policy.AccountLockAfter, svc.policy)
+	if err != nil {
+		return err
+	}
+	if acc.JustLocked {
+		svc.audit(ctx, domain.LoginScopeAccount, account, ip, acc)
+	}
+	src, err := svc.repo.Fail(ctx, domain.LoginScopeIP, ip, svc.policy.IPLockAfter, svc.policy)
+	if err != nil {
+		return err
+	}
+	if src.JustLocked {
+		svc.audit(ctx, domain.LoginScopeIP, ip, ip, src)
+	}
+	return nil
+}
+
+// Succeed IP 的计数不清，不然攻击者用一个自己的账号穿插着登录成功就能绕过 IP 锁定
+func (svc *loginGuardService) Succeed(ctx context.Context, account, ip string) error {
+	return svc.repo.Reset(ctx, domain.LoginScopeAccount, normalizeAccount(account))
+}
+
+// audit 异步记录，被撞库的时候不能拖慢接口
+func (svc *loginGuardService) audit(ctx context.Context, scope domain.LoginScope, subject, ip string, a domain.LoginAttempt) {
+	e := domain.LoginAudit{
+		Scope:   scope,
+		Subject: subject,
+		IP:      ip,
+		Event:   loginEventLock,
+		Level:   a.Level,
+		Lock:    a.LockedFor,
+		Ctime:   time.Now(),
+	}
+	svc.l.Warn("登录失败次数太多，已锁定",
+		logger.String("scope", string(scope)),
+		logger.String("subject", subject),
+		logger.String("ip", ip),
+		logger.Int("level", a.Level),
+		logger.String("lock", a.LockedFor.String()))
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+		defer cancel()
+		err := svc.repo.Audit(ctx, e)
+		if err != nil {
+			svc.l.Error("记录登录审计日志失败", logger.Error(err))
+		}
+	}()
+}
+
+// normalizeAccount 邮箱不区分大小写，不然换个写法就能绕过账号锁定
+func normalizeAccount(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/repository"
+	repomocks "webok/internal/repository/mock"
+	svcmocks "webok/internal/service/mock"
+	"webok/pkg/logger"
+)
+
+var testLoginPolicy = domain.LoginPolicy{
+	ChallengeAfter:   3,
+	AccountLockAfter: 5,
+	IPLockAfter:      20,
+	Window:           time.Minute * 15,
+	BaseLock:         time.Minute,
+	MaxLock:          time.Hour,
+	BackoffReset:     time.Hour * 24,
+}
+
+func TestLoginGuardService_Check(t *testing.T) {
+	testCases := []struct {
+		name  string
+		mock  func(ctrl *gomock.Controller) (repository.LoginAttemptRepository, LoginChallenge)
+		proof string
+
+		wantBiz string
+		wantErr error
+	}{
+		{
+			name: "没有失败记录",
+			mock: func(ctrl *gomock.Controller) (repository.LoginAttemptRepository, LoginChallenge) {
+				repo := repomocks.NewMockLoginAttemptRepository(ctrl)
+				repo.EXPECT().Get(gomock.Any(), domain.LoginScopeAccount, "123@qq.com").Return(domain.LoginAttempt{}, nil)
+				repo.EXPECT().Get(gomock.Any(), domain.LoginScopeIP, "1.1.1.1").Return(domain.LoginAttempt{}, nil)
+				return repo, svcmocks.NewMockLoginChallenge(ctrl)
+			},
+		},
+		{
+			name: "账号被锁定",
+			mock: func(ctrl *gomock.Controller) (repository.LoginAttemptRepository, LoginChallenge) {
+				repo := repomocks.NewMockLoginAttemptRepository(ctrl)
+				repo.EXPECT().Get(gomock.Any(), domain.LoginScopeAccount, "123@qq.com").
+					Return(domain.LoginAttempt{LockedFor: time.Minute, Level: 1}, nil)
+				repo.EXPECT().Get(gomock.Any(), domain.LoginScopeIP, "1.1.1.1").Return(domain.LoginAttempt{}, nil)
+				return repo, svcmocks.NewMockLoginChallenge(ctrl)
+			},
+			wantErr: ErrLoginLocked,
+		},
+		{
+			name: "IP 被锁定",
+			mock: func(ctrl *gomock.Controller) (repository.LoginAttemptRepository, LoginChallenge) {
+				repo := repomocks.NewMockLoginAttemptRepository(ctrl)
+				repo.EXPECT().Get(gomock.Any(), domain.LoginScopeAccount, "123@qq.com").Return(domain.LoginAttempt{}, nil)
+				repo.EXPECT().Get(gomock.Any(), domain.LoginScopeIP, "1.1.1.1").
+					Return(domain.LoginAttempt{LockedFor: time.Minute, Level: 1}, nil)
+				return repo, svcmocks.NewMockLoginChallenge(ctrl)
+			},
+			wantErr: ErrLoginLocked,
+		},
+		{
+			name: "失败太多次，没有完成验证",
+			mock: func(ctrl *gomock.Controller) (repository.LoginAttemptRepository, LoginChallenge) {
+				repo := repomocks.NewMockLoginAttemptRepository(ctrl)
+				repo.EXPECT().Get(gomock.Any(), domain.LoginScopeAccount, "123@qq.com").
+					Return(domain.LoginAttempt{Failures: 3}, nil)
+				repo.EXPECT().Get(gomock.Any(), domain.LoginScopeIP, "1.1.1.1").Return(domain.LoginAttempt{}, nil)
+				challenge := svcmocks.NewMockLoginChallenge(ctrl)
+				challenge.EXPECT().Verify(gomock.Any(), "").Return(false, nil)
+				challenge.EXPECT().Biz().Return("login_pwd")
+				return repo, challenge
+			},
+			wantBiz: "login_pwd",
+			wantErr: ErrLoginChallengeRequired,
+		},
+		{
+			name: "锁定过期之后还要验证",
+			mock: func(ctrl *gomock.Controller) (repository.LoginAttemptRepository, LoginChallenge) {
+				repo := repomocks.NewMockLoginAttemptRepository(ctrl)
+				repo.EXPECT().Get(gomock.Any(), domain.LoginScopeAccount, "123@qq.com").
+					Return(domain.LoginAttempt{Level: 1}, nil)
+				repo.EXPECT().Get(gomock.Any(), domain.LoginScopeIP, "1.1.1.1").Return(domain.LoginAttempt{}, nil)
+				challenge := svcmocks.NewMockLoginChallenge(ctrl)
+				challenge.EXPECT().Verify(gomock.Any(), "ticket").Return(true, nil)
+				return repo, challenge
+			},
+			proof: "ticket",
+		},
+		{
+			name: "Redis 错误",
+			mock: func(ctrl *gomock.Controller) (repository.LoginAttemptRepository, LoginChallenge) {
+				repo := repomocks.NewMockLoginAttemptRepository(ctrl)
+				repo.EXPECT().Get(gomock.Any(), domain.LoginScopeAccount, "123@qq.com").
+					Return(domain.LoginAttempt{}, errors.New("redis 错误"))
+				return repo, svcmocks.NewMockLoginChallenge(ctrl)
+			},
+			wantErr: errors.New("redis 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, challenge := tc.mock(ctrl)
+			svc := NewLoginGuardService(repo, challenge, testLoginPolicy, logger.NewNopLogger())
+			// 邮箱大小写不同也是同一个账号
+			biz, err := svc.Check(context.Background(), " 123@QQ.com", "1.1.1.1", tc.proof)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantBiz, biz)
+		})
+	}
+}
+
+func TestLoginGuardService_Fail(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller, audited chan domain.LoginAudit) repository.LoginAttemptRepository
+
+		wantErr   error
+		wantAudit domain.LoginAudit
+	}{
+		{
+			name: "没有触发锁定",
+			mock: func(ctrl *gomock.Controller, audited chan domain.LoginAudit) repository.LoginAttemptRepository {
+				repo := repomocks.NewMockLoginAttemptRepository(ctrl)
+				repo.EXPECT().Fail(gomock.Any(), domain.LoginScopeAccount, "123@qq.com", 5, testLoginPolicy).
+					Return(domain.LoginAttempt{Failures: 1}, nil)
+				repo.EXPECT().Fail(gomock.Any(), domain.LoginScopeIP, "1.1.1.1", 20, testLoginPolicy).
+					Return(domain.LoginAttempt{Failures: 1}, nil)
+				return repo
+			},
+		},
+		{
+			name: "锁定账号并且记录审计日志",
+			mock: func(ctrl *gomock.Controller, audited chan domain.LoginAudit) repository.LoginAttemptRepository {
+				repo := repomocks.NewMockLoginAttemptRepository(ctrl)
+				locked := domain.LoginAttempt{Failures: 5, LockedFor: time.Minute * 2, Level: 2, JustLocked: true}
+				repo.EXPECT().Fail(gomock.Any(), domain.LoginScopeAccount, "123@qq.com", 5, testLoginPolicy).
+					Return(locked, nil)
+				repo.EXPECT().Fail(gomock.Any(), domain.LoginScopeIP, "1.1.1.1", 20, testLoginPolicy).
+					Return(domain.LoginAttempt{Failures: 6}, nil)
+				repo.EXPECT().Audit(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, a domain.LoginAudit) error {
+						audited <- a
+						return nil
+					})
+				return repo
+			},
+			wantAudit: domain.LoginAudit{Scope: domain.LoginScopeAccount, Subject: "123@qq.com",
+				IP: "1.1.1.1", Event: "lock", Level: 2, Lock: time.Minute * 2},
+		},
+		{
+			name: "Redis 错误",
+			mock: func(ctrl *gomock.Controller, audited chan domain.LoginAudit) repository.LoginAttemptRepository {
+				repo := repomocks.NewMockLoginAttemptRepository(ctrl)
+				repo.EXPECT().Fail(gomock.Any(), domain.LoginScopeAccount, "123@qq.com", 5, testLoginPolicy).
+					Return(domain.LoginAttempt{}, errors.New("redis 错误"))
+				return repo
+			},
+			wantErr: errors.New("redis 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			audited := make(chan domain.LoginAudit, 1)
+			svc := NewLoginGuardService(tc.mock(ctrl, audited), svcmocks.NewMockLoginChallenge(ctrl),
+				testLoginPolicy, logger.NewNopLogger())
+			err := svc.Fail(context.Background(), "123@qq.com", "1.1.1.1")
+			assert.Equal(t, tc.wantErr, err)
+			if tc.wantAudit.Event != "" {
+				a := <-audited
+				a.Ctime = time.Time{}
+				assert.Equal(t, tc.wantAudit, a)
+			}
+		})
+	}
+}
@@ -12,6 +12,7 @@ package svcmocks
 import (
 	context "context"
 	reflect "reflect"
+	domain "webok/internal/domain"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -41,17 +42,46 @@ func (m *MockInteractiveService) EXPECT() *MockInteractiveServiceMockRecorder {
 }
 
 // CancelLike mocks base method.
-func (m *MockInteractiveService) CancelLike(ctx context.Context, biz string, uid, id int64) error {
+func (m *MockInteractiveService) CancelLike(ctx context.Context, biz string, id, uid int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CancelLike", ctx, biz, uid, id)
+	ret := m.ctrl.Call(m, "CancelLike", ctx, biz, id, uid)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // CancelLike indicates an expected call of CancelLike.
-func (mr *MockInteractiveServiceMockRecorder) CancelLike(ctx, biz, uid, id any) *gomock.Call {
+func (mr *MockInteractiveServiceMockRecorder) CancelLike(ctx, biz, id, uid any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelLike", reflect.TypeOf((*MockInteractiveService)(nil).CancelLike), ctx, biz, uid, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelLike", reflect.TypeOf((*MockInteractiveService)(nil).CancelLike), ctx, biz, id, uid)
+}
+
+// Collect mocks base method.
+func (m *MockInteractiveService) Collect(ctx context.Context, biz string, id, cid, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Collect", ctx, biz, id, cid, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Collect indicates an expected call of Collect.
+func (mr *MockInteractiveServiceMockRecorder) Collect(ctx, biz, id, cid, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Collect", reflect.TypeOf((*MockInteractiveService)(nil).Collect), ctx, biz, id, cid, uid)
+}
+
+// Get mocks base method.
+func (m *MockInteractiveService) Get(ctx context.Context, biz string, id, uid int64) (domain.Interactive, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, biz, id, uid)
+	ret0, _ := ret[0].(domain.Interactive)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockInteractiveServiceMockRecorder) Get(ctx, biz, id, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockInteractiveService)(nil).Get), ctx, biz, id, uid)
 }
 
 // IncrReadCnt mocks base method.
@@ -69,15 +99,15 @@ func (mr *MockInteractiveServiceMockRecorder) IncrReadCnt(ctx, biz, id any) *gom
 }
 
 // Like mocks base method.
-func (m *MockInteractiveService) Like(ctx context.Context, biz string, uid, id int64) error {
+func (m *MockInteractiveService) Like(ctx context.Context, biz string, id, uid int64) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Like", ctx, biz, uid, id)
+	ret := m.ctrl.Call(m, "Like", ctx, biz, id, uid)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Like indicates an expected call of Like.
-func (mr *MockInteractiveServiceMockRecorder) Like(ctx, biz, uid, id any) *gomock.Call {
+func (mr *MockInteractiveServiceMockRecorder) Like(ctx, biz, id, uid any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Like", reflect.TypeOf((*MockInteractiveService)(nil).Like), ctx, biz, uid, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Like", reflect.TypeOf((*MockInteractiveService)(nil).Like), ctx, biz, id, uid)
 }
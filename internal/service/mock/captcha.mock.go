@@ -0,0 +1,114 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: captcha.go
+//
+// Generated by this command:
+//
+//	mockgen -source=captcha.go -package=svcmocks -destination=./mock/captcha.mock.go
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webok/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCaptchaService is a mock of CaptchaService interface.
+type MockCaptchaService struct {
+	ctrl     *gomock.Controller
+	recorder *MockCaptchaServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockCaptchaServiceMockRecorder is the mock recorder for MockCaptchaService.
+type MockCaptchaServiceMockRecorder struct {
+	mock *MockCaptchaService
+}
+
+// NewMockCaptchaService creates a new mock instance.
+func NewMockCaptchaService(ctrl *gomock.Controller) *MockCaptchaService {
+	mock := &MockCaptchaService{ctrl: ctrl}
+	mock.recorder = &MockCaptchaServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCaptchaService) EXPECT() *MockCaptchaServiceMockRecorder {
+	return m.recorder
+}
+
+// CheckTicket mocks base method.
+func (m *MockCaptchaService) CheckTicket(ctx context.Context, biz, ticket string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckTicket", ctx, biz, ticket)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckTicket indicates an expected call of CheckTicket.
+func (mr *MockCaptchaServiceMockRecorder) CheckTicket(ctx, biz, ticket any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckTicket", reflect.TypeOf((*MockCaptchaService)(nil).CheckTicket), ctx, biz, ticket)
+}
+
+// Generate mocks base method.
+func (m *MockCaptchaService) Generate(ctx context.Context, biz string) (domain.Captcha, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Generate", ctx, biz)
+	ret0, _ := ret[0].(domain.Captcha)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Generate indicates an expected call of Generate.
+func (mr *MockCaptchaServiceMockRecorder) Generate(ctx, biz any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Generate", reflect.TypeOf((*MockCaptchaService)(nil).Generate), ctx, biz)
+}
+
+// MarkSuspicious mocks base method.
+func (m *MockCaptchaService) MarkSuspicious(ctx context.Context, biz, ip string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "MarkSuspicious", ctx, biz, ip)
+}
+
+// MarkSuspicious indicates an expected call of MarkSuspicious.
+func (mr *MockCaptchaServiceMockRecorder) MarkSuspicious(ctx, biz, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkSuspicious", reflect.TypeOf((*MockCaptchaService)(nil).MarkSuspicious), ctx, biz, ip)
+}
+
+// Required mocks base method.
+func (m *MockCaptchaService) Required(ctx context.Context, biz, ip string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Required", ctx, biz, ip)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Required indicates an expected call of Required.
+func (mr *MockCaptchaServiceMockRecorder) Required(ctx, biz, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Required", reflect.TypeOf((*MockCaptchaService)(nil).Required), ctx, biz, ip)
+}
+
+// Verify mocks base method.
+func (m *MockCaptchaService) Verify(ctx context.Context, biz, id, answer string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, biz, id, answer)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockCaptchaServiceMockRecorder) Verify(ctx, biz, id, answer any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockCaptchaService)(nil).Verify), ctx, biz, id, answer)
+}
@@ -0,0 +1,137 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: login_guard.go
+//
+// Generated by this command:
+//
+//	mockgen -source=login_guard.go -package=svcmocks -destination=./mock/login_guard.mock.go
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLoginGuardService is a mock of LoginGuardService interface.
+type MockLoginGuardService struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginGuardServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockLoginGuardServiceMockRecorder is the mock recorder for MockLoginGuardService.
+type MockLoginGuardServiceMockRecorder struct {
+	mock *MockLoginGuardService
+}
+
+// NewMockLoginGuardService creates a new mock instance.
+func NewMockLoginGuardService(ctrl *gomock.Controller) *MockLoginGuardService {
+	mock := &MockLoginGuardService{ctrl: ctrl}
+	mock.recorder = &MockLoginGuardServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginGuardService) EXPECT() *MockLoginGuardServiceMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockLoginGuardService) Check(ctx context.Context, account, ip, proof string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", ctx, account, ip, proof)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockLoginGuardServiceMockRecorder) Check(ctx, account, ip, proof any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockLoginGuardService)(nil).Check), ctx, account, ip, proof)
+}
+
+// Fail mocks base method.
+func (m *MockLoginGuardService) Fail(ctx context.Context, account, ip string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Fail", ctx, account, ip)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Fail indicates an expected call of Fail.
+func (mr *MockLoginGuardServiceMockRecorder) Fail(ctx, account, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fail", reflect.TypeOf((*MockLoginGuardService)(nil).Fail), ctx, account, ip)
+}
+
+// Succeed mocks base method.
+func (m *MockLoginGuardService) Succeed(ctx context.Context, account, ip string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Succeed", ctx, account, ip)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Succeed indicates an expected call of Succeed.
+func (mr *MockLoginGuardServiceMockRecorder) Succeed(ctx, account, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Succeed", reflect.TypeOf((*MockLoginGuardService)(nil).Succeed), ctx, account, ip)
+}
+
+// MockLoginChallenge is a mock of LoginChallenge interface.
+type MockLoginChallenge struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginChallengeMockRecorder
+	isgomock struct{}
+}
+
+// MockLoginChallengeMockRecorder is the mock recorder for MockLoginChallenge.
+type MockLoginChallengeMockRecorder struct {
+	mock *MockLoginChallenge
+}
+
+// NewMockLoginChallenge creates a new mock instance.
+func NewMockLoginChallenge(ctrl *gomock.Controller) *MockLoginChallenge {
+	mock := &MockLoginChallenge{ctrl: ctrl}
+	mock.recorder = &MockLoginChallengeMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginChallenge) EXPECT() *MockLoginChallengeMockRecorder {
+	return m.recorder
+}
+
+// Biz mocks base method.
+func (m *MockLoginChallenge) Biz() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Biz")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Biz indicates an expected call of Biz.
+func (mr *MockLoginChallengeMockRecorder) Biz() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Biz", reflect.TypeOf((*MockLoginChallenge)(nil).Biz))
+}
+
+// Verify mocks base method.
+func (m *MockLoginChallenge) Verify(ctx context.Context, proof string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, proof)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockLoginChallengeMockRecorder) Verify(ctx, proof any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockLoginChallenge)(nil).Verify), ctx, proof)
+}
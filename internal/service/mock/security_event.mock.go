@@ -0,0 +1,84 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: security_event.go
+//
+// Generated by this command:
+//
+//	mockgen -source=security_event.go -package=svcmocks -destination=./mock/security_event.mock.go
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webok/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockSecurityEventService is a mock of SecurityEventService interface.
+type MockSecurityEventService struct {
+	ctrl     *gomock.Controller
+	recorder *MockSecurityEventServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockSecurityEventServiceMockRecorder is the mock recorder for MockSecurityEventService.
+type MockSecurityEventServiceMockRecorder struct {
+	mock *MockSecurityEventService
+}
+
+// NewMockSecurityEventService creates a new mock instance.
+func NewMockSecurityEventService(ctrl *gomock.Controller) *MockSecurityEventService {
+	mock := &MockSecurityEventService{ctrl: ctrl}
+	mock.recorder = &MockSecurityEventServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSecurityEventService) EXPECT() *MockSecurityEventServiceMockRecorder {
+	return m.recorder
+}
+
+// ListByUser mocks base method.
+func (m *MockSecurityEventService) ListByUser(ctx context.Context, uid, beforeId int64, limit int) ([]domain.SecurityEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByUser", ctx, uid, beforeId, limit)
+	ret0, _ := ret[0].([]domain.SecurityEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListByUser indicates an expected call of ListByUser.
+func (mr *MockSecurityEventServiceMockRecorder) ListByUser(ctx, uid, beforeId, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByUser", reflect.TypeOf((*MockSecurityEventService)(nil).ListByUser), ctx, uid, beforeId, limit)
+}
+
+// Record mocks base method.
+func (m *MockSecurityEventService) Record(ctx context.Context, e domain.SecurityEvent) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Record", ctx, e)
+}
+
+// Record indicates an expected call of Record.
+func (mr *MockSecurityEventServiceMockRecorder) Record(ctx, e any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Record", reflect.TypeOf((*MockSecurityEventService)(nil).Record), ctx, e)
+}
+
+// Search mocks base method.
+func (m *MockSecurityEventService) Search(ctx context.Context, q domain.SecurityEventQuery) ([]domain.SecurityEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, q)
+	ret0, _ := ret[0].([]domain.SecurityEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockSecurityEventServiceMockRecorder) Search(ctx, q any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockSecurityEventService)(nil).Search), ctx, q)
+}
@@ -0,0 +1,130 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: two_factor.go
+//
+// Generated by this command:
+//
+//	mockgen -source=two_factor.go -package=svcmocks -destination=./mock/two_factor.mock.go
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	context "context"
+	reflect "reflect"
+	domain "webok/internal/domain"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockTwoFactorService is a mock of TwoFactorService interface.
+type MockTwoFactorService struct {
+	ctrl     *gomock.Controller
+	recorder *MockTwoFactorServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockTwoFactorServiceMockRecorder is the mock recorder for MockTwoFactorService.
+type MockTwoFactorServiceMockRecorder struct {
+	mock *MockTwoFactorService
+}
+
+// NewMockTwoFactorService creates a new mock instance.
+func NewMockTwoFactorService(ctrl *gomock.Controller) *MockTwoFactorService {
+	mock := &MockTwoFactorService{ctrl: ctrl}
+	mock.recorder = &MockTwoFactorServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTwoFactorService) EXPECT() *MockTwoFactorServiceMockRecorder {
+	return m.recorder
+}
+
+// Confirm mocks base method.
+func (m *MockTwoFactorService) Confirm(ctx context.Context, uid int64, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Confirm", ctx, uid, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Confirm indicates an expected call of Confirm.
+func (mr *MockTwoFactorServiceMockRecorder) Confirm(ctx, uid, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Confirm", reflect.TypeOf((*MockTwoFactorService)(nil).Confirm), ctx, uid, code)
+}
+
+// Disable mocks base method.
+func (m *MockTwoFactorService) Disable(ctx context.Context, uid int64, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Disable", ctx, uid, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Disable indicates an expected call of Disable.
+func (mr *MockTwoFactorServiceMockRecorder) Disable(ctx, uid, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Disable", reflect.TypeOf((*MockTwoFactorService)(nil).Disable), ctx, uid, code)
+}
+
+// Enroll mocks base method.
+func (m *MockTwoFactorService) Enroll(ctx context.Context, uid int64, account string) (domain.TOTPEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enroll", ctx, uid, account)
+	ret0, _ := ret[0].(domain.TOTPEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Enroll indicates an expected call of Enroll.
+func (mr *MockTwoFactorServiceMockRecorder) Enroll(ctx, uid, account any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enroll", reflect.TypeOf((*MockTwoFactorService)(nil).Enroll), ctx, uid, account)
+}
+
+// RegenerateRecoveryCodes mocks base method.
+func (m *MockTwoFactorService) RegenerateRecoveryCodes(ctx context.Context, uid int64, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegenerateRecoveryCodes", ctx, uid, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegenerateRecoveryCodes indicates an expected call of RegenerateRecoveryCodes.
+func (mr *MockTwoFactorServiceMockRecorder) RegenerateRecoveryCodes(ctx, uid, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegenerateRecoveryCodes", reflect.TypeOf((*MockTwoFactorService)(nil).RegenerateRecoveryCodes), ctx, uid, code)
+}
+
+// Status mocks base method.
+func (m *MockTwoFactorService) Status(ctx context.Context, uid int64) (domain.TwoFactor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status", ctx, uid)
+	ret0, _ := ret[0].(domain.TwoFactor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockTwoFactorServiceMockRecorder) Status(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockTwoFactorService)(nil).Status), ctx, uid)
+}
+
+// Verify mocks base method.
+func (m *MockTwoFactorService) Verify(ctx context.Context, uid int64, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, uid, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockTwoFactorServiceMockRecorder) Verify(ctx, uid, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockTwoFactorService)(nil).Verify), ctx, uid, code)
+}
@@ -41,28 +41,134 @@ func (m *MockUserService) EXPECT() *MockUserServiceMockRecorder {
 	return m.recorder
 }
 
-// FindOrCreate mocks base method.
-func (m *MockUserService) FindOrCreate(ctx context.Context, phone string) (*domain.User, error) {
+// BindEmail mocks base method.
+func (m *MockUserService) BindEmail(ctx context.Context, uid int64, email string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindOrCreate", ctx, phone)
+	ret := m.ctrl.Call(m, "BindEmail", ctx, uid, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindEmail indicates an expected call of BindEmail.
+func (mr *MockUserServiceMockRecorder) BindEmail(ctx, uid, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindEmail", reflect.TypeOf((*MockUserService)(nil).BindEmail), ctx, uid, email)
+}
+
+// BindPhone mocks base method.
+func (m *MockUserService) BindPhone(ctx context.Context, uid int64, phone string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BindPhone", ctx, uid, phone)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BindPhone indicates an expected call of BindPhone.
+func (mr *MockUserServiceMockRecorder) BindPhone(ctx, uid, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BindPhone", reflect.TypeOf((*MockUserService)(nil).BindPhone), ctx, uid, phone)
+}
+
+// ChangePassword mocks base method.
+func (m *MockUserService) ChangePassword(ctx context.Context, uid int64, oldPassword, newPassword string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, uid, oldPassword, newPassword)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockUserServiceMockRecorder) ChangePassword(ctx, uid, oldPassword, newPassword any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockUserService)(nil).ChangePassword), ctx, uid, oldPassword, newPassword)
+}
+
+// FindByAccount mocks base method.
+func (m *MockUserService) FindByAccount(ctx context.Context, email, phone string) (*domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByAccount", ctx, email, phone)
 	ret0, _ := ret[0].(*domain.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// FindByAccount indicates an expected call of FindByAccount.
+func (mr *MockUserServiceMockRecorder) FindByAccount(ctx, email, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByAccount", reflect.TypeOf((*MockUserService)(nil).FindByAccount), ctx, email, phone)
+}
+
+// FindByWechat mocks base method.
+func (m *MockUserService) FindByWechat(ctx context.Context, openId string) (domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByWechat", ctx, openId)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByWechat indicates an expected call of FindByWechat.
+func (mr *MockUserServiceMockRecorder) FindByWechat(ctx, openId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByWechat", reflect.TypeOf((*MockUserService)(nil).FindByWechat), ctx, openId)
+}
+
+// FindOrCreate mocks base method.
+func (m *MockUserService) FindOrCreate(ctx context.Context, phone string) (*domain.User, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOrCreate", ctx, phone)
+	ret0, _ := ret[0].(*domain.User)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
 // FindOrCreate indicates an expected call of FindOrCreate.
 func (mr *MockUserServiceMockRecorder) FindOrCreate(ctx, phone any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrCreate", reflect.TypeOf((*MockUserService)(nil).FindOrCreate), ctx, phone)
 }
 
+// FindOrCreateByEmail mocks base method.
+func (m *MockUserService) FindOrCreateByEmail(ctx context.Context, email string) (*domain.User, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOrCreateByEmail", ctx, email)
+	ret0, _ := ret[0].(*domain.User)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindOrCreateByEmail indicates an expected call of FindOrCreateByEmail.
+func (mr *MockUserServiceMockRecorder) FindOrCreateByEmail(ctx, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrCreateByEmail", reflect.TypeOf((*MockUserService)(nil).FindOrCreateByEmail), ctx, email)
+}
+
+// FindOrCreateByOAuth2 mocks base method.
+func (m *MockUserService) FindOrCreateByOAuth2(ctx context.Context, info domain.OAuth2Info) (domain.User, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOrCreateByOAuth2", ctx, info)
+	ret0, _ := ret[0].(domain.User)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// FindOrCreateByOAuth2 indicates an expected call of FindOrCreateByOAuth2.
+func (mr *MockUserServiceMockRecorder) FindOrCreateByOAuth2(ctx, info any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrCreateByOAuth2", reflect.TypeOf((*MockUserService)(nil).FindOrCreateByOAuth2), ctx, info)
+}
+
 // FindOrCreateByWechat mocks base method.
-func (m *MockUserService) FindOrCreateByWechat(ctx context.Context, wechatInfo domain.WechatInfo) (domain.User, error) {
+func (m *MockUserService) FindOrCreateByWechat(ctx context.Context, wechatInfo domain.WechatInfo) (domain.User, bool, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "FindOrCreateByWechat", ctx, wechatInfo)
 	ret0, _ := ret[0].(domain.User)
-	ret1, _ := ret[1].(error)
-	return ret0, ret1
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // FindOrCreateByWechat indicates an expected call of FindOrCreateByWechat.
@@ -71,6 +177,35 @@ func (mr *MockUserServiceMockRecorder) FindOrCreateByWechat(ctx, wechatInfo any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOrCreateByWechat", reflect.TypeOf((*MockUserService)(nil).FindOrCreateByWechat), ctx, wechatInfo)
 }
 
+// Identities mocks base method.
+func (m *MockUserService) Identities(ctx context.Context, uid int64) ([]domain.Identity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Identities", ctx, uid)
+	ret0, _ := ret[0].([]domain.Identity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Identities indicates an expected call of Identities.
+func (mr *MockUserServiceMockRecorder) Identities(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Identities", reflect.TypeOf((*MockUserService)(nil).Identities), ctx, uid)
+}
+
+// LinkWechat mocks base method.
+func (m *MockUserService) LinkWechat(ctx context.Context, uid int64, info domain.WechatInfo) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LinkWechat", ctx, uid, info)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LinkWechat indicates an expected call of LinkWechat.
+func (mr *MockUserServiceMockRecorder) LinkWechat(ctx, uid, info any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LinkWechat", reflect.TypeOf((*MockUserService)(nil).LinkWechat), ctx, uid, info)
+}
+
 // Login mocks base method.
 func (m *MockUserService) Login(ctx context.Context, email, password string) (*domain.User, error) {
 	m.ctrl.T.Helper()
@@ -86,6 +221,20 @@ func (mr *MockUserServiceMockRecorder) Login(ctx, email, password any) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockUserService)(nil).Login), ctx, email, password)
 }
 
+// Merge mocks base method.
+func (m *MockUserService) Merge(ctx context.Context, uid, sourceUid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Merge", ctx, uid, sourceUid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Merge indicates an expected call of Merge.
+func (mr *MockUserServiceMockRecorder) Merge(ctx, uid, sourceUid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Merge", reflect.TypeOf((*MockUserService)(nil).Merge), ctx, uid, sourceUid)
+}
+
 // ModifyNoSensitiveInfo mocks base method.
 func (m *MockUserService) ModifyNoSensitiveInfo(ctx context.Context, u *domain.User) error {
 	m.ctrl.T.Helper()
@@ -115,6 +264,20 @@ func (mr *MockUserServiceMockRecorder) Profile(ctx, d any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Profile", reflect.TypeOf((*MockUserService)(nil).Profile), ctx, d)
 }
 
+// ResetPassword mocks base method.
+func (m *MockUserService) ResetPassword(ctx context.Context, uid int64, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, uid, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockUserServiceMockRecorder) ResetPassword(ctx, uid, password any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockUserService)(nil).ResetPassword), ctx, uid, password)
+}
+
 // SignUp mocks base method.
 func (m *MockUserService) SignUp(ctx context.Context, u *domain.User) error {
 	m.ctrl.T.Helper()
@@ -128,3 +291,45 @@ func (mr *MockUserServiceMockRecorder) SignUp(ctx, u any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignUp", reflect.TypeOf((*MockUserService)(nil).SignUp), ctx, u)
 }
+
+// Unlink mocks base method.
+func (m *MockUserService) Unlink(ctx context.Context, uid int64, typ domain.IdentityType) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unlink", ctx, uid, typ)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlink indicates an expected call of Unlink.
+func (mr *MockUserServiceMockRecorder) Unlink(ctx, uid, typ any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlink", reflect.TypeOf((*MockUserService)(nil).Unlink), ctx, uid, typ)
+}
+
+// UnlinkOAuth2 mocks base method.
+func (m *MockUserService) UnlinkOAuth2(ctx context.Context, uid int64, provider, subject string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlinkOAuth2", ctx, uid, provider, subject)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlinkOAuth2 indicates an expected call of UnlinkOAuth2.
+func (mr *MockUserServiceMockRecorder) UnlinkOAuth2(ctx, uid, provider, subject any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlinkOAuth2", reflect.TypeOf((*MockUserService)(nil).UnlinkOAuth2), ctx, uid, provider, subject)
+}
+
+// VerifyEmail mocks base method.
+func (m *MockUserService) VerifyEmail(ctx context.Context, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmail", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyEmail indicates an expected call of VerifyEmail.
+func (mr *MockUserServiceMockRecorder) VerifyEmail(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmail", reflect.TypeOf((*MockUserService)(nil).VerifyEmail), ctx, uid)
+}
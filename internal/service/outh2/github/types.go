@@ -0,0 +1,148 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/service/outh2"
+)
+
+type Config struct {
+	// Name 默认是 github，接了 GitHub Enterprise 的时候用来区分
+	Name         string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Timeout      time.Duration
+	// 下面几个只有 GitHub Enterprise 或者测试的时候才需要改
+	AuthURL  string
+	TokenURL string
+	APIURL   string
+}
+
+type provider struct {
+	cfg    Config
+	client *http.Client
+}
+
+func NewProvider(cfg Config) outh2.Provider {
+	if cfg.Name == "" {
+		cfg.Name = "github"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"read:user", "user:email"}
+	}
+	if cfg.AuthURL == "" {
+		cfg.AuthURL = "https://github.com/login/oauth/authorize"
+	}
+	if cfg.TokenURL == "" {
+		cfg.TokenURL = "https://github.com/login/oauth/access_token"
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = "https://api.github.com"
+	}
+	cfg.APIURL = strings.TrimSuffix(cfg.APIURL, "/")
+	return &provider{cfg: cfg, client: outh2.NewHTTPClient(cfg.Timeout)}
+}
+
+func (p *provider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *provider) AuthURL(ctx context.Context, params outh2.AuthParams) (string, error) {
+	q := url.Values{}
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", params.State)
+	if params.CodeVerifier != "" {
+		q.Set("code_challenge", outh2.CodeChallenge(params.CodeVerifier))
+		q.Set("code_challenge_method", "S256")
+	}
+	return p.cfg.AuthURL + "?" + q.Encode(), nil
+}
+
+type tokenResult struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	Scope       string `json:"scope"`
+
+	// GitHub 出错的时候状态码也是 200
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (p *provider) Exchange(ctx context.Context, code string, params outh2.AuthParams) (outh2.Token, error) {
+	form := url.Values{}
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("client_secret", p.cfg.ClientSecret)
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	if params.CodeVerifier != "" {
+		form.Set("code_verifier", params.CodeVerifier)
+	}
+	var res tokenResult
+	err := outh2.PostForm(ctx, p.client, p.cfg.TokenURL, form, &res)
+	if err != nil {
+		return outh2.Token{}, err
+	}
+	if res.Error != "" {
+		return outh2.Token{}, fmt.Errorf("GitHub 换 token 失败 %s: %s", res.Error, res.ErrorDescription)
+	}
+	if res.AccessToken == "" {
+		return outh2.Token{}, fmt.Errorf("GitHub 没有返回 access token")
+	}
+	return outh2.Token{AccessToken: res.AccessToken}, nil
+}
+
+type user struct {
+	Id    int64  `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+}
+
+type email struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// UserInfo login 可以改，只有 id 是不变的。/user 里面的邮箱是公开邮箱，
+// 不一定验证过，所以邮箱单独查
+func (p *provider) UserInfo(ctx context.Context, tok outh2.Token) (domain.OAuth2Info, error) {
+	var u user
+	err := outh2.GetJSON(ctx, p.client, p.cfg.APIURL+"/user", tok.AccessToken, &u)
+	if err != nil {
+		return domain.OAuth2Info{}, err
+	}
+	if u.Id == 0 {
+		return domain.OAuth2Info{}, fmt.Errorf("GitHub 没有返回用户 id")
+	}
+	info := domain.OAuth2Info{
+		Provider: p.Name(),
+		Subject:  strconv.FormatInt(u.Id, 10),
+		Name:     u.Name,
+	}
+	if info.Name == "" {
+		info.Name = u.Login
+	}
+	var emails []email
+	err = outh2.GetJSON(ctx, p.client, p.cfg.APIURL+"/user/emails", tok.AccessToken, &emails)
+	if err != nil {
+		// 没有授权 user:email 的时候查不到，不影响登录
+		return info, nil
+	}
+	for _, e := range emails {
+		if e.Primary {
+			info.Email, info.EmailVerified = e.Email, e.Verified
+			break
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,109 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"webok/internal/domain"
+	"webok/internal/service/outh2"
+)
+
+func TestProvider_Login(t *testing.T) {
+	testCases := []struct {
+		name string
+		// emails /user/emails 的响应，nil 表示没有授权 user:email
+		emails   []email
+		verifier string
+
+		wantInfo domain.OAuth2Info
+		wantErr  bool
+	}{
+		{
+			name: "登录成功",
+			emails: []email{
+				{Email: "other@example.com", Verified: true},
+				{Email: "octocat@example.com", Primary: true, Verified: true},
+			},
+			wantInfo: domain.OAuth2Info{Provider: "github", Subject: "42", Name: "octocat",
+				Email: "octocat@example.com", EmailVerified: true},
+		},
+		{
+			name:     "没有邮箱权限",
+			wantInfo: domain.OAuth2Info{Provider: "github", Subject: "42", Name: "octocat"},
+		},
+		{
+			name:     "code verifier 不对",
+			verifier: "another-verifier",
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var challenge string
+			mux := http.NewServeMux()
+			mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+				require.NoError(t, r.ParseForm())
+				// GitHub 出错的时候状态码也是 200
+				if r.PostForm.Get("code") != "code" || r.PostForm.Get("client_secret") != "secret" ||
+					outh2.CodeChallenge(r.PostForm.Get("code_verifier")) != challenge {
+					writeJSON(w, http.StatusOK, map[string]string{"error": "bad_verification_code"})
+					return
+				}
+				writeJSON(w, http.StatusOK, map[string]string{"access_token": "gho_token", "token_type": "bearer"})
+			})
+			mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "Bearer gho_token", r.Header.Get("Authorization"))
+				writeJSON(w, http.StatusOK, map[string]any{"id": 42, "login": "octocat"})
+			})
+			mux.HandleFunc("/user/emails", func(w http.ResponseWriter, r *http.Request) {
+				if tc.emails == nil {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				writeJSON(w, http.StatusOK, tc.emails)
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			p := NewProvider(Config{
+				ClientID:     "client",
+				ClientSecret: "secret",
+				RedirectURL:  "http://localhost:8080/oauth2/github/callback",
+				AuthURL:      srv.URL + "/login/oauth/authorize",
+				TokenURL:     srv.URL + "/login/oauth/access_token",
+				APIURL:       srv.URL,
+			})
+			ctx := context.Background()
+			params := outh2.AuthParams{State: "state", CodeVerifier: "verifier-verifier-verifier-verifier-verifier"}
+			authURL, err := p.AuthURL(ctx, params)
+			require.NoError(t, err)
+			u, err := url.Parse(authURL)
+			require.NoError(t, err)
+			assert.Equal(t, "state", u.Query().Get("state"))
+			challenge = u.Query().Get("code_challenge")
+
+			if tc.verifier != "" {
+				params.CodeVerifier = tc.verifier
+			}
+			tok, err := p.Exchange(ctx, "code", params)
+			if err == nil {
+				var info domain.OAuth2Info
+				info, err = p.UserInfo(ctx, tok)
+				assert.Equal(t, tc.wantInfo, info)
+			}
+			assert.Equal(t, tc.wantErr, err != nil, err)
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, val any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(val)
+}
@@ -0,0 +1,63 @@
+package outh2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout 第三方接口慢的时候不能把登录请求一直挂着
+const DefaultTimeout = time.Second * 5
+
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// PostForm 授权码换 token 的标准请求，返回的 JSON 解析到 res 里面
+func PostForm(ctx context.Context, client *http.Client, endpoint string, form url.Values, res any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	return Do(client, req, res)
+}
+
+// GetJSON accessToken 不为空的时候带上 Bearer 头
+func GetJSON(ctx context.Context, client *http.Client, endpoint, accessToken string, res any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return Do(client, req, res)
+}
+
+func Do(client *http.Client, req *http.Request, res any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// 响应体限制一下大小，第三方出问题的时候不至于把内存吃光
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求 %s 失败，状态码 %d，响应 %s", req.URL.Host+req.URL.Path, resp.StatusCode, body)
+	}
+	return json.Unmarshal(body, res)
+}
@@ -0,0 +1,352 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/service/outh2"
+	"webok/pkg/jwtx"
+)
+
+var (
+	ErrNonceMismatch = errors.New("oidc: nonce 不匹配")
+	ErrSubMismatch   = errors.New("oidc: userinfo 的 sub 和 id token 不一致")
+)
+
+// validMethods 只接受非对称的签名算法，HS256 的密钥就是 client secret，没有意义
+var validMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+type Config struct {
+	// Name 路由里面的名字，同时接多个 OIDC 的时候用来区分，默认是 oidc
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Timeout      time.Duration
+}
+
+// metadata 发现文档里面用到的字段
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+type publicKey struct {
+	alg string
+	key crypto.PublicKey
+}
+
+type provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu   sync.Mutex
+	meta *metadata
+	keys map[string]publicKey
+	// keysFetchedAt 遇到不认识的 kid 说明对方换了密钥，要重新拉，但是不能每次都拉
+	keysFetchedAt time.Time
+	keysRefresh   time.Duration
+	now           func() time.Time
+}
+
+func NewProvider(cfg Config) outh2.Provider {
+	if cfg.Name == "" {
+		cfg.Name = "oidc"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	if !slices.Contains(cfg.Scopes, "openid") {
+		cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+	}
+	return &provider{
+		cfg:         cfg,
+		client:      outh2.NewHTTPClient(cfg.Timeout),
+		keysRefresh: time.Minute,
+		now:         time.Now,
+	}
+}
+
+func (p *provider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *provider) AuthURL(ctx context.Context, params outh2.AuthParams) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", params.State)
+	if params.Nonce != "" {
+		q.Set("nonce", params.Nonce)
+	}
+	if params.CodeVerifier != "" {
+		q.Set("code_challenge", outh2.CodeChallenge(params.CodeVerifier))
+		q.Set("code_challenge_method", "S256")
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+type tokenResult struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	IDToken      string `json:"id_token"`
+}
+
+// Exchange 换到 token 之后马上校验 id token，nonce 只有这个时候才有
+func (p *provider) Exchange(ctx context.Context, code string, params outh2.AuthParams) (outh2.Token, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return outh2.Token{}, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("client_secret", p.cfg.ClientSecret)
+	if params.CodeVerifier != "" {
+		form.Set("code_verifier", params.CodeVerifier)
+	}
+	var res tokenResult
+	err = outh2.PostForm(ctx, p.client, meta.TokenEndpoint, form, &res)
+	if err != nil {
+		return outh2.Token{}, err
+	}
+	if res.IDToken == "" {
+		return outh2.Token{}, errors.New("oidc: 没有返回 id token")
+	}
+	claims, err := p.verify(ctx, res.IDToken, params.Nonce)
+	if err != nil {
+		return outh2.Token{}, err
+	}
+	tok := outh2.Token{
+		AccessToken:  res.AccessToken,
+		RefreshToken: res.RefreshToken,
+		IDToken:      res.IDToken,
+		Subject:      claims.Subject,
+		Extra: map[string]string{
+			"email":          claims.Email,
+			"email_verified": strconv.FormatBool(bool(claims.EmailVerified)),
+			"name":           claims.Name,
+		},
+	}
+	if res.ExpiresIn > 0 {
+		tok.Expiry = p.now().Add(time.Duration(res.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
+
+// UserInfo id token 里面的信息不全的时候，用 userinfo 接口补充
+func (p *provider) UserInfo(ctx context.Context, tok outh2.Token) (domain.OAuth2Info, error) {
+	if tok.Subject == "" {
+		return domain.OAuth2Info{}, errors.New("oidc: token 没有经过校验")
+	}
+	info := domain.OAuth2Info{
+		Provider:      p.Name(),
+		Subject:       tok.Subject,
+		Email:         tok.Extra["email"],
+		EmailVerified: tok.Extra["email_verified"] == "true",
+		Name:          tok.Extra["name"],
+	}
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return domain.OAuth2Info{}, err
+	}
+	if meta.UserinfoEndpoint == "" || tok.AccessToken == "" {
+		return info, nil
+	}
+	var ui userInfo
+	err = outh2.GetJSON(ctx, p.client, meta.UserinfoEndpoint, tok.AccessToken, &ui)
+	if err != nil {
+		return domain.OAuth2Info{}, err
+	}
+	// 规范要求必须检查，不然可能拿到的是别人的信息
+	if ui.Subject != tok.Subject {
+		return domain.OAuth2Info{}, ErrSubMismatch
+	}
+	if ui.Email != "" {
+		info.Email, info.EmailVerified = ui.Email, bool(ui.EmailVerified)
+	}
+	if ui.Name != "" {
+		info.Name = ui.Name
+	}
+	return info, nil
+}
+
+type userInfo struct {
+	Subject       string  `json:"sub"`
+	Email         string  `json:"email"`
+	EmailVerified boolish `json:"email_verified"`
+	Name          string  `json:"name"`
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce           string  `json:"nonce"`
+	AuthorizedParty string  `json:"azp"`
+	Email           string  `json:"email"`
+	EmailVerified   boolish `json:"email_verified"`
+	Name            string  `json:"name"`
+}
+
+// boolish 有的平台 email_verified 返回的是字符串 "true"
+type boolish bool
+
+func (b *boolish) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch val := v.(type) {
+	case bool:
+		*b = boolish(val)
+	case string:
+		*b = boolish(val == "true")
+	default:
+		*b = false
+	}
+	return nil
+}
+
+func (p *provider) verify(ctx context.Context, idToken, nonce string) (*idTokenClaims, error) {
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(idToken, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid, token.Method.Alg())
+	},
+		jwt.WithValidMethods(validMethods),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+		jwt.WithTimeFunc(p.now))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: id token 校验失败 %w", err)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("oidc: id token 没有 sub")
+	}
+	// 有多个 aud 的时候，azp 必须是自己
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.cfg.ClientID {
+		return nil, errors.New("oidc: id token 的 azp 不对")
+	}
+	if nonce != "" && subtle.ConstantTimeCompare([]byte(nonce), []byte(claims.Nonce)) != 1 {
+		return nil, ErrNonceMismatch
+	}
+	return &claims, nil
+}
+
+// key 按照 kid 找公钥，没有 kid 的时候只能是只有一把密钥
+func (p *provider) key(ctx context.Context, kid, alg string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k, ok := p.lookupKey(kid)
+	if !ok && p.now().Sub(p.keysFetchedAt) >= p.keysRefresh {
+		if err := p.fetchKeys(ctx); err != nil {
+			return nil, err
+		}
+		k, ok = p.lookupKey(kid)
+	}
+	if !ok {
+		return nil, jwtx.ErrUnknownKid
+	}
+	if k.alg != "" && k.alg != alg {
+		return nil, fmt.Errorf("oidc: kid %s 的算法是 %s，token 用的是 %s", kid, k.alg, alg)
+	}
+	return k.key, nil
+}
+
+func (p *provider) lookupKey(kid string) (publicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, k := range p.keys {
+			return k, true
+		}
+	}
+	k, ok := p.keys[kid]
+	return k, ok
+}
+
+// fetchKeys 调用方持有锁
+func (p *provider) fetchKeys(ctx context.Context) error {
+	meta, err := p.loadMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	var jwks jwtx.JWKS
+	err = outh2.GetJSON(ctx, p.client, meta.JwksURI, "", &jwks)
+	if err != nil {
+		return err
+	}
+	p.keysFetchedAt = p.now()
+	keys := make(map[string]publicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, er := jwk.PublicKey()
+		if er != nil {
+			// 不认识的密钥类型跳过，不影响别的密钥
+			continue
+		}
+		keys[jwk.Kid] = publicKey{alg: jwk.Alg, key: key}
+	}
+	p.keys = keys
+	return nil
+}
+
+func (p *provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loadMetadata(ctx)
+}
+
+// loadMetadata 调用方持有锁，拉取失败的时候不缓存，下一次再试
+func (p *provider) loadMetadata(ctx context.Context) (*metadata, error) {
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	var meta metadata
+	err := outh2.GetJSON(ctx, p.client,
+		strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", "", &meta)
+	if err != nil {
+		return nil, err
+	}
+	// 发现文档里面的 issuer 必须和配置的完全一致，防止被换成别的身份提供方
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc: issuer 不匹配，配置的是 %s，发现文档里面是 %s", p.cfg.Issuer, meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JwksURI == "" {
+		return nil, errors.New("oidc: 发现文档缺少必要的字段")
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
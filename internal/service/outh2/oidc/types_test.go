@@ -0,0 +1,399 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/service/outh2"
+	"webok/pkg/jwtx"
+)
+
+const (
+	clientID     = "webok"
+	clientSecret = "secret"
+	redirectURL  = "http://localhost:8080/oauth2/oidc/callback"
+)
+
+func TestProvider_Login(t *testing.T) {
+	testCases := []struct {
+		name string
+		// before 用户在授权页面同意之前，可以修改假服务器的行为
+		before func(t *testing.T, srv *fakeServer)
+		// params 换 token 的时候带上的参数，默认和发起授权的时候一致
+		params func(p outh2.AuthParams) outh2.AuthParams
+
+		wantInfo domain.OAuth2Info
+		wantErr  bool
+	}{
+		{
+			name: "登录成功",
+			wantInfo: domain.OAuth2Info{
+				Provider:      "oidc",
+				Subject:       "user-1",
+				Email:         "user-1@example.com",
+				EmailVerified: true,
+				Name:          "用户一",
+			},
+		},
+		{
+			name: "code verifier 不对",
+			params: func(p outh2.AuthParams) outh2.AuthParams {
+				p.CodeVerifier = "another-verifier-another-verifier-another-ver"
+				return p
+			},
+			wantErr: true,
+		},
+		{
+			name: "nonce 不对",
+			params: func(p outh2.AuthParams) outh2.AuthParams {
+				p.Nonce = "another-nonce"
+				return p
+			},
+			wantErr: true,
+		},
+		{
+			name: "issuer 不对",
+			before: func(t *testing.T, srv *fakeServer) {
+				srv.mutate = func(c jwt.MapClaims) {
+					c["iss"] = "https://evil.example.com"
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "不是发给我们的 id token",
+			before: func(t *testing.T, srv *fakeServer) {
+				srv.mutate = func(c jwt.MapClaims) {
+					c["aud"] = "another-client"
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "id token 过期",
+			before: func(t *testing.T, srv *fakeServer) {
+				srv.mutate = func(c jwt.MapClaims) {
+					c["exp"] = time.Now().Add(-time.Hour).Unix()
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "签名的密钥不在 JWKS 里面",
+			before: func(t *testing.T, srv *fakeServer) {
+				key, err := rsa.GenerateKey(rand.Reader, 2048)
+				require.NoError(t, err)
+				srv.signWith = key
+			},
+			wantErr: true,
+		},
+		{
+			name: "userinfo 返回了别人的信息",
+			before: func(t *testing.T, srv *fakeServer) {
+				srv.userinfoSub = "user-2"
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			defer srv.Close()
+			p := NewProvider(Config{
+				Issuer:       srv.URL,
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+			})
+			if tc.before != nil {
+				tc.before(t, srv)
+			}
+			ctx := context.Background()
+
+			params := newAuthParams(t)
+			authURL, err := p.AuthURL(ctx, params)
+			require.NoError(t, err)
+			code := srv.authorize(t, authURL, "user-1")
+
+			if tc.params != nil {
+				params = tc.params(params)
+			}
+			tok, err := p.Exchange(ctx, code, params)
+			if err == nil {
+				var info domain.OAuth2Info
+				info, err = p.UserInfo(ctx, tok)
+				assert.Equal(t, tc.wantInfo, info)
+			}
+			assert.Equal(t, tc.wantErr, err != nil, err)
+		})
+	}
+}
+
+// TestProvider_KeyRotation 对方换了密钥之后，不认识的 kid 触发重新拉取 JWKS
+func TestProvider_KeyRotation(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.Close()
+	p := NewProvider(Config{
+		Issuer:       srv.URL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+	}).(*provider)
+	ctx := context.Background()
+	login := func() error {
+		params := newAuthParams(t)
+		authURL, err := p.AuthURL(ctx, params)
+		require.NoError(t, err)
+		_, err = p.Exchange(ctx, srv.authorize(t, authURL, "user-1"), params)
+		return err
+	}
+	require.NoError(t, login())
+	assert.Equal(t, 1, srv.hits())
+
+	srv.rotate(t)
+	// 刚拉过 JWKS，还没到可以重新拉取的时间
+	assert.ErrorIs(t, login(), jwtx.ErrUnknownKid)
+	assert.Equal(t, 1, srv.hits())
+
+	p.keysRefresh = 0
+	require.NoError(t, login())
+	assert.Equal(t, 2, srv.hits())
+}
+
+func TestProvider_AuthURL(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.Close()
+	p := NewProvider(Config{
+		Name:        "corp",
+		Issuer:      srv.URL,
+		ClientID:    clientID,
+		RedirectURL: redirectURL,
+		Scopes:      []string{"email"},
+	})
+	assert.Equal(t, "corp", p.Name())
+	authURL, err := p.AuthURL(context.Background(), outh2.AuthParams{
+		State:        "state",
+		Nonce:        "nonce",
+		CodeVerifier: "verifier",
+	})
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	assert.Equal(t, url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURL},
+		"scope":                 {"openid email"},
+		"state":                 {"state"},
+		"nonce":                 {"nonce"},
+		"code_challenge":        {outh2.CodeChallenge("verifier")},
+		"code_challenge_method": {"S256"},
+	}, u.Query())
+}
+
+func TestProvider_IssuerMismatch(t *testing.T) {
+	srv := newFakeServer(t)
+	defer srv.Close()
+	// 配置的 issuer 和发现文档里面的不一样
+	p := NewProvider(Config{Issuer: srv.URL + "/", ClientID: clientID})
+	_, err := p.AuthURL(context.Background(), newAuthParams(t))
+	assert.Error(t, err)
+}
+
+func newAuthParams(t *testing.T) outh2.AuthParams {
+	verifier, err := outh2.NewCodeVerifier()
+	require.NoError(t, err)
+	return outh2.AuthParams{State: "state", Nonce: "nonce-" + verifier[:8], CodeVerifier: verifier}
+}
+
+type grant struct {
+	sub       string
+	nonce     string
+	challenge string
+	redirect  string
+}
+
+// fakeServer 本地的 OIDC 服务器，实现发现文档，JWKS，授权码和 userinfo
+type fakeServer struct {
+	*httptest.Server
+	t *testing.T
+
+	mu     sync.Mutex
+	key    *rsa.PrivateKey
+	kid    string
+	grants map[string]grant
+	tokens map[string]string
+	// signWith 不为空的时候用这个密钥签名，kid 不变
+	signWith *rsa.PrivateKey
+	// mutate 签发 id token 之前修改 claims
+	mutate      func(c jwt.MapClaims)
+	userinfoSub string
+	jwksHits    int
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	srv := &fakeServer{
+		t:      t,
+		grants: map[string]grant{},
+		tokens: map[string]string{},
+	}
+	srv.rotate(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", srv.discovery)
+	mux.HandleFunc("/jwks", srv.jwks)
+	mux.HandleFunc("/token", srv.token)
+	mux.HandleFunc("/userinfo", srv.userinfo)
+	srv.Server = httptest.NewServer(mux)
+	return srv
+}
+
+func (s *fakeServer) hits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jwksHits
+}
+
+func (s *fakeServer) rotate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.key = key
+	s.kid = base64.RawURLEncoding.EncodeToString(key.N.Bytes()[:8])
+}
+
+// authorize 模拟用户在授权页面同意，返回回调里面的 code
+func (s *fakeServer) authorize(t *testing.T, authURL, sub string) string {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	q := u.Query()
+	require.Equal(t, "code", q.Get("response_type"))
+	require.Equal(t, clientID, q.Get("client_id"))
+	require.Equal(t, "S256", q.Get("code_challenge_method"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := "code-" + q.Get("nonce")
+	s.grants[code] = grant{
+		sub:       sub,
+		nonce:     q.Get("nonce"),
+		challenge: q.Get("code_challenge"),
+		redirect:  q.Get("redirect_uri"),
+	}
+	return code
+}
+
+func (s *fakeServer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                           s.URL,
+		"authorization_endpoint":           s.URL + "/authorize",
+		"token_endpoint":                   s.URL + "/token",
+		"userinfo_endpoint":                s.URL + "/userinfo",
+		"jwks_uri":                         s.URL + "/jwks",
+		"code_challenge_methods_supported": []string{"S256"},
+	})
+}
+
+func (s *fakeServer) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jwksHits++
+	writeJSON(w, http.StatusOK, jwtx.JWKS{Keys: []jwtx.JWK{{
+		Kty: "RSA",
+		Kid: s.kid,
+		Use: "sig",
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+	}}})
+}
+
+func (s *fakeServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.grants[r.PostForm.Get("code")]
+	// 授权码只能用一次
+	delete(s.grants, r.PostForm.Get("code"))
+	if r.PostForm.Get("client_id") != clientID || r.PostForm.Get("client_secret") != clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != g.redirect ||
+		outh2.CodeChallenge(r.PostForm.Get("code_verifier")) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	claims := jwt.MapClaims{
+		"iss":            s.URL,
+		"sub":            g.sub,
+		"aud":            clientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          g.nonce,
+		"email":          g.sub + "@example.com",
+		"email_verified": "true",
+	}
+	if s.mutate != nil {
+		s.mutate(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = s.kid
+	key := s.key
+	if s.signWith != nil {
+		key = s.signWith
+	}
+	idToken, err := token.SignedString(key)
+	require.NoError(s.t, err)
+	accessToken := "at-" + g.nonce
+	s.tokens[accessToken] = g.sub
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (s *fakeServer) userinfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if s.userinfoSub != "" {
+		sub = s.userinfoSub
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"sub":            sub,
+		"name":           "用户一",
+		"email_verified": true,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, val any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(val)
+}
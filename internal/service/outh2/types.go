@@ -0,0 +1,68 @@
+package outh2
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+	"webok/internal/domain"
+)
+
+var ErrUnknownProvider = errors.New("不支持的第三方登录")
+
+// AuthParams 发起授权的时候生成，回调的时候原样拿回来换 token。
+// 不支持 PKCE 或者 OIDC 的平台忽略对应的字段
+type AuthParams struct {
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+type Token struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	Expiry       time.Time
+	// Subject 换 token 的时候就能确定的用户标识，比如微信的 openid，OIDC 校验过的 sub
+	Subject string
+	// Extra 平台特有的字段，比如微信的 unionid
+	Extra map[string]string
+}
+
+//go:generate mockgen -source=types.go -package=outh2mocks -destination=./mock/types.mock.go
+type Provider interface {
+	// Name 路由 /oauth2/:provider 里面的名字
+	Name() string
+	AuthURL(ctx context.Context, p AuthParams) (string, error)
+	Exchange(ctx context.Context, code string, p AuthParams) (Token, error)
+	UserInfo(ctx context.Context, tok Token) (domain.OAuth2Info, error)
+}
+
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider, len(providers))}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (Provider, error) {
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+func (r *Registry) Names() []string {
+	res := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -2,29 +2,32 @@ package wechat
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	uuid "github.com/lithammer/shortuuid/v4"
 	"net/http"
 	"net/url"
+	"time"
 	"webok/internal/domain"
+	"webok/internal/service/outh2"
 )
 
-type Service interface {
-	AuthURL(ctx context.Context) (string, error)
-	VerifyCode(ctx context.Context, code string) (domain.WechatInfo, error)
-}
-
-var redirectURL = url.PathEscape("https://xiaoxina.xyz/oauth2/wechat/callback")
-
-const authURLPattern = "https://open.weixin.qq.com/connect/qrconnect?appid=%s&redirect_uri=%s&response_type=code&scope=snsapi_login&state=%s#wechat_redirect"
+const (
+	authURL        = "https://open.weixin.qq.com/connect/qrconnect"
+	accessTokenURL = "https://api.weixin.qq.com/sns/oauth2/access_token"
+)
 
-const accessTokenUrlPattern = "https://api.weixin.qq.com/sns/oauth2/access_token?appid=%s&secret=%s&code=%s&grant_type=authorization_code"
+type Config struct {
+	AppID       string
+	AppSecret   string
+	RedirectURL string
+	Timeout     time.Duration
+}
 
-type service struct {
-	appID     string
-	appSecret string
-	client    *http.Client
+// provider 微信不支持 PKCE 和 OIDC，AuthParams 里面只用 state
+type provider struct {
+	appID       string
+	appSecret   string
+	redirectURL string
+	client      *http.Client
 }
 
 type Result struct {
@@ -40,41 +43,56 @@ type Result struct {
 	ErrMsg  string `json:"errmsg"`
 }
 
-func NewService(appid string, appSecret string) Service {
-	return &service{
-		appID:     appid,
-		appSecret: appSecret,
-		client:    http.DefaultClient,
+func NewProvider(cfg Config) outh2.Provider {
+	return &provider{
+		appID:       cfg.AppID,
+		appSecret:   cfg.AppSecret,
+		redirectURL: cfg.RedirectURL,
+		client:      outh2.NewHTTPClient(cfg.Timeout),
 	}
 }
-func (s *service) AuthURL(ctx context.Context) (string, error) {
-	state := uuid.New()
-	retStr := fmt.Sprintf(authURLPattern, s.appID, redirectURL, state)
-	return retStr, nil
+
+func (p *provider) Name() string {
+	return domain.OAuth2ProviderWechat
 }
 
-func (s *service) VerifyCode(ctx context.Context, code string) (domain.WechatInfo, error) {
-	accessTokenUrl := fmt.Sprintf(accessTokenUrlPattern, s.appID, s.appSecret, code)
-	// 发送请求
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, accessTokenUrl, nil)
-	if err != nil {
-		return domain.WechatInfo{}, err
-	}
-	httpResp, err := s.client.Do(req)
-	if err != nil {
-		return domain.WechatInfo{}, err
-	}
-	// 解析结果
+func (p *provider) AuthURL(ctx context.Context, params outh2.AuthParams) (string, error) {
+	// 微信要求参数按照文档的顺序，url.Values 的 Encode 会按字母排序，所以手动拼
+	return fmt.Sprintf("%s?appid=%s&redirect_uri=%s&response_type=code&scope=snsapi_login&state=%s#wechat_redirect",
+		authURL, url.QueryEscape(p.appID), url.QueryEscape(p.redirectURL), url.QueryEscape(params.State)), nil
+}
+
+func (p *provider) Exchange(ctx context.Context, code string, params outh2.AuthParams) (outh2.Token, error) {
+	q := url.Values{}
+	q.Set("appid", p.appID)
+	q.Set("secret", p.appSecret)
+	q.Set("code", code)
+	q.Set("grant_type", "authorization_code")
 	var res Result
-	err = json.NewDecoder(httpResp.Body).Decode(&res)
+	err := outh2.GetJSON(ctx, p.client, accessTokenURL+"?"+q.Encode(), "", &res)
 	if err != nil {
-		return domain.WechatInfo{}, err
+		return outh2.Token{}, err
 	}
 	if res.ErrCode != 0 {
-		return domain.WechatInfo{}, fmt.Errorf("调用微信接口失败 errcode %d, errmsg %s", res.ErrCode, res.ErrMsg)
+		return outh2.Token{}, fmt.Errorf("调用微信接口失败 errcode %d, errmsg %s", res.ErrCode, res.ErrMsg)
+	}
+	return outh2.Token{
+		AccessToken:  res.AccessToken,
+		RefreshToken: res.RefreshToken,
+		Expiry:       time.Now().Add(time.Duration(res.ExpiresIn) * time.Second),
+		Subject:      res.OpenId,
+		Extra:        map[string]string{"unionid": res.UnionId},
+	}, nil
+}
+
+// UserInfo openid 和 unionid 换 token 的时候就拿到了，不需要再调接口
+func (p *provider) UserInfo(ctx context.Context, tok outh2.Token) (domain.OAuth2Info, error) {
+	if tok.Subject == "" {
+		return domain.OAuth2Info{}, fmt.Errorf("微信没有返回 openid")
 	}
-	return domain.WechatInfo{
-		UnionId: res.UnionId,
-		OpenId:  res.OpenId,
+	return domain.OAuth2Info{
+		Provider: p.Name(),
+		Subject:  tok.Subject,
+		UnionId:  tok.Extra["unionid"],
 	}, nil
 }
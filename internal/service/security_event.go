@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/repository"
+	"webok/pkg/logger"
+)
+
+const (
+	defaultSecurityEventLimit = 20
+	maxSecurityEventLimit     = 100
+)
+
+//go:generate mockgen -source=security_event.go -package=svcmocks -destination=./mock/security_event.mock.go
+type SecurityEventService interface {
+	// Record 不会阻塞，事件先放到缓冲区里面，后台批量写入
+	Record(ctx context.Context, e domain.SecurityEvent)
+	// ListByUser 用户自己最近的安全事件
+	ListByUser(ctx context.Context, uid int64, beforeId int64, limit int) ([]domain.SecurityEvent, error)
+	// Search 管理员按照用户或者 IP 查询
+	Search(ctx context.Context, q domain.SecurityEventQuery) ([]domain.SecurityEvent, error)
+}
+
+type SecurityEventConfig struct {
+	// Buffer 缓冲区满了之后新的事件直接丢掉，数据库慢的时候不能拖慢登录
+	Buffer int
+	// BatchSize 攒够这么多条或者过了 FlushInterval 就写一次
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+type securityEventService struct {
+	repo      repository.SecurityEventRepository
+	userRepo  repository.UserRepository
+	events    chan domain.SecurityEvent
+	batchSize int
+	interval  time.Duration
+	l         logger.Logger
+}
+
+// NewSecurityEventService 会启动一个后台写入的 goroutine，进程退出的时候缓冲区里面还没写的事件会丢失
+func NewSecurityEventService(repo repository.SecurityEventRepository, userRepo repository.UserRepository,
+	cfg SecurityEventConfig, l logger.Logger) SecurityEventService {
+	svc := &securityEventService{
+		repo:      repo,
+		userRepo:  userRepo,
+		events:    make(chan domain.SecurityEvent, cfg.Buffer),
+		batchSize: cfg.BatchSize,
+		interval:  cfg.FlushInterval,
+		l:         l,
+	}
+	go svc.loop()
+	return svc
+}
+
+func (svc *securityEventService) Record(ctx context.Context, e domain.SecurityEvent) {
+	if e.Ctime.IsZero() {
+		e.Ctime = time.Now()
+	}
+	select {
+	case svc.events <- e:
+	default:
+		svc.l.Warn("安全事件缓冲区已满，丢弃事件",
+			logger.String("type", string(e.Type)),
+			logger.Int64("uid", e.Uid),
+			logger.String("ip", e.IP))
+	}
+}
+
+func (svc *securityEventService) loop() {
+	ticker := time.NewTicker(svc.interval)
+	defer ticker.Stop()
+	batch := make([]domain.SecurityEvent, 0, svc.batchSize)
+	for {
+		select {
+		case e := <-svc.events:
+			batch = append(batch, e)
+			if len(batch) < svc.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		svc.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+func (svc *securityEventService) flush(batch []domain.SecurityEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	for i := range batch {
+		svc.resolveUid(ctx, &batch[i])
+	}
+	err := svc.repo.AddBatch(ctx, batch)
+	if err != nil {
+		svc.l.Error("写入安全事件失败", logger.Int("count", len(batch)), logger.Error(err))
+	}
+}
+
+// resolveUid 登录失败的时候 handler 不查用户，免得响应时间暴露账号是否存在，
+// 这里按照登录用的账号补上 uid，用户才能在自己的安全记录里面看到别人试过登录
+func (svc *securityEventService) resolveUid(ctx context.Context, e *domain.SecurityEvent) {
+	if e.Uid != 0 || e.Type != domain.SecurityEventLoginFailed || e.Account == "" {
+		return
+	}
+	var (
+		u   *domain.User
+		err error
+	)
+	switch e.Method {
+	case domain.LoginMethodPassword, domain.LoginMethodEmail:
+		u, err = svc.userRepo.FindByEmail(ctx, e.Account)
+	case domain.LoginMethodSMS:
+		u, err = svc.userRepo.FindByPhone(ctx, e.Account)
+	default:
+		return
+	}
+	switch {
+	case err == nil:
+		e.Uid = u.Id
+	case errors.Is(err, repository.ErrRecordNotFound):
+	default:
+		svc.l.Warn("查询登录失败的用户失败", logger.String("method", e.Method), logger.Error(err))
+	}
+}
+
+func (svc *securityEventService) ListByUser(ctx context.Context, uid int64, beforeId int64, limit int) ([]domain.SecurityEvent, error) {
+	return svc.Search(ctx, domain.SecurityEventQuery{Uid: uid, BeforeId: beforeId, Limit: limit})
+}
+
+func (svc *securityEventService) Search(ctx context.Context, q domain.SecurityEventQuery) ([]domain.SecurityEvent, error) {
+	switch {
+	case q.Limit <= 0:
+		q.Limit = defaultSecurityEventLimit
+	case q.Limit > maxSecurityEventLimit:
+		q.Limit = maxSecurityEventLimit
+	}
+	return svc.repo.Find(ctx, q)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/repository"
+	repomocks "webok/internal/repository/mock"
+	"webok/pkg/logger"
+)
+
+func TestSecurityEventService_Record(t *testing.T) {
+	testCases := []struct {
+		name   string
+		cfg    SecurityEventConfig
+		events int
+
+		wantBatches []int
+	}{
+		{
+			name:        "攒够一批就写",
+			cfg:         SecurityEventConfig{Buffer: 10, BatchSize: 2, FlushInterval: time.Hour},
+			events:      4,
+			wantBatches: []int{2, 2},
+		},
+		{
+			name:        "不够一批，定时写",
+			cfg:         SecurityEventConfig{Buffer: 10, BatchSize: 100, FlushInterval: time.Millisecond * 10},
+			events:      3,
+			wantBatches: []int{3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			written := make(chan []domain.SecurityEvent, len(tc.wantBatches))
+			repo := repomocks.NewMockSecurityEventRepository(ctrl)
+			repo.EXPECT().AddBatch(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, events []domain.SecurityEvent) error {
+					// 写完之后 batch 会被复用，要拷贝一份
+					written <- append([]domain.SecurityEvent(nil), events...)
+					return nil
+				}).Times(len(tc.wantBatches))
+			svc := NewSecurityEventService(repo, nil, tc.cfg, logger.NewNopLogger())
+			for i := 0; i < tc.events; i++ {
+				svc.Record(context.Background(), domain.SecurityEvent{Uid: int64(i), Type: domain.SecurityEventLogin})
+			}
+			uid := int64(0)
+			for _, want := range tc.wantBatches {
+				select {
+				case batch := <-written:
+					require.Len(t, batch, want)
+					for _, e := range batch {
+						assert.Equal(t, uid, e.Uid)
+						assert.False(t, e.Ctime.IsZero())
+						uid++
+					}
+				case <-time.After(time.Second):
+					t.Fatal("没有写入安全事件")
+				}
+			}
+		})
+	}
+}
+
+func TestSecurityEventService_Record_BufferFull(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockSecurityEventRepository(ctrl)
+	svc := &securityEventService{
+		repo:   repo,
+		events: make(chan domain.SecurityEvent, 1),
+		l:      logger.NewNopLogger(),
+	}
+	// 没有启动后台写入，缓冲区满了也不能阻塞
+	svc.Record(context.Background(), domain.SecurityEvent{Uid: 1})
+	svc.Record(context.Background(), domain.SecurityEvent{Uid: 2})
+	assert.Len(t, svc.events, 1)
+}
+
+func TestSecurityEventService_flush(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	userRepo := repomocks.NewMockUserRepository(ctrl)
+	userRepo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").Return(&domain.User{Id: 1}, nil)
+	userRepo.EXPECT().FindByPhone(gomock.Any(), "15212345678").Return(&domain.User{Id: 2}, nil)
+	userRepo.EXPECT().FindByEmail(gomock.Any(), "none@qq.com").Return(nil, repository.ErrRecordNotFound)
+	repo := repomocks.NewMockSecurityEventRepository(ctrl)
+	repo.EXPECT().AddBatch(gomock.Any(), []domain.SecurityEvent{
+		{Uid: 1, Type: domain.SecurityEventLoginFailed, Method: domain.LoginMethodPassword, Account: "123@qq.com"},
+		{Uid: 2, Type: domain.SecurityEventLoginFailed, Method: domain.LoginMethodSMS, Account: "15212345678"},
+		// 账号不存在的还是 0
+		{Type: domain.SecurityEventLoginFailed, Method: domain.LoginMethodEmail, Account: "none@qq.com"},
+		// 已经知道是谁的不用再查
+		{Uid: 3, Type: domain.SecurityEventLoginFailed, Method: domain.LoginMethodTOTP, Account: "123@qq.com"},
+		{Type: domain.SecurityEventLoginFailed, Method: "github"},
+	}).Return(nil)
+	svc := &securityEventService{repo: repo, userRepo: userRepo, l: logger.NewNopLogger()}
+	svc.flush([]domain.SecurityEvent{
+		{Type: domain.SecurityEventLoginFailed, Method: domain.LoginMethodPassword, Account: "123@qq.com"},
+		{Type: domain.SecurityEventLoginFailed, Method: domain.LoginMethodSMS, Account: "15212345678"},
+		{Type: domain.SecurityEventLoginFailed, Method: domain.LoginMethodEmail, Account: "none@qq.com"},
+		{Uid: 3, Type: domain.SecurityEventLoginFailed, Method: domain.LoginMethodTOTP, Account: "123@qq.com"},
+		{Type: domain.SecurityEventLoginFailed, Method: "github"},
+	})
+}
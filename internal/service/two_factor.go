@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/repository"
+	"webok/pkg/totp"
+)
+
+var (
+	ErrTwoFactorEnabled     = repository.ErrTwoFactorEnabled
+	ErrTwoFactorNotEnabled  = errors.New("没有开启两步验证")
+	ErrTwoFactorNotEnrolled = errors.New("请先扫码绑定验证器")
+	ErrTwoFactorCodeInvalid = errors.New("两步验证码不对")
+)
+
+const (
+	recoveryCodeCount = 10
+	recoveryCodeLen   = 10
+	// totpSecretSize RFC 4226 建议至少 160 位
+	totpSecretSize = 20
+)
+
+//go:generate mockgen -source=two_factor.go -package=svcmocks -destination=./mock/two_factor.mock.go
+type TwoFactorService interface {
+	// Enroll 生成新的密钥，account 显示在验证器 App 里面，用户确认之前不生效
+	Enroll(ctx context.Context, uid int64, account string) (domain.TOTPEnrollment, error)
+	// Confirm 用验证器上的验证码确认开启，返回恢复码的明文，只有这一次能看到
+	Confirm(ctx context.Context, uid int64, code string) ([]string, error)
+	// Status 没有记录的时候返回没开启的状态，不会返回密钥
+	Status(ctx context.Context, uid int64) (domain.TwoFactor, error)
+	// Verify code 可以是验证器上的验证码，也可以是恢复码
+	Verify(ctx context.Context, uid int64, code string) error
+	Disable(ctx context.Context, uid int64, code string) error
+	// RegenerateRecoveryCodes 旧的恢复码全部作废
+	RegenerateRecoveryCodes(ctx context.Context, uid int64, code string) ([]string, error)
+}
+
+type TwoFactorConfig struct {
+	// Issuer 验证器 App 里面显示的名字
+	Issuer string
+	// Skew 允许手机时间偏差几个周期
+	Skew int
+}
+
+type twoFactorService struct {
+	repo   repository.TwoFactorRepository
+	issuer string
+	opts   totp.Options
+	now    func() time.Time
+}
+
+func NewTwoFactorService(repo repository.TwoFactorRepository, cfg TwoFactorConfig) TwoFactorService {
+	return &twoFactorService{
+		repo:   repo,
+		issuer: cfg.Issuer,
+		opts:   totp.Options{Skew: cfg.Skew},
+		now:    time.Now,
+	}
+}
+
+func (svc *twoFactorService) Enroll(ctx context.Context, uid int64, account string) (domain.TOTPEnrollment, error) {
+	secret, err := totp.NewSecret(totpSecretSize)
+	if err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	err = svc.repo.Upsert(ctx, domain.TwoFactor{Uid: uid, Secret: secret})
+	if err != nil {
+		return domain.TOTPEnrollment{}, err
+	}
+	return domain.TOTPEnrollment{
+		Secret: totp.EncodeSecret(secret),
+		URI:    totp.ProvisioningURI(svc.issuer, account, secret, svc.opts),
+	}, nil
+}
+
+func (svc *twoFactorService) Confirm(ctx context.Context, uid int64, code string) ([]string, error) {
+	tf, err := svc.repo.FindByUid(ctx, uid)
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		return nil, ErrTwoFactorNotEnrolled
+	case err != nil:
+		return nil, err
+	case tf.Enabled:
+		return nil, ErrTwoFactorEnabled
+	}
+	step, ok := totp.Validate(normalizeCode(code), tf.Secret, svc.now(), svc.opts)
+	if !ok {
+		return nil, ErrTwoFactorCodeInvalid
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = svc.repo.Enable(ctx, uid, int64(step), hashes)
+	if errors.Is(err, repository.ErrTwoFactorStepUsed) {
+		return nil, ErrTwoFactorCodeInvalid
+	}
+	return codes, err
+}
+
+func (svc *twoFactorService) Status(ctx context.Context, uid int64) (domain.TwoFactor, error) {
+	tf, err := svc.repo.FindByUid(ctx, uid)
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		return domain.TwoFactor{Uid: uid}, nil
+	case err != nil:
+		return domain.TwoFactor{}, err
+	}
+	tf.Secret = nil
+	return tf, nil
+}
+
+func (svc *twoFactorService) Verify(ctx context.Context, uid int64, code string) error {
+	tf, err := svc.repo.FindByUid(ctx, uid)
+	switch {
+	case errors.Is(err, repository.ErrRecordNotFound):
+		return ErrTwoFactorNotEnabled
+	case err != nil:
+		return err
+	case !tf.Enabled:
+		return ErrTwoFactorNotEnabled
+	}
+	code = normalizeCode(code)
+	if len(code) != recoveryCodeLen {
+		step, ok := totp.Validate(code, tf.Secret, svc.now(), svc.opts)
+		if !ok {
+			return ErrTwoFactorCodeInvalid
+		}
+		// 记下用过的周期，验证码被人看到了也不能再用一次
+		err = svc.repo.UseStep(ctx, uid, int64(step))
+		if errors.Is(err, repository.ErrTwoFactorStepUsed) {
+			return ErrTwoFactorCodeInvalid
+		}
+		return err
+	}
+	err = svc.repo.UseRecoveryCode(ctx, uid, hashRecoveryCode(code))
+	if errors.Is(err, repository.ErrRecoveryCodeInvalid) {
+		return ErrTwoFactorCodeInvalid
+	}
+	return err
+}
+
+func (svc *twoFactorService) Disable(ctx context.Context, uid int64, code string) error {
+	err := svc.Verify(ctx, uid, code)
+	if err != nil {
+		return err
+	}
+	return svc.repo.Delete(ctx, uid)
+}
+
+func (svc *twoFactorService) RegenerateRecoveryCodes(ctx context.Context, uid int64, code string) ([]string, error) {
+	err := svc.Verify(ctx, uid, code)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	return codes, svc.repo.UpdateRecoveryCodes(ctx, uid, hashes)
+}
+
+// normalizeCode 用户输入的时候可能带了空格和分隔符
+func normalizeCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+var recoveryEncoding = base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").WithPadding(base32.NoPadding)
+
+// newRecoveryCodes 返回明文和哈希，明文形如 abcde-fghij，只保存哈希
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	buf := make([]byte, 7)
+	for i := 0; i < recoveryCodeCount; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		code := recoveryEncoding.EncodeToString(buf)[:recoveryCodeLen]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode 恢复码是随机生成的，熵足够高，不需要 bcrypt 这种慢哈希
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
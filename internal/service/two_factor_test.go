@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/repository"
+	repomocks "webok/internal/repository/mock"
+	"webok/pkg/totp"
+)
+
+func TestTwoFactorService_Verify(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1111111111, 0)
+	step := int64(totp.Step(now, totp.Options{}))
+	code := totp.Generate(secret, now, totp.Options{})
+	enabled := domain.TwoFactor{Uid: 123, Secret: secret, Enabled: true}
+
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.TwoFactorRepository
+		code string
+
+		wantErr error
+	}{
+		{
+			name: "验证器的验证码",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(enabled, nil)
+				repo.EXPECT().UseStep(gomock.Any(), int64(123), step).Return(nil)
+				return repo
+			},
+			code: code,
+		},
+		{
+			name: "验证码已经用过",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(enabled, nil)
+				repo.EXPECT().UseStep(gomock.Any(), int64(123), step).Return(repository.ErrTwoFactorStepUsed)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTwoFactorCodeInvalid,
+		},
+		{
+			name: "验证码不对",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(enabled, nil)
+				return repo
+			},
+			code:    "000000",
+			wantErr: ErrTwoFactorCodeInvalid,
+		},
+		{
+			name: "恢复码",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(enabled, nil)
+				repo.EXPECT().UseRecoveryCode(gomock.Any(), int64(123), hashRecoveryCode("abcdefghij")).Return(nil)
+				return repo
+			},
+			code: "ABCDE-fghij",
+		},
+		{
+			name: "恢复码无效",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(enabled, nil)
+				repo.EXPECT().UseRecoveryCode(gomock.Any(), int64(123), gomock.Any()).Return(repository.ErrRecoveryCodeInvalid)
+				return repo
+			},
+			code:    "abcde-fghij",
+			wantErr: ErrTwoFactorCodeInvalid,
+		},
+		{
+			name: "还没确认开启",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(domain.TwoFactor{Uid: 123, Secret: secret}, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTwoFactorNotEnabled,
+		},
+		{
+			name: "没有开启",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(domain.TwoFactor{}, repository.ErrRecordNotFound)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTwoFactorNotEnabled,
+		},
+		{
+			name: "数据库错误",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(domain.TwoFactor{}, errors.New("db 错误"))
+				return repo
+			},
+			code:    code,
+			wantErr: errors.New("db 错误"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewTwoFactorService(tc.mock(ctrl), TwoFactorConfig{Issuer: "webook", Skew: 1}).(*twoFactorService)
+			svc.now = func() time.Time { return now }
+			err := svc.Verify(context.Background(), 123, tc.code)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestTwoFactorService_Confirm(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1111111111, 0)
+	step := int64(totp.Step(now, totp.Options{}))
+	code := totp.Generate(secret, now, totp.Options{})
+
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.TwoFactorRepository
+		code string
+
+		wantCodes int
+		wantErr   error
+	}{
+		{
+			name: "确认开启",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(domain.TwoFactor{Uid: 123, Secret: secret}, nil)
+				repo.EXPECT().Enable(gomock.Any(), int64(123), step, gomock.Len(recoveryCodeCount)).Return(nil)
+				return repo
+			},
+			code:      code,
+			wantCodes: recoveryCodeCount,
+		},
+		{
+			name: "还没扫码",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(domain.TwoFactor{}, repository.ErrRecordNotFound)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTwoFactorNotEnrolled,
+		},
+		{
+			name: "已经开启",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).
+					Return(domain.TwoFactor{Uid: 123, Secret: secret, Enabled: true}, nil)
+				return repo
+			},
+			code:    code,
+			wantErr: ErrTwoFactorEnabled,
+		},
+		{
+			name: "恢复码不能用来确认",
+			mock: func(ctrl *gomock.Controller) repository.TwoFactorRepository {
+				repo := repomocks.NewMockTwoFactorRepository(ctrl)
+				repo.EXPECT().FindByUid(gomock.Any(), int64(123)).Return(domain.TwoFactor{Uid: 123, Secret: secret}, nil)
+				return repo
+			},
+			code:    "abcde-fghij",
+			wantErr: ErrTwoFactorCodeInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewTwoFactorService(tc.mock(ctrl), TwoFactorConfig{Issuer: "webook", Skew: 1}).(*twoFactorService)
+			svc.now = func() time.Time { return now }
+			codes, err := svc.Confirm(context.Background(), 123, tc.code)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Len(t, codes, tc.wantCodes)
+			for _, c := range codes {
+				// 明文是给用户看的，长度加上中间的分隔符
+				assert.Len(t, c, recoveryCodeLen+1)
+			}
+		})
+	}
+}
@@ -6,10 +6,17 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"webok/internal/domain"
 	"webok/internal/repository"
+	"webok/pkg/logger"
 )
 
 var (
 	ErrDuplicate             = repository.ErrDuplicate
+	ErrDuplicatePhone        = repository.ErrDuplicatePhone
+	ErrDuplicateWechat       = repository.ErrDuplicateWechat
+	ErrDuplicateOAuth2       = repository.ErrDuplicateOAuth2
+	ErrLastIdentity          = repository.ErrLastIdentity
+	ErrMergeConflict         = repository.ErrMergeConflict
+	ErrMergeSelf             = errors.New("不能合并同一个账号")
 	ErrInvalidUserOrPassword = errors.New("用户不存在或者密码不对")
 	ErrRecordNotFound        = repository.ErrRecordNotFound
 )
@@ -20,12 +27,46 @@ type UserService interface {
 	Login(ctx context.Context, email string, password string) (*domain.User, error)
 	ModifyNoSensitiveInfo(ctx context.Context, u *domain.User) error
 	Profile(ctx context.Context, d *domain.User) (*domain.User, error)
-	FindOrCreate(ctx context.Context, phone string) (*domain.User, error)
-	FindOrCreateByWechat(ctx context.Context, wechatInfo domain.WechatInfo) (domain.User, error)
+	// FindOrCreate 验证码登录的时候没有账号就创建，created 表示这一次新建了账号
+	FindOrCreate(ctx context.Context, phone string) (u *domain.User, created bool, err error)
+	FindOrCreateByEmail(ctx context.Context, email string) (u *domain.User, created bool, err error)
+	FindOrCreateByWechat(ctx context.Context, wechatInfo domain.WechatInfo) (u domain.User, created bool, err error)
+	// FindByWechat 只查找，不会创建账号
+	FindByWechat(ctx context.Context, openId string) (domain.User, error)
+	// FindOrCreateByOAuth2 第三方登录，第一次登录的时候创建账号
+	FindOrCreateByOAuth2(ctx context.Context, info domain.OAuth2Info) (u domain.User, created bool, err error)
+	// FindByAccount 按照邮箱或者手机号查找用户，email 不为空的时候用 email
+	FindByAccount(ctx context.Context, email, phone string) (*domain.User, error)
+	// ResetPassword 忘记密码的时候重置，调用方要先完成身份验证
+	ResetPassword(ctx context.Context, uid int64, password string) error
+	// ChangePassword 已经登录的用户修改密码，需要验证原密码
+	ChangePassword(ctx context.Context, uid int64, oldPassword, newPassword string) error
+	// VerifyEmail 用户第一次用邮箱验证码登录的时候调用，证明了邮箱是自己的。
+	// 用密码注册的时候没有验证邮箱，密码可能是别人抢注的时候设置的，要作废，
+	// 调用方还要踢掉之前的登录态
+	VerifyEmail(ctx context.Context, uid int64) error
+	// BindEmail 修改或者绑定邮箱，调用方要先验证新邮箱
+	BindEmail(ctx context.Context, uid int64, email string) error
+	BindPhone(ctx context.Context, uid int64, phone string) error
+	// Identities 用户绑定的所有登录方式
+	Identities(ctx context.Context, uid int64) ([]domain.Identity, error)
+	// LinkWechat 已经登录的用户绑定微信
+	LinkWechat(ctx context.Context, uid int64, info domain.WechatInfo) error
+	// Unlink 解绑一种登录方式，最后一种不能解绑
+	Unlink(ctx context.Context, uid int64, typ domain.IdentityType) error
+	// UnlinkOAuth2 第三方账号可以绑定多个，要指定 provider 和 subject
+	UnlinkOAuth2(ctx context.Context, uid int64, provider, subject string) error
+	// Merge 同一个人有两个账号的时候，把 sourceUid 的登录方式、文章、点赞和收藏都合并到 uid 上面，
+	// 调用方要先确认 sourceUid 也是这个人的
+	Merge(ctx context.Context, uid, sourceUid int64) error
 }
 
 type NormalUserService struct {
 	repo repository.UserRepository
+	// 合并账号的时候要转移文章，删除点赞收藏计数的缓存
+	articleRepo repository.ArticleRepository
+	interRepo   repository.InteractiveRepository
+	l           logger.Logger
 }
 
 func (us *NormalUserService) SignUp(ctx context.Context, u *domain.User) error {
@@ -69,33 +110,179 @@ func (us *NormalUserService) Profile(ctx context.Context, d *domain.User) (*doma
 	return u, nil
 }
 
-func (us *NormalUserService) FindOrCreate(ctx context.Context, phone string) (*domain.User, error) {
+func (us *NormalUserService) FindOrCreate(ctx context.Context, phone string) (*domain.User, bool, error) {
 	u, err := us.repo.FindByPhone(ctx, phone)
 	if !errors.Is(err, repository.ErrRecordNotFound) {
-		return u, err
+		return u, false, err
 	}
 
 	err = us.repo.Create(ctx, &domain.User{Phone: phone})
+	// 并发登录的时候别的请求已经创建了
+	created := err == nil
 	if err != nil && !errors.Is(err, ErrDuplicate) {
-		return nil, err
+		return nil, false, err
 	}
 	// 如果有主从，则强制走主库，避免同步造成的读取失败
-	return us.repo.FindByPhone(ctx, phone)
+	u, err = us.repo.FindByPhone(ctx, phone)
+	return u, created, err
+}
+
+func (us *NormalUserService) FindOrCreateByEmail(ctx context.Context, email string) (*domain.User, bool, error) {
+	u, err := us.repo.FindByEmail(ctx, email)
+	if !errors.Is(err, repository.ErrRecordNotFound) {
+		return u, false, err
+	}
+
+	// 通过验证码登录的用户没有密码，之后可以再设置
+	err = us.repo.Create(ctx, &domain.User{Email: email, EmailVerified: true})
+	created := err == nil
+	if err != nil && !errors.Is(err, ErrDuplicate) {
+		return nil, false, err
+	}
+	u, err = us.repo.FindByEmail(ctx, email)
+	return u, created, err
 }
 
-func (us *NormalUserService) FindOrCreateByWechat(ctx context.Context, wechatInfo domain.WechatInfo) (domain.User, error) {
+func (us *NormalUserService) FindOrCreateByWechat(ctx context.Context, wechatInfo domain.WechatInfo) (domain.User, bool, error) {
 	u, err := us.repo.FindByWechat(ctx, wechatInfo.OpenId)
 	if !errors.Is(err, repository.ErrRecordNotFound) {
-		return u, err
+		return u, false, err
 	}
 
 	err = us.repo.Create(ctx, &domain.User{WechatInfo: wechatInfo})
+	created := err == nil
 	if err != nil && !errors.Is(err, ErrDuplicate) {
-		return domain.User{}, err
+		return domain.User{}, false, err
+	}
+	u, err = us.repo.FindByWechat(ctx, wechatInfo.OpenId)
+	return u, created, err
+}
+
+// FindOrCreateByOAuth2 第三方返回的邮箱不会关联到已有的账号上，也不会设置成新账号的邮箱，
+// 不然别人在第三方平台填一个你的邮箱就能登录你的账号。要合并的话走账号合并
+func (us *NormalUserService) FindOrCreateByOAuth2(ctx context.Context, info domain.OAuth2Info) (domain.User, bool, error) {
+	if info.Provider == domain.OAuth2ProviderWechat {
+		return us.FindOrCreateByWechat(ctx, domain.WechatInfo{OpenId: info.Subject, UnionId: info.UnionId})
+	}
+	u, err := us.repo.FindByOAuth2(ctx, info.Provider, info.Subject)
+	if !errors.Is(err, repository.ErrRecordNotFound) {
+		return u, false, err
+	}
+
+	err = us.repo.CreateWithOAuth2(ctx, &domain.User{Nickname: info.Name}, info)
+	created := err == nil
+	if err != nil && !errors.Is(err, ErrDuplicateOAuth2) {
+		return domain.User{}, false, err
+	}
+	u, err = us.repo.FindByOAuth2(ctx, info.Provider, info.Subject)
+	return u, created, err
+}
+
+func NewNormalUserService(repo repository.UserRepository, articleRepo repository.ArticleRepository,
+	interRepo repository.InteractiveRepository, l logger.Logger) UserService {
+	return &NormalUserService{repo: repo, articleRepo: articleRepo, interRepo: interRepo, l: l}
+}
+
+func (us *NormalUserService) FindByAccount(ctx context.Context, email, phone string) (*domain.User, error) {
+	if email != "" {
+		return us.repo.FindByEmail(ctx, email)
+	}
+	return us.repo.FindByPhone(ctx, phone)
+}
+
+func (us *NormalUserService) ResetPassword(ctx context.Context, uid int64, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return us.repo.UpdatePassword(ctx, uid, string(hash))
+}
+
+func (us *NormalUserService) ChangePassword(ctx context.Context, uid int64, oldPassword, newPassword string) error {
+	u, err := us.repo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	// 手机号或者微信注册的用户没有密码，只能走重置密码
+	if u.Password == "" {
+		return ErrInvalidUserOrPassword
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(oldPassword))
+	if err != nil {
+		return ErrInvalidUserOrPassword
+	}
+	return us.ResetPassword(ctx, uid, newPassword)
+}
+
+func (us *NormalUserService) VerifyEmail(ctx context.Context, uid int64) error {
+	return us.repo.VerifyEmail(ctx, uid)
+}
+
+func (us *NormalUserService) BindEmail(ctx context.Context, uid int64, email string) error {
+	return us.repo.UpdateEmail(ctx, uid, email)
+}
+
+func (us *NormalUserService) BindPhone(ctx context.Context, uid int64, phone string) error {
+	return us.repo.UpdatePhone(ctx, uid, phone)
+}
+
+func (us *NormalUserService) Identities(ctx context.Context, uid int64) ([]domain.Identity, error) {
+	u, err := us.repo.FindById(ctx, uid)
+	if err != nil {
+		return nil, err
 	}
-	return us.repo.FindByWechat(ctx, wechatInfo.OpenId)
+	u.OAuth2Identities, err = us.repo.FindOAuth2Identities(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return u.Identities(), nil
+}
+
+func (us *NormalUserService) FindByWechat(ctx context.Context, openId string) (domain.User, error) {
+	return us.repo.FindByWechat(ctx, openId)
+}
+
+func (us *NormalUserService) LinkWechat(ctx context.Context, uid int64, info domain.WechatInfo) error {
+	return us.repo.UpdateWechat(ctx, uid, info)
+}
+
+func (us *NormalUserService) Unlink(ctx context.Context, uid int64, typ domain.IdentityType) error {
+	return us.repo.Unlink(ctx, uid, typ)
 }
 
-func NewNormalUserService(repo repository.UserRepository) UserService {
-	return &NormalUserService{repo: repo}
+func (us *NormalUserService) UnlinkOAuth2(ctx context.Context, uid int64, provider, subject string) error {
+	return us.repo.UnlinkOAuth2(ctx, uid, provider, subject)
+}
+
+func (us *NormalUserService) Merge(ctx context.Context, uid, sourceUid int64) error {
+	if uid == sourceUid {
+		return ErrMergeSelf
+	}
+	target, err := us.repo.FindById(ctx, uid)
+	if err != nil {
+		return err
+	}
+	source, err := us.repo.FindById(ctx, sourceUid)
+	if err != nil {
+		return err
+	}
+	// 文章不在合并账号的事务里面，先检查冲突，不然文章转过去了账号却合并不了。
+	// 最终还是以 Merge 里面加锁之后的检查为准
+	if target.ConflictsWith(*source) {
+		return ErrMergeConflict
+	}
+	// 先转移文章，失败了用户可以重试，账号合并之后 source 就没有办法再验证了
+	err = us.articleRepo.TransferAuthor(ctx, sourceUid, uid)
+	if err != nil {
+		return err
+	}
+	changed, err := us.repo.Merge(ctx, uid, sourceUid)
+	if err != nil {
+		return err
+	}
+	if er := us.interRepo.Invalidate(ctx, changed...); er != nil {
+		// 已经合并成功了，缓存过期之后计数就对了
+		us.l.Error("删除点赞收藏计数缓存失败", logger.Int64("uid", uid), logger.Error(er))
+	}
+	return nil
 }
@@ -10,6 +10,7 @@ import (
 	"webok/internal/domain"
 	"webok/internal/repository"
 	repomocks "webok/internal/repository/mock"
+	"webok/pkg/logger"
 )
 
 func TestPasswordEncrypt(t *testing.T) {
@@ -105,10 +106,397 @@ func TestNormalUserService_Login(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			repo := tc.mock(ctrl)
-			userSvc := NewNormalUserService(repo)
+			userSvc := NewNormalUserService(repo, nil, nil, logger.NewNopLogger())
 			gotUser, err := userSvc.Login(context.Background(), tc.email, tc.password)
 			assert.Equal(t, tc.wantUser, gotUser)
 			assert.Equal(t, tc.wantErr, err)
 		})
 	}
 }
+
+func TestNormalUserService_FindOrCreateByEmail(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mock        func(ctrl *gomock.Controller) repository.UserRepository
+		wantUser    *domain.User
+		wantCreated bool
+		wantErr     error
+	}{
+		{
+			name: "用户已经存在",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(&domain.User{Id: 1, Email: "123@qq.com"}, nil)
+				return repo
+			},
+			wantUser: &domain.User{Id: 1, Email: "123@qq.com"},
+		},
+		{
+			name: "新用户",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(nil, repository.ErrRecordNotFound)
+				// 用验证码登录创建的账号，邮箱已经验证过了
+				repo.EXPECT().Create(gomock.Any(), &domain.User{Email: "123@qq.com", EmailVerified: true}).Return(nil)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(&domain.User{Id: 1, Email: "123@qq.com"}, nil)
+				return repo
+			},
+			wantUser:    &domain.User{Id: 1, Email: "123@qq.com"},
+			wantCreated: true,
+		},
+		{
+			name: "并发创建，邮箱冲突",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(nil, repository.ErrRecordNotFound)
+				repo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(repository.ErrDuplicate)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(&domain.User{Id: 2, Email: "123@qq.com"}, nil)
+				return repo
+			},
+			wantUser: &domain.User{Id: 2, Email: "123@qq.com"},
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByEmail(gomock.Any(), "123@qq.com").
+					Return(nil, errors.New("db error"))
+				return repo
+			},
+			wantErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			userSvc := NewNormalUserService(tc.mock(ctrl), nil, nil, logger.NewNopLogger())
+			gotUser, created, err := userSvc.FindOrCreateByEmail(context.Background(), "123@qq.com")
+			assert.Equal(t, tc.wantUser, gotUser)
+			assert.Equal(t, tc.wantCreated, created)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestNormalUserService_ResetPassword(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) repository.UserRepository
+		wantErr error
+	}{
+		{
+			name: "重置成功",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().UpdatePassword(gomock.Any(), int64(1), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, id int64, hash string) error {
+						// 存的是加密之后的密码
+						return bcrypt.CompareHashAndPassword([]byte(hash), []byte("hello#world123"))
+					})
+				return repo
+			},
+		},
+		{
+			name: "更新失败",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().UpdatePassword(gomock.Any(), int64(1), gomock.Any()).
+					Return(errors.New("db error"))
+				return repo
+			},
+			wantErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			userSvc := NewNormalUserService(tc.mock(ctrl), nil, nil, logger.NewNopLogger())
+			err := userSvc.ResetPassword(context.Background(), 1, "hello#world123")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestNormalUserService_ChangePassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hello#world123"), bcrypt.DefaultCost)
+	assert.NoError(t, err)
+	testCases := []struct {
+		name        string
+		mock        func(ctrl *gomock.Controller) repository.UserRepository
+		oldPassword string
+		wantErr     error
+	}{
+		{
+			name: "修改成功",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).
+					Return(&domain.User{Id: 1, Password: string(hash)}, nil)
+				repo.EXPECT().UpdatePassword(gomock.Any(), int64(1), gomock.Any()).Return(nil)
+				return repo
+			},
+			oldPassword: "hello#world123",
+		},
+		{
+			name: "原密码不对",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).
+					Return(&domain.User{Id: 1, Password: string(hash)}, nil)
+				return repo
+			},
+			oldPassword: "wrong#world123",
+			wantErr:     ErrInvalidUserOrPassword,
+		},
+		{
+			name: "没有设置过密码",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).
+					Return(&domain.User{Id: 1, Phone: "15212345678"}, nil)
+				return repo
+			},
+			oldPassword: "",
+			wantErr:     ErrInvalidUserOrPassword,
+		},
+		{
+			name: "查询用户失败",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).
+					Return(nil, errors.New("db error"))
+				return repo
+			},
+			oldPassword: "hello#world123",
+			wantErr:     errors.New("db error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			userSvc := NewNormalUserService(tc.mock(ctrl), nil, nil, logger.NewNopLogger())
+			err := userSvc.ChangePassword(context.Background(), 1, tc.oldPassword, "new#world123")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestNormalUserService_Merge(t *testing.T) {
+	testCases := []struct {
+		name      string
+		mock      func(ctrl *gomock.Controller) (repository.UserRepository, repository.ArticleRepository, repository.InteractiveRepository)
+		sourceUid int64
+		wantErr   error
+	}{
+		{
+			name: "合并成功",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.ArticleRepository, repository.InteractiveRepository) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				artRepo := repomocks.NewMockArticleRepository(ctrl)
+				interRepo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(&domain.User{Id: 1, Phone: "15212345678"}, nil)
+				repo.EXPECT().FindById(gomock.Any(), int64(2)).Return(&domain.User{Id: 2, Email: "123@qq.com"}, nil)
+				artRepo.EXPECT().TransferAuthor(gomock.Any(), int64(2), int64(1)).Return(nil)
+				changed := []domain.BizRef{{Biz: "article", BizId: 3}}
+				repo.EXPECT().Merge(gomock.Any(), int64(1), int64(2)).Return(changed, nil)
+				interRepo.EXPECT().Invalidate(gomock.Any(), changed[0]).Return(nil)
+				return repo, artRepo, interRepo
+			},
+			sourceUid: 2,
+		},
+		{
+			name: "删除计数缓存失败",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.ArticleRepository, repository.InteractiveRepository) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				artRepo := repomocks.NewMockArticleRepository(ctrl)
+				interRepo := repomocks.NewMockInteractiveRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(&domain.User{Id: 1}, nil)
+				repo.EXPECT().FindById(gomock.Any(), int64(2)).Return(&domain.User{Id: 2}, nil)
+				artRepo.EXPECT().TransferAuthor(gomock.Any(), int64(2), int64(1)).Return(nil)
+				repo.EXPECT().Merge(gomock.Any(), int64(1), int64(2)).Return(nil, nil)
+				interRepo.EXPECT().Invalidate(gomock.Any()).Return(errors.New("redis error"))
+				return repo, artRepo, interRepo
+			},
+			sourceUid: 2,
+		},
+		{
+			name: "合并自己",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.ArticleRepository, repository.InteractiveRepository) {
+				return repomocks.NewMockUserRepository(ctrl), nil, nil
+			},
+			sourceUid: 1,
+			wantErr:   ErrMergeSelf,
+		},
+		{
+			name: "预先检查到身份冲突",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.ArticleRepository, repository.InteractiveRepository) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(&domain.User{Id: 1, Phone: "15212345678"}, nil)
+				repo.EXPECT().FindById(gomock.Any(), int64(2)).Return(&domain.User{Id: 2, Phone: "15287654321"}, nil)
+				return repo, repomocks.NewMockArticleRepository(ctrl), nil
+			},
+			sourceUid: 2,
+			wantErr:   ErrMergeConflict,
+		},
+		{
+			name: "加锁之后发现身份冲突",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.ArticleRepository, repository.InteractiveRepository) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				artRepo := repomocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(&domain.User{Id: 1}, nil)
+				repo.EXPECT().FindById(gomock.Any(), int64(2)).Return(&domain.User{Id: 2}, nil)
+				artRepo.EXPECT().TransferAuthor(gomock.Any(), int64(2), int64(1)).Return(nil)
+				repo.EXPECT().Merge(gomock.Any(), int64(1), int64(2)).Return(nil, repository.ErrMergeConflict)
+				return repo, artRepo, nil
+			},
+			sourceUid: 2,
+			wantErr:   ErrMergeConflict,
+		},
+		{
+			name: "转移文章失败",
+			mock: func(ctrl *gomock.Controller) (repository.UserRepository, repository.ArticleRepository, repository.InteractiveRepository) {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				artRepo := repomocks.NewMockArticleRepository(ctrl)
+				repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(&domain.User{Id: 1}, nil)
+				repo.EXPECT().FindById(gomock.Any(), int64(2)).Return(&domain.User{Id: 2}, nil)
+				artRepo.EXPECT().TransferAuthor(gomock.Any(), int64(2), int64(1)).Return(errors.New("db error"))
+				return repo, artRepo, nil
+			},
+			sourceUid: 2,
+			wantErr:   errors.New("db error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			repo, artRepo, interRepo := tc.mock(ctrl)
+			userSvc := NewNormalUserService(repo, artRepo, interRepo, logger.NewNopLogger())
+			err := userSvc.Merge(context.Background(), 1, tc.sourceUid)
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestNormalUserService_Identities(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	repo := repomocks.NewMockUserRepository(ctrl)
+	repo.EXPECT().FindById(gomock.Any(), int64(1)).Return(&domain.User{
+		Id:         1,
+		Phone:      "15212345678",
+		WechatInfo: domain.WechatInfo{OpenId: "openid"},
+	}, nil)
+	repo.EXPECT().FindOAuth2Identities(gomock.Any(), int64(1)).
+		Return([]domain.OAuth2Info{{Provider: "github", Subject: "123", Email: "123@qq.com"}}, nil)
+	ids, err := NewNormalUserService(repo, nil, nil, logger.NewNopLogger()).Identities(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Identity{
+		{Type: domain.IdentityPhone, Value: "15212345678"},
+		{Type: domain.IdentityWechat, Value: "openid"},
+		{Type: domain.IdentityOAuth2, Provider: "github", Value: "123"},
+	}, ids)
+}
+
+func TestNormalUserService_FindOrCreateByOAuth2(t *testing.T) {
+	info := domain.OAuth2Info{
+		Provider:      "github",
+		Subject:       "123",
+		Email:         "123@qq.com",
+		EmailVerified: true,
+		Name:          "octocat",
+	}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) repository.UserRepository
+		info domain.OAuth2Info
+
+		wantUser    domain.User
+		wantCreated bool
+		wantErr     error
+	}{
+		{
+			name: "已经绑定过",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth2(gomock.Any(), "github", "123").Return(domain.User{Id: 1}, nil)
+				return repo
+			},
+			info:     info,
+			wantUser: domain.User{Id: 1},
+		},
+		{
+			name: "第一次登录，创建账号",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth2(gomock.Any(), "github", "123").
+					Return(domain.User{}, repository.ErrRecordNotFound)
+				// 第三方的邮箱不能设置成账号的邮箱
+				repo.EXPECT().CreateWithOAuth2(gomock.Any(), &domain.User{Nickname: "octocat"}, info).Return(nil)
+				repo.EXPECT().FindByOAuth2(gomock.Any(), "github", "123").Return(domain.User{Id: 2}, nil)
+				return repo
+			},
+			info:        info,
+			wantUser:    domain.User{Id: 2},
+			wantCreated: true,
+		},
+		{
+			name: "并发登录，别人已经创建了",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth2(gomock.Any(), "github", "123").
+					Return(domain.User{}, repository.ErrRecordNotFound)
+				repo.EXPECT().CreateWithOAuth2(gomock.Any(), gomock.Any(), info).Return(ErrDuplicateOAuth2)
+				repo.EXPECT().FindByOAuth2(gomock.Any(), "github", "123").Return(domain.User{Id: 2}, nil)
+				return repo
+			},
+			info:     info,
+			wantUser: domain.User{Id: 2},
+		},
+		{
+			name: "创建失败",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByOAuth2(gomock.Any(), "github", "123").
+					Return(domain.User{}, repository.ErrRecordNotFound)
+				repo.EXPECT().CreateWithOAuth2(gomock.Any(), gomock.Any(), info).Return(errors.New("db 错误"))
+				return repo
+			},
+			info:    info,
+			wantErr: errors.New("db 错误"),
+		},
+		{
+			name: "微信走用户表",
+			mock: func(ctrl *gomock.Controller) repository.UserRepository {
+				repo := repomocks.NewMockUserRepository(ctrl)
+				repo.EXPECT().FindByWechat(gomock.Any(), "openid").Return(domain.User{Id: 3}, nil)
+				return repo
+			},
+			info:     domain.OAuth2Info{Provider: domain.OAuth2ProviderWechat, Subject: "openid", UnionId: "unionid"},
+			wantUser: domain.User{Id: 3},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			u, created, err := NewNormalUserService(tc.mock(ctrl), nil, nil, logger.NewNopLogger()).FindOrCreateByOAuth2(context.Background(), tc.info)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantUser, u)
+			assert.Equal(t, tc.wantCreated, created)
+		})
+	}
+}
@@ -132,7 +132,7 @@ func TestArticleHandler_publish(t *testing.T) {
 			defer ctrl.Finish()
 
 			svc := tc.mock(ctrl)
-			h := NewArticleHandler(svc, logger.NewNopLogger())
+			h := NewArticleHandler(svc, logger.NewNopLogger(), nil)
 			server := gin.Default()
 			server.Use(func(c *gin.Context) {
 				c.Set("user", ijwt.TokenClaims{Uid: 123})
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"webok/internal/service"
+	"webok/pkg/ginx"
+	"webok/pkg/logger"
+)
+
+type CaptchaHandler struct {
+	svc service.CaptchaService
+	log logger.Logger
+}
+
+func NewCaptchaHandler(svc service.CaptchaService, l logger.Logger) *CaptchaHandler {
+	return &CaptchaHandler{svc: svc, log: l}
+}
+
+func (h *CaptchaHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/captcha")
+	g.POST("/generate", ginx.WarpBody[CaptchaGenerateReq](h.Generate))
+	g.POST("/verify", ginx.WarpBody[CaptchaVerifyReq](h.Verify))
+}
+
+func (h *CaptchaHandler) Generate(ctx *gin.Context, req CaptchaGenerateReq) (ginx.Result, error) {
+	c, err := h.svc.Generate(ctx, req.Biz)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	return ginx.Result{Data: CaptchaVo{
+		Id:     c.Id,
+		Type:   c.Type,
+		Images: c.Images,
+		Y:      c.Y,
+	}}, nil
+}
+
+func (h *CaptchaHandler) Verify(ctx *gin.Context, req CaptchaVerifyReq) (ginx.Result, error) {
+	ticket, err := h.svc.Verify(ctx, req.Biz, req.Id, req.Answer)
+	switch {
+	case err == nil:
+		return ginx.Result{Data: ticket}, nil
+	case errors.Is(err, service.ErrCaptchaInvalid):
+		return ginx.Result{Code: 4, Msg: "验证失败，请重试"}, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+}
@@ -0,0 +1,26 @@
+package web
+
+type CaptchaGenerateReq struct {
+	Biz string `json:"biz"`
+}
+
+type CaptchaVerifyReq struct {
+	Biz string `json:"biz"`
+	Id  string `json:"id"`
+	// Answer 数字验证码直接填数字，滑块填 JSON 编码的拖动轨迹，格式见 captcha.SliderInput
+	Answer string `json:"answer"`
+}
+
+type CaptchaVo struct {
+	Id     string            `json:"id"`
+	Type   string            `json:"type"`
+	Images map[string]string `json:"images"`
+	// Y 滑块的纵坐标
+	Y int `json:"y,omitempty"`
+}
+
+// CaptchaRequiredVo 需要先完成人机验证的时候返回，前端据此弹出验证
+type CaptchaRequiredVo struct {
+	Captcha bool   `json:"captcha"`
+	Biz     string `json:"biz"`
+}
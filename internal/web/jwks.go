@@ -0,0 +1,27 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"webok/pkg/jwtx"
+)
+
+// JWKSHandler 公开验证 token 用的公钥，其它服务不需要共享密钥就能验证 webook 签发的 token
+type JWKSHandler struct {
+	keys *jwtx.KeySet
+}
+
+func NewJWKSHandler(keys *jwtx.KeySet) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+func (h *JWKSHandler) RegisterRoutes(server *gin.Engine) {
+	server.GET("/.well-known/jwks.json", h.JWKS)
+}
+
+// JWKS 按照 RFC 7517 的格式直接返回，不包 ginx.Result
+func (h *JWKSHandler) JWKS(ctx *gin.Context) {
+	// 轮换密钥的时候，新的公钥要比开始用它签名早发布一个缓存周期
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, h.keys.JWKS())
+}
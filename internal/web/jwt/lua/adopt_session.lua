@@ -0,0 +1,11 @@
+-- 给没有会话 hash 的旧会话补上 hash，已经有了就什么都不做
+-- KEYS[1] 会话的 hash，ARGV[1] UA，ARGV[2] IP，ARGV[3] 当前时间毫秒数
+-- ARGV[4] 请求里面 refresh token 的 jti，ARGV[5] 会话的过期时间秒数
+local key = KEYS[1]
+if redis.call("EXISTS", key) == 1 then
+    return 0
+end
+redis.call("HSET", key, "ua", ARGV[1], "ip", ARGV[2], "ctime", ARGV[3], "last_seen", ARGV[3],
+    "refresh_jti", ARGV[4])
+redis.call("EXPIRE", key, tonumber(ARGV[5]))
+return 1
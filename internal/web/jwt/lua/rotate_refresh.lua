@@ -0,0 +1,23 @@
+-- 轮换 refresh token
+-- KEYS[1] 会话的 hash，ARGV[1] 请求里面的 jti，ARGV[2] 新的 jti
+-- ARGV[3] 当前时间毫秒数，ARGV[4] 宽限期毫秒数，ARGV[5] 会话的过期时间秒数
+-- 返回 {1, jti} 表示可以用 jti 签发新的 refresh token，{0} 表示被重复使用，{-1} 表示会话不存在
+local key = KEYS[1]
+local jti = ARGV[1]
+local now = tonumber(ARGV[3])
+if redis.call("EXISTS", key) == 0 then
+    return {-1}
+end
+local cur = redis.call("HGET", key, "refresh_jti")
+if cur ~= false and cur ~= jti then
+    -- 前端并发刷新的时候，同一个 refresh token 可能会在很短的时间内用两次
+    local prev = redis.call("HGET", key, "prev_jti")
+    local rotated = tonumber(redis.call("HGET", key, "rotated_at") or "0")
+    if prev == jti and now - rotated <= tonumber(ARGV[4]) then
+        return {1, cur}
+    end
+    return {0}
+end
+redis.call("HSET", key, "refresh_jti", ARGV[2], "prev_jti", jti, "rotated_at", now)
+redis.call("EXPIRE", key, tonumber(ARGV[5]))
+return {1, ARGV[2]}
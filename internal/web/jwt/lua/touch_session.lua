@@ -0,0 +1,14 @@
+-- 更新会话的最后活跃时间，会话已经不存在了就什么都不做
+-- 为了不在每个请求上都写 Redis，距离上次更新超过 ARGV[2] 毫秒才更新
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local last = redis.call("HGET", key, "last_seen")
+if last == false then
+    return 0
+end
+if now - tonumber(last) < interval then
+    return 0
+end
+redis.call("HSET", key, "last_seen", now)
+return 1
@@ -10,6 +10,7 @@
 package jwtmocks
 
 import (
+	context "context"
 	reflect "reflect"
 	jwt "webok/internal/web/jwt"
 
@@ -55,6 +56,20 @@ func (mr *MockHandlerMockRecorder) CheckSession(ctx, ssid any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckSession", reflect.TypeOf((*MockHandler)(nil).CheckSession), ctx, ssid)
 }
 
+// ClearPendingToken mocks base method.
+func (m *MockHandler) ClearPendingToken(ctx context.Context, uc jwt.TokenClaims) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearPendingToken", ctx, uc)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearPendingToken indicates an expected call of ClearPendingToken.
+func (mr *MockHandlerMockRecorder) ClearPendingToken(ctx, uc any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearPendingToken", reflect.TypeOf((*MockHandler)(nil).ClearPendingToken), ctx, uc)
+}
+
 // ClearToken mocks base method.
 func (m *MockHandler) ClearToken(ctx *gin.Context) error {
 	m.ctrl.T.Helper()
@@ -69,6 +84,20 @@ func (mr *MockHandlerMockRecorder) ClearToken(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearToken", reflect.TypeOf((*MockHandler)(nil).ClearToken), ctx)
 }
 
+// ClearUserSessions mocks base method.
+func (m *MockHandler) ClearUserSessions(ctx context.Context, uid int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearUserSessions", ctx, uid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearUserSessions indicates an expected call of ClearUserSessions.
+func (mr *MockHandlerMockRecorder) ClearUserSessions(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearUserSessions", reflect.TypeOf((*MockHandler)(nil).ClearUserSessions), ctx, uid)
+}
+
 // ExtractToken mocks base method.
 func (m *MockHandler) ExtractToken(ctx *gin.Context) string {
 	m.ctrl.T.Helper()
@@ -83,6 +112,36 @@ func (mr *MockHandlerMockRecorder) ExtractToken(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractToken", reflect.TypeOf((*MockHandler)(nil).ExtractToken), ctx)
 }
 
+// IssuePendingToken mocks base method.
+func (m *MockHandler) IssuePendingToken(uid int64, method, account string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IssuePendingToken", uid, method, account)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IssuePendingToken indicates an expected call of IssuePendingToken.
+func (mr *MockHandlerMockRecorder) IssuePendingToken(uid, method, account any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IssuePendingToken", reflect.TypeOf((*MockHandler)(nil).IssuePendingToken), uid, method, account)
+}
+
+// ListSessions mocks base method.
+func (m *MockHandler) ListSessions(ctx context.Context, uid int64) ([]jwt.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSessions", ctx, uid)
+	ret0, _ := ret[0].([]jwt.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSessions indicates an expected call of ListSessions.
+func (mr *MockHandlerMockRecorder) ListSessions(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessions", reflect.TypeOf((*MockHandler)(nil).ListSessions), ctx, uid)
+}
+
 // ParseAccessToken mocks base method.
 func (m *MockHandler) ParseAccessToken(tokenStr string) (*jwt.TokenClaims, error) {
 	m.ctrl.T.Helper()
@@ -98,6 +157,21 @@ func (mr *MockHandlerMockRecorder) ParseAccessToken(tokenStr any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseAccessToken", reflect.TypeOf((*MockHandler)(nil).ParseAccessToken), tokenStr)
 }
 
+// ParsePendingToken mocks base method.
+func (m *MockHandler) ParsePendingToken(ctx context.Context, tokenStr string) (*jwt.TokenClaims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParsePendingToken", ctx, tokenStr)
+	ret0, _ := ret[0].(*jwt.TokenClaims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ParsePendingToken indicates an expected call of ParsePendingToken.
+func (mr *MockHandlerMockRecorder) ParsePendingToken(ctx, tokenStr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParsePendingToken", reflect.TypeOf((*MockHandler)(nil).ParsePendingToken), ctx, tokenStr)
+}
+
 // ParseRefreshToken mocks base method.
 func (m *MockHandler) ParseRefreshToken(tokenStr string) (*jwt.TokenClaims, error) {
 	m.ctrl.T.Helper()
@@ -113,6 +187,48 @@ func (mr *MockHandlerMockRecorder) ParseRefreshToken(tokenStr any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseRefreshToken", reflect.TypeOf((*MockHandler)(nil).ParseRefreshToken), tokenStr)
 }
 
+// RefreshTokens mocks base method.
+func (m *MockHandler) RefreshTokens(ctx *gin.Context, uc jwt.TokenClaims) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshTokens", ctx, uc)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RefreshTokens indicates an expected call of RefreshTokens.
+func (mr *MockHandlerMockRecorder) RefreshTokens(ctx, uc any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTokens", reflect.TypeOf((*MockHandler)(nil).RefreshTokens), ctx, uc)
+}
+
+// RevokeOtherSessions mocks base method.
+func (m *MockHandler) RevokeOtherSessions(ctx context.Context, uid int64, current string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeOtherSessions", ctx, uid, current)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeOtherSessions indicates an expected call of RevokeOtherSessions.
+func (mr *MockHandlerMockRecorder) RevokeOtherSessions(ctx, uid, current any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeOtherSessions", reflect.TypeOf((*MockHandler)(nil).RevokeOtherSessions), ctx, uid, current)
+}
+
+// RevokeSession mocks base method.
+func (m *MockHandler) RevokeSession(ctx context.Context, uid int64, ssid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", ctx, uid, ssid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockHandlerMockRecorder) RevokeSession(ctx, uid, ssid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockHandler)(nil).RevokeSession), ctx, uid, ssid)
+}
+
 // SetAccessToken mocks base method.
 func (m *MockHandler) SetAccessToken(ctx *gin.Context, userId int64, ssid string) error {
 	m.ctrl.T.Helper()
@@ -128,11 +244,12 @@ func (mr *MockHandlerMockRecorder) SetAccessToken(ctx, userId, ssid any) *gomock
 }
 
 // SetLoginToken mocks base method.
-func (m *MockHandler) SetLoginToken(ctx *gin.Context, userId int64) error {
+func (m *MockHandler) SetLoginToken(ctx *gin.Context, userId int64) (string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "SetLoginToken", ctx, userId)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // SetLoginToken indicates an expected call of SetLoginToken.
@@ -140,17 +257,3 @@ func (mr *MockHandlerMockRecorder) SetLoginToken(ctx, userId any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLoginToken", reflect.TypeOf((*MockHandler)(nil).SetLoginToken), ctx, userId)
 }
-
-// SetRefreshToken mocks base method.
-func (m *MockHandler) SetRefreshToken(ctx *gin.Context, userId int64, ssid string) error {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetRefreshToken", ctx, userId, ssid)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// SetRefreshToken indicates an expected call of SetRefreshToken.
-func (mr *MockHandlerMockRecorder) SetRefreshToken(ctx, userId, ssid any) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRefreshToken", reflect.TypeOf((*MockHandler)(nil).SetRefreshToken), ctx, userId, ssid)
-}
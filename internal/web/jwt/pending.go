@@ -0,0 +1,56 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+)
+
+// ErrTooManyAttempts 同一个 pending token 输错太多次，要重新输入密码
+var ErrTooManyAttempts = errors.New("尝试次数太多")
+
+const (
+	kindPending = "2fa_pending"
+	// pendingMaxAttempts 6 位验证码，每次登录只给几次机会
+	pendingMaxAttempts = 5
+)
+
+// IssuePendingToken pending token 只能用来完成两步验证，不能访问别的接口
+func (h *RedisHandler) IssuePendingToken(uid int64, method, account string) (string, error) {
+	return h.sign(TokenClaims{
+		Uid:              uid,
+		Kind:             kindPending,
+		Method:           method,
+		Account:          account,
+		RegisteredClaims: h.registeredClaims(uuid.New().String(), h.pendingExpiration),
+	})
+}
+
+func (h *RedisHandler) ParsePendingToken(ctx context.Context, tokenStr string) (*TokenClaims, error) {
+	uc, err := h.parseToken(tokenStr, kindPending)
+	if err != nil {
+		return nil, err
+	}
+	// 这里不降级，Redis 不可用的时候宁可登录不了，也不能放开暴力破解
+	key := h.pendingKey(uc.ID)
+	pipe := h.rdb.Pipeline()
+	cnt := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, h.pendingExpiration)
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if cnt.Val() > pendingMaxAttempts {
+		return uc, ErrTooManyAttempts
+	}
+	return uc, nil
+}
+
+func (h *RedisHandler) ClearPendingToken(ctx context.Context, uc TokenClaims) error {
+	// 直接把次数用完，token 过期之前也不能再用
+	return h.rdb.Set(ctx, h.pendingKey(uc.ID), pendingMaxAttempts+1, h.pendingExpiration).Err()
+}
+
+func (h *RedisHandler) pendingKey(jti string) string {
+	return "users:2fa_pending:" + jti
+}
@@ -7,44 +7,98 @@ import (
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"strings"
+	"sync/atomic"
 	"time"
+	"webok/pkg/jwtx"
+	"webok/pkg/logger"
+	"webok/pkg/redisx"
 )
 
+// Config token 的有效期，ReuseGrace 是同一个 refresh token 并发刷新的宽限期，
+// PendingTTL 是输完密码之后完成两步验证的时间。
+// Issuer 和 Audience 写进 iss 和 aud，别的服务用同一个 JWKS 验证的时候靠它们区分
+type Config struct {
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	ReuseGrace time.Duration
+	PendingTTL time.Duration
+	Issuer     string
+	Audience   string
+}
+
 type RedisHandler struct {
 	rdb                 redis.Cmdable
-	accessTokenKey      []byte
-	refreshTokenKey     []byte
+	keys                *jwtx.KeySet
+	accessExpiration    time.Duration
 	refreshExpirationAt time.Duration
+	reuseGrace          time.Duration
+	pendingExpiration   time.Duration
+	issuer              string
+	audience            string
 	keyPrefix           string
+	userSessionsPrefix  string
+	sessionPrefix       string
+	health              redisx.HealthMonitor
+	l                   logger.Logger
+
+	// 熔断期间每个请求都会跳过会话检查，日志按照 skipWarnInterval 合并成一条
+	skippedChecks atomic.Int64
+	lastSkipWarn  atomic.Int64
 }
 
-func NewRedisHandler(rdb redis.Cmdable) Handler {
+// skipWarnInterval 熔断期间跳过会话检查的日志最多多久打一次
+const skipWarnInterval = time.Minute
+
+func NewRedisHandler(rdb redis.Cmdable, health redisx.HealthMonitor, keys *jwtx.KeySet, cfg Config, l logger.Logger) Handler {
 	return &RedisHandler{
 		rdb:                 rdb,
-		accessTokenKey:      []byte("k6CswdUm77WKcbM68UQUuxVsHSpTCwgK"),
-		refreshTokenKey:     []byte("k6CswdUm77WKcbM68UQUuxVsHSpTCwgA"),
-		refreshExpirationAt: time.Hour * 24 * 7,
+		health:              health,
+		l:                   l,
+		keys:                keys,
+		accessExpiration:    cfg.AccessTTL,
+		refreshExpirationAt: cfg.RefreshTTL,
+		reuseGrace:          cfg.ReuseGrace,
+		pendingExpiration:   cfg.PendingTTL,
+		issuer:              cfg.Issuer,
+		audience:            cfg.Audience,
 		keyPrefix:           "users:ssid:",
+		userSessionsPrefix:  "users:sessions:",
+		sessionPrefix:       "users:session:",
 	}
 }
 
+const (
+	kindAccess  = "access"
+	kindRefresh = "refresh"
+)
+
+// tokenTypes 每种 token 头部的 typ，refresh token 和 pending token 没有标准的类型，自己定义
+var tokenTypes = map[string]string{
+	kindAccess:  jwtx.TypAccessToken,
+	kindRefresh: "refresh+jwt",
+	kindPending: "2fa-pending+jwt",
+}
+
 type TokenClaims struct {
 	jwt.RegisteredClaims
 	Uid  int64
 	Ssid string
+	// Kind access, refresh 或者 2fa_pending，几种 token 用同一套密钥签名，头部的 typ 也不一样
+	Kind string
+	// Method 和 Account 只有 pending token 有，是第一步用的登录方式和账号
+	Method  string
+	Account string
 }
 
 // SetAccessToken 设置 AccessToken
 func (h *RedisHandler) SetAccessToken(ctx *gin.Context, userId int64, ssid string) error {
 	uc := TokenClaims{
-		Uid:  userId,
-		Ssid: ssid,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 30)),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, uc)
-	tokenStr, err := token.SignedString(h.accessTokenKey)
+		Uid:              userId,
+		Ssid:             ssid,
+		Kind:             kindAccess,
+		RegisteredClaims: h.registeredClaims("", h.accessExpiration),
+	}
+	tokenStr, err := h.sign(uc)
 	if err != nil {
 		return err
 	}
@@ -52,25 +106,60 @@ func (h *RedisHandler) SetAccessToken(ctx *gin.Context, userId int64, ssid strin
 	return nil
 }
 
-// SetLoginToken 设置登录 token,同时 AccessToken 和 RefreshToken
-func (h *RedisHandler) SetLoginToken(ctx *gin.Context, userId int64) error {
+// SetLoginToken 设置登录 token,同时 AccessToken 和 RefreshToken，返回新会话的 ssid
+func (h *RedisHandler) SetLoginToken(ctx *gin.Context, userId int64) (string, error) {
 	ssid := uuid.New().String()
-	err := h.SetRefreshToken(ctx, userId, ssid)
+	jti := uuid.New().String()
+	err := h.setRefreshToken(ctx, userId, ssid, jti)
 	if err != nil {
-		return nil
+		return "", err
+	}
+	err = h.addSession(ctx, userId, ssid, jti)
+	if err != nil {
+		// 记录失败只影响会话管理，不影响这一次登录
+		h.l.Error("记录登录会话失败", logger.Int64("uid", userId), logger.Error(err))
+	}
+	return ssid, h.SetAccessToken(ctx, userId, ssid)
+}
+
+// RefreshTokens 每次刷新都换一个新的 refresh token，旧的作废。
+// 作废的 refresh token 又被拿来用，说明泄露了，整个会话都要失效
+func (h *RedisHandler) RefreshTokens(ctx *gin.Context, uc TokenClaims) error {
+	jti, err := h.rotate(ctx, uc.Uid, uc.Ssid, uc.ID)
+	if errors.Is(err, ErrSessionNotFound) {
+		err = h.adoptSession(ctx, uc)
+		if err == nil {
+			jti, err = h.rotate(ctx, uc.Uid, uc.Ssid, uc.ID)
+		}
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrRefreshTokenReused):
+		h.l.Warn("refresh token 被重复使用，会话已失效",
+			logger.Int64("uid", uc.Uid),
+			logger.String("ssid", uc.Ssid))
+		if er := h.revoke(ctx, uc.Uid, uc.Ssid); er != nil {
+			return er
+		}
+		return err
+	default:
+		return err
 	}
-	return h.SetAccessToken(ctx, userId, ssid)
+	err = h.setRefreshToken(ctx, uc.Uid, uc.Ssid, jti)
+	if err != nil {
+		return err
+	}
+	return h.SetAccessToken(ctx, uc.Uid, uc.Ssid)
 }
 
-func (h *RedisHandler) SetRefreshToken(ctx *gin.Context, userId int64, ssid string) error {
-	refresh := jwt.NewWithClaims(jwt.SigningMethodHS512, TokenClaims{
-		Uid:  userId,
-		Ssid: ssid,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(h.refreshExpirationAt)),
-		},
+// setRefreshToken jti 用来识别同一个会话里面的每一个 refresh token
+func (h *RedisHandler) setRefreshToken(ctx *gin.Context, userId int64, ssid, jti string) error {
+	refreshToken, err := h.sign(TokenClaims{
+		Uid:              userId,
+		Ssid:             ssid,
+		Kind:             kindRefresh,
+		RegisteredClaims: h.registeredClaims(jti, h.refreshExpirationAt),
 	})
-	refreshToken, err := refresh.SignedString(h.refreshTokenKey)
 	if err != nil {
 		return err
 	}
@@ -95,44 +184,74 @@ func (h *RedisHandler) ClearToken(ctx *gin.Context) error {
 	ctx.Header("x-jwt-token", "")
 	ctx.Header("x-refresh-token", "")
 	uc := ctx.MustGet("user").(TokenClaims)
-	strBuilder := strings.Builder{}
-	strBuilder.WriteString(h.keyPrefix)
-	strBuilder.WriteString(uc.Ssid)
-	return h.rdb.Set(ctx, strBuilder.String(), "", time.Hour*24).Err()
+	return h.revoke(ctx, uc.Uid, uc.Ssid)
 }
 
 func (h *RedisHandler) CheckSession(ctx *gin.Context, ssid string) error {
 	strBuilder := strings.Builder{}
 	strBuilder.WriteString(h.keyPrefix)
 	strBuilder.WriteString(ssid)
-	cnt, err := h.rdb.Exists(ctx, strBuilder.String()).Result()
-	// 这里可以做降级处理，防止redis奔溃导致服务奔溃
+	if !h.health.Healthy() {
+		// 降级，Redis 熔断期间不检查是否已经退出登录，只要 token 本身有效就放行
+		h.warnSkipped()
+		return nil
+	}
+	pipe := h.rdb.Pipeline()
+	exists := pipe.Exists(ctx, strBuilder.String())
+	h.touchSession(ctx, pipe, ssid)
+	_, err := pipe.Exec(ctx)
 	if err != nil {
 		return err
 	}
-	if cnt > 0 {
+	if exists.Val() > 0 {
 		return errors.New("token 无效")
 	}
 	return nil
 }
 
+// warnSkipped 记录跳过会话检查的次数，每个 skipWarnInterval 最多打一条日志
+func (h *RedisHandler) warnSkipped() {
+	h.skippedChecks.Add(1)
+	now := time.Now().UnixNano()
+	last := h.lastSkipWarn.Load()
+	if now-last < int64(skipWarnInterval) || !h.lastSkipWarn.CompareAndSwap(last, now) {
+		return
+	}
+	h.l.Warn("Redis 不可用，跳过会话检查", logger.Int64("skipped", h.skippedChecks.Swap(0)))
+}
+
 func (h *RedisHandler) ParseAccessToken(tokenStr string) (*TokenClaims, error) {
-	return h.parseToken(tokenStr, h.accessTokenKey)
+	return h.parseToken(tokenStr, kindAccess)
 }
 
 func (h *RedisHandler) ParseRefreshToken(tokenStr string) (*TokenClaims, error) {
-	return h.parseToken(tokenStr, h.refreshTokenKey)
+	return h.parseToken(tokenStr, kindRefresh)
+}
+
+func (h *RedisHandler) sign(uc TokenClaims) (string, error) {
+	return h.keys.Sign(tokenTypes[uc.Kind], uc)
 }
 
-func (h *RedisHandler) parseToken(tokenStr string, key []byte) (*TokenClaims, error) {
+func (h *RedisHandler) registeredClaims(jti string, ttl time.Duration) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		ID:        jti,
+		Issuer:    h.issuer,
+		Audience:  jwt.ClaimStrings{h.audience},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+	}
+}
+
+// parseToken 头部的 typ、iss 和 aud 都要对得上，claims 里面的 Kind 再检查一遍
+func (h *RedisHandler) parseToken(tokenStr string, kind string) (*TokenClaims, error) {
 	var uc TokenClaims
-	token, err := jwt.ParseWithClaims(tokenStr, &uc, func(token *jwt.Token) (interface{}, error) {
-		return key, nil
-	})
+	token, err := h.keys.Parse(tokenStr, tokenTypes[kind], &uc,
+		jwt.WithIssuer(h.issuer),
+		jwt.WithAudience(h.audience),
+		jwt.WithExpirationRequired())
 	if err != nil {
 		return nil, err
 	}
-	if token == nil || !token.Valid {
+	if token == nil || !token.Valid || uc.Kind != kind {
 		return nil, errors.New("token 无效")
 	}
 	return &uc, nil
@@ -0,0 +1,244 @@
+package jwt
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"strconv"
+	"testing"
+	"time"
+	"webok/pkg/jwtx"
+	"webok/pkg/logger"
+	redisxmocks "webok/pkg/redisx/mock"
+)
+
+// loginClaims 登录，返回 refresh token 里面的 claims
+func loginClaims(t *testing.T, h *RedisHandler, uid int64) TokenClaims {
+	ctx, recorder := newTestContext("test-agent")
+	_, err := h.SetLoginToken(ctx, uid)
+	require.NoError(t, err)
+	uc, err := h.ParseRefreshToken(recorder.Header().Get("x-refresh-token"))
+	require.NoError(t, err)
+	return *uc
+}
+
+// refresh 刷新一次，成功的时候返回新的 refresh token 里面的 claims
+func refresh(h *RedisHandler, uc TokenClaims) (TokenClaims, error) {
+	ctx, recorder := newTestContext("test-agent")
+	err := h.RefreshTokens(ctx, uc)
+	if err != nil {
+		return TokenClaims{}, err
+	}
+	nuc, err := h.ParseRefreshToken(recorder.Header().Get("x-refresh-token"))
+	if err != nil {
+		return TokenClaims{}, err
+	}
+	_, err = h.ParseAccessToken(recorder.Header().Get("x-jwt-token"))
+	return *nuc, err
+}
+
+func TestRedisHandler_RefreshTokens(t *testing.T) {
+	testCases := []struct {
+		name string
+		// before 返回这一次刷新用的 claims，以及之前最后一次刷新拿到的 claims
+		before  func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis) (TokenClaims, TokenClaims)
+		wantErr error
+		after   func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis, uc, latest, res TokenClaims)
+	}{
+		{
+			name: "正常轮换",
+			before: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis) (TokenClaims, TokenClaims) {
+				uc := loginClaims(t, h, 123)
+				return uc, uc
+			},
+			after: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis, uc, latest, res TokenClaims) {
+				assert.Equal(t, uc.Ssid, res.Ssid)
+				assert.Equal(t, uc.Uid, res.Uid)
+				assert.NotEqual(t, uc.ID, res.ID)
+				sessKey := "users:session:" + uc.Ssid
+				assert.Equal(t, res.ID, mr.HGet(sessKey, "refresh_jti"))
+				assert.Equal(t, uc.ID, mr.HGet(sessKey, "prev_jti"))
+				assert.NotEmpty(t, mr.HGet(sessKey, "rotated_at"))
+			},
+		},
+		{
+			name: "宽限期内重复使用上一个",
+			before: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis) (TokenClaims, TokenClaims) {
+				uc := loginClaims(t, h, 123)
+				latest, err := refresh(h, uc)
+				require.NoError(t, err)
+				return uc, latest
+			},
+			after: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis, uc, latest, res TokenClaims) {
+				// 并发刷新拿到的是同一个 refresh token
+				assert.Equal(t, latest.ID, res.ID)
+				assert.Equal(t, latest.ID, mr.HGet("users:session:"+uc.Ssid, "refresh_jti"))
+				assert.False(t, mr.Exists("users:ssid:"+uc.Ssid))
+			},
+		},
+		{
+			name: "超过宽限期重复使用",
+			before: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis) (TokenClaims, TokenClaims) {
+				uc := loginClaims(t, h, 123)
+				latest, err := refresh(h, uc)
+				require.NoError(t, err)
+				mr.HSet("users:session:"+uc.Ssid, "rotated_at",
+					strconv.FormatInt(time.Now().Add(-h.reuseGrace-time.Second).UnixMilli(), 10))
+				return uc, latest
+			},
+			wantErr: ErrRefreshTokenReused,
+			after: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis, uc, latest, res TokenClaims) {
+				// 整个会话都失效，最新的 refresh token 也不能用了
+				assert.True(t, mr.Exists("users:ssid:"+uc.Ssid))
+				assert.False(t, mr.Exists("users:session:"+uc.Ssid))
+				members, _ := mr.ZMembers("users:sessions:123")
+				assert.Empty(t, members)
+				_, err := refresh(h, latest)
+				assert.Equal(t, ErrSessionNotFound, err)
+			},
+		},
+		{
+			name: "使用更早的 refresh token",
+			before: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis) (TokenClaims, TokenClaims) {
+				uc := loginClaims(t, h, 123)
+				second, err := refresh(h, uc)
+				require.NoError(t, err)
+				latest, err := refresh(h, second)
+				require.NoError(t, err)
+				return uc, latest
+			},
+			wantErr: ErrRefreshTokenReused,
+			after: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis, uc, latest, res TokenClaims) {
+				assert.True(t, mr.Exists("users:ssid:"+uc.Ssid))
+			},
+		},
+		{
+			name: "没有会话 hash 的旧会话，补上之后正常轮换",
+			before: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis) (TokenClaims, TokenClaims) {
+				uc := loginClaims(t, h, 123)
+				mr.Del("users:session:" + uc.Ssid)
+				mr.Del("users:sessions:123")
+				return uc, uc
+			},
+			after: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis, uc, latest, res TokenClaims) {
+				sessKey := "users:session:" + uc.Ssid
+				assert.Equal(t, res.ID, mr.HGet(sessKey, "refresh_jti"))
+				assert.Equal(t, uc.ID, mr.HGet(sessKey, "prev_jti"))
+				assert.Equal(t, "test-agent", mr.HGet(sessKey, "ua"))
+				members, _ := mr.ZMembers("users:sessions:123")
+				assert.Equal(t, []string{uc.Ssid}, members)
+				// 同一个旧 token 过了宽限期再用，当成重复使用
+				mr.HSet(sessKey, "rotated_at",
+					strconv.FormatInt(time.Now().Add(-h.reuseGrace-time.Second).UnixMilli(), 10))
+				_, err := refresh(h, uc)
+				assert.Equal(t, ErrRefreshTokenReused, err)
+			},
+		},
+		{
+			name: "被踢掉的会话不会补上",
+			before: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis) (TokenClaims, TokenClaims) {
+				uc := loginClaims(t, h, 123)
+				require.NoError(t, h.revoke(context.Background(), 123, uc.Ssid))
+				return uc, uc
+			},
+			wantErr: ErrSessionNotFound,
+			after: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis, uc, latest, res TokenClaims) {
+				assert.False(t, mr.Exists("users:session:"+uc.Ssid))
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, mr := newTestHandler(t)
+			uc, latest := tc.before(t, h, mr)
+			res, err := refresh(h, uc)
+			assert.Equal(t, tc.wantErr, err)
+			tc.after(t, h, mr, uc, latest, res)
+		})
+	}
+}
+
+func TestRedisHandler_parseToken(t *testing.T) {
+	h, _ := newTestHandler(t)
+	ctx, recorder := newTestContext("test-agent")
+	_, err := h.SetLoginToken(ctx, 123)
+	require.NoError(t, err)
+	access := recorder.Header().Get("x-jwt-token")
+	refreshToken := recorder.Header().Get("x-refresh-token")
+	pending, err := h.IssuePendingToken(123, "sms", "15212345678")
+	require.NoError(t, err)
+	// 同一套密钥，别的服务签发的 token
+	other := NewRedisHandler(h.rdb, h.health, h.keys, Config{
+		AccessTTL:  h.accessExpiration,
+		RefreshTTL: h.refreshExpirationAt,
+		Issuer:     "other",
+		Audience:   h.audience,
+	}, logger.NewNopLogger())
+	ctx, recorder = newTestContext("test-agent")
+	require.NoError(t, other.SetAccessToken(ctx, 123, "ssid"))
+	otherAccess := recorder.Header().Get("x-jwt-token")
+
+	testCases := []struct {
+		name    string
+		token   string
+		kind    string
+		wantErr error
+	}{
+		{name: "access token", token: access, kind: kindAccess},
+		{name: "refresh token", token: refreshToken, kind: kindRefresh},
+		{name: "pending token", token: pending, kind: kindPending},
+		{name: "refresh token 冒充 access token", token: refreshToken, kind: kindAccess, wantErr: jwtx.ErrTypeMismatch},
+		{name: "pending token 冒充 access token", token: pending, kind: kindAccess, wantErr: jwtx.ErrTypeMismatch},
+		{name: "access token 冒充 refresh token", token: access, kind: kindRefresh, wantErr: jwtx.ErrTypeMismatch},
+		{name: "签发方不对", token: otherAccess, kind: kindAccess, wantErr: jwt.ErrTokenInvalidIssuer},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			uc, err := h.parseToken(tc.token, tc.kind)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(123), uc.Uid)
+			assert.Equal(t, "webook", uc.Issuer)
+			assert.Equal(t, jwt.ClaimStrings{"webook"}, uc.Audience)
+		})
+	}
+}
+
+// warnLogger 只记录 Warn 日志的字段
+type warnLogger struct {
+	logger.Logger
+	warns [][]logger.Field
+}
+
+func (l *warnLogger) Warn(msg string, args ...logger.Field) {
+	l.warns = append(l.warns, args)
+}
+
+func TestRedisHandler_CheckSession_Unhealthy(t *testing.T) {
+	h, _ := newTestHandler(t)
+	health := redisxmocks.NewMockHealthMonitor(gomock.NewController(t))
+	health.EXPECT().Healthy().Return(false).AnyTimes()
+	l := &warnLogger{Logger: logger.NewNopLogger()}
+	h.health, h.l = health, l
+
+	// 熔断期间放行，日志合并成一条
+	for i := 0; i < 3; i++ {
+		ctx, _ := newTestContext("test-agent")
+		assert.NoError(t, h.CheckSession(ctx, "ssid"))
+	}
+	require.Len(t, l.warns, 1)
+	assert.Equal(t, []logger.Field{logger.Int64("skipped", 1)}, l.warns[0])
+
+	// 过了间隔之后再打一条，带上这段时间跳过的次数
+	h.lastSkipWarn.Add(-int64(skipWarnInterval))
+	ctx, _ := newTestContext("test-agent")
+	assert.NoError(t, h.CheckSession(ctx, "ssid"))
+	require.Len(t, l.warns, 2)
+	assert.Equal(t, []logger.Field{logger.Int64("skipped", 3)}, l.warns[1])
+}
@@ -0,0 +1,237 @@
+package jwt
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"webok/pkg/logger"
+)
+
+var (
+	ErrSessionNotFound = errors.New("会话不存在")
+	// ErrRefreshTokenReused 已经作废的 refresh token 又被用了一次
+	ErrRefreshTokenReused = errors.New("refresh token 被重复使用")
+
+	//go:embed lua/touch_session.lua
+	luaTouchSession string
+	//go:embed lua/rotate_refresh.lua
+	luaRotateRefresh string
+	//go:embed lua/adopt_session.lua
+	luaAdoptSession string
+)
+
+const (
+	// touchInterval 最后活跃时间不需要很精确
+	touchInterval = time.Minute
+	maxUserAgent  = 256
+)
+
+// Session 一次登录，一个 ssid 对应一对 access token 和 refresh token
+type Session struct {
+	Ssid      string
+	UserAgent string
+	IP        string
+	Ctime     time.Time
+	LastSeen  time.Time
+}
+
+// addSession 用户的 ssid 记在 zset 里面，score 是会话的过期时间，顺便清理已经过期的。
+// 每个会话的设备信息和当前的 refresh token 单独用一个 hash 保存
+func (h *RedisHandler) addSession(ctx *gin.Context, uid int64, ssid, jti string) error {
+	now := time.Now()
+	key := h.userSessionsKey(uid)
+	sessKey := h.sessionKey(ssid)
+	ua := userAgent(ctx)
+	// 不同的 key 在集群里面可能不在一个节点上，所以不用事务
+	pipe := h.rdb.Pipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.Add(h.refreshExpirationAt).UnixMilli()), Member: ssid})
+	pipe.Expire(ctx, key, h.refreshExpirationAt)
+	pipe.HSet(ctx, sessKey,
+		"ua", ua,
+		"ip", ctx.ClientIP(),
+		"ctime", now.UnixMilli(),
+		"last_seen", now.UnixMilli(),
+		"refresh_jti", jti)
+	pipe.Expire(ctx, sessKey, h.refreshExpirationAt)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func userAgent(ctx *gin.Context) string {
+	ua := ctx.Request.UserAgent()
+	if len(ua) > maxUserAgent {
+		// 截断之后最后一个字符可能不完整
+		ua = strings.ToValidUTF8(ua[:maxUserAgent], "")
+	}
+	return ua
+}
+
+// adoptSession 有会话 hash 之前登录的会话，refresh token 还没过期，但是没有 hash，
+// 第一次刷新的时候补上，不然这些用户都会被踢下线。被踢掉的会话在黑名单里面，不能补。
+// 补上的 hash 记录的是请求里面的 jti，之后再有人拿同一个旧 token 来刷新，会被当成重复使用。
+// 旧会话最多再过一个 refresh token 的有效期就都没了，到时候可以删掉这段逻辑
+func (h *RedisHandler) adoptSession(ctx *gin.Context, uc TokenClaims) error {
+	cnt, err := h.rdb.Exists(ctx, h.keyPrefix+uc.Ssid).Result()
+	if err != nil {
+		return err
+	}
+	if cnt > 0 {
+		return ErrSessionNotFound
+	}
+	adopted, err := h.rdb.Eval(ctx, luaAdoptSession, []string{h.sessionKey(uc.Ssid)},
+		userAgent(ctx), ctx.ClientIP(), time.Now().UnixMilli(), uc.ID,
+		int64(h.refreshExpirationAt.Seconds())).Int()
+	if err != nil {
+		return err
+	}
+	if adopted == 1 {
+		h.l.Info("补上旧会话的 hash", logger.Int64("uid", uc.Uid), logger.String("ssid", uc.Ssid))
+	}
+	return nil
+}
+
+// rotate 校验 jti 是不是这个会话当前的 refresh token，是的话换成新的，返回用来签发的 jti
+func (h *RedisHandler) rotate(ctx context.Context, uid int64, ssid, jti string) (string, error) {
+	now := time.Now()
+	res, err := h.rdb.Eval(ctx, luaRotateRefresh, []string{h.sessionKey(ssid)},
+		jti, uuid.New().String(), now.UnixMilli(), h.reuseGrace.Milliseconds(),
+		int64(h.refreshExpirationAt.Seconds())).Slice()
+	if err != nil {
+		return "", err
+	}
+	switch res[0].(int64) {
+	case 0:
+		return "", ErrRefreshTokenReused
+	case -1:
+		return "", ErrSessionNotFound
+	}
+	// 会话跟着 refresh token 续期
+	key := h.userSessionsKey(uid)
+	pipe := h.rdb.Pipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(now.Add(h.refreshExpirationAt).UnixMilli()), Member: ssid})
+	pipe.Expire(ctx, key, h.refreshExpirationAt)
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		h.l.Error("会话续期失败", logger.String("ssid", ssid), logger.Error(err))
+	}
+	return res[1].(string), nil
+}
+
+func (h *RedisHandler) touchSession(ctx context.Context, pipe redis.Pipeliner, ssid string) {
+	pipe.Eval(ctx, luaTouchSession, []string{h.sessionKey(ssid)},
+		time.Now().UnixMilli(), touchInterval.Milliseconds())
+}
+
+func (h *RedisHandler) ListSessions(ctx context.Context, uid int64) ([]Session, error) {
+	ssids, err := h.rdb.ZRange(ctx, h.userSessionsKey(uid), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ssids) == 0 {
+		return []Session{}, nil
+	}
+	pipe := h.rdb.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(ssids))
+	for i, ssid := range ssids {
+		cmds[i] = pipe.HGetAll(ctx, h.sessionKey(ssid))
+	}
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Session, 0, len(ssids))
+	for i, cmd := range cmds {
+		vals := cmd.Val()
+		// 已经过期了，zset 里面的会在下次登录的时候清理
+		if len(vals) == 0 {
+			continue
+		}
+		res = append(res, toSession(ssids[i], vals))
+	}
+	// 最近登录的排在前面
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Ctime.After(res[j].Ctime)
+	})
+	return res, nil
+}
+
+func toSession(ssid string, vals map[string]string) Session {
+	ctime, _ := strconv.ParseInt(vals["ctime"], 10, 64)
+	lastSeen, _ := strconv.ParseInt(vals["last_seen"], 10, 64)
+	return Session{
+		Ssid:      ssid,
+		UserAgent: vals["ua"],
+		IP:        vals["ip"],
+		Ctime:     time.UnixMilli(ctime),
+		LastSeen:  time.UnixMilli(lastSeen),
+	}
+}
+
+func (h *RedisHandler) RevokeSession(ctx context.Context, uid int64, ssid string) error {
+	// 只能踢掉自己的会话
+	err := h.rdb.ZScore(ctx, h.userSessionsKey(uid), ssid).Err()
+	if errors.Is(err, redis.Nil) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return h.revoke(ctx, uid, ssid)
+}
+
+func (h *RedisHandler) RevokeOtherSessions(ctx context.Context, uid int64, current string) error {
+	ssids, err := h.rdb.ZRange(ctx, h.userSessionsKey(uid), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	others := make([]string, 0, len(ssids))
+	for _, ssid := range ssids {
+		if ssid != current {
+			others = append(others, ssid)
+		}
+	}
+	return h.revoke(ctx, uid, others...)
+}
+
+func (h *RedisHandler) ClearUserSessions(ctx context.Context, uid int64) error {
+	ssids, err := h.rdb.ZRange(ctx, h.userSessionsKey(uid), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	return h.revoke(ctx, uid, ssids...)
+}
+
+// revoke 把 ssid 加入黑名单，CheckSession 就会拒绝这些 token
+func (h *RedisHandler) revoke(ctx context.Context, uid int64, ssids ...string) error {
+	if len(ssids) == 0 {
+		return nil
+	}
+	pipe := h.rdb.Pipeline()
+	members := make([]any, 0, len(ssids))
+	for _, ssid := range ssids {
+		// 黑名单的过期时间要覆盖 refresh token 的有效期
+		pipe.Set(ctx, h.keyPrefix+ssid, "", h.refreshExpirationAt)
+		pipe.Del(ctx, h.sessionKey(ssid))
+		members = append(members, ssid)
+	}
+	pipe.ZRem(ctx, h.userSessionsKey(uid), members...)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (h *RedisHandler) userSessionsKey(uid int64) string {
+	return fmt.Sprintf("%s%d", h.userSessionsPrefix, uid)
+}
+
+func (h *RedisHandler) sessionKey(ssid string) string {
+	return h.sessionPrefix + ssid
+}
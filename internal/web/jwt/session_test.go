@@ -0,0 +1,241 @@
+package jwt
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"webok/pkg/jwtx"
+	"webok/pkg/logger"
+	redisxmocks "webok/pkg/redisx/mock"
+)
+
+func newTestHandler(t *testing.T) (*RedisHandler, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	keys, err := jwtx.NewEphemeralKeySet("test")
+	require.NoError(t, err)
+	health := redisxmocks.NewMockHealthMonitor(gomock.NewController(t))
+	health.EXPECT().Healthy().Return(true).AnyTimes()
+	h := NewRedisHandler(rdb, health, keys, Config{
+		AccessTTL:  time.Minute * 30,
+		RefreshTTL: time.Hour * 24 * 7,
+		ReuseGrace: time.Second * 10,
+		PendingTTL: time.Minute * 5,
+		Issuer:     "webook",
+		Audience:   "webook",
+	}, logger.NewNopLogger())
+	return h.(*RedisHandler), mr
+}
+
+func newTestContext(ua string) (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(recorder)
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/users/login", nil)
+	ctx.Request.Header.Set("User-Agent", ua)
+	return ctx, recorder
+}
+
+func login(t *testing.T, h *RedisHandler, uid int64) string {
+	ctx, _ := newTestContext("test-agent")
+	ssid, err := h.SetLoginToken(ctx, uid)
+	require.NoError(t, err)
+	return ssid
+}
+
+func TestRedisHandler_addSession(t *testing.T) {
+	h, mr := newTestHandler(t)
+	ctx, recorder := newTestContext(strings.Repeat("中", maxUserAgent))
+	ssid, err := h.SetLoginToken(ctx, 123)
+	require.NoError(t, err)
+	assert.NotEmpty(t, recorder.Header().Get("x-jwt-token"))
+	assert.NotEmpty(t, recorder.Header().Get("x-refresh-token"))
+
+	members, err := mr.ZMembers("users:sessions:123")
+	require.NoError(t, err)
+	assert.Equal(t, []string{ssid}, members)
+	assert.True(t, mr.TTL("users:sessions:123") > 0)
+
+	sessKey := "users:session:" + ssid
+	assert.Equal(t, "192.0.2.1", mr.HGet(sessKey, "ip"))
+	assert.NotEmpty(t, mr.HGet(sessKey, "refresh_jti"))
+	assert.Equal(t, mr.HGet(sessKey, "ctime"), mr.HGet(sessKey, "last_seen"))
+	assert.True(t, mr.TTL(sessKey) > 0)
+	// 太长的 User-Agent 截断之后还是合法的 UTF-8
+	ua := mr.HGet(sessKey, "ua")
+	assert.True(t, len(ua) <= maxUserAgent)
+	assert.Equal(t, strings.Repeat("中", maxUserAgent/3), ua)
+}
+
+func TestRedisHandler_ListSessions(t *testing.T) {
+	h, mr := newTestHandler(t)
+	first := login(t, h, 123)
+	second := login(t, h, 123)
+	expired := login(t, h, 123)
+	other := login(t, h, 456)
+	// 同一毫秒登录的 ctime 一样，手动错开
+	now := time.Now()
+	mr.HSet("users:session:"+first, "ctime", strconv.FormatInt(now.Add(-time.Hour).UnixMilli(), 10))
+	mr.HSet("users:session:"+second, "ctime", strconv.FormatInt(now.UnixMilli(), 10))
+	// hash 过期了，zset 里面还有
+	mr.Del("users:session:" + expired)
+
+	sessions, err := h.ListSessions(context.Background(), 123)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, second, sessions[0].Ssid)
+	assert.Equal(t, first, sessions[1].Ssid)
+	assert.Equal(t, "test-agent", sessions[0].UserAgent)
+	assert.Equal(t, "192.0.2.1", sessions[0].IP)
+	for _, s := range sessions {
+		assert.NotEqual(t, other, s.Ssid)
+	}
+
+	sessions, err = h.ListSessions(context.Background(), 789)
+	require.NoError(t, err)
+	assert.NotNil(t, sessions)
+	assert.Empty(t, sessions)
+}
+
+func TestRedisHandler_touchSession(t *testing.T) {
+	testCases := []struct {
+		name string
+		// before 返回会话的 ssid
+		before   func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis) string
+		wantSeen func(t *testing.T, mr *miniredis.Miniredis, ssid string, old string)
+	}{
+		{
+			name: "超过间隔更新活跃时间",
+			before: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis) string {
+				ssid := login(t, h, 123)
+				mr.HSet("users:session:"+ssid, "last_seen",
+					strconv.FormatInt(time.Now().Add(-touchInterval*2).UnixMilli(), 10))
+				return ssid
+			},
+			wantSeen: func(t *testing.T, mr *miniredis.Miniredis, ssid string, old string) {
+				seen, err := strconv.ParseInt(mr.HGet("users:session:"+ssid, "last_seen"), 10, 64)
+				require.NoError(t, err)
+				oldSeen, err := strconv.ParseInt(old, 10, 64)
+				require.NoError(t, err)
+				assert.True(t, seen-oldSeen >= touchInterval.Milliseconds())
+			},
+		},
+		{
+			name: "间隔内不更新",
+			before: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis) string {
+				return login(t, h, 123)
+			},
+			wantSeen: func(t *testing.T, mr *miniredis.Miniredis, ssid string, old string) {
+				assert.Equal(t, old, mr.HGet("users:session:"+ssid, "last_seen"))
+			},
+		},
+		{
+			name: "会话不存在不会重新创建",
+			before: func(t *testing.T, h *RedisHandler, mr *miniredis.Miniredis) string {
+				return "not-exist"
+			},
+			wantSeen: func(t *testing.T, mr *miniredis.Miniredis, ssid string, old string) {
+				assert.False(t, mr.Exists("users:session:"+ssid))
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, mr := newTestHandler(t)
+			ssid := tc.before(t, h, mr)
+			old := mr.HGet("users:session:"+ssid, "last_seen")
+			ctx, _ := newTestContext("test-agent")
+			err := h.CheckSession(ctx, ssid)
+			require.NoError(t, err)
+			tc.wantSeen(t, mr, ssid, old)
+		})
+	}
+}
+
+func TestRedisHandler_RevokeSession(t *testing.T) {
+	testCases := []struct {
+		name    string
+		uid     int64
+		wantErr error
+		// wantRevoked 目标会话是不是被踢掉了
+		wantRevoked bool
+	}{
+		{
+			name:        "踢掉自己的会话",
+			uid:         123,
+			wantRevoked: true,
+		},
+		{
+			name:    "不能踢掉别人的会话",
+			uid:     456,
+			wantErr: ErrSessionNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h, mr := newTestHandler(t)
+			ssid := login(t, h, 123)
+			login(t, h, 456)
+
+			err := h.RevokeSession(context.Background(), tc.uid, ssid)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantRevoked, mr.Exists("users:ssid:"+ssid))
+			assert.Equal(t, !tc.wantRevoked, mr.Exists("users:session:"+ssid))
+			members, _ := mr.ZMembers("users:sessions:123")
+			assert.Equal(t, !tc.wantRevoked, len(members) == 1)
+			ctx, _ := newTestContext("test-agent")
+			assert.Equal(t, tc.wantRevoked, h.CheckSession(ctx, ssid) != nil)
+		})
+	}
+}
+
+func TestRedisHandler_RevokeOtherSessions(t *testing.T) {
+	h, mr := newTestHandler(t)
+	current := login(t, h, 123)
+	others := []string{login(t, h, 123), login(t, h, 123)}
+	another := login(t, h, 456)
+
+	err := h.RevokeOtherSessions(context.Background(), 123, current)
+	require.NoError(t, err)
+	members, err := mr.ZMembers("users:sessions:123")
+	require.NoError(t, err)
+	assert.Equal(t, []string{current}, members)
+	assert.False(t, mr.Exists("users:ssid:"+current))
+	assert.True(t, mr.Exists("users:session:"+current))
+	for _, ssid := range others {
+		assert.True(t, mr.Exists("users:ssid:"+ssid))
+		assert.False(t, mr.Exists("users:session:"+ssid))
+	}
+	assert.False(t, mr.Exists("users:ssid:"+another))
+}
+
+func TestRedisHandler_ClearUserSessions(t *testing.T) {
+	h, mr := newTestHandler(t)
+	ssids := []string{login(t, h, 123), login(t, h, 123)}
+	another := login(t, h, 456)
+
+	err := h.ClearUserSessions(context.Background(), 123)
+	require.NoError(t, err)
+	assert.False(t, mr.Exists("users:sessions:123"))
+	for _, ssid := range ssids {
+		assert.True(t, mr.Exists("users:ssid:"+ssid))
+		assert.False(t, mr.Exists("users:session:"+ssid))
+		// 黑名单要覆盖 refresh token 的有效期
+		assert.Equal(t, h.refreshExpirationAt, mr.TTL("users:ssid:"+ssid))
+	}
+	assert.False(t, mr.Exists("users:ssid:"+another))
+	assert.True(t, mr.Exists("users:session:"+another))
+
+	// 没有会话的时候什么都不做
+	err = h.ClearUserSessions(context.Background(), 789)
+	assert.NoError(t, err)
+}
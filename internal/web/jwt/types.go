@@ -1,15 +1,36 @@
 package jwt
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+)
 
 //go:generate mockgen -destination ./mock/jwt.mock.go -package jwtmocks -source types.go
 type Handler interface {
 	SetAccessToken(ctx *gin.Context, userId int64, ssid string) error
-	SetLoginToken(ctx *gin.Context, userId int64) error
-	SetRefreshToken(ctx *gin.Context, userId int64, ssid string) error
+	// SetLoginToken 登录成功之后开一个新的会话，返回会话的 ssid
+	SetLoginToken(ctx *gin.Context, userId int64) (string, error)
+	// RefreshTokens 用 refresh token 换一对新的 token，旧的 refresh token 作废
+	RefreshTokens(ctx *gin.Context, uc TokenClaims) error
 	ExtractToken(ctx *gin.Context) string
 	ClearToken(ctx *gin.Context) error
 	CheckSession(ctx *gin.Context, ssid string) error
+	// ClearUserSessions 让这个用户所有的登录会话失效，比如重置密码之后
+	ClearUserSessions(ctx context.Context, uid int64) error
+	// ListSessions 用户所有还有效的登录会话
+	ListSessions(ctx context.Context, uid int64) ([]Session, error)
+	// RevokeSession 踢掉用户的某个会话，ssid 不属于这个用户的时候返回 ErrSessionNotFound
+	RevokeSession(ctx context.Context, uid int64, ssid string) error
+	// RevokeOtherSessions 除了 current 之外的会话都失效
+	RevokeOtherSessions(ctx context.Context, uid int64, current string) error
+	// IssuePendingToken 第一步认证通过了但是还要两步验证，先发一个只能用来完成两步验证的 token，
+	// method 和 account 是第一步用的登录方式和账号，完成第二步的时候要用
+	IssuePendingToken(uid int64, method, account string) (string, error)
+	// ParsePendingToken 每解析一次算一次尝试，超过次数返回 ErrTooManyAttempts，
+	// 这个时候也会返回 claims，用来记录是哪个用户
+	ParsePendingToken(ctx context.Context, tokenStr string) (*TokenClaims, error)
+	// ClearPendingToken 两步验证通过之后作废
+	ClearPendingToken(ctx context.Context, uc TokenClaims) error
 	ParseAccessToken(tokenStr string) (*TokenClaims, error)
 	ParseRefreshToken(tokenStr string) (*TokenClaims, error)
 }
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"webok/internal/domain"
+	"webok/internal/service"
+	ijwt "webok/internal/web/jwt"
+	"webok/pkg/ginx"
+	"webok/pkg/logger"
+)
+
+// loginFlow 第一步认证通过之后都交给 finish，开启了两步验证的用户只拿到 pending token，
+// 要去 /users/login/2fa 完成第二步。所有的登录方式都要走这里，不然换一种方式登录就绕过了两步验证
+type loginFlow struct {
+	jwt          ijwt.Handler
+	twoFactorSvc service.TwoFactorService
+	loginGuard   service.LoginGuardService
+	events       service.SecurityEventService
+	l            logger.Logger
+}
+
+func newLoginFlow(jwt ijwt.Handler, twoFactorSvc service.TwoFactorService, loginGuard service.LoginGuardService,
+	events service.SecurityEventService, l logger.Logger) loginFlow {
+	return loginFlow{jwt: jwt, twoFactorSvc: twoFactorSvc, loginGuard: loginGuard, events: events, l: l}
+}
+
+// finish e 是登录成功的时候要记录的事件，调用方填 Uid、Method 和 Account
+func (f loginFlow) finish(ctx *gin.Context, e domain.SecurityEvent) (ginx.Result, error) {
+	tf, err := f.twoFactorSvc.Status(ctx, e.Uid)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if tf.Enabled {
+		token, err := f.jwt.IssuePendingToken(e.Uid, e.Method, e.Account)
+		if err != nil {
+			return ginx.Result{Msg: "系统错误", Code: 5}, err
+		}
+		return ginx.Result{Msg: "请输入两步验证码",
+			Data: TwoFactorPendingVo{TwoFactor: true, PendingToken: token}}, nil
+	}
+	return f.login(ctx, e)
+}
+
+// login 认证全部完成，开一个新的会话。
+// 这个时候才清掉账号的登录失败记录，开启了两步验证的要等第二步也通过
+func (f loginFlow) login(ctx *gin.Context, e domain.SecurityEvent) (ginx.Result, error) {
+	ssid, err := f.jwt.SetLoginToken(ctx, e.Uid)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if e.Account != "" {
+		if er := f.loginGuard.Succeed(ctx, e.Account, ctx.ClientIP()); er != nil {
+			f.l.Error("清除登录失败记录失败", logger.Int64("uid", e.Uid), logger.Error(er))
+		}
+	}
+	e.Type = domain.SecurityEventLogin
+	e.Ssid = ssid
+	recordEvent(ctx, f.events, e)
+	return ginx.Result{Msg: "登录成功"}, nil
+}
+
+// fail 第二步验证码不对，和密码不对一样算账号的一次失败
+func (f loginFlow) fail(ctx *gin.Context, account string) {
+	if account == "" {
+		return
+	}
+	if err := f.loginGuard.Fail(ctx, account, ctx.ClientIP()); err != nil {
+		f.l.Error("记录登录失败次数失败", logger.Error(err))
+	}
+}
@@ -0,0 +1,350 @@
+package web
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/service"
+	svcmocks "webok/internal/service/mock"
+	"webok/internal/service/outh2"
+	outh2mocks "webok/internal/service/outh2/mock"
+	jwtmocks "webok/internal/web/jwt/mock"
+	"webok/pkg/jwtx"
+	"webok/pkg/logger"
+)
+
+// loginDeps 各种登录方式第一步用到的依赖
+type loginDeps struct {
+	userSvc    *svcmocks.MockUserService
+	codeSvc    *svcmocks.MockCodeService
+	loginGuard *svcmocks.MockLoginGuardService
+	wechat     *outh2mocks.MockProvider
+	github     *outh2mocks.MockProvider
+	keys       *jwtx.KeySet
+}
+
+func newLoginServer(ctrl *gomock.Controller, deps loginDeps, twoFactorSvc *svcmocks.MockTwoFactorService,
+	events *svcmocks.MockSecurityEventService, jwtHdl *jwtmocks.MockHandler) *gin.Engine {
+	l := logger.NewNopLogger()
+	server := gin.New()
+	NewUserHandler(deps.userSvc, deps.codeSvc, svcmocks.NewMockCaptchaService(ctrl), twoFactorSvc,
+		deps.loginGuard, events, jwtHdl, l).RegisterRoutes(server)
+	NewOAuth2WechatHandler(deps.wechat, deps.userSvc, twoFactorSvc, deps.loginGuard, jwtHdl, deps.keys, events, l).
+		RegisterRoutes(server)
+	deps.github.EXPECT().Name().Return("github").AnyTimes()
+	NewOAuth2Handler(outh2.NewRegistry(deps.github), deps.userSvc, twoFactorSvc, deps.loginGuard, jwtHdl,
+		deps.keys, events, l).RegisterRoutes(server)
+	return server
+}
+
+func jsonRequest(t *testing.T, path, body string) *http.Request {
+	req, err := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "test-agent")
+	req.RemoteAddr = "192.0.2.1:1234"
+	return req
+}
+
+// wechatCallback 带上合法的 state cookie
+func wechatCallback(t *testing.T, keys *jwtx.KeySet) *http.Request {
+	tokenStr, err := keys.Sign(typWechatState, StateClaims{
+		State: "state",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/oauth2/wechat/callback?code=code&state=state", nil)
+	req.AddCookie(&http.Cookie{Name: "jwt-state", Value: tokenStr})
+	req.Header.Set("User-Agent", "test-agent")
+	return req
+}
+
+// oauth2Callback 通用第三方登录的回调，带上合法的 state cookie
+func oauth2Callback(t *testing.T, keys *jwtx.KeySet, provider string) *http.Request {
+	tokenStr, err := keys.Sign(typOAuth2State, OAuth2StateClaims{
+		Provider:     provider,
+		State:        "state",
+		Nonce:        "nonce",
+		CodeVerifier: "verifier",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/oauth2/"+provider+"/callback?code=code&state=state", nil)
+	req.AddCookie(&http.Cookie{Name: "oauth2-state", Value: tokenStr})
+	req.Header.Set("User-Agent", "test-agent")
+	return req
+}
+
+func TestLoginFlow_TwoFactor(t *testing.T) {
+	testCases := []struct {
+		name    string
+		method  string
+		account string
+		// firstStep 准备第一步认证成功需要的 mock，返回请求
+		firstStep func(t *testing.T, deps loginDeps) *http.Request
+	}{
+		{
+			name:    "密码登录",
+			method:  domain.LoginMethodPassword,
+			account: "123@qq.com",
+			firstStep: func(t *testing.T, deps loginDeps) *http.Request {
+				deps.loginGuard.EXPECT().Check(gomock.Any(), "123@qq.com", "192.0.2.1", "").Return("", nil)
+				deps.userSvc.EXPECT().Login(gomock.Any(), "123@qq.com", "hello#world123").
+					Return(&domain.User{Id: 123}, nil)
+				return jsonRequest(t, "/users/login", `{"email":"123@qq.com","password":"hello#world123"}`)
+			},
+		},
+		{
+			name:    "短信登录",
+			method:  domain.LoginMethodSMS,
+			account: "15212345678",
+			firstStep: func(t *testing.T, deps loginDeps) *http.Request {
+				deps.codeSvc.EXPECT().Verify(gomock.Any(), bizLogin, "15212345678", "123456").Return(true, nil)
+				deps.userSvc.EXPECT().FindOrCreate(gomock.Any(), "15212345678").
+					Return(&domain.User{Id: 123}, false, nil)
+				return jsonRequest(t, "/users/login_sms", `{"phone":"15212345678","code":"123456"}`)
+			},
+		},
+		{
+			name:    "邮箱登录",
+			method:  domain.LoginMethodEmail,
+			account: "123@qq.com",
+			firstStep: func(t *testing.T, deps loginDeps) *http.Request {
+				deps.codeSvc.EXPECT().Verify(gomock.Any(), bizLoginEmail, "123@qq.com", "123456").Return(true, nil)
+				deps.userSvc.EXPECT().FindOrCreateByEmail(gomock.Any(), "123@qq.com").
+					Return(&domain.User{Id: 123, EmailVerified: true}, false, nil)
+				return jsonRequest(t, "/users/login_email", `{"email":"123@qq.com","code":"123456"}`)
+			},
+		},
+		{
+			name:   "微信登录",
+			method: domain.LoginMethodWechat,
+			firstStep: func(t *testing.T, deps loginDeps) *http.Request {
+				deps.wechat.EXPECT().Exchange(gomock.Any(), "code", outh2.AuthParams{State: "state"}).
+					Return(outh2.Token{AccessToken: "access"}, nil)
+				deps.wechat.EXPECT().UserInfo(gomock.Any(), outh2.Token{AccessToken: "access"}).
+					Return(domain.OAuth2Info{Subject: "openid", UnionId: "unionid"}, nil)
+				deps.userSvc.EXPECT().FindOrCreateByWechat(gomock.Any(),
+					domain.WechatInfo{OpenId: "openid", UnionId: "unionid"}).Return(domain.User{Id: 123}, false, nil)
+				return wechatCallback(t, deps.keys)
+			},
+		},
+		{
+			name:   "GitHub 登录",
+			method: "github",
+			firstStep: func(t *testing.T, deps loginDeps) *http.Request {
+				params := outh2.AuthParams{State: "state", Nonce: "nonce", CodeVerifier: "verifier"}
+				deps.github.EXPECT().Exchange(gomock.Any(), "code", params).
+					Return(outh2.Token{AccessToken: "access"}, nil)
+				info := domain.OAuth2Info{Provider: "github", Subject: "octocat"}
+				deps.github.EXPECT().UserInfo(gomock.Any(), outh2.Token{AccessToken: "access"}).Return(info, nil)
+				deps.userSvc.EXPECT().FindOrCreateByOAuth2(gomock.Any(), info).Return(domain.User{Id: 123}, false, nil)
+				return oauth2Callback(t, deps.keys, "github")
+			},
+		},
+	}
+	keys, err := jwtx.NewEphemeralKeySet("test")
+	require.NoError(t, err)
+	for _, tc := range testCases {
+		t.Run(tc.name+"，开启了两步验证", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			deps := loginDeps{
+				userSvc:    svcmocks.NewMockUserService(ctrl),
+				codeSvc:    svcmocks.NewMockCodeService(ctrl),
+				loginGuard: svcmocks.NewMockLoginGuardService(ctrl),
+				wechat:     outh2mocks.NewMockProvider(ctrl),
+				github:     outh2mocks.NewMockProvider(ctrl),
+				keys:       keys,
+			}
+			twoFactorSvc := svcmocks.NewMockTwoFactorService(ctrl)
+			twoFactorSvc.EXPECT().Status(gomock.Any(), int64(123)).
+				Return(domain.TwoFactor{Uid: 123, Enabled: true}, nil)
+			jwtHdl := jwtmocks.NewMockHandler(ctrl)
+			// 只发 pending token，不能调用 SetLoginToken，也不能清掉失败记录
+			jwtHdl.EXPECT().IssuePendingToken(int64(123), tc.method, tc.account).Return("pending", nil)
+			events := svcmocks.NewMockSecurityEventService(ctrl)
+			server := newLoginServer(ctrl, deps, twoFactorSvc, events, jwtHdl)
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, tc.firstStep(t, deps))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.JSONEq(t, `{"code":0,"msg":"请输入两步验证码","data":{"twoFactor":true,"pendingToken":"pending"}}`,
+				recorder.Body.String())
+		})
+		t.Run(tc.name+"，没有开启两步验证", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			deps := loginDeps{
+				userSvc:    svcmocks.NewMockUserService(ctrl),
+				codeSvc:    svcmocks.NewMockCodeService(ctrl),
+				loginGuard: svcmocks.NewMockLoginGuardService(ctrl),
+				wechat:     outh2mocks.NewMockProvider(ctrl),
+				github:     outh2mocks.NewMockProvider(ctrl),
+				keys:       keys,
+			}
+			twoFactorSvc := svcmocks.NewMockTwoFactorService(ctrl)
+			twoFactorSvc.EXPECT().Status(gomock.Any(), int64(123)).Return(domain.TwoFactor{Uid: 123}, nil)
+			jwtHdl := jwtmocks.NewMockHandler(ctrl)
+			jwtHdl.EXPECT().SetLoginToken(gomock.Any(), int64(123)).Return("ssid", nil)
+			if tc.account != "" {
+				// 完整登录之后才清掉失败记录
+				deps.loginGuard.EXPECT().Succeed(gomock.Any(), tc.account, "192.0.2.1").Return(nil)
+			}
+			events := svcmocks.NewMockSecurityEventService(ctrl)
+			events.EXPECT().Record(gomock.Any(), domain.SecurityEvent{Uid: 123, Type: domain.SecurityEventLogin,
+				Method: tc.method, Account: tc.account, Ssid: "ssid", IP: "192.0.2.1", UserAgent: "test-agent"})
+			server := newLoginServer(ctrl, deps, twoFactorSvc, events, jwtHdl)
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, tc.firstStep(t, deps))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.JSONEq(t, `{"code":0,"msg":"登录成功","data":null}`, recorder.Body.String())
+		})
+	}
+}
+
+func TestUserHandler_LoginEvents(t *testing.T) {
+	testCases := []struct {
+		name string
+		// mock 准备依赖，返回请求和期望记录的事件
+		mock func(t *testing.T, deps loginDeps, twoFactorSvc *svcmocks.MockTwoFactorService,
+			jwtHdl *jwtmocks.MockHandler) (*http.Request, []domain.SecurityEvent)
+		wantBody string
+	}{
+		{
+			name: "密码不对",
+			mock: func(t *testing.T, deps loginDeps, twoFactorSvc *svcmocks.MockTwoFactorService,
+				jwtHdl *jwtmocks.MockHandler) (*http.Request, []domain.SecurityEvent) {
+				deps.loginGuard.EXPECT().Check(gomock.Any(), "123@qq.com", "192.0.2.1", "").Return("", nil)
+				deps.userSvc.EXPECT().Login(gomock.Any(), "123@qq.com", "hello#world123").
+					Return(nil, service.ErrInvalidUserOrPassword)
+				deps.loginGuard.EXPECT().Fail(gomock.Any(), "123@qq.com", "192.0.2.1").Return(nil)
+				return jsonRequest(t, "/users/login", `{"email":"123@qq.com","password":"hello#world123"}`),
+					[]domain.SecurityEvent{{Type: domain.SecurityEventLoginFailed, Method: domain.LoginMethodPassword,
+						Account: "123@qq.com", Detail: "bad_password", IP: "192.0.2.1", UserAgent: "test-agent"}}
+			},
+			wantBody: `{"code":4,"msg":"用户名或者密码不对","data":null}`,
+		},
+		{
+			name: "需要人机验证",
+			mock: func(t *testing.T, deps loginDeps, twoFactorSvc *svcmocks.MockTwoFactorService,
+				jwtHdl *jwtmocks.MockHandler) (*http.Request, []domain.SecurityEvent) {
+				deps.loginGuard.EXPECT().Check(gomock.Any(), "123@qq.com", "192.0.2.1", "").
+					Return("login", service.ErrLoginChallengeRequired)
+				return jsonRequest(t, "/users/login", `{"email":"123@qq.com","password":"hello#world123"}`),
+					[]domain.SecurityEvent{{Type: domain.SecurityEventLoginFailed, Method: domain.LoginMethodPassword,
+						Account: "123@qq.com", Detail: "challenge_required", IP: "192.0.2.1", UserAgent: "test-agent"}}
+			},
+			wantBody: `{"code":4,"msg":"请先完成人机验证","data":{"captcha":true,"biz":"login"}}`,
+		},
+		{
+			name: "短信验证次数太多",
+			mock: func(t *testing.T, deps loginDeps, twoFactorSvc *svcmocks.MockTwoFactorService,
+				jwtHdl *jwtmocks.MockHandler) (*http.Request, []domain.SecurityEvent) {
+				deps.codeSvc.EXPECT().Verify(gomock.Any(), bizLogin, "15212345678", "123456").
+					Return(false, service.ErrCodeVerifyTooMany)
+				return jsonRequest(t, "/users/login_sms", `{"phone":"15212345678","code":"123456"}`),
+					[]domain.SecurityEvent{{Type: domain.SecurityEventLoginFailed, Method: domain.LoginMethodSMS,
+						Account: "15212345678", Detail: "too_many_attempts", IP: "192.0.2.1", UserAgent: "test-agent"}}
+			},
+			wantBody: `{"code":4,"msg":"验证失败","data":null}`,
+		},
+		{
+			name: "短信登录创建了账号",
+			mock: func(t *testing.T, deps loginDeps, twoFactorSvc *svcmocks.MockTwoFactorService,
+				jwtHdl *jwtmocks.MockHandler) (*http.Request, []domain.SecurityEvent) {
+				deps.codeSvc.EXPECT().Verify(gomock.Any(), bizLogin, "15212345678", "123456").Return(true, nil)
+				deps.userSvc.EXPECT().FindOrCreate(gomock.Any(), "15212345678").
+					Return(&domain.User{Id: 123}, true, nil)
+				twoFactorSvc.EXPECT().Status(gomock.Any(), int64(123)).Return(domain.TwoFactor{Uid: 123}, nil)
+				jwtHdl.EXPECT().SetLoginToken(gomock.Any(), int64(123)).Return("ssid", nil)
+				deps.loginGuard.EXPECT().Succeed(gomock.Any(), "15212345678", "192.0.2.1").Return(nil)
+				return jsonRequest(t, "/users/login_sms", `{"phone":"15212345678","code":"123456"}`),
+					[]domain.SecurityEvent{
+						{Uid: 123, Type: domain.SecurityEventSignup, Method: domain.LoginMethodSMS,
+							Account: "15212345678", IP: "192.0.2.1", UserAgent: "test-agent"},
+						{Uid: 123, Type: domain.SecurityEventLogin, Method: domain.LoginMethodSMS,
+							Account: "15212345678", Ssid: "ssid", IP: "192.0.2.1", UserAgent: "test-agent"},
+					}
+			},
+			wantBody: `{"code":0,"msg":"登录成功","data":null}`,
+		},
+		{
+			name: "邮箱登录创建了账号",
+			mock: func(t *testing.T, deps loginDeps, twoFactorSvc *svcmocks.MockTwoFactorService,
+				jwtHdl *jwtmocks.MockHandler) (*http.Request, []domain.SecurityEvent) {
+				deps.codeSvc.EXPECT().Verify(gomock.Any(), bizLoginEmail, "123@qq.com", "123456").Return(true, nil)
+				deps.userSvc.EXPECT().FindOrCreateByEmail(gomock.Any(), "123@qq.com").
+					Return(&domain.User{Id: 123, EmailVerified: true}, true, nil)
+				twoFactorSvc.EXPECT().Status(gomock.Any(), int64(123)).
+					Return(domain.TwoFactor{Uid: 123, Enabled: true}, nil)
+				jwtHdl.EXPECT().IssuePendingToken(int64(123), domain.LoginMethodEmail, "123@qq.com").
+					Return("pending", nil)
+				// 开启两步验证之前就注册了，注册事件不用等第二步
+				return jsonRequest(t, "/users/login_email", `{"email":"123@qq.com","code":"123456"}`),
+					[]domain.SecurityEvent{{Uid: 123, Type: domain.SecurityEventSignup, Method: domain.LoginMethodEmail,
+						Account: "123@qq.com", IP: "192.0.2.1", UserAgent: "test-agent"}}
+			},
+			wantBody: `{"code":0,"msg":"请输入两步验证码","data":{"twoFactor":true,"pendingToken":"pending"}}`,
+		},
+		{
+			name: "密码注册的账号第一次用邮箱验证码登录",
+			mock: func(t *testing.T, deps loginDeps, twoFactorSvc *svcmocks.MockTwoFactorService,
+				jwtHdl *jwtmocks.MockHandler) (*http.Request, []domain.SecurityEvent) {
+				deps.codeSvc.EXPECT().Verify(gomock.Any(), bizLoginEmail, "123@qq.com", "123456").Return(true, nil)
+				deps.userSvc.EXPECT().FindOrCreateByEmail(gomock.Any(), "123@qq.com").
+					Return(&domain.User{Id: 123}, false, nil)
+				// 抢注的人用密码登录的会话要踢掉，密码作废
+				jwtHdl.EXPECT().ClearUserSessions(gomock.Any(), int64(123)).Return(nil)
+				deps.userSvc.EXPECT().VerifyEmail(gomock.Any(), int64(123)).Return(nil)
+				twoFactorSvc.EXPECT().Status(gomock.Any(), int64(123)).
+					Return(domain.TwoFactor{Uid: 123, Enabled: true}, nil)
+				jwtHdl.EXPECT().IssuePendingToken(int64(123), domain.LoginMethodEmail, "123@qq.com").
+					Return("pending", nil)
+				return jsonRequest(t, "/users/login_email", `{"email":"123@qq.com","code":"123456"}`), nil
+			},
+			wantBody: `{"code":0,"msg":"请输入两步验证码","data":{"twoFactor":true,"pendingToken":"pending"}}`,
+		},
+	}
+	keys, err := jwtx.NewEphemeralKeySet("test")
+	require.NoError(t, err)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			deps := loginDeps{
+				userSvc:    svcmocks.NewMockUserService(ctrl),
+				codeSvc:    svcmocks.NewMockCodeService(ctrl),
+				loginGuard: svcmocks.NewMockLoginGuardService(ctrl),
+				wechat:     outh2mocks.NewMockProvider(ctrl),
+				github:     outh2mocks.NewMockProvider(ctrl),
+				keys:       keys,
+			}
+			twoFactorSvc := svcmocks.NewMockTwoFactorService(ctrl)
+			jwtHdl := jwtmocks.NewMockHandler(ctrl)
+			req, wantEvents := tc.mock(t, deps, twoFactorSvc, jwtHdl)
+			events := svcmocks.NewMockSecurityEventService(ctrl)
+			calls := make([]any, 0, len(wantEvents))
+			for _, e := range wantEvents {
+				calls = append(calls, events.EXPECT().Record(gomock.Any(), e))
+			}
+			gomock.InOrder(calls...)
+			server := newLoginServer(ctrl, deps, twoFactorSvc, events, jwtHdl)
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	ijwt "webok/internal/web/jwt"
+)
+
+// AdminMiddlewareBuilder 只放行配置里面的管理员，要放在登录校验的后面
+type AdminMiddlewareBuilder struct {
+	uids map[int64]struct{}
+}
+
+func NewAdminMiddlewareBuilder(uids []int64) *AdminMiddlewareBuilder {
+	m := make(map[int64]struct{}, len(uids))
+	for _, uid := range uids {
+		m[uid] = struct{}{}
+	}
+	return &AdminMiddlewareBuilder{uids: m}
+}
+
+func (b *AdminMiddlewareBuilder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		uc, ok := ctx.Get("user")
+		if !ok {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		claims, ok := uc.(ijwt.TokenClaims)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if _, ok = b.uids[claims.Uid]; !ok {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+	}
+}
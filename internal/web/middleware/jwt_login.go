@@ -3,6 +3,7 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"strings"
 	ijwt "webok/internal/web/jwt"
 )
 
@@ -15,10 +16,17 @@ func (m *LoginJWTMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 		path := ctx.Request.URL.Path
 		if path == "/users/signup" ||
 			path == "/users/login" ||
+			path == "/users/login/2fa" ||
 			path == "/users/login_sms/code/send" ||
 			path == "/users/login_sms" ||
-			path == "/oauth2/wechat/authurl" ||
-			path == "/oauth2/wechat/callback" {
+			path == "/users/login_email/code/send" ||
+			path == "/users/login_email" ||
+			path == "/users/password/reset/code/send" ||
+			path == "/users/password/reset" ||
+			path == "/captcha/generate" ||
+			path == "/captcha/verify" ||
+			isOAuth2Login(path) ||
+			path == "/.well-known/jwks.json" {
 			return
 		}
 
@@ -44,3 +52,11 @@ func (m *LoginJWTMiddlewareBuilder) CheckLogin() gin.HandlerFunc {
 		ctx.Set("user", *uc)
 	}
 }
+
+// isOAuth2Login /oauth2/:provider/authurl 和 /oauth2/:provider/callback，
+// 微信绑定和合并的 /oauth2/wechat/link/authurl 这种要登录
+func isOAuth2Login(path string) bool {
+	segs := strings.Split(path, "/")
+	return len(segs) == 4 && segs[1] == "oauth2" && segs[2] != "" &&
+		(segs[3] == "authurl" || segs[3] == "callback")
+}
@@ -0,0 +1,161 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	uuid "github.com/lithammer/shortuuid/v4"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/service"
+	"webok/internal/service/outh2"
+	ijwt "webok/internal/web/jwt"
+	"webok/pkg/ginx"
+	"webok/pkg/jwtx"
+	"webok/pkg/logger"
+)
+
+// OAuth2Handler 通用的第三方登录，provider 在 outh2.Registry 里面注册。
+// 微信的路由是 OAuth2WechatHandler 注册的静态路由，优先级比这里高
+type OAuth2Handler struct {
+	ijwt.Handler
+	registry        *outh2.Registry
+	userSvc         service.UserService
+	keys            *jwtx.KeySet
+	events          service.SecurityEventService
+	flow            loginFlow
+	stateCookieName string
+	log             logger.Logger
+}
+
+func NewOAuth2Handler(registry *outh2.Registry, userSvc service.UserService, twoFactorSvc service.TwoFactorService,
+	loginGuard service.LoginGuardService, jwt ijwt.Handler, keys *jwtx.KeySet, events service.SecurityEventService,
+	l logger.Logger) *OAuth2Handler {
+	return &OAuth2Handler{
+		Handler:         jwt,
+		registry:        registry,
+		userSvc:         userSvc,
+		keys:            keys,
+		events:          events,
+		flow:            newLoginFlow(jwt, twoFactorSvc, loginGuard, events, l),
+		stateCookieName: "oauth2-state",
+		log:             l,
+	}
+}
+
+func (o *OAuth2Handler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/oauth2")
+	g.GET("/:provider/authurl", ginx.Warp(o.AuthURL))
+	g.Any("/:provider/callback", ginx.Warp(o.Callback))
+}
+
+// typOAuth2State 和微信的 state cookie 分开，不能互相冒充
+const typOAuth2State = "oauth2-state+jwt"
+
+// OAuth2StateClaims 放在 cookie 里面，回调的时候用来校验 state，
+// code verifier 和 nonce 也放在这里，不需要服务端存储
+type OAuth2StateClaims struct {
+	jwt.RegisteredClaims
+	Provider     string
+	State        string
+	Nonce        string
+	CodeVerifier string
+}
+
+func (o *OAuth2Handler) AuthURL(ctx *gin.Context) (ginx.Result, error) {
+	p, err := o.registry.Get(ctx.Param("provider"))
+	if err != nil {
+		return ginx.Result{Code: 4, Msg: "不支持的登录方式"}, nil
+	}
+	verifier, err := outh2.NewCodeVerifier()
+	if err != nil {
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+	sc := OAuth2StateClaims{
+		Provider:     p.Name(),
+		State:        uuid.New(),
+		Nonce:        uuid.New(),
+		CodeVerifier: verifier,
+	}
+	val, err := p.AuthURL(ctx, outh2.AuthParams{State: sc.State, Nonce: sc.Nonce, CodeVerifier: sc.CodeVerifier})
+	if err != nil {
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+	err = o.setStateCookie(ctx, sc)
+	if err != nil {
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+	return ginx.Result{Data: val}, nil
+}
+
+func (o *OAuth2Handler) Callback(ctx *gin.Context) (ginx.Result, error) {
+	p, err := o.registry.Get(ctx.Param("provider"))
+	if err != nil {
+		return ginx.Result{Code: 4, Msg: "不支持的登录方式"}, nil
+	}
+	sc, err := o.verifyState(ctx, p.Name())
+	if err != nil {
+		return ginx.Result{Code: 4, Msg: "非法请求"}, err
+	}
+	info, err := o.userInfo(ctx, p, sc)
+	if err != nil {
+		recordEvent(ctx, o.events, domain.SecurityEvent{Type: domain.SecurityEventLoginFailed,
+			Method: p.Name(), Detail: "bad_code"})
+		return ginx.Result{Code: 4, Msg: "授权码有误"}, err
+	}
+	u, created, err := o.userSvc.FindOrCreateByOAuth2(ctx, info)
+	if err != nil {
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+	if created {
+		recordEvent(ctx, o.events, domain.SecurityEvent{Uid: u.Id, Type: domain.SecurityEventSignup,
+			Method: p.Name()})
+	}
+	return o.flow.finish(ctx, domain.SecurityEvent{Uid: u.Id, Method: p.Name()})
+}
+
+func (o *OAuth2Handler) userInfo(ctx *gin.Context, p outh2.Provider, sc OAuth2StateClaims) (domain.OAuth2Info, error) {
+	if errMsg := ctx.Query("error"); errMsg != "" {
+		// 用户在授权页面点了取消
+		return domain.OAuth2Info{}, fmt.Errorf("授权失败 %s", errMsg)
+	}
+	params := outh2.AuthParams{State: sc.State, Nonce: sc.Nonce, CodeVerifier: sc.CodeVerifier}
+	tok, err := p.Exchange(ctx, ctx.Query("code"), params)
+	if err != nil {
+		return domain.OAuth2Info{}, err
+	}
+	return p.UserInfo(ctx, tok)
+}
+
+func (o *OAuth2Handler) setStateCookie(ctx *gin.Context, claims OAuth2StateClaims) error {
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(stateTTL))
+	tokenStr, err := o.keys.Sign(typOAuth2State, claims)
+	if err != nil {
+		return err
+	}
+	ctx.SetCookie(o.stateCookieName, tokenStr,
+		int(stateTTL.Seconds()), "/oauth2/"+claims.Provider+"/callback",
+		"", false, true)
+	return nil
+}
+
+func (o *OAuth2Handler) verifyState(ctx *gin.Context, provider string) (OAuth2StateClaims, error) {
+	var sc OAuth2StateClaims
+	ck, err := ctx.Cookie(o.stateCookieName)
+	if err != nil {
+		return sc, fmt.Errorf("无法获得 cookie %w", err)
+	}
+	_, err = o.keys.Parse(ck, typOAuth2State, &sc)
+	if err != nil {
+		return sc, fmt.Errorf("解析 token 失败 %w", err)
+	}
+	// A 平台发起的授权不能拿到 B 平台的回调里面用
+	if sc.State == "" || sc.State != ctx.Query("state") {
+		return sc, errors.New("state 不匹配")
+	}
+	if sc.Provider != provider {
+		return sc, errors.New("provider 不匹配")
+	}
+	return sc, nil
+}
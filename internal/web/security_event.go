@@ -0,0 +1,81 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/service"
+	ijwt "webok/internal/web/jwt"
+	"webok/internal/web/middleware"
+	"webok/pkg/ginx"
+)
+
+// SecurityEventHandler 用户查看自己最近的登录记录，管理员按照用户或者 IP 排查
+type SecurityEventHandler struct {
+	svc   service.SecurityEventService
+	admin *middleware.AdminMiddlewareBuilder
+}
+
+func NewSecurityEventHandler(svc service.SecurityEventService, admin *middleware.AdminMiddlewareBuilder) *SecurityEventHandler {
+	return &SecurityEventHandler{svc: svc, admin: admin}
+}
+
+func (h *SecurityEventHandler) RegisterRoutes(server *gin.Engine) {
+	server.POST("/users/security/events", ginx.WarpBodyAndClaims[SecurityEventListReq, ijwt.TokenClaims](h.list))
+	ag := server.Group("/admin", h.admin.Build())
+	ag.POST("/security/events", ginx.WarpBody[SecurityEventSearchReq](h.search))
+}
+
+func (h *SecurityEventHandler) list(ctx *gin.Context, req SecurityEventListReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	events, err := h.svc.ListByUser(ctx, uc.Uid, req.BeforeId, req.Limit)
+	if err != nil {
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+	return ginx.Result{Data: toSecurityEventVos(events, false)}, nil
+}
+
+func (h *SecurityEventHandler) search(ctx *gin.Context, req SecurityEventSearchReq) (ginx.Result, error) {
+	if req.Uid <= 0 && req.IP == "" {
+		return ginx.Result{Code: 4, Msg: "uid 和 ip 至少填一个"}, nil
+	}
+	events, err := h.svc.Search(ctx, domain.SecurityEventQuery{
+		Uid:      req.Uid,
+		IP:       req.IP,
+		BeforeId: req.BeforeId,
+		Limit:    req.Limit,
+	})
+	if err != nil {
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+	return ginx.Result{Data: toSecurityEventVos(events, true)}, nil
+}
+
+// toSecurityEventVos 用户自己看的时候不返回登录失败时填的账号，那可能是别人输错的
+func toSecurityEventVos(events []domain.SecurityEvent, admin bool) []SecurityEventVo {
+	res := make([]SecurityEventVo, 0, len(events))
+	for _, e := range events {
+		vo := SecurityEventVo{
+			Id:        e.Id,
+			Type:      string(e.Type),
+			Method:    e.Method,
+			IP:        e.IP,
+			UserAgent: e.UserAgent,
+			Ssid:      e.Ssid,
+			Detail:    e.Detail,
+			Ctime:     e.Ctime.Format(time.DateTime),
+		}
+		if admin {
+			vo.Uid = e.Uid
+			vo.Account = e.Account
+		}
+		res = append(res, vo)
+	}
+	return res
+}
+
+// recordEvent IP 和 User-Agent 从请求里面拿，异步写入不影响接口
+func recordEvent(ctx *gin.Context, svc service.SecurityEventService, e domain.SecurityEvent) {
+	e.IP = ctx.ClientIP()
+	e.UserAgent = ctx.Request.UserAgent()
+	svc.Record(ctx, e)
+}
@@ -0,0 +1,27 @@
+package web
+
+// SecurityEventListReq BeforeId 是上一页最后一条的 Id，第一页不填
+type SecurityEventListReq struct {
+	BeforeId int64 `json:"beforeId"`
+	Limit    int   `json:"limit"`
+}
+
+type SecurityEventSearchReq struct {
+	Uid      int64  `json:"uid"`
+	IP       string `json:"ip"`
+	BeforeId int64  `json:"beforeId"`
+	Limit    int    `json:"limit"`
+}
+
+type SecurityEventVo struct {
+	Id        int64  `json:"id"`
+	Uid       int64  `json:"uid,omitempty"`
+	Type      string `json:"type"`
+	Method    string `json:"method"`
+	Account   string `json:"account,omitempty"`
+	IP        string `json:"ip"`
+	UserAgent string `json:"userAgent"`
+	Ssid      string `json:"ssid"`
+	Detail    string `json:"detail"`
+	Ctime     string `json:"ctime"`
+}
@@ -0,0 +1,162 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"webok/internal/domain"
+	"webok/internal/service"
+	ijwt "webok/internal/web/jwt"
+	"webok/pkg/ginx"
+	"webok/pkg/logger"
+)
+
+// TwoFactorHandler TOTP 两步验证，开启之后密码登录要多输一次验证器上的验证码
+type TwoFactorHandler struct {
+	ijwt.Handler
+	svc     service.TwoFactorService
+	userSvc service.UserService
+	events  service.SecurityEventService
+	flow    loginFlow
+	l       logger.Logger
+}
+
+func NewTwoFactorHandler(svc service.TwoFactorService, userSvc service.UserService, loginGuard service.LoginGuardService,
+	events service.SecurityEventService, jwt ijwt.Handler, l logger.Logger) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		Handler: jwt,
+		svc:     svc,
+		userSvc: userSvc,
+		events:  events,
+		flow:    newLoginFlow(jwt, svc, loginGuard, events, l),
+		l:       l,
+	}
+}
+
+func (h *TwoFactorHandler) RegisterRoutes(server *gin.Engine) {
+	ug := server.Group("/users")
+	// 密码登录的第二步，带着第一步拿到的 pending token
+	ug.POST("/login/2fa", ginx.WarpBody[LoginTwoFactorReq](h.login))
+	ug.GET("/2fa", ginx.WarpClaims[ijwt.TokenClaims](h.status))
+	ug.POST("/2fa/enroll", ginx.WarpClaims[ijwt.TokenClaims](h.enroll))
+	ug.POST("/2fa/confirm", ginx.WarpBodyAndClaims[TwoFactorCodeReq, ijwt.TokenClaims](h.confirm))
+	ug.POST("/2fa/disable", ginx.WarpBodyAndClaims[TwoFactorCodeReq, ijwt.TokenClaims](h.disable))
+	ug.POST("/2fa/recovery_codes", ginx.WarpBodyAndClaims[TwoFactorCodeReq, ijwt.TokenClaims](h.recoveryCodes))
+}
+
+func (h *TwoFactorHandler) login(ctx *gin.Context, req LoginTwoFactorReq) (ginx.Result, error) {
+	uc, err := h.ParsePendingToken(ctx, req.PendingToken)
+	switch {
+	case err == nil:
+	case errors.Is(err, ijwt.ErrTooManyAttempts):
+		recordEvent(ctx, h.events, domain.SecurityEvent{Uid: uc.Uid, Type: domain.SecurityEventLoginFailed,
+			Method: domain.LoginMethodTOTP, Account: uc.Account, Detail: "too_many_attempts"})
+		return ginx.Result{Code: 4, Msg: "验证码错误次数太多，请重新登录"}, nil
+	default:
+		h.l.Debug("pending token 无效", logger.Error(err))
+		return ginx.Result{Code: 4, Msg: "登录已失效，请重新登录"}, nil
+	}
+	err = h.svc.Verify(ctx, uc.Uid, req.Code)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrTwoFactorCodeInvalid):
+		h.flow.fail(ctx, uc.Account)
+		recordEvent(ctx, h.events, domain.SecurityEvent{Uid: uc.Uid, Type: domain.SecurityEventLoginFailed,
+			Method: domain.LoginMethodTOTP, Account: uc.Account, Detail: "bad_code"})
+		return ginx.Result{Code: 4, Msg: "验证码不对"}, nil
+	case errors.Is(err, service.ErrTwoFactorNotEnabled):
+		// 输完密码之后在别的地方关掉了两步验证，重新走一遍登录
+		return ginx.Result{Code: 4, Msg: "登录已失效，请重新登录"}, nil
+	default:
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+	err = h.ClearPendingToken(ctx, *uc)
+	if err != nil {
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+	return h.flow.login(ctx, domain.SecurityEvent{Uid: uc.Uid,
+		Method: uc.Method, Account: uc.Account, Detail: domain.LoginMethodTOTP})
+}
+
+func (h *TwoFactorHandler) status(ctx *gin.Context, uc ijwt.TokenClaims) (ginx.Result, error) {
+	tf, err := h.svc.Status(ctx, uc.Uid)
+	if err != nil {
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+	return ginx.Result{Data: TwoFactorStatusVo{
+		Enabled:           tf.Enabled,
+		RecoveryCodesLeft: len(tf.RecoveryCodes),
+	}}, nil
+}
+
+func (h *TwoFactorHandler) enroll(ctx *gin.Context, uc ijwt.TokenClaims) (ginx.Result, error) {
+	u, err := h.userSvc.Profile(ctx, &domain.User{Id: uc.Uid})
+	if err != nil {
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+	e, err := h.svc.Enroll(ctx, uc.Uid, accountLabel(u))
+	switch {
+	case err == nil:
+		return ginx.Result{Data: TOTPEnrollmentVo{Secret: e.Secret, URI: e.URI}}, nil
+	case errors.Is(err, service.ErrTwoFactorEnabled):
+		return ginx.Result{Code: 4, Msg: "已经开启了两步验证"}, nil
+	default:
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+}
+
+func (h *TwoFactorHandler) confirm(ctx *gin.Context, req TwoFactorCodeReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	codes, err := h.svc.Confirm(ctx, uc.Uid, req.Code)
+	switch {
+	case err == nil:
+		return ginx.Result{Msg: "已开启两步验证，请保存好恢复码", Data: RecoveryCodesVo{RecoveryCodes: codes}}, nil
+	case errors.Is(err, service.ErrTwoFactorCodeInvalid):
+		return ginx.Result{Code: 4, Msg: "验证码不对"}, nil
+	case errors.Is(err, service.ErrTwoFactorNotEnrolled):
+		return ginx.Result{Code: 4, Msg: "请先扫码绑定验证器"}, nil
+	case errors.Is(err, service.ErrTwoFactorEnabled):
+		return ginx.Result{Code: 4, Msg: "已经开启了两步验证"}, nil
+	default:
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+}
+
+func (h *TwoFactorHandler) disable(ctx *gin.Context, req TwoFactorCodeReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	err := h.svc.Disable(ctx, uc.Uid, req.Code)
+	if err != nil {
+		return h.codeErr(err)
+	}
+	return ginx.Result{Msg: "已关闭两步验证"}, nil
+}
+
+func (h *TwoFactorHandler) recoveryCodes(ctx *gin.Context, req TwoFactorCodeReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	codes, err := h.svc.RegenerateRecoveryCodes(ctx, uc.Uid, req.Code)
+	if err != nil {
+		return h.codeErr(err)
+	}
+	return ginx.Result{Msg: "旧的恢复码已经作废", Data: RecoveryCodesVo{RecoveryCodes: codes}}, nil
+}
+
+// codeErr 已经开启两步验证之后的操作都要先输验证码
+func (h *TwoFactorHandler) codeErr(err error) (ginx.Result, error) {
+	switch {
+	case errors.Is(err, service.ErrTwoFactorCodeInvalid):
+		return ginx.Result{Code: 4, Msg: "验证码不对"}, nil
+	case errors.Is(err, service.ErrTwoFactorNotEnabled):
+		return ginx.Result{Code: 4, Msg: "没有开启两步验证"}, nil
+	default:
+		return ginx.Result{Code: 5, Msg: "系统错误"}, err
+	}
+}
+
+// accountLabel 验证器 App 里面用来区分账号，优先用邮箱
+func accountLabel(u *domain.User) string {
+	switch {
+	case u.Email != "":
+		return u.Email
+	case u.Phone != "":
+		return u.Phone
+	default:
+		return strconv.FormatInt(u.Id, 10)
+	}
+}
@@ -0,0 +1,129 @@
+package web
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"webok/internal/domain"
+	"webok/internal/service"
+	svcmocks "webok/internal/service/mock"
+	ijwt "webok/internal/web/jwt"
+	jwtmocks "webok/internal/web/jwt/mock"
+	"webok/pkg/logger"
+)
+
+func TestTwoFactorHandler_login(t *testing.T) {
+	pending := &ijwt.TokenClaims{Uid: 123, Method: domain.LoginMethodPassword, Account: "123@qq.com"}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.TwoFactorService, service.LoginGuardService,
+			service.SecurityEventService, ijwt.Handler)
+		body     string
+		wantBody string
+	}{
+		{
+			name: "验证码正确",
+			mock: func(ctrl *gomock.Controller) (service.TwoFactorService, service.LoginGuardService,
+				service.SecurityEventService, ijwt.Handler) {
+				jwtHdl := jwtmocks.NewMockHandler(ctrl)
+				jwtHdl.EXPECT().ParsePendingToken(gomock.Any(), "pending").Return(pending, nil)
+				svc := svcmocks.NewMockTwoFactorService(ctrl)
+				svc.EXPECT().Verify(gomock.Any(), int64(123), "123456").Return(nil)
+				jwtHdl.EXPECT().ClearPendingToken(gomock.Any(), *pending).Return(nil)
+				jwtHdl.EXPECT().SetLoginToken(gomock.Any(), int64(123)).Return("ssid", nil)
+				guard := svcmocks.NewMockLoginGuardService(ctrl)
+				// 第二步也通过了才清掉失败记录
+				guard.EXPECT().Succeed(gomock.Any(), "123@qq.com", "192.0.2.1").Return(nil)
+				events := svcmocks.NewMockSecurityEventService(ctrl)
+				events.EXPECT().Record(gomock.Any(), domain.SecurityEvent{Uid: 123, Type: domain.SecurityEventLogin,
+					Method: domain.LoginMethodPassword, Account: "123@qq.com", Detail: domain.LoginMethodTOTP,
+					Ssid: "ssid", IP: "192.0.2.1", UserAgent: "test-agent"})
+				return svc, guard, events, jwtHdl
+			},
+			body:     `{"pendingToken":"pending","code":"123456"}`,
+			wantBody: `{"code":0,"msg":"登录成功","data":null}`,
+		},
+		{
+			name: "验证码错误算一次失败",
+			mock: func(ctrl *gomock.Controller) (service.TwoFactorService, service.LoginGuardService,
+				service.SecurityEventService, ijwt.Handler) {
+				jwtHdl := jwtmocks.NewMockHandler(ctrl)
+				jwtHdl.EXPECT().ParsePendingToken(gomock.Any(), "pending").Return(pending, nil)
+				svc := svcmocks.NewMockTwoFactorService(ctrl)
+				svc.EXPECT().Verify(gomock.Any(), int64(123), "000000").Return(service.ErrTwoFactorCodeInvalid)
+				guard := svcmocks.NewMockLoginGuardService(ctrl)
+				guard.EXPECT().Fail(gomock.Any(), "123@qq.com", "192.0.2.1").Return(nil)
+				events := svcmocks.NewMockSecurityEventService(ctrl)
+				events.EXPECT().Record(gomock.Any(), domain.SecurityEvent{Uid: 123, Type: domain.SecurityEventLoginFailed,
+					Method: domain.LoginMethodTOTP, Account: "123@qq.com", Detail: "bad_code",
+					IP: "192.0.2.1", UserAgent: "test-agent"})
+				return svc, guard, events, jwtHdl
+			},
+			body:     `{"pendingToken":"pending","code":"000000"}`,
+			wantBody: `{"code":4,"msg":"验证码不对","data":null}`,
+		},
+		{
+			name: "第一步没有账号的不记失败次数",
+			mock: func(ctrl *gomock.Controller) (service.TwoFactorService, service.LoginGuardService,
+				service.SecurityEventService, ijwt.Handler) {
+				jwtHdl := jwtmocks.NewMockHandler(ctrl)
+				jwtHdl.EXPECT().ParsePendingToken(gomock.Any(), "pending").
+					Return(&ijwt.TokenClaims{Uid: 123, Method: domain.LoginMethodWechat}, nil)
+				svc := svcmocks.NewMockTwoFactorService(ctrl)
+				svc.EXPECT().Verify(gomock.Any(), int64(123), "000000").Return(service.ErrTwoFactorCodeInvalid)
+				events := svcmocks.NewMockSecurityEventService(ctrl)
+				events.EXPECT().Record(gomock.Any(), gomock.Any())
+				return svc, svcmocks.NewMockLoginGuardService(ctrl), events, jwtHdl
+			},
+			body:     `{"pendingToken":"pending","code":"000000"}`,
+			wantBody: `{"code":4,"msg":"验证码不对","data":null}`,
+		},
+		{
+			name: "尝试次数太多",
+			mock: func(ctrl *gomock.Controller) (service.TwoFactorService, service.LoginGuardService,
+				service.SecurityEventService, ijwt.Handler) {
+				jwtHdl := jwtmocks.NewMockHandler(ctrl)
+				jwtHdl.EXPECT().ParsePendingToken(gomock.Any(), "pending").Return(pending, ijwt.ErrTooManyAttempts)
+				events := svcmocks.NewMockSecurityEventService(ctrl)
+				events.EXPECT().Record(gomock.Any(), domain.SecurityEvent{Uid: 123, Type: domain.SecurityEventLoginFailed,
+					Method: domain.LoginMethodTOTP, Account: "123@qq.com", Detail: "too_many_attempts",
+					IP: "192.0.2.1", UserAgent: "test-agent"})
+				return svcmocks.NewMockTwoFactorService(ctrl), svcmocks.NewMockLoginGuardService(ctrl), events, jwtHdl
+			},
+			body:     `{"pendingToken":"pending","code":"000000"}`,
+			wantBody: `{"code":4,"msg":"验证码错误次数太多，请重新登录","data":null}`,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) (service.TwoFactorService, service.LoginGuardService,
+				service.SecurityEventService, ijwt.Handler) {
+				jwtHdl := jwtmocks.NewMockHandler(ctrl)
+				jwtHdl.EXPECT().ParsePendingToken(gomock.Any(), "pending").Return(pending, nil)
+				svc := svcmocks.NewMockTwoFactorService(ctrl)
+				svc.EXPECT().Verify(gomock.Any(), int64(123), "123456").Return(errors.New("db error"))
+				return svc, svcmocks.NewMockLoginGuardService(ctrl), svcmocks.NewMockSecurityEventService(ctrl), jwtHdl
+			},
+			body:     `{"pendingToken":"pending","code":"123456"}`,
+			wantBody: `{"code":5,"msg":"系统错误","data":null}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc, guard, events, jwtHdl := tc.mock(ctrl)
+			hdl := NewTwoFactorHandler(svc, svcmocks.NewMockUserService(ctrl), guard, events, jwtHdl,
+				logger.NewNopLogger())
+			server := gin.New()
+			hdl.RegisterRoutes(server)
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, jsonRequest(t, "/users/login/2fa", tc.body))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.JSONEq(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
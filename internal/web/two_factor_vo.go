@@ -0,0 +1,32 @@
+package web
+
+type TwoFactorCodeReq struct {
+	// Code 验证器上的 6 位数字，或者恢复码
+	Code string `json:"code"`
+}
+
+type LoginTwoFactorReq struct {
+	PendingToken string `json:"pendingToken"`
+	Code         string `json:"code"`
+}
+
+// TwoFactorPendingVo 密码登录的时候开启了两步验证，返回这个而不是直接登录
+type TwoFactorPendingVo struct {
+	TwoFactor    bool   `json:"twoFactor"`
+	PendingToken string `json:"pendingToken"`
+}
+
+type TwoFactorStatusVo struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+// TOTPEnrollmentVo URI 给前端生成二维码，扫不了码的时候手动输入 Secret
+type TOTPEnrollmentVo struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+type RecoveryCodesVo struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
@@ -19,6 +19,13 @@ const (
 	passwordRegexPattern = `^(?=.*[A-Za-z])(?=.*\d)(?=.*[$@$!%*#?&])[A-Za-z\d$@$!%*#?&]{8,}$`
 	phoneRegexPattern    = `^1[3-9]\d{9}$`
 	bizLogin             = "login"
+	bizLoginEmail        = "login_email"
+	bizResetPwdSMS       = "reset_pwd_sms"
+	bizResetPwdEmail     = "reset_pwd_email"
+	bizBindEmail         = "bind_email"
+	bizBindPhone         = "bind_phone"
+	bizMergeSMS          = "merge_sms"
+	bizMergeEmail        = "merge_email"
 )
 
 type UserHandler struct {
@@ -28,16 +35,26 @@ type UserHandler struct {
 	phoneRexExp    *regexp.Regexp
 	svc            service.UserService
 	codeSvc        service.CodeService
+	captchaSvc     service.CaptchaService
+	loginGuard     service.LoginGuardService
+	events         service.SecurityEventService
+	flow           loginFlow
 	log            logger.Logger
 }
 
-func NewUserHandler(svc service.UserService, codeSvc service.CodeService, jwt ijwt.Handler, l logger.Logger) *UserHandler {
+func NewUserHandler(svc service.UserService, codeSvc service.CodeService, captchaSvc service.CaptchaService,
+	twoFactorSvc service.TwoFactorService, loginGuard service.LoginGuardService,
+	events service.SecurityEventService, jwt ijwt.Handler, l logger.Logger) *UserHandler {
 	return &UserHandler{
 		emailRexExp:    regexp.MustCompile(emailRegexPattern, regexp.None),
 		passwordRexExp: regexp.MustCompile(passwordRegexPattern, regexp.None),
 		phoneRexExp:    regexp.MustCompile(phoneRegexPattern, regexp.None),
 		svc:            svc,
 		codeSvc:        codeSvc,
+		captchaSvc:     captchaSvc,
+		loginGuard:     loginGuard,
+		events:         events,
+		flow:           newLoginFlow(jwt, twoFactorSvc, loginGuard, events, l),
 		Handler:        jwt,
 		log:            l,
 	}
@@ -50,10 +67,31 @@ func (h *UserHandler) RegisterRoutes(server *gin.Engine) {
 	ug.POST("/edit", ginx.WarpBodyAndClaims[EditReq, ijwt.TokenClaims](h.edit))
 	ug.GET("/profile", ginx.WarpClaims[ijwt.TokenClaims](h.profile))
 	ug.GET("/refresh_token", ginx.WarpClaims[ijwt.TokenClaims](h.ReFreshToken))
-	ug.GET("/logout", ginx.Warp(h.logout))
+	ug.GET("/logout", ginx.WarpClaims[ijwt.TokenClaims](h.logout))
+	// 登录的设备
+	ug.GET("/sessions", ginx.WarpClaims[ijwt.TokenClaims](h.sessions))
+	ug.POST("/sessions/revoke", ginx.WarpBodyAndClaims[RevokeSessionReq, ijwt.TokenClaims](h.revokeSession))
+	ug.POST("/sessions/revoke_others", ginx.WarpClaims[ijwt.TokenClaims](h.revokeOtherSessions))
 	//验证码相关接口
 	ug.POST("/login_sms/code/send", ginx.WarpBody[SendSMSReq](h.SendSMSLoginCode))
 	ug.POST("/login_sms", ginx.WarpBody[LoginSMSReq](h.LoginSMS))
+	ug.POST("/login_email/code/send", ginx.WarpBody[SendEmailCodeReq](h.SendEmailLoginCode))
+	ug.POST("/login_email", ginx.WarpBody[LoginEmailReq](h.LoginEmail))
+	// 忘记密码
+	ug.POST("/password/reset/code/send", ginx.WarpBody[SendResetPwdCodeReq](h.SendResetPasswordCode))
+	ug.POST("/password/reset", ginx.WarpBody[ResetPwdReq](h.ResetPassword))
+	// 修改敏感信息，新的邮箱和手机号都要先验证
+	ug.POST("/password/change", ginx.WarpBodyAndClaims[ChangePwdReq, ijwt.TokenClaims](h.ChangePassword))
+	ug.POST("/email/code/send", ginx.WarpBodyAndClaims[SendEmailCodeReq, ijwt.TokenClaims](h.SendBindEmailCode))
+	ug.POST("/email/bind", ginx.WarpBodyAndClaims[BindEmailReq, ijwt.TokenClaims](h.BindEmail))
+	ug.POST("/phone/code/send", ginx.WarpBodyAndClaims[SendSMSReq, ijwt.TokenClaims](h.SendBindPhoneCode))
+	ug.POST("/phone/bind", ginx.WarpBodyAndClaims[BindPhoneReq, ijwt.TokenClaims](h.BindPhone))
+	// 绑定的登录方式，绑定邮箱和手机号用上面的接口，绑定微信走 /oauth2/wechat/link/authurl
+	ug.GET("/identities", ginx.WarpClaims[ijwt.TokenClaims](h.Identities))
+	ug.POST("/identities/unlink", ginx.WarpBodyAndClaims[UnlinkReq, ijwt.TokenClaims](h.Unlink))
+	// 合并另外一个账号，需要验证另外一个账号的邮箱或者手机号
+	ug.POST("/merge/code/send", ginx.WarpBodyAndClaims[SendMergeCodeReq, ijwt.TokenClaims](h.SendMergeCode))
+	ug.POST("/merge", ginx.WarpBodyAndClaims[MergeReq, ijwt.TokenClaims](h.Merge))
 }
 
 func (h *UserHandler) signUp(ctx *gin.Context, req SignUpReq) (ginx.Result, error) {
@@ -77,9 +115,12 @@ func (h *UserHandler) signUp(ctx *gin.Context, req SignUpReq) (ginx.Result, erro
 		return ginx.Result{Code: 4, Msg: "密码格式错误"}, nil
 	}
 
-	err = h.svc.SignUp(ctx, &domain.User{Email: req.Email, Password: req.Password})
+	u := &domain.User{Email: req.Email, Password: req.Password}
+	err = h.svc.SignUp(ctx, u)
 	switch {
 	case err == nil:
+		recordEvent(ctx, h.events, domain.SecurityEvent{Uid: u.Id, Type: domain.SecurityEventSignup,
+			Method: domain.LoginMethodPassword, Account: req.Email})
 		return ginx.Result{Msg: "注册成功"}, nil
 	case errors.Is(err, service.ErrDuplicate):
 		return ginx.Result{Code: 4, Msg: "邮箱冲突"}, nil
@@ -89,15 +130,32 @@ func (h *UserHandler) signUp(ctx *gin.Context, req SignUpReq) (ginx.Result, erro
 }
 
 func (h *UserHandler) LoginJWT(ctx *gin.Context, req LoginJwtReq) (ginx.Result, error) {
+	biz, err := h.loginGuard.Check(ctx, req.Email, ctx.ClientIP(), req.Ticket)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrLoginLocked):
+		recordEvent(ctx, h.events, domain.SecurityEvent{Type: domain.SecurityEventLoginFailed,
+			Method: domain.LoginMethodPassword, Account: req.Email, Detail: "locked"})
+		return ginx.Result{Code: 4, Msg: "登录失败次数太多，请稍后再试"}, nil
+	case errors.Is(err, service.ErrLoginChallengeRequired):
+		recordEvent(ctx, h.events, domain.SecurityEvent{Type: domain.SecurityEventLoginFailed,
+			Method: domain.LoginMethodPassword, Account: req.Email, Detail: "challenge_required"})
+		return ginx.Result{Code: 4, Msg: "请先完成人机验证",
+			Data: CaptchaRequiredVo{Captcha: true, Biz: biz}}, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
 	u, err := h.svc.Login(ctx, req.Email, req.Password)
 	switch {
 	case err == nil:
-		err = h.SetLoginToken(ctx, u.Id)
-		if err != nil {
-			return ginx.Result{}, err
-		}
-		return ginx.Result{Msg: "登录成功"}, nil
+		return h.flow.finish(ctx, domain.SecurityEvent{Uid: u.Id,
+			Method: domain.LoginMethodPassword, Account: req.Email})
 	case errors.Is(err, service.ErrInvalidUserOrPassword):
+		if er := h.loginGuard.Fail(ctx, req.Email, ctx.ClientIP()); er != nil {
+			h.log.Error("记录登录失败次数失败", logger.Error(er))
+		}
+		recordEvent(ctx, h.events, domain.SecurityEvent{Type: domain.SecurityEventLoginFailed,
+			Method: domain.LoginMethodPassword, Account: req.Email, Detail: "bad_password"})
 		return ginx.Result{Code: 4, Msg: "用户名或者密码不对"}, nil
 	default:
 		return ginx.Result{Msg: "系统错误", Code: 5}, err
@@ -105,11 +163,21 @@ func (h *UserHandler) LoginJWT(ctx *gin.Context, req LoginJwtReq) (ginx.Result,
 }
 
 func (h *UserHandler) ReFreshToken(ctx *gin.Context, uc ijwt.TokenClaims) (ginx.Result, error) {
-	err := h.SetAccessToken(ctx, uc.Uid, uc.Ssid)
-	if err != nil {
+	err := h.RefreshTokens(ctx, uc)
+	switch {
+	case err == nil:
+		recordEvent(ctx, h.events, domain.SecurityEvent{Uid: uc.Uid, Type: domain.SecurityEventTokenRefresh, Ssid: uc.Ssid})
+		return ginx.Result{Msg: "刷新成功"}, nil
+	case errors.Is(err, ijwt.ErrRefreshTokenReused):
+		// refresh token 可能泄露了，会话已经被踢掉
+		recordEvent(ctx, h.events, domain.SecurityEvent{Uid: uc.Uid, Type: domain.SecurityEventTokenRefresh,
+			Ssid: uc.Ssid, Detail: "reused"})
+		return ginx.Result{Code: 4, Msg: "登录已失效，请重新登录"}, nil
+	case errors.Is(err, ijwt.ErrSessionNotFound):
+		return ginx.Result{Code: 4, Msg: "登录已失效，请重新登录"}, nil
+	default:
 		return ginx.Result{Code: 5, Msg: "系统错误"}, err
 	}
-	return ginx.Result{Msg: "刷新成功"}, nil
 }
 
 func (h *UserHandler) edit(ctx *gin.Context, req EditReq, uc ijwt.TokenClaims) (ginx.Result, error) {
@@ -172,44 +240,499 @@ func (h *UserHandler) SendSMSLoginCode(ctx *gin.Context, req SendSMSReq) (ginx.R
 	if !ok {
 		return ginx.Result{Code: 4, Msg: "手机格式不正确"}, nil
 	}
-	err = h.codeSvc.Send(ctx, bizLogin, req.Phone)
+	return h.sendCode(ctx, bizLogin, req.Phone, req.Ticket)
+}
+
+func (h *UserHandler) SendEmailLoginCode(ctx *gin.Context, req SendEmailCodeReq) (ginx.Result, error) {
+	ok, err := h.emailRexExp.MatchString(req.Email)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "邮箱格式错误"}, nil
+	}
+	return h.sendCode(ctx, bizLoginEmail, req.Email, req.Ticket)
+}
 
+// sendCode 短信和邮件验证码共用，需要人机验证的时候先检查票据
+func (h *UserHandler) sendCode(ctx *gin.Context, biz, target, ticket string) (ginx.Result, error) {
+	res, ok, err := h.checkCaptcha(ctx, biz, ticket)
+	if !ok {
+		return res, err
+	}
+	err = h.codeSvc.Send(ctx, biz, target, ctx.ClientIP())
 	switch {
 	case err == nil:
 		return ginx.Result{Msg: "发送成功"}, nil
 	case errors.Is(err, service.ErrCodeSendTooMany):
-		return ginx.Result{Code: 4, Msg: "短信发送太频繁，请稍后再试"}, nil
+		h.captchaSvc.MarkSuspicious(ctx, biz, ctx.ClientIP())
+		return ginx.Result{Code: 4, Msg: "验证码发送太频繁，请稍后再试"}, nil
+	case errors.Is(err, service.ErrCodeQuotaExceeded):
+		h.captchaSvc.MarkSuspicious(ctx, biz, ctx.ClientIP())
+		return ginx.Result{Code: 4, Msg: "验证码发送次数超过限制，请稍后再试"}, nil
 	default:
 		return ginx.Result{Msg: "系统错误", Code: 5}, err
 	}
 }
 
+// checkCaptcha 需要人机验证的时候检查票据，ok 为 false 的时候直接返回 res
+func (h *UserHandler) checkCaptcha(ctx *gin.Context, biz, ticket string) (ginx.Result, bool, error) {
+	need, err := h.captchaSvc.Required(ctx, biz, ctx.ClientIP())
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, false, err
+	}
+	if !need {
+		return ginx.Result{}, true, nil
+	}
+	ok, err := h.captchaSvc.CheckTicket(ctx, biz, ticket)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, false, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "请先完成人机验证",
+			Data: CaptchaRequiredVo{Captcha: true, Biz: biz}}, false, nil
+	}
+	return ginx.Result{}, true, nil
+}
+
 func (h *UserHandler) LoginSMS(ctx *gin.Context, req LoginSMSReq) (ginx.Result, error) {
 
-	ok, err := h.codeSvc.Verify(ctx, bizLogin, req.Phone, req.Code)
+	ok, detail, err := h.verifyCode(ctx, bizLogin, req.Phone, req.Code)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		recordEvent(ctx, h.events, domain.SecurityEvent{Type: domain.SecurityEventLoginFailed,
+			Method: domain.LoginMethodSMS, Account: req.Phone, Detail: detail})
+		return ginx.Result{Code: 4, Msg: "验证失败"}, nil
+	}
+
+	u, created, err := h.svc.FindOrCreate(ctx, req.Phone)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if created {
+		recordEvent(ctx, h.events, domain.SecurityEvent{Uid: u.Id, Type: domain.SecurityEventSignup,
+			Method: domain.LoginMethodSMS, Account: req.Phone})
+	}
+	return h.flow.finish(ctx, domain.SecurityEvent{Uid: u.Id,
+		Method: domain.LoginMethodSMS, Account: req.Phone})
+}
+
+func (h *UserHandler) LoginEmail(ctx *gin.Context, req LoginEmailReq) (ginx.Result, error) {
+	ok, detail, err := h.verifyCode(ctx, bizLoginEmail, req.Email, req.Code)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		recordEvent(ctx, h.events, domain.SecurityEvent{Type: domain.SecurityEventLoginFailed,
+			Method: domain.LoginMethodEmail, Account: req.Email, Detail: detail})
+		return ginx.Result{Code: 4, Msg: "验证失败"}, nil
+	}
+
+	u, created, err := h.svc.FindOrCreateByEmail(ctx, req.Email)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if created {
+		recordEvent(ctx, h.events, domain.SecurityEvent{Uid: u.Id, Type: domain.SecurityEventSignup,
+			Method: domain.LoginMethodEmail, Account: req.Email})
+	}
+	if !u.EmailVerified {
+		// 用密码注册的账号第一次证明邮箱是自己的，之前用密码登录的会话可能是抢注的人的。
+		// 先踢掉会话再作废密码，中间失败了重试的时候还会再来一遍
+		err = h.ClearUserSessions(ctx, u.Id)
+		if err != nil {
+			return ginx.Result{Msg: "系统错误", Code: 5}, err
+		}
+		err = h.svc.VerifyEmail(ctx, u.Id)
+		if err != nil {
+			return ginx.Result{Msg: "系统错误", Code: 5}, err
+		}
+	}
+	return h.flow.finish(ctx, domain.SecurityEvent{Uid: u.Id,
+		Method: domain.LoginMethodEmail, Account: req.Email})
+}
+
+// accountTarget 邮箱和手机号二选一，两种渠道用不同的 biz
+func (h *UserHandler) accountTarget(email, phone, emailBiz, smsBiz string) (biz, target string, ok bool, err error) {
+	if email != "" {
+		ok, err = h.emailRexExp.MatchString(email)
+		return emailBiz, email, ok, err
+	}
+	ok, err = h.phoneRexExp.MatchString(phone)
+	return smsBiz, phone, ok, err
+}
+
+// verifyCode 验证次数太多对用户来说也是验证失败，detail 是记录安全事件用的失败原因
+func (h *UserHandler) verifyCode(ctx *gin.Context, biz, target, code string) (ok bool, detail string, err error) {
+	ok, err = h.codeSvc.Verify(ctx, biz, target, code)
+	switch {
+	case errors.Is(err, service.ErrCodeVerifyTooMany):
+		return false, "too_many_attempts", nil
+	case err != nil:
+		return false, "", err
+	case !ok:
+		return false, "bad_code", nil
+	default:
+		return true, "", nil
+	}
+}
+
+func (h *UserHandler) SendResetPasswordCode(ctx *gin.Context, req SendResetPwdCodeReq) (ginx.Result, error) {
+	biz, target, ok, err := h.accountTarget(req.Email, req.Phone, bizResetPwdEmail, bizResetPwdSMS)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "邮箱或者手机号格式错误"}, nil
+	}
+	// 人机验证要在查账号之前，不然只有存在的账号才要求验证
+	res, ok, err := h.checkCaptcha(ctx, biz, req.Ticket)
+	if !ok {
+		return res, err
+	}
+	// 账号不存在、发送太频繁、超过限额都返回一样的结果，不能让人用这个接口探测哪些账号注册过
+	sent := ginx.Result{Msg: "发送成功"}
+	_, err = h.svc.FindByAccount(ctx, req.Email, req.Phone)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrRecordNotFound):
+		h.captchaSvc.MarkSuspicious(ctx, biz, ctx.ClientIP())
+		return sent, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	err = h.codeSvc.Send(ctx, biz, target, ctx.ClientIP())
+	switch {
+	case err == nil:
+		return sent, nil
+	case errors.Is(err, service.ErrCodeSendTooMany), errors.Is(err, service.ErrCodeQuotaExceeded):
+		h.captchaSvc.MarkSuspicious(ctx, biz, ctx.ClientIP())
+		return sent, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+}
+
+func (h *UserHandler) ResetPassword(ctx *gin.Context, req ResetPwdReq) (ginx.Result, error) {
+	biz, target, ok, err := h.accountTarget(req.Email, req.Phone, bizResetPwdEmail, bizResetPwdSMS)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "邮箱或者手机号格式错误"}, nil
+	}
+	if req.Password != req.ConfirmPassword {
+		return ginx.Result{Code: 4, Msg: "两次密码不一致"}, nil
+	}
+	ok, err = h.passwordRexExp.MatchString(req.Password)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "密码格式错误"}, nil
+	}
+
+	ok, _, err = h.verifyCode(ctx, biz, target, req.Code)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "验证失败"}, nil
+	}
+	u, err := h.svc.FindByAccount(ctx, req.Email, req.Phone)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrRecordNotFound):
+		return ginx.Result{Code: 4, Msg: "验证失败"}, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	err = h.svc.ResetPassword(ctx, u.Id, req.Password)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	recordEvent(ctx, h.events, domain.SecurityEvent{Uid: u.Id, Type: domain.SecurityEventPasswordReset,
+		Account: target})
+	// 旧密码可能已经泄露，之前的登录态都要失效。
+	// 密码已经改了，清理失败也不能告诉用户重置失败，不然用户会用新密码再重置一次
+	if er := h.ClearUserSessions(ctx, u.Id); er != nil {
+		h.log.Error("重置密码之后清理登录态失败", logger.Int64("uid", u.Id), logger.Error(er))
+	}
+	return ginx.Result{Msg: "密码已重置，请重新登录"}, nil
+}
+
+func (h *UserHandler) ChangePassword(ctx *gin.Context, req ChangePwdReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	if req.Password != req.ConfirmPassword {
+		return ginx.Result{Code: 4, Msg: "两次密码不一致"}, nil
+	}
+	ok, err := h.passwordRexExp.MatchString(req.Password)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "密码格式错误"}, nil
+	}
+	err = h.svc.ChangePassword(ctx, uc.Uid, req.OldPassword, req.Password)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrInvalidUserOrPassword):
+		return ginx.Result{Code: 4, Msg: "原密码不对"}, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	// 其它设备上的登录态都失效，当前设备重新登录
+	err = h.ClearUserSessions(ctx, uc.Uid)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	ssid, err := h.SetLoginToken(ctx, uc.Uid)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	recordEvent(ctx, h.events, domain.SecurityEvent{Uid: uc.Uid, Type: domain.SecurityEventPasswordChange, Ssid: ssid})
+	return ginx.Result{Msg: "修改成功"}, nil
+}
+
+func (h *UserHandler) SendBindEmailCode(ctx *gin.Context, req SendEmailCodeReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	ok, err := h.emailRexExp.MatchString(req.Email)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "邮箱格式错误"}, nil
+	}
+	res, ok, err := h.checkAccountFree(ctx, req.Email, "", uc.Uid)
+	if !ok {
+		return res, err
+	}
+	return h.sendCode(ctx, bizBindEmail, req.Email, req.Ticket)
+}
+
+func (h *UserHandler) BindEmail(ctx *gin.Context, req BindEmailReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	ok, _, err := h.verifyCode(ctx, bizBindEmail, req.Email, req.Code)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "验证失败"}, nil
+	}
+	err = h.svc.BindEmail(ctx, uc.Uid, req.Email)
+	switch {
+	case err == nil:
+		return ginx.Result{Msg: "绑定成功"}, nil
+	case errors.Is(err, service.ErrDuplicate):
+		return ginx.Result{Code: 4, Msg: "邮箱已经被其他账号使用"}, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+}
+
+func (h *UserHandler) SendBindPhoneCode(ctx *gin.Context, req SendSMSReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	ok, err := h.phoneRexExp.MatchString(req.Phone)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "手机格式不正确"}, nil
+	}
+	res, ok, err := h.checkAccountFree(ctx, "", req.Phone, uc.Uid)
+	if !ok {
+		return res, err
+	}
+	return h.sendCode(ctx, bizBindPhone, req.Phone, req.Ticket)
+}
+
+func (h *UserHandler) BindPhone(ctx *gin.Context, req BindPhoneReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	ok, _, err := h.verifyCode(ctx, bizBindPhone, req.Phone, req.Code)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "验证失败"}, nil
+	}
+	err = h.svc.BindPhone(ctx, uc.Uid, req.Phone)
+	switch {
+	case err == nil:
+		return ginx.Result{Msg: "绑定成功"}, nil
+	case errors.Is(err, service.ErrDuplicatePhone):
+		return ginx.Result{Code: 4, Msg: "手机号已经被其他账号使用"}, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+}
+
+// checkAccountFree 发验证码之前先检查新的邮箱或者手机号有没有被占用，
+// 真正绑定的时候还是靠唯一索引兜底
+func (h *UserHandler) checkAccountFree(ctx *gin.Context, email, phone string, uid int64) (ginx.Result, bool, error) {
+	u, err := h.svc.FindByAccount(ctx, email, phone)
+	switch {
+	case errors.Is(err, service.ErrRecordNotFound):
+		return ginx.Result{}, true, nil
+	case err != nil:
+		return ginx.Result{Msg: "系统错误", Code: 5}, false, err
+	case u.Id == uid:
+		return ginx.Result{Code: 4, Msg: "已经绑定了，不需要修改"}, false, nil
+	default:
+		return ginx.Result{Code: 4, Msg: "已经被其他账号使用"}, false, nil
+	}
+}
+
+func (h *UserHandler) Identities(ctx *gin.Context, uc ijwt.TokenClaims) (ginx.Result, error) {
+	ids, err := h.svc.Identities(ctx, uc.Uid)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	res := make([]IdentityVo, 0, len(ids))
+	for _, id := range ids {
+		vo := IdentityVo{Type: string(id.Type), Provider: id.Provider}
+		// openid 对用户没有意义
+		if id.Type != domain.IdentityWechat {
+			vo.Value = id.Value
+		}
+		res = append(res, vo)
+	}
+	return ginx.Result{Data: res}, nil
+}
+
+func (h *UserHandler) Unlink(ctx *gin.Context, req UnlinkReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	var err error
+	typ := domain.IdentityType(req.Type)
+	switch typ {
+	case domain.IdentityEmail, domain.IdentityPhone, domain.IdentityWechat:
+		err = h.svc.Unlink(ctx, uc.Uid, typ)
+	case domain.IdentityOAuth2:
+		err = h.svc.UnlinkOAuth2(ctx, uc.Uid, req.Provider, req.Value)
+	default:
+		return ginx.Result{Code: 4, Msg: "未知的登录方式"}, nil
+	}
+	switch {
+	case err == nil:
+		return ginx.Result{Msg: "解绑成功"}, nil
+	case errors.Is(err, service.ErrLastIdentity):
+		return ginx.Result{Code: 4, Msg: "至少要保留一种登录方式"}, nil
+	case errors.Is(err, service.ErrRecordNotFound):
+		return ginx.Result{Code: 4, Msg: "没有绑定这个账号"}, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+}
+
+func (h *UserHandler) SendMergeCode(ctx *gin.Context, req SendMergeCodeReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	biz, target, ok, err := h.accountTarget(req.Email, req.Phone, bizMergeEmail, bizMergeSMS)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "邮箱或者手机号格式错误"}, nil
+	}
+	_, res, ok, err := h.findMergeSource(ctx, req.Email, req.Phone, uc.Uid)
+	if !ok {
+		return res, err
+	}
+	return h.sendCode(ctx, biz, target, req.Ticket)
+}
+
+func (h *UserHandler) Merge(ctx *gin.Context, req MergeReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	biz, target, ok, err := h.accountTarget(req.Email, req.Phone, bizMergeEmail, bizMergeSMS)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	if !ok {
+		return ginx.Result{Code: 4, Msg: "邮箱或者手机号格式错误"}, nil
+	}
+	ok, _, err = h.verifyCode(ctx, biz, target, req.Code)
 	if err != nil {
 		return ginx.Result{Msg: "系统错误", Code: 5}, err
 	}
 	if !ok {
 		return ginx.Result{Code: 4, Msg: "验证失败"}, nil
 	}
+	sourceUid, res, ok, err := h.findMergeSource(ctx, req.Email, req.Phone, uc.Uid)
+	if !ok {
+		return res, err
+	}
+	err = h.svc.Merge(ctx, uc.Uid, sourceUid)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrMergeConflict):
+		return ginx.Result{Code: 4, Msg: "两个账号绑定了不同的邮箱或者手机号，请先解绑"}, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	// 被合并的账号已经没有登录方式了，之前的登录态也要失效
+	err = h.ClearUserSessions(ctx, sourceUid)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	return ginx.Result{Msg: "合并成功"}, nil
+}
+
+// findMergeSource 找到要被合并的账号，找不到的时候返回给前端的结果
+func (h *UserHandler) findMergeSource(ctx *gin.Context, email, phone string, uid int64) (int64, ginx.Result, bool, error) {
+	u, err := h.svc.FindByAccount(ctx, email, phone)
+	switch {
+	case errors.Is(err, service.ErrRecordNotFound):
+		return 0, ginx.Result{Code: 4, Msg: "账号不存在"}, false, nil
+	case err != nil:
+		return 0, ginx.Result{Msg: "系统错误", Code: 5}, false, err
+	case u.Id == uid:
+		return 0, ginx.Result{Code: 4, Msg: "这是当前登录的账号"}, false, nil
+	default:
+		return u.Id, ginx.Result{}, true, nil
+	}
+}
 
-	u, err := h.svc.FindOrCreate(ctx, req.Phone)
+func (h *UserHandler) sessions(ctx *gin.Context, uc ijwt.TokenClaims) (ginx.Result, error) {
+	sessions, err := h.ListSessions(ctx, uc.Uid)
 	if err != nil {
 		return ginx.Result{Msg: "系统错误", Code: 5}, err
 	}
-	// 设置登录态
-	err = h.SetLoginToken(ctx, u.Id)
+	res := make([]SessionVo, 0, len(sessions))
+	for _, s := range sessions {
+		res = append(res, SessionVo{
+			Ssid:      s.Ssid,
+			UserAgent: s.UserAgent,
+			IP:        s.IP,
+			Ctime:     s.Ctime.Format(time.DateTime),
+			LastSeen:  s.LastSeen.Format(time.DateTime),
+			Current:   s.Ssid == uc.Ssid,
+		})
+	}
+	return ginx.Result{Data: res}, nil
+}
+
+func (h *UserHandler) revokeSession(ctx *gin.Context, req RevokeSessionReq, uc ijwt.TokenClaims) (ginx.Result, error) {
+	err := h.RevokeSession(ctx, uc.Uid, req.Ssid)
+	switch {
+	case err == nil:
+		// Ssid 是被踢掉的会话，Detail 记下是哪个会话操作的
+		recordEvent(ctx, h.events, domain.SecurityEvent{Uid: uc.Uid, Type: domain.SecurityEventSessionRevoke,
+			Ssid: req.Ssid, Detail: "by " + uc.Ssid})
+		return ginx.Result{Msg: "已退出该设备"}, nil
+	case errors.Is(err, ijwt.ErrSessionNotFound):
+		return ginx.Result{Code: 4, Msg: "会话不存在"}, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+}
+
+func (h *UserHandler) revokeOtherSessions(ctx *gin.Context, uc ijwt.TokenClaims) (ginx.Result, error) {
+	err := h.RevokeOtherSessions(ctx, uc.Uid, uc.Ssid)
 	if err != nil {
 		return ginx.Result{Msg: "系统错误", Code: 5}, err
 	}
-	return ginx.Result{Msg: "登录成功"}, nil
+	recordEvent(ctx, h.events, domain.SecurityEvent{Uid: uc.Uid, Type: domain.SecurityEventSessionRevoke,
+		Ssid: uc.Ssid, Detail: "others"})
+	return ginx.Result{Msg: "已退出其它设备"}, nil
 }
 
-func (h *UserHandler) logout(ctx *gin.Context) (ginx.Result, error) {
+func (h *UserHandler) logout(ctx *gin.Context, uc ijwt.TokenClaims) (ginx.Result, error) {
 	err := h.ClearToken(ctx)
 	if err != nil {
 		return ginx.Result{Msg: "系统错误", Code: 5}, err
 	}
+	recordEvent(ctx, h.events, domain.SecurityEvent{Uid: uc.Uid, Type: domain.SecurityEventLogout, Ssid: uc.Ssid})
 	return ginx.Result{Msg: "登出成功"}, nil
 }
@@ -13,6 +13,7 @@ import (
 	"webok/internal/service"
 	svcmocks "webok/internal/service/mock"
 	ijwt "webok/internal/web/jwt"
+	jwtmocks "webok/internal/web/jwt/mock"
 	"webok/pkg/logger"
 )
 
@@ -205,7 +206,11 @@ func TestUserHandler_SignUp(t *testing.T) {
 			defer ctrl.Finish()
 
 			userSvc, codeSvc, jwtHdl := tc.mock(ctrl)
-			hdl := NewUserHandler(userSvc, codeSvc, jwtHdl, l)
+			events := svcmocks.NewMockSecurityEventService(ctrl)
+			events.EXPECT().Record(gomock.Any(), gomock.Any()).AnyTimes()
+			hdl := NewUserHandler(userSvc, codeSvc, svcmocks.NewMockCaptchaService(ctrl),
+				svcmocks.NewMockTwoFactorService(ctrl), svcmocks.NewMockLoginGuardService(ctrl),
+				events, jwtHdl, l)
 
 			server := gin.Default()
 			hdl.RegisterRoutes(server)
@@ -219,3 +224,114 @@ func TestUserHandler_SignUp(t *testing.T) {
 		})
 	}
 }
+
+func TestUserHandler_SendResetPasswordCode(t *testing.T) {
+	sent := `{"code":0,"msg":"发送成功","data":null}`
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService)
+		wantBody string
+	}{
+		{
+			name: "账号存在",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), bizResetPwdEmail, gomock.Any()).Return(false, nil)
+				userSvc.EXPECT().FindByAccount(gomock.Any(), "123@qq.com", "").Return(&domain.User{Id: 1}, nil)
+				codeSvc.EXPECT().Send(gomock.Any(), bizResetPwdEmail, "123@qq.com", gomock.Any()).Return(nil)
+				return userSvc, codeSvc, captchaSvc
+			},
+			wantBody: sent,
+		},
+		{
+			name: "账号不存在，结果和发送成功一样",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), bizResetPwdEmail, gomock.Any()).Return(false, nil)
+				userSvc.EXPECT().FindByAccount(gomock.Any(), "123@qq.com", "").Return(nil, service.ErrRecordNotFound)
+				captchaSvc.EXPECT().MarkSuspicious(gomock.Any(), bizResetPwdEmail, gomock.Any())
+				return userSvc, svcmocks.NewMockCodeService(ctrl), captchaSvc
+			},
+			wantBody: sent,
+		},
+		{
+			name: "发送太频繁，结果和发送成功一样",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				codeSvc := svcmocks.NewMockCodeService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), bizResetPwdEmail, gomock.Any()).Return(false, nil)
+				userSvc.EXPECT().FindByAccount(gomock.Any(), "123@qq.com", "").Return(&domain.User{Id: 1}, nil)
+				codeSvc.EXPECT().Send(gomock.Any(), bizResetPwdEmail, "123@qq.com", gomock.Any()).
+					Return(service.ErrCodeSendTooMany)
+				captchaSvc.EXPECT().MarkSuspicious(gomock.Any(), bizResetPwdEmail, gomock.Any())
+				return userSvc, codeSvc, captchaSvc
+			},
+			wantBody: sent,
+		},
+		{
+			name: "先检查人机验证，不查账号",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService) {
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), bizResetPwdEmail, gomock.Any()).Return(true, nil)
+				captchaSvc.EXPECT().CheckTicket(gomock.Any(), bizResetPwdEmail, "").Return(false, nil)
+				return svcmocks.NewMockUserService(ctrl), svcmocks.NewMockCodeService(ctrl), captchaSvc
+			},
+			wantBody: `{"code":4,"msg":"请先完成人机验证","data":{"captcha":true,"biz":"reset_pwd_email"}}`,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, service.CaptchaService) {
+				userSvc := svcmocks.NewMockUserService(ctrl)
+				captchaSvc := svcmocks.NewMockCaptchaService(ctrl)
+				captchaSvc.EXPECT().Required(gomock.Any(), bizResetPwdEmail, gomock.Any()).Return(false, nil)
+				userSvc.EXPECT().FindByAccount(gomock.Any(), "123@qq.com", "").Return(nil, errors.New("db error"))
+				return userSvc, svcmocks.NewMockCodeService(ctrl), captchaSvc
+			},
+			wantBody: `{"code":5,"msg":"系统错误","data":null}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			userSvc, codeSvc, captchaSvc := tc.mock(ctrl)
+			server := gin.New()
+			NewUserHandler(userSvc, codeSvc, captchaSvc, svcmocks.NewMockTwoFactorService(ctrl),
+				svcmocks.NewMockLoginGuardService(ctrl), svcmocks.NewMockSecurityEventService(ctrl), nil,
+				logger.NewNopLogger()).RegisterRoutes(server)
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, jsonRequest(t, "/users/password/reset/code/send", `{"email":"123@qq.com"}`))
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, tc.wantBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestUserHandler_ResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	userSvc := svcmocks.NewMockUserService(ctrl)
+	codeSvc := svcmocks.NewMockCodeService(ctrl)
+	jwtHdl := jwtmocks.NewMockHandler(ctrl)
+	events := svcmocks.NewMockSecurityEventService(ctrl)
+	codeSvc.EXPECT().Verify(gomock.Any(), bizResetPwdEmail, "123@qq.com", "123456").Return(true, nil)
+	userSvc.EXPECT().FindByAccount(gomock.Any(), "123@qq.com", "").Return(&domain.User{Id: 1}, nil)
+	userSvc.EXPECT().ResetPassword(gomock.Any(), int64(1), "pass@1234").Return(nil)
+	events.EXPECT().Record(gomock.Any(), gomock.Any())
+	// 密码已经改了，清理登录态失败也要告诉用户重置成功
+	jwtHdl.EXPECT().ClearUserSessions(gomock.Any(), int64(1)).Return(errors.New("redis error"))
+	server := gin.New()
+	NewUserHandler(userSvc, codeSvc, svcmocks.NewMockCaptchaService(ctrl), svcmocks.NewMockTwoFactorService(ctrl),
+		svcmocks.NewMockLoginGuardService(ctrl), events, jwtHdl, logger.NewNopLogger()).RegisterRoutes(server)
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, jsonRequest(t, "/users/password/reset",
+		`{"email":"123@qq.com","code":"123456","password":"pass@1234","confirmPassword":"pass@1234"}`))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `{"code":0,"msg":"密码已重置，请重新登录","data":null}`, recorder.Body.String())
+}
@@ -3,6 +3,8 @@ package web
 type LoginJwtReq struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Ticket 失败次数多了之后要先通过人机验证
+	Ticket string `json:"ticket"`
 }
 
 type SignUpReq struct {
@@ -19,9 +21,94 @@ type EditReq struct {
 
 type SendSMSReq struct {
 	Phone string `json:"phone"`
+	// Ticket 通过人机验证之后拿到的票据，需要验证的时候才检查
+	Ticket string `json:"ticket"`
 }
 
 type LoginSMSReq struct {
 	Phone string `json:"phone"`
 	Code  string `json:"code"`
 }
+
+type SendEmailCodeReq struct {
+	Email  string `json:"email"`
+	Ticket string `json:"ticket"`
+}
+
+type LoginEmailReq struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+// SendResetPwdCodeReq Email 和 Phone 二选一，都有的时候用 Email
+type SendResetPwdCodeReq struct {
+	Email  string `json:"email"`
+	Phone  string `json:"phone"`
+	Ticket string `json:"ticket"`
+}
+
+type ResetPwdReq struct {
+	Email           string `json:"email"`
+	Phone           string `json:"phone"`
+	Code            string `json:"code"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirmPassword"`
+}
+
+type ChangePwdReq struct {
+	OldPassword     string `json:"oldPassword"`
+	Password        string `json:"password"`
+	ConfirmPassword string `json:"confirmPassword"`
+}
+
+// BindEmailReq Code 是发到新邮箱的验证码
+type BindEmailReq struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+type BindPhoneReq struct {
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+}
+
+type IdentityVo struct {
+	Type     string `json:"type"`
+	Provider string `json:"provider,omitempty"`
+	Value    string `json:"value"`
+}
+
+// UnlinkReq Type 可选 email, phone, wechat, oauth2，
+// oauth2 要带上 Provider 和 Value，也就是 /users/identities 返回的值
+type UnlinkReq struct {
+	Type     string `json:"type"`
+	Provider string `json:"provider"`
+	Value    string `json:"value"`
+}
+
+// SendMergeCodeReq Email 和 Phone 是要被合并的账号的，二选一
+type SendMergeCodeReq struct {
+	Email  string `json:"email"`
+	Phone  string `json:"phone"`
+	Ticket string `json:"ticket"`
+}
+
+type MergeReq struct {
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+	Code  string `json:"code"`
+}
+
+type SessionVo struct {
+	Ssid      string `json:"ssid"`
+	UserAgent string `json:"userAgent"`
+	IP        string `json:"ip"`
+	Ctime     string `json:"ctime"`
+	LastSeen  string `json:"lastSeen"`
+	// Current 是不是当前请求用的会话
+	Current bool `json:"current"`
+}
+
+type RevokeSessionReq struct {
+	Ssid string `json:"ssid"`
+}
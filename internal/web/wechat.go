@@ -1,32 +1,42 @@
 package web
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
-	longUUID "github.com/google/uuid"
 	uuid "github.com/lithammer/shortuuid/v4"
+	"time"
+	"webok/internal/domain"
 	"webok/internal/service"
-	"webok/internal/service/outh2/wechat"
+	"webok/internal/service/outh2"
 	ijwt "webok/internal/web/jwt"
 	"webok/pkg/ginx"
+	"webok/pkg/jwtx"
 	"webok/pkg/logger"
 )
 
 type OAuth2WechatHandler struct {
 	ijwt.Handler
-	svc             wechat.Service
+	svc             outh2.Provider
 	userSvc         service.UserService
-	key             []byte
+	keys            *jwtx.KeySet
+	events          service.SecurityEventService
+	flow            loginFlow
 	stateCookieName string
 	log             logger.Logger
 }
 
-func NewOAuth2WechatHandler(svc wechat.Service, userSvc service.UserService, jwt ijwt.Handler, l logger.Logger) *OAuth2WechatHandler {
+// NewOAuth2WechatHandler 微信除了登录还支持绑定和合并，所以单独处理，svc 是微信的 Provider
+func NewOAuth2WechatHandler(svc outh2.Provider, userSvc service.UserService, twoFactorSvc service.TwoFactorService,
+	loginGuard service.LoginGuardService, jwt ijwt.Handler, keys *jwtx.KeySet, events service.SecurityEventService,
+	l logger.Logger) *OAuth2WechatHandler {
 	return &OAuth2WechatHandler{
 		svc:             svc,
 		userSvc:         userSvc,
-		key:             []byte("k6CswdUm77WKcbM68UQUuxVsHSpTCwgB"),
+		keys:            keys,
+		events:          events,
+		flow:            newLoginFlow(jwt, twoFactorSvc, loginGuard, events, l),
 		stateCookieName: "jwt-state",
 		Handler:         jwt,
 		log:             l,
@@ -36,16 +46,33 @@ func NewOAuth2WechatHandler(svc wechat.Service, userSvc service.UserService, jwt
 func (o *OAuth2WechatHandler) RegisterRoutes(server *gin.Engine) {
 	g := server.Group("/oauth2/wechat")
 	g.GET("/authurl", ginx.Warp(o.Auth2URL))
+	// 已经登录的用户绑定微信，或者合并一个只绑定了微信的账号
+	g.GET("/link/authurl", ginx.WarpClaims[ijwt.TokenClaims](o.LinkAuth2URL))
+	g.GET("/merge/authurl", ginx.WarpClaims[ijwt.TokenClaims](o.MergeAuth2URL))
 	g.Any("/callback", ginx.Warp(o.Callback)) // 不知道微信到底会返回一个什么请求
 }
 
 func (o *OAuth2WechatHandler) Auth2URL(ctx *gin.Context) (ginx.Result, error) {
-	val, err := o.svc.AuthURL(ctx)
+	return o.authURL(ctx, StateClaims{})
+}
+
+func (o *OAuth2WechatHandler) LinkAuth2URL(ctx *gin.Context, uc ijwt.TokenClaims) (ginx.Result, error) {
+	return o.authURL(ctx, StateClaims{Uid: uc.Uid, Action: stateActionLink})
+}
+
+func (o *OAuth2WechatHandler) MergeAuth2URL(ctx *gin.Context, uc ijwt.TokenClaims) (ginx.Result, error) {
+	return o.authURL(ctx, StateClaims{Uid: uc.Uid, Action: stateActionMerge})
+}
+
+// authURL state 同时放在跳转链接和 cookie 里面，回调的时候两边要一致。
+// 绑定和合并的时候回调没有登录态，当前用户也记在 state cookie 里面
+func (o *OAuth2WechatHandler) authURL(ctx *gin.Context, sc StateClaims) (ginx.Result, error) {
+	sc.State = uuid.New()
+	val, err := o.svc.AuthURL(ctx, outh2.AuthParams{State: sc.State})
 	if err != nil {
 		return ginx.Result{Msg: "系统错误", Code: 5}, err
 	}
-	state := uuid.New()
-	err = o.setStateCookie(ctx, state)
+	err = o.setStateCookie(ctx, sc)
 	if err != nil {
 		return ginx.Result{Msg: "系统错误", Code: 5}, err
 	}
@@ -53,65 +80,130 @@ func (o *OAuth2WechatHandler) Auth2URL(ctx *gin.Context) (ginx.Result, error) {
 }
 
 func (o *OAuth2WechatHandler) Callback(ctx *gin.Context) (ginx.Result, error) {
-	err := o.verifyState(ctx)
+	sc, err := o.verifyState(ctx)
 	if err != nil {
 		return ginx.Result{Msg: "非法请求", Code: 4}, err
 	}
 	// 你校验不校验都可以
 	code := ctx.Query("code")
 	// state := ctx.Query("state")
-	wechatInfo, err := o.svc.VerifyCode(ctx, code)
+	wechatInfo, err := o.verifyCode(ctx, code, sc.State)
 	if err != nil {
+		recordEvent(ctx, o.events, domain.SecurityEvent{Uid: sc.Uid, Type: domain.SecurityEventLoginFailed,
+			Method: domain.LoginMethodWechat, Detail: "bad_code"})
 		return ginx.Result{Msg: "授权码有误", Code: 4}, err
 	}
-	u, err := o.userSvc.FindOrCreateByWechat(ctx, wechatInfo)
+	switch sc.Action {
+	case stateActionLink:
+		return o.link(ctx, sc.Uid, wechatInfo)
+	case stateActionMerge:
+		return o.merge(ctx, sc.Uid, wechatInfo)
+	}
+	u, created, err := o.userSvc.FindOrCreateByWechat(ctx, wechatInfo)
 	if err != nil {
 		return ginx.Result{Msg: "系统错误", Code: 5}, err
 	}
-	// 设置登录态
-	ssid := longUUID.New().String()
-	if err := o.SetAccessToken(ctx, u.Id, ssid); err != nil {
-		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	if created {
+		recordEvent(ctx, o.events, domain.SecurityEvent{Uid: u.Id, Type: domain.SecurityEventSignup,
+			Method: domain.LoginMethodWechat})
 	}
-	return ginx.Result{Msg: "Ok"}, nil
+	return o.flow.finish(ctx, domain.SecurityEvent{Uid: u.Id, Method: domain.LoginMethodWechat})
+}
 
+func (o *OAuth2WechatHandler) verifyCode(ctx *gin.Context, code, state string) (domain.WechatInfo, error) {
+	tok, err := o.svc.Exchange(ctx, code, outh2.AuthParams{State: state})
+	if err != nil {
+		return domain.WechatInfo{}, err
+	}
+	info, err := o.svc.UserInfo(ctx, tok)
+	if err != nil {
+		return domain.WechatInfo{}, err
+	}
+	return domain.WechatInfo{OpenId: info.Subject, UnionId: info.UnionId}, nil
 }
-func (o *OAuth2WechatHandler) setStateCookie(ctx *gin.Context, state string) error {
-	claims := StateClaims{
-		State: state,
+
+func (o *OAuth2WechatHandler) link(ctx *gin.Context, uid int64, info domain.WechatInfo) (ginx.Result, error) {
+	err := o.userSvc.LinkWechat(ctx, uid, info)
+	switch {
+	case err == nil:
+		return ginx.Result{Msg: "绑定成功"}, nil
+	case errors.Is(err, service.ErrDuplicateWechat):
+		return ginx.Result{Code: 4, Msg: "这个微信已经绑定了其他账号，可以合并账号"}, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	tokenStr, err := token.SignedString(o.key)
+}
+
+// merge 能走到这里说明用户能登录这个微信，也就证明了微信对应的账号是他的。
+// 这个微信还没有账号的时候没有东西可以合并，直接绑定到当前账号
+func (o *OAuth2WechatHandler) merge(ctx *gin.Context, uid int64, info domain.WechatInfo) (ginx.Result, error) {
+	source, err := o.userSvc.FindByWechat(ctx, info.OpenId)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrRecordNotFound):
+		return o.link(ctx, uid, info)
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	err = o.userSvc.Merge(ctx, uid, source.Id)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrMergeSelf):
+		return ginx.Result{Code: 4, Msg: "这个微信已经绑定了当前账号"}, nil
+	case errors.Is(err, service.ErrMergeConflict):
+		return ginx.Result{Code: 4, Msg: "两个账号绑定了不同的邮箱，手机号或者微信，请先解绑"}, nil
+	default:
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	err = o.ClearUserSessions(ctx, source.Id)
+	if err != nil {
+		return ginx.Result{Msg: "系统错误", Code: 5}, err
+	}
+	return ginx.Result{Msg: "合并成功"}, nil
+}
+
+func (o *OAuth2WechatHandler) setStateCookie(ctx *gin.Context, claims StateClaims) error {
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(stateTTL))
+	tokenStr, err := o.keys.Sign(typWechatState, claims)
 	if err != nil {
 		return err
 	}
 	ctx.SetCookie(o.stateCookieName, tokenStr,
-		600, "/oauth2/wechat/callback",
+		int(stateTTL.Seconds()), "/oauth2/wechat/callback",
 		"", false, true)
 	return nil
 }
 
+const (
+	stateActionLink  = "link"
+	stateActionMerge = "merge"
+	stateTTL         = time.Minute * 10
+	// typWechatState state cookie 和登录用的 token 是同一套密钥，用 typ 区分
+	typWechatState = "wechat-state+jwt"
+)
+
 type StateClaims struct {
 	jwt.RegisteredClaims
 	State string
+	// Uid 和 Action 只有绑定和合并的时候才有，登录的时候为空
+	Uid    int64
+	Action string
 }
 
-func (o *OAuth2WechatHandler) verifyState(ctx *gin.Context) error {
+func (o *OAuth2WechatHandler) verifyState(ctx *gin.Context) (StateClaims, error) {
 	state := ctx.Query("state")
+	var sc StateClaims
 	ck, err := ctx.Cookie(o.stateCookieName)
 	if err != nil {
-		return fmt.Errorf("无法获得 cookie %w", err)
+		return sc, fmt.Errorf("无法获得 cookie %w", err)
 	}
-	var sc StateClaims
-	_, err = jwt.ParseWithClaims(ck, &sc, func(token *jwt.Token) (interface{}, error) {
-		return o.key, nil
-	})
+	_, err = o.keys.Parse(ck, typWechatState, &sc)
 	if err != nil {
-		return fmt.Errorf("解析 token 失败 %w", err)
+		return sc, fmt.Errorf("解析 token 失败 %w", err)
 	}
-	if state != sc.State {
+	if sc.State == "" || state != sc.State {
 		// state 不匹配，有人搞你
-		return fmt.Errorf("state 不匹配")
+		return sc, fmt.Errorf("state 不匹配")
 	}
-	return nil
+	return sc, nil
 }
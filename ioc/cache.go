@@ -0,0 +1,39 @@
+package ioc
+
+import (
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"time"
+	"webok/pkg/cachex"
+	"webok/pkg/logger"
+)
+
+// InitInvalidator 本地缓存的失效通知，mode 为 local 的时候只清理本实例，适合单机部署
+func InitInvalidator(cmd redis.Cmdable, l logger.Logger) cachex.Invalidator {
+	type Config struct {
+		Mode        string        `yaml:"mode"`
+		Channel     string        `yaml:"channel"`
+		FallbackTTL time.Duration `yaml:"fallbackTTL"`
+	}
+	cfg := Config{
+		Mode:        "redis",
+		Channel:     "cache:invalidation",
+		FallbackTTL: time.Minute,
+	}
+	err := viper.UnmarshalKey("cache.invalidation", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	client, ok := cmd.(redis.UniversalClient)
+	if cfg.Mode == "local" || !ok {
+		return cachex.NewLocalInvalidator(cfg.FallbackTTL)
+	}
+	inv := cachex.NewRedisInvalidator(client, l,
+		cachex.WithChannel(cfg.Channel),
+		cachex.WithFallbackTTL(cfg.FallbackTTL))
+	err = inv.Start()
+	if err != nil {
+		panic(err)
+	}
+	return inv
+}
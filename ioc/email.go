@@ -0,0 +1,91 @@
+package ioc
+
+import (
+	"github.com/spf13/viper"
+	"webok/internal/service/channel"
+	"webok/internal/service/email"
+	"webok/internal/service/sms"
+)
+
+const (
+	emailProviderSMTP = "smtp"
+	emailProviderFile = "file"
+)
+
+// InitEmailService provider 可选 smtp 或者 file，file 把邮件写到本地目录，开发环境用
+func InitEmailService() email.Service {
+	type Config struct {
+		Provider string
+		From     string
+		Dir      string
+		SMTP     email.SMTPConfig
+	}
+	cfg := Config{
+		Provider: emailProviderFile,
+		From:     "noreply@webok.local",
+		Dir:      "./tmp/mail",
+	}
+	err := viper.UnmarshalKey("email", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	switch cfg.Provider {
+	case emailProviderSMTP:
+		if cfg.SMTP.From == "" {
+			cfg.SMTP.From = cfg.From
+		}
+		svc, err := email.NewSMTPService(cfg.SMTP)
+		if err != nil {
+			panic(err)
+		}
+		return svc
+	default:
+		svc, err := email.NewFileService(cfg.Dir, cfg.From)
+		if err != nil {
+			panic(err)
+		}
+		return svc
+	}
+}
+
+func emailProvider() string {
+	provider := viper.GetString("email.provider")
+	if provider == "" {
+		return emailProviderFile
+	}
+	return provider
+}
+
+// InitChannels 验证码的发送渠道，业务在 code.policy 里面通过 channel 选择
+func InitChannels(smsSvc sms.Service, emailSvc email.Service) []channel.Channel {
+	templates := map[string]channel.EmailTemplate{
+		"login_code": {
+			Subject: "登录验证码",
+			Body:    "你的验证码是 {{index . 0}}，请不要告诉别人。",
+		},
+		"bind_code": {
+			Subject: "验证新邮箱",
+			Body:    "你正在绑定这个邮箱，验证码是 {{index . 0}}。如果不是你本人操作，请忽略这封邮件。",
+		},
+		"merge_code": {
+			Subject: "合并账号",
+			Body:    "你正在把这个邮箱对应的账号合并到另外一个账号，验证码是 {{index . 0}}。如果不是你本人操作，请忽略这封邮件。",
+		},
+		"reset_pwd_code": {
+			Subject: "重置密码",
+			Body:    "你正在重置密码，验证码是 {{index . 0}}。如果不是你本人操作，请忽略这封邮件。",
+		},
+	}
+	err := viper.UnmarshalKey("email.templates", &templates)
+	if err != nil {
+		panic(err)
+	}
+	emailCh, err := channel.NewEmailChannel(emailSvc, emailProvider(), templates)
+	if err != nil {
+		panic(err)
+	}
+	return []channel.Channel{
+		channel.NewSMSChannel(smsSvc, smsProvider()),
+		emailCh,
+	}
+}
@@ -0,0 +1,93 @@
+package ioc
+
+import (
+	"github.com/spf13/viper"
+	"os"
+	"time"
+	"webok/internal/service"
+	ijwt "webok/internal/web/jwt"
+	"webok/pkg/cryptox"
+	"webok/pkg/jwtx"
+	"webok/pkg/logger"
+)
+
+// InitJWTConfig token 的有效期，refresh token 每次使用都会换新的
+func InitJWTConfig() ijwt.Config {
+	cfg := ijwt.Config{
+		AccessTTL:  time.Minute * 30,
+		RefreshTTL: time.Hour * 24 * 7,
+		ReuseGrace: time.Second * 10,
+		PendingTTL: time.Minute * 5,
+		Issuer:     "webook",
+		Audience:   "webook",
+	}
+	err := viper.UnmarshalKey("jwt", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// InitJWTKeySet 签名用的密钥，配置在 jwt.keys 下面。
+// 没有配置的时候直接启动失败，只有 jwt.allowEphemeralKey 打开的时候才随机生成一把，
+// 只适合单机开发，重启之后所有人都要重新登录
+func InitJWTKeySet(l logger.Logger) *jwtx.KeySet {
+	var cfg jwtx.Config
+	err := viper.UnmarshalKey("jwt.keys", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	if len(cfg.Keys) == 0 {
+		if !viper.GetBool("jwt.allowEphemeralKey") {
+			panic("没有配置 JWT 密钥，开发环境可以打开 jwt.allowEphemeralKey")
+		}
+		l.Warn("没有配置 JWT 密钥，使用随机生成的密钥")
+		ks, err := jwtx.NewEphemeralKeySet("ephemeral")
+		if err != nil {
+			panic(err)
+		}
+		return ks
+	}
+	ks, err := jwtx.NewKeySet(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return ks
+}
+
+// InitTwoFactorConfig TOTP 两步验证
+func InitTwoFactorConfig() service.TwoFactorConfig {
+	cfg := service.TwoFactorConfig{
+		Issuer: "webook",
+		Skew:   1,
+	}
+	err := viper.UnmarshalKey("twoFactor", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// InitTwoFactorCipher 加密 TOTP 密钥用的 AES-256 密钥，base64 编码之后放在 twoFactor.secretKeyEnv 指定的环境变量里面。
+// 没有配置的时候直接启动失败，只有 twoFactor.allowEphemeralKey 打开的时候才随机生成一把，
+// 只适合单机开发，重启之后已经开启的两步验证都要重新绑定
+func InitTwoFactorCipher(l logger.Logger) *cryptox.Cipher {
+	env := viper.GetString("twoFactor.secretKeyEnv")
+	key, ok := os.LookupEnv(env)
+	if !ok || env == "" {
+		if !viper.GetBool("twoFactor.allowEphemeralKey") {
+			panic("没有配置两步验证的加密密钥，开发环境可以打开 twoFactor.allowEphemeralKey")
+		}
+		l.Warn("没有配置两步验证的加密密钥，使用随机生成的密钥", logger.String("env", env))
+		c, err := cryptox.NewEphemeralCipher()
+		if err != nil {
+			panic(err)
+		}
+		return c
+	}
+	c, err := cryptox.NewCipherFromBase64(key)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
@@ -3,13 +3,19 @@ package ioc
 import (
 	"fmt"
 	"github.com/IBM/sarama"
+	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
+	"time"
 	"webok/internal/events"
 	"webok/internal/events/article"
+	"webok/pkg/samarax"
+	"webok/pkg/samarax/membus"
 )
 
-func InitSaramaClient() sarama.Client {
+// InitBroker mode 为 memory 的时候使用进程内的消息总线，不需要 Kafka，只适合单机部署
+func InitBroker() samarax.Broker {
 	type Config struct {
+		Mode string   `yaml:"mode"`
 		Addr []string `yaml:"addr"`
 	}
 	var cfg Config
@@ -17,6 +23,9 @@ func InitSaramaClient() sarama.Client {
 	if err != nil {
 		panic(err)
 	}
+	if cfg.Mode == "memory" {
+		return membus.NewBus()
+	}
 	scfg := sarama.NewConfig()
 	fmt.Printf("kafka addr: %v\n", cfg.Addr)
 	scfg.Producer.Return.Successes = true
@@ -25,17 +34,30 @@ func InitSaramaClient() sarama.Client {
 		// 这里可以使用日志库
 		panic(err)
 	}
-	return client
+	return samarax.NewSaramaBroker(client)
 }
 
-func InitSyncProducer(c sarama.Client) sarama.SyncProducer {
-	p, err := sarama.NewSyncProducerFromClient(c)
+func InitSyncProducer(b samarax.Broker) sarama.SyncProducer {
+	p, err := b.SyncProducer()
 	if err != nil {
 		panic(err)
 	}
 	return p
 }
 
-func InitConsumers(c1 *article.InteractiveReadEventConsumer) []events.Consumer {
-	return []events.Consumer{c1}
+// InitEventRegistry 所有的事件都要在这里登记
+func InitEventRegistry() *samarax.Registry {
+	r := samarax.NewRegistry("webook")
+	article.RegisterEvents(r)
+	return r
+}
+
+// InitIdempotencyStore 消费者去重用，只要覆盖住 Kafka 可能重复投递的时间就可以
+func InitIdempotencyStore(cmd redis.Cmdable) samarax.IdempotencyStore {
+	return samarax.NewRedisIdempotencyStore(cmd, time.Hour*24)
+}
+
+func InitConsumers(c1 *article.InteractiveReadEventConsumer,
+	c2 *article.CacheInvalidationConsumer) []events.Consumer {
+	return []events.Consumer{c1, c2}
 }
@@ -0,0 +1,52 @@
+package ioc
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/service"
+)
+
+// bizLoginPassword 密码登录失败太多次之后的人机验证
+const bizLoginPassword = "login_pwd"
+
+// InitLoginPolicy 密码登录的防暴力破解策略
+func InitLoginPolicy() domain.LoginPolicy {
+	p := domain.LoginPolicy{
+		ChallengeAfter:   3,
+		AccountLockAfter: 5,
+		IPLockAfter:      20,
+		Window:           time.Minute * 15,
+		BaseLock:         time.Minute,
+		MaxLock:          time.Hour,
+		BackoffReset:     time.Hour * 24,
+	}
+	err := viper.UnmarshalKey("login.guard", &p)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func InitLoginChallenge(captchaSvc service.CaptchaService) service.LoginChallenge {
+	return service.NewCaptchaLoginChallenge(captchaSvc, bizLoginPassword)
+}
+
+// InitSecurityEventConfig 安全事件异步批量写入
+func InitSecurityEventConfig() service.SecurityEventConfig {
+	cfg := service.SecurityEventConfig{
+		Buffer:        4096,
+		BatchSize:     100,
+		FlushInterval: time.Second,
+	}
+	err := viper.UnmarshalKey("securityEvent", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	// 间隔不是正数 NewTicker 会 panic，buffer 为 0 的时候事件基本都会被丢掉
+	if cfg.Buffer <= 0 || cfg.BatchSize <= 0 || cfg.FlushInterval <= 0 {
+		panic(fmt.Sprintf("securityEvent 配置错误，buffer、batchSize 和 flushInterval 都必须大于 0：%+v", cfg))
+	}
+	return cfg
+}
@@ -0,0 +1,74 @@
+package ioc
+
+import (
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"time"
+	"webok/internal/service/outh2"
+	"webok/internal/service/outh2/github"
+	"webok/internal/service/outh2/oidc"
+	"webok/pkg/logger"
+)
+
+// OAuth2ProviderConfig oauth2.providers 下面的一项，Type 是 github 或者 oidc。
+// client secret 不写在配置文件里面，ClientSecretEnv 是保存它的环境变量
+type OAuth2ProviderConfig struct {
+	Name            string
+	Type            string
+	ClientID        string
+	ClientSecretEnv string
+	RedirectURL     string
+	Scopes          []string
+	// Issuer 只有 oidc 才需要
+	Issuer string
+}
+
+// InitOAuth2Registry 微信之外的第三方登录。环境变量里面没有 client secret 的跳过，
+// 这样开发环境不需要把每一个都配好
+func InitOAuth2Registry(wechatProvider outh2.Provider, l logger.Logger) *outh2.Registry {
+	var cfgs []OAuth2ProviderConfig
+	err := viper.UnmarshalKey("oauth2.providers", &cfgs)
+	if err != nil {
+		panic(err)
+	}
+	timeout := viper.GetDuration("oauth2.timeout")
+	providers := []outh2.Provider{wechatProvider}
+	for _, cfg := range cfgs {
+		secret, ok := os.LookupEnv(cfg.ClientSecretEnv)
+		if !ok {
+			l.Warn("找不到第三方登录的 client secret，跳过",
+				logger.String("provider", cfg.Name),
+				logger.String("env", cfg.ClientSecretEnv))
+			continue
+		}
+		providers = append(providers, newOAuth2Provider(cfg, secret, timeout))
+	}
+	return outh2.NewRegistry(providers...)
+}
+
+func newOAuth2Provider(cfg OAuth2ProviderConfig, secret string, timeout time.Duration) outh2.Provider {
+	switch cfg.Type {
+	case "github":
+		return github.NewProvider(github.Config{
+			Name:         cfg.Name,
+			ClientID:     cfg.ClientID,
+			ClientSecret: secret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+			Timeout:      timeout,
+		})
+	case "oidc":
+		return oidc.NewProvider(oidc.Config{
+			Name:         cfg.Name,
+			Issuer:       cfg.Issuer,
+			ClientID:     cfg.ClientID,
+			ClientSecret: secret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+			Timeout:      timeout,
+		})
+	default:
+		panic(fmt.Sprintf("不支持的第三方登录类型 %s", cfg.Type))
+	}
+}
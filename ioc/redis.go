@@ -3,6 +3,9 @@ package ioc
 import (
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/viper"
+	"time"
+	"webok/pkg/logger"
+	"webok/pkg/redisx"
 )
 
 func InitRedis() redis.Cmdable {
@@ -18,3 +21,33 @@ func InitRedis() redis.Cmdable {
 		Addr: c.Url,
 	})
 }
+
+// InitRedisHealth Redis 的熔断器，挂到客户端上统计所有命令的结果，
+// 依赖 Redis 的地方通过它判断要不要降级
+func InitRedisHealth(cmd redis.Cmdable, l logger.Logger) redisx.HealthMonitor {
+	type Config struct {
+		Window      time.Duration `yaml:"window"`
+		MinRequests int           `yaml:"minRequests"`
+		Threshold   float64       `yaml:"threshold"`
+		Cooldown    time.Duration `yaml:"cooldown"`
+		Probes      int           `yaml:"probes"`
+	}
+	cfg := Config{
+		Window:      time.Second * 10,
+		MinRequests: 20,
+		Threshold:   0.5,
+		Cooldown:    time.Second * 5,
+		Probes:      5,
+	}
+	err := viper.UnmarshalKey("redis.breaker", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	b := redisx.NewBreaker(l,
+		redisx.WithThreshold(cfg.Window, cfg.MinRequests, cfg.Threshold),
+		redisx.WithCooldown(cfg.Cooldown, cfg.Probes))
+	if client, ok := cmd.(redis.UniversalClient); ok {
+		client.AddHook(b)
+	}
+	return b
+}
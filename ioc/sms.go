@@ -1,18 +1,40 @@
 package ioc
 
 import (
+	"github.com/spf13/viper"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
 	tencentSms "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
 	"os"
+	"time"
+	"webok/internal/domain"
+	"webok/internal/service"
+	"webok/internal/service/channel"
 	"webok/internal/service/sms"
 	"webok/internal/service/sms/localsms"
 	"webok/internal/service/sms/tencent"
 )
 
+const (
+	smsProviderLocal   = "local"
+	smsProviderTencent = "tencent"
+)
+
 func InitSMSService() sms.Service {
-	return localsms.NewLocalSmsService()
-	//return InitTencentSmsService()
+	switch smsProvider() {
+	case smsProviderTencent:
+		return InitTencentSmsService()
+	default:
+		return localsms.NewLocalSmsService()
+	}
+}
+
+func smsProvider() string {
+	provider := viper.GetString("sms.provider")
+	if provider == "" {
+		return smsProviderLocal
+	}
+	return provider
 }
 
 func InitTencentSmsService() sms.Service {
@@ -35,3 +57,99 @@ func InitTencentSmsService() sms.Service {
 
 	return tencent.NewService(c, "", "")
 }
+
+// InitCodeQuotaConfig 验证码的发送限额，biz 下面可以按照业务覆盖默认值
+func InitCodeQuotaConfig() service.CodeQuotaConfig {
+	cfg := service.CodeQuotaConfig{
+		Default: domain.CodeQuota{
+			PhonePerDay:  10,
+			IPPerHour:    20,
+			GlobalPerDay: 100000,
+		},
+		TotalPerDay: 200000,
+	}
+	err := viper.UnmarshalKey("code.quota", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// InitCaptchaConfig 人机验证的配置，biz 下面配置哪些业务需要验证，mode 可选 off, always, suspicious
+func InitCaptchaConfig() service.CaptchaConfig {
+	cfg := service.CaptchaConfig{
+		AnswerTTL:           time.Minute * 2,
+		TicketTTL:           time.Minute * 5,
+		SuspiciousThreshold: 3,
+		SuspiciousWindow:    time.Hour,
+		Biz: map[string]service.CaptchaBizConfig{
+			"login":           {Mode: service.CaptchaModeSuspicious, Type: "slider"},
+			"login_email":     {Mode: service.CaptchaModeSuspicious, Type: "slider"},
+			"reset_pwd_sms":   {Mode: service.CaptchaModeSuspicious, Type: "slider"},
+			"reset_pwd_email": {Mode: service.CaptchaModeSuspicious, Type: "slider"},
+			"bind_email":      {Mode: service.CaptchaModeSuspicious, Type: "slider"},
+			"bind_phone":      {Mode: service.CaptchaModeSuspicious, Type: "slider"},
+			"merge_email":     {Mode: service.CaptchaModeSuspicious, Type: "slider"},
+			"merge_sms":       {Mode: service.CaptchaModeSuspicious, Type: "slider"},
+			// 什么时候要验证由登录保护决定
+			"login_pwd": {Mode: service.CaptchaModeOff, Type: "slider"},
+		},
+	}
+	err := viper.UnmarshalKey("captcha", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
+
+// InitCodePolicyConfig 验证码策略，biz 下面只需要配置和默认值不一样的字段
+func InitCodePolicyConfig() service.CodePolicyConfig {
+	cfg := service.CodePolicyConfig{
+		Default: domain.CodePolicy{
+			Channel:        channel.TypeSMS,
+			Length:         6,
+			Alphabet:       "0123456789",
+			TTL:            time.Minute * 10,
+			ResendInterval: time.Minute,
+			MaxAttempts:    3,
+			Templates: map[string]string{
+				smsProviderLocal:   "local",
+				smsProviderTencent: "1877556",
+				emailProviderSMTP:  "login_code",
+				emailProviderFile:  "login_code",
+			},
+		},
+		Biz: map[string]domain.CodePolicy{
+			"login_email":   {Channel: channel.TypeEmail},
+			"reset_pwd_sms": {Channel: channel.TypeSMS},
+			"reset_pwd_email": {
+				Channel: channel.TypeEmail,
+				Templates: map[string]string{
+					emailProviderSMTP: "reset_pwd_code",
+					emailProviderFile: "reset_pwd_code",
+				},
+			},
+			"bind_phone": {Channel: channel.TypeSMS},
+			"bind_email": {
+				Channel: channel.TypeEmail,
+				Templates: map[string]string{
+					emailProviderSMTP: "bind_code",
+					emailProviderFile: "bind_code",
+				},
+			},
+			"merge_sms": {Channel: channel.TypeSMS},
+			"merge_email": {
+				Channel: channel.TypeEmail,
+				Templates: map[string]string{
+					emailProviderSMTP: "merge_code",
+					emailProviderFile: "merge_code",
+				},
+			},
+		},
+	}
+	err := viper.UnmarshalKey("code.policy", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	return cfg
+}
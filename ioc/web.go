@@ -5,6 +5,8 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+	"net/http"
 	"strings"
 	"time"
 	"webok/internal/web"
@@ -13,22 +15,59 @@ import (
 	"webok/pkg/ginx/middleware/ratelimit"
 	"webok/pkg/limiter"
 	"webok/pkg/logger"
+	"webok/pkg/redisx"
 )
 
-func InitWebServer(mdls []gin.HandlerFunc, userHdl *web.UserHandler, wechatHandler *web.OAuth2WechatHandler, articleHdl *web.ArticleHandler) *gin.Engine {
+func InitWebServer(mdls []gin.HandlerFunc, userHdl *web.UserHandler, wechatHandler *web.OAuth2WechatHandler,
+	articleHdl *web.ArticleHandler, captchaHdl *web.CaptchaHandler, jwksHdl *web.JWKSHandler,
+	twoFactorHdl *web.TwoFactorHandler, securityHdl *web.SecurityEventHandler, oauth2Hdl *web.OAuth2Handler) *gin.Engine {
 	server := gin.Default()
+	// 限流、登录保护和验证码限额都按 ClientIP 算，要在注册中间件之前设置
+	err := server.SetTrustedProxies(trustedProxies())
+	if err != nil {
+		panic(err)
+	}
 	server.Use(mdls...)
 	userHdl.RegisterRoutes(server)
 	wechatHandler.RegisterRoutes(server)
+	oauth2Hdl.RegisterRoutes(server)
 	articleHdl.RegisterRoutes(server)
+	captchaHdl.RegisterRoutes(server)
+	jwksHdl.RegisterRoutes(server)
+	twoFactorHdl.RegisterRoutes(server)
+	securityHdl.RegisterRoutes(server)
 	return server
 }
 
-func InitGinMiddlewares(client redis.Cmdable, jwt ijwt.Handler, l logger.Logger) []gin.HandlerFunc {
+// trustedProxies 只有来自这些代理的请求才相信 X-Forwarded-For，
+// 没有配置的时候返回 nil，ClientIP 直接用连接的地址，防止客户端伪造 IP
+func trustedProxies() []string {
+	var proxies []string
+	err := viper.UnmarshalKey("web.trustedProxies", &proxies)
+	if err != nil {
+		panic(err)
+	}
+	if len(proxies) == 0 {
+		return nil
+	}
+	return proxies
+}
+
+// InitAdminMiddleware 管理员的 uid 配置在 admin.uids 下面，没有配置的时候谁都不能访问 /admin
+func InitAdminMiddleware() *middleware.AdminMiddlewareBuilder {
+	var uids []int64
+	err := viper.UnmarshalKey("admin.uids", &uids)
+	if err != nil {
+		panic(err)
+	}
+	return middleware.NewAdminMiddlewareBuilder(uids)
+}
+
+func InitGinMiddlewares(client redis.Cmdable, health redisx.HealthMonitor, jwt ijwt.Handler, l logger.Logger) []gin.HandlerFunc {
 	return []gin.HandlerFunc{
 		useCors(),
 		useJWT(jwt),
-		useRateLimit(client),
+		useRateLimit(client, health, l),
 		useLogger(l),
 		useErrorLogger(l),
 	}
@@ -55,8 +94,154 @@ func useCors() gin.HandlerFunc {
 	return cors.New(corsConfig)
 }
 
-func useRateLimit(redisClient redis.Cmdable) gin.HandlerFunc {
-	return ratelimit.NewBuilder(limiter.NewRedisSlidingWindowLimiter(redisClient, time.Second, 1000)).Build()
+func useRateLimit(redisClient redis.Cmdable, health redisx.HealthMonitor, l logger.Logger) gin.HandlerFunc {
+	rules := defaultRateLimitRules()
+	if viper.IsSet("ratelimit.rules") {
+		rules = nil
+		err := viper.UnmarshalKey("ratelimit.rules", &rules)
+		if err != nil {
+			panic(err)
+		}
+	}
+	b, err := ratelimit.NewRuleBuilder(redisClient, health, l, rules)
+	if err != nil {
+		panic(err)
+	}
+	return b.Uid(func(ctx *gin.Context) (int64, bool) {
+		uc, ok := ctx.Get("user")
+		if !ok {
+			return 0, false
+		}
+		claims, ok := uc.(ijwt.TokenClaims)
+		return claims.Uid, ok
+	}).Build()
+}
+
+// defaultRateLimitRules 没有配置的时候使用，登录和发验证码容易被刷，单独收紧
+func defaultRateLimitRules() []ratelimit.RuleConfig {
+	return []ratelimit.RuleConfig{
+		{
+			Name: "global",
+			Path: "/**",
+			Key:  ratelimit.KeyByIP,
+			Limiter: limiter.Config{
+				Algorithm: limiter.AlgorithmTokenBucket,
+				Interval:  time.Second,
+				Rate:      1000,
+			},
+		},
+		{
+			Name:   "login",
+			Method: http.MethodPost,
+			Path:   "/users/login",
+			Key:    ratelimit.KeyByIP,
+			Limiter: limiter.Config{
+				Algorithm: limiter.AlgorithmSlidingWindow,
+				Interval:  time.Minute,
+				Rate:      10,
+			},
+		},
+		{
+			Name:   "login_2fa",
+			Method: http.MethodPost,
+			Path:   "/users/login/2fa",
+			Key:    ratelimit.KeyByIP,
+			Limiter: limiter.Config{
+				Algorithm: limiter.AlgorithmSlidingWindow,
+				Interval:  time.Minute,
+				Rate:      10,
+			},
+		},
+		{
+			Name:   "two_factor",
+			Method: http.MethodPost,
+			Path:   "/users/2fa/**",
+			Key:    ratelimit.KeyByUid,
+			Limiter: limiter.Config{
+				Algorithm: limiter.AlgorithmSlidingWindow,
+				Interval:  time.Minute,
+				Rate:      10,
+			},
+		},
+		{
+			Name:   "sms_code",
+			Method: http.MethodPost,
+			Path:   "/users/login_sms/code/send",
+			Key:    ratelimit.KeyByIP,
+			Limiter: limiter.Config{
+				Algorithm: limiter.AlgorithmSlidingWindow,
+				Interval:  time.Minute,
+				Rate:      5,
+			},
+		},
+		{
+			Name:   "email_code",
+			Method: http.MethodPost,
+			Path:   "/users/login_email/code/send",
+			Key:    ratelimit.KeyByIP,
+			Limiter: limiter.Config{
+				Algorithm: limiter.AlgorithmSlidingWindow,
+				Interval:  time.Minute,
+				Rate:      5,
+			},
+		},
+		{
+			Name:   "reset_pwd",
+			Method: http.MethodPost,
+			Path:   "/users/password/reset/**",
+			Key:    ratelimit.KeyByIP,
+			Limiter: limiter.Config{
+				Algorithm: limiter.AlgorithmSlidingWindow,
+				Interval:  time.Minute,
+				Rate:      5,
+			},
+		},
+		{
+			Name:   "bind_email_code",
+			Method: http.MethodPost,
+			Path:   "/users/email/code/send",
+			Key:    ratelimit.KeyByUid,
+			Limiter: limiter.Config{
+				Algorithm: limiter.AlgorithmSlidingWindow,
+				Interval:  time.Minute,
+				Rate:      5,
+			},
+		},
+		{
+			Name:   "bind_phone_code",
+			Method: http.MethodPost,
+			Path:   "/users/phone/code/send",
+			Key:    ratelimit.KeyByUid,
+			Limiter: limiter.Config{
+				Algorithm: limiter.AlgorithmSlidingWindow,
+				Interval:  time.Minute,
+				Rate:      5,
+			},
+		},
+		{
+			Name:   "merge_code",
+			Method: http.MethodPost,
+			Path:   "/users/merge/code/send",
+			Key:    ratelimit.KeyByUid,
+			Limiter: limiter.Config{
+				Algorithm: limiter.AlgorithmSlidingWindow,
+				Interval:  time.Minute,
+				Rate:      5,
+			},
+		},
+		{
+			// 生成图片比较耗 CPU
+			Name:   "captcha",
+			Method: http.MethodPost,
+			Path:   "/captcha/generate",
+			Key:    ratelimit.KeyByIP,
+			Limiter: limiter.Config{
+				Algorithm: limiter.AlgorithmTokenBucket,
+				Interval:  time.Minute,
+				Rate:      20,
+			},
+		},
+	}
 }
 
 func useJWT(jwt ijwt.Handler) gin.HandlerFunc {
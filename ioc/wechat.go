@@ -1,11 +1,14 @@
 package ioc
 
 import (
+	"github.com/spf13/viper"
 	"os"
+	"webok/internal/service/outh2"
 	"webok/internal/service/outh2/wechat"
 )
 
-func InitWechatService() wechat.Service {
+// InitWechatProvider appid 和 secret 从环境变量里面读，回调地址配置在 oauth2.wechat.redirectURL
+func InitWechatProvider() outh2.Provider {
 	appID, ok := os.LookupEnv("WECHAT_APP_ID")
 	if !ok {
 		panic("找不到微信的 app id")
@@ -14,5 +17,14 @@ func InitWechatService() wechat.Service {
 	if !ok {
 		panic("找不到微信的 app secret")
 	}
-	return wechat.NewService(appID, appSecret)
+	cfg := wechat.Config{
+		RedirectURL: "https://xiaoxina.xyz/oauth2/wechat/callback",
+		Timeout:     viper.GetDuration("oauth2.timeout"),
+	}
+	err := viper.UnmarshalKey("oauth2.wechat", &cfg)
+	if err != nil {
+		panic(err)
+	}
+	cfg.AppID, cfg.AppSecret = appID, appSecret
+	return wechat.NewProvider(cfg)
 }
@@ -0,0 +1,91 @@
+package cachex
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Evictable 可以接收失效通知的本地缓存
+type Evictable interface {
+	// Evict 删除 key，key 不存在也不是错误
+	Evict(key string)
+	// Purge 清空全部数据，在可能丢了失效通知的时候调用
+	Purge()
+}
+
+// Invalidator 多实例部署的时候，每个实例都有自己的本地缓存。
+// 数据更新之后调用 Invalidate，所有实例都会删除本地缓存里面对应的 key
+type Invalidator interface {
+	// Invalidate 删除所有实例本地缓存里面的 keys，返回 error 的时候本实例已经删掉了，
+	// 其它实例只能等兜底的过期时间
+	Invalidate(ctx context.Context, keys ...string) error
+	// Register 登记本地缓存，以 prefix 开头的 key 的失效通知会交给 local
+	Register(prefix string, local Evictable)
+	// TTL 本地缓存的过期时间不能超过兜底时间，失效通知丢了也只会读到一小段时间的旧数据
+	TTL(want time.Duration) time.Duration
+}
+
+type local struct {
+	prefix string
+	cache  Evictable
+}
+
+// locals 本实例登记过的本地缓存
+type locals struct {
+	mu     sync.RWMutex
+	caches []local
+}
+
+func (l *locals) Register(prefix string, cache Evictable) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.caches = append(l.caches, local{prefix: prefix, cache: cache})
+}
+
+func (l *locals) evict(keys []string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, key := range keys {
+		for _, c := range l.caches {
+			if strings.HasPrefix(key, c.prefix) {
+				c.cache.Evict(key)
+			}
+		}
+	}
+}
+
+func (l *locals) purge() {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, c := range l.caches {
+		c.cache.Purge()
+	}
+}
+
+func fallbackTTL(fallback, want time.Duration) time.Duration {
+	if want <= 0 || want > fallback {
+		return fallback
+	}
+	return want
+}
+
+// LocalInvalidator 单实例部署的时候用，只删除本实例的缓存
+type LocalInvalidator struct {
+	locals
+	fallback time.Duration
+}
+
+func NewLocalInvalidator(fallback time.Duration) *LocalInvalidator {
+	return &LocalInvalidator{fallback: fallback}
+}
+
+func (l *LocalInvalidator) Invalidate(_ context.Context, keys ...string) error {
+	l.evict(keys)
+	return nil
+}
+
+func (l *LocalInvalidator) TTL(want time.Duration) time.Duration {
+	return fallbackTTL(l.fallback, want)
+}
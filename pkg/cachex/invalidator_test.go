@@ -0,0 +1,83 @@
+package cachex
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+	"webok/pkg/logger"
+)
+
+type mapCache map[string]int
+
+func (m mapCache) Evict(key string) {
+	delete(m, key)
+}
+
+func (m mapCache) Purge() {
+	for k := range m {
+		delete(m, k)
+	}
+}
+
+func TestLocalInvalidator(t *testing.T) {
+	inv := NewLocalInvalidator(time.Minute)
+	articles := mapCache{"article:1": 1, "article:2": 2}
+	users := mapCache{"user:1": 1}
+	inv.Register("article:", articles)
+	inv.Register("user:", users)
+
+	err := inv.Invalidate(context.Background(), "article:1", "user:2")
+	require.NoError(t, err)
+	assert.Equal(t, mapCache{"article:2": 2}, articles)
+	assert.Equal(t, mapCache{"user:1": 1}, users)
+
+	assert.Equal(t, time.Minute, inv.TTL(time.Hour))
+	assert.Equal(t, time.Second, inv.TTL(time.Second))
+	assert.Equal(t, time.Minute, inv.TTL(0))
+}
+
+func TestRedisInvalidator_handle(t *testing.T) {
+	testCases := []struct {
+		name    string
+		payload func(r *RedisInvalidator) string
+
+		wantCache mapCache
+	}{
+		{
+			name: "其它实例发的通知",
+			payload: func(r *RedisInvalidator) string {
+				val, _ := json.Marshal(invalidation{Src: "other", Keys: []string{"article:1"}})
+				return string(val)
+			},
+			wantCache: mapCache{"article:2": 2},
+		},
+		{
+			name: "自己发的通知",
+			payload: func(r *RedisInvalidator) string {
+				val, _ := json.Marshal(invalidation{Src: r.id, Keys: []string{"article:1"}})
+				return string(val)
+			},
+			wantCache: mapCache{"article:1": 1, "article:2": 2},
+		},
+		{
+			name: "格式错误",
+			payload: func(r *RedisInvalidator) string {
+				return "article:1"
+			},
+			wantCache: mapCache{"article:1": 1, "article:2": 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRedisInvalidator(nil, logger.NewNopLogger())
+			c := mapCache{"article:1": 1, "article:2": 2}
+			r.Register("article:", c)
+			r.handle(tc.payload(r))
+			assert.Equal(t, tc.wantCache, c)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package cachex
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"time"
+	"webok/pkg/logger"
+)
+
+const (
+	defaultChannel     = "cache:invalidation"
+	defaultFallbackTTL = time.Minute
+
+	minBackoff = 100 * time.Millisecond
+	maxBackoff = 10 * time.Second
+)
+
+type invalidation struct {
+	// Src 发送失效通知的实例，自己发的在 Invalidate 里面已经处理过了
+	Src  string   `json:"src"`
+	Keys []string `json:"keys"`
+}
+
+// RedisInvalidator 通过 Redis 的 pub/sub 广播失效通知。
+// pub/sub 不保证送达，断线期间的通知会丢，所以重连之后清空本地缓存，
+// 本地缓存的过期时间也要用 TTL 限制住
+type RedisInvalidator struct {
+	locals
+	client   redis.UniversalClient
+	l        logger.Logger
+	id       string
+	channel  string
+	fallback time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type Option func(r *RedisInvalidator)
+
+func WithChannel(channel string) Option {
+	return func(r *RedisInvalidator) {
+		r.channel = channel
+	}
+}
+
+// WithFallbackTTL 本地缓存最长的过期时间
+func WithFallbackTTL(ttl time.Duration) Option {
+	return func(r *RedisInvalidator) {
+		r.fallback = ttl
+	}
+}
+
+func NewRedisInvalidator(client redis.UniversalClient, l logger.Logger, opts ...Option) *RedisInvalidator {
+	r := &RedisInvalidator{
+		client:   client,
+		l:        l,
+		id:       uuid.New().String(),
+		channel:  defaultChannel,
+		fallback: defaultFallbackTTL,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Start 订阅失效通知，订阅成功之后才返回
+func (r *RedisInvalidator) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	ps := r.client.Subscribe(ctx, r.channel)
+	// 等订阅确认，这之后发的通知都能收到
+	_, err := ps.Receive(ctx)
+	if err != nil {
+		cancel()
+		_ = ps.Close()
+		return err
+	}
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	go r.loop(ctx, ps)
+	return nil
+}
+
+func (r *RedisInvalidator) Close() error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+func (r *RedisInvalidator) Invalidate(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	r.evict(keys)
+	val, err := json.Marshal(invalidation{Src: r.id, Keys: keys})
+	if err != nil {
+		return err
+	}
+	return r.client.Publish(ctx, r.channel, val).Err()
+}
+
+func (r *RedisInvalidator) TTL(want time.Duration) time.Duration {
+	return fallbackTTL(r.fallback, want)
+}
+
+func (r *RedisInvalidator) loop(ctx context.Context, ps *redis.PubSub) {
+	defer close(r.done)
+	defer ps.Close()
+	backoff := minBackoff
+	for {
+		msg, err := ps.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// go-redis 下一次 Receive 的时候会重连并且重新订阅，
+			// 断开期间的通知已经丢了，只能清空本地缓存
+			r.l.Warn("接收缓存失效通知失败，清空本地缓存", logger.Error(err))
+			r.purge()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = minBackoff
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			// 重新订阅成功，中间可能漏了通知
+			r.purge()
+		case *redis.Message:
+			r.handle(m.Payload)
+		}
+	}
+}
+
+func (r *RedisInvalidator) handle(payload string) {
+	var inv invalidation
+	err := json.Unmarshal([]byte(payload), &inv)
+	if err != nil {
+		r.l.Error("解析缓存失效通知失败", logger.String("payload", payload), logger.Error(err))
+		return
+	}
+	if inv.Src == r.id {
+		return
+	}
+	r.evict(inv.Keys)
+}
@@ -0,0 +1,190 @@
+package cachex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+	"math/rand/v2"
+	"time"
+	localMemCache "webok/pkg"
+	"webok/pkg/logger"
+	"webok/pkg/redisx"
+)
+
+// Jitter 在 ttl 的基础上随机增加最多 ttl*ratio，避免同一批写进去的 key 同时过期
+func Jitter(ttl time.Duration, ratio float64) time.Duration {
+	delta := int64(float64(ttl) * ratio)
+	if delta <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int64N(delta))
+}
+
+// remoteDelTimeout 熔断期间后台删除 Redis 缓存的超时时间
+const remoteDelTimeout = time.Second
+
+type TwoLevelConfig struct {
+	// Capacity 本地缓存最多保存多少个 key
+	Capacity int
+	// LocalTTL 本地缓存的过期时间，不会超过 Invalidator 的兜底时间
+	LocalTTL time.Duration
+	// RemoteTTL Redis 的过期时间
+	RemoteTTL time.Duration
+	// Jitter 过期时间随机增加的比例
+	Jitter float64
+}
+
+// TwoLevel 本地 LRU + Redis 的两级缓存。
+// 两级都没有命中的时候，同一个 key 只有一个请求会去加载数据，其它请求等它的结果。
+// cmd 为 nil 的时候只有本地一级，用在 Redis 里面的结构不是简单的 JSON 的场景
+type TwoLevel[V any] struct {
+	local *localMemCache.Cache[string, V]
+	cmd   redis.Cmdable
+	// health 熔断期间读的时候跳过 Redis，直接回源，为 nil 的时候认为一直可用
+	health redisx.HealthMonitor
+	inv    Invalidator
+	group  singleflight.Group
+	cfg    TwoLevelConfig
+	l      logger.Logger
+}
+
+// NewTwoLevel key 都要以 prefix 开头，收到 prefix 下的失效通知会删除本地缓存
+func NewTwoLevel[V any](cmd redis.Cmdable, health redisx.HealthMonitor, inv Invalidator, l logger.Logger,
+	prefix string, cfg TwoLevelConfig) *TwoLevel[V] {
+	cfg.LocalTTL = inv.TTL(cfg.LocalTTL)
+	c := &TwoLevel[V]{
+		local: localMemCache.NewCache[string, V](localMemCache.Config[string, V]{
+			MaxEntries: cfg.Capacity,
+		}),
+		cmd:    cmd,
+		health: health,
+		inv:    inv,
+		cfg:    cfg,
+		l:      l,
+	}
+	inv.Register(prefix, evictable[V]{c.local})
+	return c
+}
+
+type evictable[V any] struct {
+	c *localMemCache.Cache[string, V]
+}
+
+func (e evictable[V]) Evict(key string) {
+	e.c.Delete(key)
+}
+
+func (e evictable[V]) Purge() {
+	e.c.Purge()
+}
+
+// Get 依次查询本地缓存、Redis，都没有的时候调用 load，并且回写两级缓存。
+// Redis 不可用的时候只用本地缓存。load 返回 error 的时候不会缓存
+func (c *TwoLevel[V]) Get(ctx context.Context, key string,
+	load func(ctx context.Context) (V, error)) (V, error) {
+	if val, ok := c.local.Get(key); ok {
+		return val, nil
+	}
+	res, err, _ := c.group.Do(key, func() (any, error) {
+		// 共享结果的请求不应该因为第一个请求取消了而失败
+		ctx := context.WithoutCancel(ctx)
+		if val, ok := c.local.Get(key); ok {
+			return val, nil
+		}
+		val, err := c.getRemote(ctx, key)
+		if err == nil {
+			c.setLocal(key, val)
+			return val, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			// Redis 出问题了，直接查数据源
+			c.l.Warn("查询 Redis 缓存失败", logger.String("key", key), logger.Error(err))
+		}
+		val, err = load(ctx)
+		if err != nil {
+			return val, err
+		}
+		c.setLocal(key, val)
+		if er := c.setRemote(ctx, key, val); er != nil {
+			c.l.Warn("回写 Redis 缓存失败", logger.String("key", key), logger.Error(er))
+		}
+		return val, nil
+	})
+	if err != nil {
+		var v V
+		return v, err
+	}
+	return res.(V), nil
+}
+
+// Set 写入两级缓存，并且通知其它实例删除本地缓存
+func (c *TwoLevel[V]) Set(ctx context.Context, key string, val V) error {
+	err := c.setRemote(ctx, key, val)
+	if err != nil {
+		return err
+	}
+	err = c.inv.Invalidate(ctx, key)
+	c.setLocal(key, val)
+	return err
+}
+
+// Del 删除两级缓存，并且通知其它实例删除本地缓存。
+// 熔断的时候 Redis 在后台删，不让调用方等超时，但是也不能不删，不然 Redis 恢复之后会读到旧数据
+func (c *TwoLevel[V]) Del(ctx context.Context, keys ...string) error {
+	switch {
+	case c.cmd == nil:
+	case c.remoteHealthy():
+		err := c.cmd.Del(ctx, keys...).Err()
+		if err != nil {
+			return err
+		}
+	default:
+		go c.delRemote(keys)
+	}
+	return c.inv.Invalidate(ctx, keys...)
+}
+
+func (c *TwoLevel[V]) delRemote(keys []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteDelTimeout)
+	defer cancel()
+	err := c.cmd.Del(ctx, keys...).Err()
+	if err != nil {
+		c.l.Warn("熔断期间删除 Redis 缓存失败", logger.Int("count", len(keys)), logger.Error(err))
+	}
+}
+
+func (c *TwoLevel[V]) setLocal(key string, val V) {
+	c.local.Set(key, val, Jitter(c.cfg.LocalTTL, c.cfg.Jitter))
+}
+
+func (c *TwoLevel[V]) remoteHealthy() bool {
+	return c.health == nil || c.health.Healthy()
+}
+
+func (c *TwoLevel[V]) getRemote(ctx context.Context, key string) (V, error) {
+	var val V
+	// 熔断的时候当成没有命中，不打日志
+	if c.cmd == nil || !c.remoteHealthy() {
+		return val, redis.Nil
+	}
+	data, err := c.cmd.Get(ctx, key).Bytes()
+	if err != nil {
+		return val, err
+	}
+	err = json.Unmarshal(data, &val)
+	return val, err
+}
+
+// setRemote 熔断的时候不写 Redis，只写本地缓存
+func (c *TwoLevel[V]) setRemote(ctx context.Context, key string, val V) error {
+	if c.cmd == nil || !c.remoteHealthy() {
+		return nil
+	}
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return c.cmd.Set(ctx, key, data, Jitter(c.cfg.RemoteTTL, c.cfg.Jitter)).Err()
+}
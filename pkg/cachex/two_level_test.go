@@ -0,0 +1,168 @@
+package cachex
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"webok/pkg/logger"
+	redisxmocks "webok/pkg/redisx/mock"
+)
+
+func newLocalTwoLevel(capacity int) (*TwoLevel[int], *LocalInvalidator) {
+	inv := NewLocalInvalidator(time.Minute)
+	return NewTwoLevel[int](nil, nil, inv, logger.NewNopLogger(), "test:", TwoLevelConfig{
+		Capacity: capacity,
+		LocalTTL: time.Minute,
+		Jitter:   0.1,
+	}), inv
+}
+
+func TestTwoLevel_Singleflight(t *testing.T) {
+	c, _ := newLocalTwoLevel(10)
+	var cnt atomic.Int32
+	start := make(chan struct{})
+	load := func(ctx context.Context) (int, error) {
+		cnt.Add(1)
+		<-start
+		return 1, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := c.Get(context.Background(), "test:1", load)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, val)
+		}()
+	}
+	// 等所有请求都进入 singleflight
+	time.Sleep(time.Millisecond * 50)
+	close(start)
+	wg.Wait()
+	assert.Equal(t, int32(1), cnt.Load())
+
+	// 之后命中本地缓存
+	val, err := c.Get(context.Background(), "test:1", func(ctx context.Context) (int, error) {
+		return 0, errors.New("不应该加载")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+}
+
+func TestTwoLevel_LoadError(t *testing.T) {
+	c, _ := newLocalTwoLevel(10)
+	_, err := c.Get(context.Background(), "test:1", func(ctx context.Context) (int, error) {
+		return 0, errors.New("mock error")
+	})
+	assert.Error(t, err)
+
+	// 失败的结果不缓存
+	val, err := c.Get(context.Background(), "test:1", func(ctx context.Context) (int, error) {
+		return 2, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, val)
+}
+
+func TestTwoLevel_Invalidate(t *testing.T) {
+	c, inv := newLocalTwoLevel(10)
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "test:1", 1))
+
+	require.NoError(t, inv.Invalidate(ctx, "test:1"))
+	val, err := c.Get(ctx, "test:1", func(ctx context.Context) (int, error) {
+		return 2, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, val)
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		d := Jitter(time.Minute, 0.2)
+		assert.GreaterOrEqual(t, d, time.Minute)
+		assert.Less(t, d, time.Minute*12/10)
+	}
+	assert.Equal(t, time.Minute, Jitter(time.Minute, 0))
+}
+
+func TestTwoLevel_RemoteUnhealthy(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cmd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctrl := gomock.NewController(t)
+	health := redisxmocks.NewMockHealthMonitor(ctrl)
+	c := NewTwoLevel[int](cmd, health, NewLocalInvalidator(time.Minute), logger.NewNopLogger(), "test:",
+		TwoLevelConfig{Capacity: 10, LocalTTL: time.Minute, RemoteTTL: time.Minute})
+	ctx := context.Background()
+	require.NoError(t, mr.Set("test:1", "1"))
+
+	// 熔断的时候不查 Redis，也不回写
+	health.EXPECT().Healthy().Return(false).AnyTimes()
+	val, err := c.Get(ctx, "test:1", func(ctx context.Context) (int, error) {
+		return 2, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, val)
+	_, err = c.Get(ctx, "test:2", func(ctx context.Context) (int, error) {
+		return 3, nil
+	})
+	require.NoError(t, err)
+	assert.False(t, mr.Exists("test:2"))
+
+	// 写的时候只写本地缓存
+	require.NoError(t, c.Set(ctx, "test:3", 3))
+	assert.False(t, mr.Exists("test:3"))
+	val, err = c.Get(ctx, "test:3", func(ctx context.Context) (int, error) {
+		return 0, errors.New("不应该加载")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, val)
+
+	// 删除的时候不等 Redis，后台还是会删掉，避免恢复之后读到旧数据
+	require.NoError(t, c.Del(ctx, "test:1", "test:3"))
+	val, err = c.Get(ctx, "test:3", func(ctx context.Context) (int, error) {
+		return 4, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 4, val)
+	assert.Eventually(t, func() bool {
+		return !mr.Exists("test:1")
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestTwoLevel_RemoteHealthy(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cmd := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctrl := gomock.NewController(t)
+	health := redisxmocks.NewMockHealthMonitor(ctrl)
+	health.EXPECT().Healthy().Return(true).AnyTimes()
+	c := NewTwoLevel[int](cmd, health, NewLocalInvalidator(time.Minute), logger.NewNopLogger(), "test:",
+		TwoLevelConfig{Capacity: 10, LocalTTL: time.Minute, RemoteTTL: time.Minute})
+	ctx := context.Background()
+	require.NoError(t, mr.Set("test:1", "1"))
+
+	val, err := c.Get(ctx, "test:1", func(ctx context.Context) (int, error) {
+		return 0, errors.New("不应该加载")
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+
+	// 没有命中的时候回写 Redis
+	_, err = c.Get(ctx, "test:2", func(ctx context.Context) (int, error) {
+		return 2, nil
+	})
+	require.NoError(t, err)
+	got, err := mr.Get("test:2")
+	require.NoError(t, err)
+	assert.Equal(t, "2", got)
+}
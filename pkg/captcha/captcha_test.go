@@ -0,0 +1,115 @@
+package captcha
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDigitGenerator(t *testing.T) {
+	g := NewDigitGenerator()
+	c, answer, err := g.Generate()
+	require.NoError(t, err)
+	assert.Equal(t, TypeDigits, c.Type)
+	assert.True(t, strings.HasPrefix(c.Images["image"], "data:image/png;base64,"))
+	assert.Len(t, answer, g.Length)
+	assert.True(t, g.Match(answer, " "+answer+" "))
+	assert.False(t, g.Match(answer, answer+"0"))
+	assert.False(t, g.Match("", ""))
+}
+
+func TestDrawLine(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	c := color.RGBA{R: 255, A: 255}
+	// 斜率各种各样的线都要能画到终点，不能死循环
+	for i := 0; i < 1000; i++ {
+		x0, y0, x1, y1 := i%20, i/20%20, i*7%20, i*13%20
+		drawLine(img, x0, y0, x1, y1, c)
+		assert.Equal(t, c, img.RGBAAt(x1, y1))
+	}
+}
+
+func TestSliderGenerator(t *testing.T) {
+	g := NewSliderGenerator()
+	c, answer, err := g.Generate()
+	require.NoError(t, err)
+	assert.Equal(t, TypeSlider, c.Type)
+	assert.Contains(t, c.Images, "background")
+	assert.Contains(t, c.Images, "piece")
+	x, err := strconv.Atoi(answer)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, x, g.Piece)
+
+	testCases := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{name: "拖到缺口", input: sliderInput(t, easeOut(x+g.Tolerance, 20, 800)), want: true},
+		{name: "差了一点", input: sliderInput(t, easeOut(x-g.Tolerance-1, 20, 800))},
+		{name: "只提交终点", input: sliderInput(t, []TrackPoint{{X: x, T: 500}})},
+		{name: "匀速拖动", input: sliderInput(t, linear(x, 20, 800))},
+		{name: "太快", input: sliderInput(t, easeOut(x, 20, 100))},
+		{name: "太慢", input: sliderInput(t, easeOut(x, 20, 60000))},
+		{name: "不是从起点开始", input: sliderInput(t, append([]TrackPoint{{X: 50}}, easeOut(x, 20, 800)...))},
+		{name: "时间倒退", input: sliderInput(t, append(easeOut(x, 20, 800), TrackPoint{X: x, T: 10}))},
+		{name: "旧的格式", input: answer},
+		{name: "不是 JSON", input: "abc"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, g.Match(answer, tc.input))
+		})
+	}
+}
+
+// easeOut 先快后慢拖到 x，和人拖动的样子差不多
+func easeOut(x, n int, ms int64) []TrackPoint {
+	track := make([]TrackPoint, 0, n+1)
+	for i := 0; i <= n; i++ {
+		p := float64(i) / float64(n)
+		track = append(track, TrackPoint{
+			X: int(float64(x) * (1 - (1-p)*(1-p))),
+			Y: i % 3,
+			T: ms * int64(i) / int64(n),
+		})
+	}
+	return track
+}
+
+func linear(x, n int, ms int64) []TrackPoint {
+	track := make([]TrackPoint, 0, n+1)
+	for i := 0; i <= n; i++ {
+		track = append(track, TrackPoint{X: x * i / n, T: ms * int64(i) / int64(n)})
+	}
+	return track
+}
+
+func sliderInput(t *testing.T, track []TrackPoint) string {
+	val, err := json.Marshal(SliderInput{Track: track})
+	require.NoError(t, err)
+	return string(val)
+}
+
+func TestSliderGenerator_Decoys(t *testing.T) {
+	g := NewSliderGenerator()
+	for i := 0; i < 100; i++ {
+		x := g.Piece + 10 + i
+		decoys := g.decoys(x)
+		assert.Len(t, decoys, g.Decoys)
+		taken := []int{x}
+		for _, d := range decoys {
+			for _, other := range taken {
+				assert.GreaterOrEqual(t, abs(d.X-other), g.Piece)
+			}
+			assert.LessOrEqual(t, d.X+g.Piece, g.Width)
+			assert.LessOrEqual(t, d.Y+g.Piece, g.Height)
+			taken = append(taken, d.X)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package captcha
+
+import (
+	"image"
+	"image/color"
+	"math/rand/v2"
+	"strings"
+)
+
+// glyphs 5x7 的点阵数字，每一行的低 5 位表示这一行的像素
+var glyphs = [10][7]uint8{
+	{0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	{0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	{0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	{0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	{0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	{0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	{0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	{0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	{0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	{0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+}
+
+// DigitGenerator 数字图形验证码，每个数字随机缩放、偏移和着色，再加上干扰线和噪点
+type DigitGenerator struct {
+	Width  int
+	Height int
+	Length int
+}
+
+func NewDigitGenerator() *DigitGenerator {
+	return &DigitGenerator{Width: 160, Height: 60, Length: 5}
+}
+
+func (g *DigitGenerator) Generate() (Challenge, string, error) {
+	img := image.NewRGBA(image.Rect(0, 0, g.Width, g.Height))
+	fill(img, color.RGBA{R: 240, G: 240, B: 235, A: 255})
+	var answer strings.Builder
+	cell := g.Width / g.Length
+	for i := 0; i < g.Length; i++ {
+		d := rand.IntN(10)
+		answer.WriteByte(byte('0' + d))
+		scale := max(1, min(cell/6, g.Height/9)-rand.IntN(2))
+		x := i*cell + rand.IntN(max(1, cell-5*scale))
+		y := rand.IntN(max(1, g.Height-7*scale))
+		drawGlyph(img, glyphs[d], x, y, scale, randColor())
+	}
+	for i := 0; i < 4; i++ {
+		drawLine(img, rand.IntN(g.Width), rand.IntN(g.Height),
+			rand.IntN(g.Width), rand.IntN(g.Height), randColor())
+	}
+	for i := 0; i < g.Width*g.Height/20; i++ {
+		img.Set(rand.IntN(g.Width), rand.IntN(g.Height), randColor())
+	}
+	uri, err := dataURI(img)
+	if err != nil {
+		return Challenge{}, "", err
+	}
+	return Challenge{Type: TypeDigits, Images: map[string]string{"image": uri}}, answer.String(), nil
+}
+
+func (g *DigitGenerator) Match(answer, input string) bool {
+	return answer != "" && strings.TrimSpace(input) == answer
+}
+
+func drawGlyph(img *image.RGBA, glyph [7]uint8, x, y, scale int, c color.Color) {
+	for row, bits := range glyph {
+		for col := 0; col < 5; col++ {
+			if bits&(1<<(4-col)) == 0 {
+				continue
+			}
+			for dx := 0; dx < scale; dx++ {
+				for dy := 0; dy < scale; dy++ {
+					img.Set(x+col*scale+dx, y+row*scale+dy, c)
+				}
+			}
+		}
+	}
+}
+
+// drawLine Bresenham 画线
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		// 两个判断都要用更新之前的误差，不然会越过终点一直画下去
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func fill(img *image.RGBA, c color.RGBA) {
+	b := img.Bounds()
+	for x := b.Min.X; x < b.Max.X; x++ {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+func randColor() color.RGBA {
+	return color.RGBA{R: uint8(rand.IntN(160)), G: uint8(rand.IntN(160)), B: uint8(rand.IntN(160)), A: 255}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
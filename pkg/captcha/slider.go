@@ -0,0 +1,211 @@
+package captcha
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"math/rand/v2"
+	"strconv"
+	"time"
+)
+
+// SliderGenerator 滑块验证码，从随机生成的背景图里面抠出一块作为滑块，
+// 用户要把滑块拖到缺口的位置，答案是缺口的横坐标。
+// 背景图上面还有几个一样的假缺口，只看明暗找不到真的那个，要和滑块的图案对上才行
+type SliderGenerator struct {
+	Width  int
+	Height int
+	Piece  int
+	// Tolerance 允许的误差，单位是像素。缺口的范围大约 190 像素，盲猜通过的概率不到 4%，
+	// 所以还要求拖动轨迹
+	Tolerance int
+	// Decoys 假缺口的个数
+	Decoys int
+	// MinTrackPoints 拖动轨迹至少要有几个点
+	MinTrackPoints int
+	// MinDuration 和 MaxDuration 拖动的时长范围，太快的是脚本，太慢的多半是在试答案
+	MinDuration time.Duration
+	MaxDuration time.Duration
+}
+
+func NewSliderGenerator() *SliderGenerator {
+	return &SliderGenerator{
+		Width:          300,
+		Height:         150,
+		Piece:          44,
+		Tolerance:      3,
+		Decoys:         2,
+		MinTrackPoints: 10,
+		MinDuration:    time.Millisecond * 300,
+		MaxDuration:    time.Second * 15,
+	}
+}
+
+// SliderInput 前端提交的答案，JSON 编码之后作为 Match 的 input
+type SliderInput struct {
+	// Track 拖动过程中采样的点，x 是滑块相对起点移动的距离，最后一个点就是松手的位置
+	Track []TrackPoint `json:"track"`
+}
+
+type TrackPoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	// T 相对开始拖动的毫秒数
+	T int64 `json:"t"`
+}
+
+func (g *SliderGenerator) Generate() (Challenge, string, error) {
+	bg := image.NewRGBA(image.Rect(0, 0, g.Width, g.Height))
+	g.background(bg)
+	// 缺口不能太靠左，否则不用拖动就能通过
+	x := g.Piece + 10 + rand.IntN(g.Width-2*g.Piece-20)
+	y := 5 + rand.IntN(g.Height-g.Piece-10)
+
+	piece := image.NewRGBA(image.Rect(0, 0, g.Piece, g.Piece))
+	border := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	for dx := 0; dx < g.Piece; dx++ {
+		for dy := 0; dy < g.Piece; dy++ {
+			if dx < 2 || dy < 2 || dx >= g.Piece-2 || dy >= g.Piece-2 {
+				piece.SetRGBA(dx, dy, border)
+			} else {
+				piece.SetRGBA(dx, dy, bg.RGBAAt(x+dx, y+dy))
+			}
+		}
+	}
+	g.shade(bg, x, y)
+	for _, p := range g.decoys(x) {
+		g.shade(bg, p.X, p.Y)
+	}
+	bgURI, err := dataURI(bg)
+	if err != nil {
+		return Challenge{}, "", err
+	}
+	pieceURI, err := dataURI(piece)
+	if err != nil {
+		return Challenge{}, "", err
+	}
+	return Challenge{
+		Type:   TypeSlider,
+		Images: map[string]string{"background": bgURI, "piece": pieceURI},
+		Y:      y,
+	}, strconv.Itoa(x), nil
+}
+
+// Match input 是 JSON 编码的 SliderInput，松手的位置对了，轨迹也要像人拖出来的
+func (g *SliderGenerator) Match(answer, input string) bool {
+	want, err := strconv.Atoi(answer)
+	if err != nil {
+		return false
+	}
+	var in SliderInput
+	if json.Unmarshal([]byte(input), &in) != nil || !g.humanTrack(in.Track) {
+		return false
+	}
+	return abs(want-in.Track[len(in.Track)-1].X) <= g.Tolerance
+}
+
+// humanTrack 粗略判断轨迹是不是人拖出来的：从起点出发，时间不倒退，时长合理，
+// 而且速度有变化，脚本直接提交终点或者匀速拖过去的都过不了
+func (g *SliderGenerator) humanTrack(track []TrackPoint) bool {
+	if len(track) < g.MinTrackPoints {
+		return false
+	}
+	first, last := track[0], track[len(track)-1]
+	if abs(first.X) > g.Tolerance {
+		return false
+	}
+	d := time.Duration(last.T-first.T) * time.Millisecond
+	if d < g.MinDuration || d > g.MaxDuration {
+		return false
+	}
+	speeds := make(map[int64]struct{}, len(track))
+	for i := 1; i < len(track); i++ {
+		dt := track[i].T - track[i-1].T
+		if dt < 0 {
+			return false
+		}
+		if dt == 0 {
+			continue
+		}
+		// 按每秒多少像素取整，匀速的轨迹只有一两种速度
+		speeds[int64(track[i].X-track[i-1].X)*1000/dt] = struct{}{}
+	}
+	return len(speeds) >= 3
+}
+
+// shade 缺口只是稍微调暗、偏灰一点，和背景的色块差不多，不能靠找最暗的方块定位
+func (g *SliderGenerator) shade(bg *image.RGBA, x, y int) {
+	for dx := 0; dx < g.Piece; dx++ {
+		for dy := 0; dy < g.Piece; dy++ {
+			c := bg.RGBAAt(x+dx, y+dy)
+			bg.SetRGBA(x+dx, y+dy, color.RGBA{
+				R: uint8((int(c.R)*3 + 96) / 5),
+				G: uint8((int(c.G)*3 + 96) / 5),
+				B: uint8((int(c.B)*3 + 96) / 5),
+				A: 255,
+			})
+		}
+	}
+}
+
+// decoys 假缺口的位置，和真缺口以及彼此都不重叠，放不下就少放几个
+func (g *SliderGenerator) decoys(x int) []image.Point {
+	taken := []int{x}
+	res := make([]image.Point, 0, g.Decoys)
+	for i := 0; i < g.Decoys*50 && len(res) < g.Decoys; i++ {
+		dx := rand.IntN(g.Width - g.Piece)
+		overlap := false
+		for _, t := range taken {
+			if abs(dx-t) < g.Piece {
+				overlap = true
+				break
+			}
+		}
+		if overlap {
+			continue
+		}
+		taken = append(taken, dx)
+		res = append(res, image.Point{X: dx, Y: 5 + rand.IntN(g.Height-g.Piece-10)})
+	}
+	return res
+}
+
+// background 渐变底色加上随机的色块和噪点，缺口的位置不能一眼看出来
+func (g *SliderGenerator) background(img *image.RGBA) {
+	from, to := randColor(), randColor()
+	for x := 0; x < g.Width; x++ {
+		r := x * 255 / g.Width
+		c := color.RGBA{
+			R: uint8((int(from.R)*(255-r) + int(to.R)*r) / 255),
+			G: uint8((int(from.G)*(255-r) + int(to.G)*r) / 255),
+			B: uint8((int(from.B)*(255-r) + int(to.B)*r) / 255),
+			A: 255,
+		}
+		for y := 0; y < g.Height; y++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	for i := 0; i < 12; i++ {
+		c := randColor()
+		cx, cy, radius := rand.IntN(g.Width), rand.IntN(g.Height), 8+rand.IntN(24)
+		for x := cx - radius; x <= cx+radius; x++ {
+			for y := cy - radius; y <= cy+radius; y++ {
+				if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= radius*radius {
+					img.Set(x, y, c)
+				}
+			}
+		}
+	}
+	// 每个像素加一点噪声，缺口的边缘不再是干净的直线
+	for x := 0; x < g.Width; x++ {
+		for y := 0; y < g.Height; y++ {
+			c := img.RGBAAt(x, y)
+			n := rand.IntN(25) - 12
+			img.SetRGBA(x, y, color.RGBA{R: clamp(int(c.R) + n), G: clamp(int(c.G) + n), B: clamp(int(c.B) + n), A: 255})
+		}
+	}
+}
+
+func clamp(v int) uint8 {
+	return uint8(min(255, max(0, v)))
+}
@@ -0,0 +1,37 @@
+package captcha
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+)
+
+const (
+	TypeDigits = "digits"
+	TypeSlider = "slider"
+)
+
+// Challenge 返回给前端的挑战，图片都是 data URI，可以直接放到 img 标签里面
+type Challenge struct {
+	Type   string            `json:"type"`
+	Images map[string]string `json:"images"`
+	// Y 滑块在背景图里面的纵坐标，前端用来摆放滑块
+	Y int `json:"y,omitempty"`
+}
+
+// Generator 生成挑战和对应的答案，答案由调用方保存
+type Generator interface {
+	Generate() (Challenge, string, error)
+	// Match 用户的输入是否和答案一致
+	Match(answer, input string) bool
+}
+
+func dataURI(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
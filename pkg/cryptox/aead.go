@@ -0,0 +1,92 @@
+// Package cryptox 加密存在数据库里面的敏感字段
+package cryptox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix 密文的版本，以后换算法或者换密钥的时候靠它区分
+const prefix = "v1:"
+
+var (
+	ErrInvalidKey        = errors.New("cryptox: 密钥必须是 32 字节")
+	ErrInvalidCiphertext = errors.New("cryptox: 密文格式不对或者被篡改")
+)
+
+// Cipher AES-256-GCM，密文是 "v1:" 加上 base64 编码的 nonce 和密文，可以直接存在字符串字段里面
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != 32 {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// NewCipherFromBase64 配置里面的密钥是 base64 编码的
+func NewCipherFromBase64(key string) (*Cipher, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("cryptox: 密钥不是合法的 base64: %w", err)
+	}
+	return NewCipher(raw)
+}
+
+// NewEphemeralCipher 随机生成密钥，只能在开发环境用，重启之后之前加密的数据都解不开了
+func NewEphemeralCipher() (*Cipher, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	return NewCipher(key)
+}
+
+// Encrypt aad 会参与认证但是不加密，用来把密文绑定到某一行上面，
+// 把别人的密文复制过来是解不开的
+func (c *Cipher) Encrypt(plaintext string, aad []byte) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), aad)
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Decrypt(ciphertext string, aad []byte) (string, error) {
+	if !IsEncrypted(ciphertext) {
+		return "", ErrInvalidCiphertext
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(ciphertext[len(prefix):])
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, aad)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plain), nil
+}
+
+// IsEncrypted 加密之前写进去的数据没有前缀
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
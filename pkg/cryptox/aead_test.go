@@ -0,0 +1,72 @@
+package cryptox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCipher(t *testing.T) {
+	c, err := NewCipher(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	aad := []byte("123")
+
+	// 32 字节的 base32 密钥加密之后要能放进 varchar(128)
+	plain := "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXPJBSW"
+	enc, err := c.Encrypt(plain, aad)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(enc))
+	assert.NotContains(t, enc, plain)
+	assert.LessOrEqual(t, len(enc), 128)
+
+	// 每次的 nonce 不一样
+	enc2, err := c.Encrypt(plain, aad)
+	require.NoError(t, err)
+	assert.NotEqual(t, enc, enc2)
+
+	got, err := c.Decrypt(enc, aad)
+	require.NoError(t, err)
+	assert.Equal(t, plain, got)
+
+	tampered := []byte(enc)
+	if tampered[10] == 'A' {
+		tampered[10] = 'B'
+	} else {
+		tampered[10] = 'A'
+	}
+
+	testCases := []struct {
+		name       string
+		ciphertext string
+		aad        []byte
+	}{
+		{name: "别的行的密文", ciphertext: enc, aad: []byte("456")},
+		{name: "没有前缀", ciphertext: plain, aad: aad},
+		{name: "不是 base64", ciphertext: "v1:???", aad: aad},
+		{name: "太短", ciphertext: "v1:AAAA", aad: aad},
+		{name: "被篡改", ciphertext: string(tampered), aad: aad},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := c.Decrypt(tc.ciphertext, tc.aad)
+			assert.Equal(t, ErrInvalidCiphertext, err)
+		})
+	}
+
+	// 换了密钥解不开
+	other, err := NewEphemeralCipher()
+	require.NoError(t, err)
+	_, err = other.Decrypt(enc, aad)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+}
+
+func TestNewCipherFromBase64(t *testing.T) {
+	_, err := NewCipherFromBase64(base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	assert.NoError(t, err)
+	_, err = NewCipherFromBase64(base64.StdEncoding.EncodeToString(make([]byte, 16)))
+	assert.Equal(t, ErrInvalidKey, err)
+	_, err = NewCipherFromBase64("not base64!")
+	assert.Error(t, err)
+}
@@ -7,11 +7,15 @@ import (
 	"log"
 	"net/http"
 	"webok/pkg/limiter"
+	"webok/pkg/redisx"
 )
 
 type Builder struct {
 	prefix  string
 	limiter limiter.Limiter
+	// Redis 不可用的时候改用 fallback，一般是本地限流
+	fallback limiter.Limiter
+	health   redisx.HealthMonitor
 }
 
 func NewBuilder(l limiter.Limiter) *Builder {
@@ -26,9 +30,25 @@ func (b *Builder) Prefix(prefix string) *Builder {
 	return b
 }
 
+// Fallback health 认为 Redis 不可用，或者限流出错的时候，改用 l 限流
+func (b *Builder) Fallback(l limiter.Limiter, health redisx.HealthMonitor) *Builder {
+	b.fallback = l
+	b.health = health
+	return b
+}
+
 func (b *Builder) Build() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		limited, err := b.limiter.Limit(ctx, fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP()))
+		key := fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP())
+		lim := b.limiter
+		if b.fallback != nil && b.health != nil && !b.health.Healthy() {
+			lim = b.fallback
+		}
+		limited, err := lim.Limit(ctx, key)
+		if err != nil && b.fallback != nil && lim != b.fallback {
+			log.Println(err)
+			limited, err = b.fallback.Limit(ctx, key)
+		}
 		if err != nil {
 			log.Println(err)
 			// 这一步很有意思，就是如果这边出错了
@@ -0,0 +1,218 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"webok/pkg/limiter"
+	"webok/pkg/logger"
+	"webok/pkg/redisx"
+)
+
+type KeyType string
+
+const (
+	KeyByIP     KeyType = "ip"
+	KeyByUid    KeyType = "uid"
+	KeyByHeader KeyType = "header"
+)
+
+// RuleConfig 一条限流规则，Method 为空表示所有方法。
+// Path 支持 path.Match 的通配符，以 /** 结尾的匹配这个前缀下的所有路径
+type RuleConfig struct {
+	Name    string         `yaml:"name"`
+	Method  string         `yaml:"method"`
+	Path    string         `yaml:"path"`
+	Key     KeyType        `yaml:"key"`
+	Header  string         `yaml:"header"`
+	Limiter limiter.Config `yaml:"limiter"`
+}
+
+// UidExtractor 从请求里面拿到登录用户的 uid，没有登录返回 false
+type UidExtractor func(ctx *gin.Context) (int64, bool)
+
+type rule struct {
+	RuleConfig
+	limiter  limiter.QuotaLimiter
+	fallback limiter.QuotaLimiter
+}
+
+// RuleBuilder 按照规则限流，一个请求命中的所有规则都要通过。
+// 每条规则有自己的限流器，Redis 不可用的时候退化成同样阈值的本地令牌桶
+type RuleBuilder struct {
+	prefix string
+	rules  []rule
+	uid    UidExtractor
+	health redisx.HealthMonitor
+	l      logger.Logger
+}
+
+func NewRuleBuilder(cmd redis.Cmdable, health redisx.HealthMonitor, l logger.Logger, cfgs []RuleConfig) (*RuleBuilder, error) {
+	b := &RuleBuilder{
+		prefix: "rate-limit",
+		uid:    func(ctx *gin.Context) (int64, bool) { return 0, false },
+		health: health,
+		l:      l,
+	}
+	for _, cfg := range cfgs {
+		r, err := newRule(cmd, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("限流规则 %s: %w", cfg.Name, err)
+		}
+		b.rules = append(b.rules, r)
+	}
+	return b, nil
+}
+
+func newRule(cmd redis.Cmdable, cfg RuleConfig) (rule, error) {
+	if cfg.Name == "" || cfg.Path == "" {
+		return rule{}, errors.New("name 和 path 不能为空")
+	}
+	if _, err := path.Match(strings.TrimSuffix(cfg.Path, "/**"), ""); err != nil {
+		return rule{}, err
+	}
+	switch cfg.Key {
+	case "":
+		cfg.Key = KeyByIP
+	case KeyByIP, KeyByUid:
+	case KeyByHeader:
+		if cfg.Header == "" {
+			return rule{}, errors.New("按照 header 限流必须指定 header")
+		}
+	default:
+		return rule{}, fmt.Errorf("未知的 key 类型 %s", cfg.Key)
+	}
+	cfg.Method = strings.ToUpper(cfg.Method)
+	l, err := limiter.New(cmd, cfg.Limiter)
+	if err != nil {
+		return rule{}, err
+	}
+	fallbackCfg := cfg.Limiter
+	fallbackCfg.Algorithm = limiter.AlgorithmLocalTokenBucket
+	fallback, err := limiter.New(nil, fallbackCfg)
+	if err != nil {
+		return rule{}, err
+	}
+	return rule{RuleConfig: cfg, limiter: l, fallback: fallback}, nil
+}
+
+func (b *RuleBuilder) Prefix(prefix string) *RuleBuilder {
+	b.prefix = prefix
+	return b
+}
+
+// Uid 按照 uid 限流的规则用 fn 拿 uid，没有登录的请求按照 IP 限流
+func (b *RuleBuilder) Uid(fn UidExtractor) *RuleBuilder {
+	b.uid = fn
+	return b
+}
+
+func (b *RuleBuilder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var (
+			tightest limiter.Result
+			matched  bool
+		)
+		for _, r := range b.rules {
+			if !r.match(ctx.Request.Method, ctx.Request.URL.Path) {
+				continue
+			}
+			res, err := b.allow(ctx, r)
+			if err != nil {
+				b.l.Error("限流失败",
+					logger.String("rule", r.Name),
+					logger.Error(err))
+				// 和 Builder 一样，保守做法直接拒绝
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			if !matched || tighter(res, tightest) {
+				tightest = res
+				matched = true
+			}
+			if res.Limited {
+				b.l.Warn("触发限流",
+					logger.String("rule", r.Name),
+					logger.String("path", ctx.Request.URL.Path),
+					logger.String("ip", ctx.ClientIP()))
+				break
+			}
+		}
+		if !matched {
+			return
+		}
+		setHeaders(ctx, tightest)
+		if tightest.Limited {
+			ctx.AbortWithStatus(http.StatusTooManyRequests)
+		}
+	}
+}
+
+func (b *RuleBuilder) allow(ctx *gin.Context, r rule) (limiter.Result, error) {
+	key := fmt.Sprintf("%s:%s:%s", b.prefix, r.Name, b.key(ctx, r))
+	if b.health != nil && !b.health.Healthy() {
+		return r.fallback.Allow(ctx, key)
+	}
+	res, err := r.limiter.Allow(ctx, key)
+	if err != nil && r.limiter != r.fallback {
+		b.l.Warn("限流器出错，改用本地限流",
+			logger.String("rule", r.Name),
+			logger.Error(err))
+		return r.fallback.Allow(ctx, key)
+	}
+	return res, err
+}
+
+func (b *RuleBuilder) key(ctx *gin.Context, r rule) string {
+	switch r.Key {
+	case KeyByUid:
+		if uid, ok := b.uid(ctx); ok {
+			return "uid:" + strconv.FormatInt(uid, 10)
+		}
+	case KeyByHeader:
+		if val := ctx.GetHeader(r.Header); val != "" {
+			return "header:" + val
+		}
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+func (r rule) match(method, p string) bool {
+	if r.Method != "" && r.Method != "*" && r.Method != method {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(r.Path, "/**"); ok {
+		return p == prefix || strings.HasPrefix(p, prefix+"/")
+	}
+	ok, _ := path.Match(r.Path, p)
+	return ok
+}
+
+// tighter a 是否比 b 更严格，响应头只报告最严格的那条规则
+func tighter(a, b limiter.Result) bool {
+	if a.Limited != b.Limited {
+		return a.Limited
+	}
+	return a.Remaining < b.Remaining
+}
+
+// setHeaders 参考 IETF RateLimit header fields 草案，时间都是秒
+func setHeaders(ctx *gin.Context, res limiter.Result) {
+	ctx.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+	ctx.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	ctx.Header("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+	if res.Limited {
+		ctx.Header("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+	}
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
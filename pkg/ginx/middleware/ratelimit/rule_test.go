@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"webok/pkg/limiter"
+	"webok/pkg/logger"
+	redisxmocks "webok/pkg/redisx/mock"
+)
+
+func TestRuleBuilder_Build(t *testing.T) {
+	local := func(rate int) limiter.Config {
+		return limiter.Config{
+			Algorithm: limiter.AlgorithmLocalFixedWindow,
+			Interval:  time.Minute,
+			Rate:      rate,
+		}
+	}
+	rules := []RuleConfig{
+		{Name: "global", Path: "/**", Limiter: local(100)},
+		{Name: "login", Method: "post", Path: "/users/login", Limiter: local(2)},
+		{Name: "profile", Path: "/users/prof*", Key: KeyByUid, Limiter: local(1)},
+		{Name: "api", Path: "/api/**", Key: KeyByHeader, Header: "X-Api-Key", Limiter: local(1)},
+	}
+	type req struct {
+		method string
+		path   string
+		uid    int64
+		header string
+	}
+	testCases := []struct {
+		name string
+		reqs []req
+
+		wantCode       int
+		wantRemaining  string
+		wantRetryAfter string
+	}{
+		{
+			name:          "只命中全局规则",
+			reqs:          []req{{method: http.MethodGet, path: "/articles/1"}},
+			wantCode:      http.StatusOK,
+			wantRemaining: "99",
+		},
+		{
+			name: "登录触发限流",
+			reqs: []req{
+				{method: http.MethodPost, path: "/users/login"},
+				{method: http.MethodPost, path: "/users/login"},
+				{method: http.MethodPost, path: "/users/login"},
+			},
+			wantCode:       http.StatusTooManyRequests,
+			wantRemaining:  "0",
+			wantRetryAfter: "60",
+		},
+		{
+			name: "方法不匹配",
+			reqs: []req{
+				{method: http.MethodGet, path: "/users/login"},
+				{method: http.MethodGet, path: "/users/login"},
+				{method: http.MethodGet, path: "/users/login"},
+			},
+			wantCode:      http.StatusOK,
+			wantRemaining: "97",
+		},
+		{
+			name: "按照 uid 限流，不同用户互不影响",
+			reqs: []req{
+				{method: http.MethodGet, path: "/users/profile", uid: 1},
+				{method: http.MethodGet, path: "/users/profile", uid: 2},
+			},
+			wantCode:      http.StatusOK,
+			wantRemaining: "0",
+		},
+		{
+			name: "按照 uid 限流",
+			reqs: []req{
+				{method: http.MethodGet, path: "/users/profile", uid: 1},
+				{method: http.MethodGet, path: "/users/profile", uid: 1},
+			},
+			wantCode:       http.StatusTooManyRequests,
+			wantRemaining:  "0",
+			wantRetryAfter: "60",
+		},
+		{
+			name: "按照 header 限流",
+			reqs: []req{
+				{method: http.MethodGet, path: "/api/a", header: "k1"},
+				{method: http.MethodGet, path: "/api/b", header: "k1"},
+			},
+			wantCode:       http.StatusTooManyRequests,
+			wantRemaining:  "0",
+			wantRetryAfter: "60",
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			health := redisxmocks.NewMockHealthMonitor(ctrl)
+			health.EXPECT().Healthy().Return(true).AnyTimes()
+			b, err := NewRuleBuilder(nil, health, logger.NewNopLogger(), rules)
+			require.NoError(t, err)
+			server := gin.New()
+			server.Use(b.Uid(func(ctx *gin.Context) (int64, bool) {
+				uid, err := strconv.ParseInt(ctx.GetHeader("uid"), 10, 64)
+				return uid, err == nil
+			}).Build())
+			server.Any("/*path", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+
+			var resp *httptest.ResponseRecorder
+			for _, r := range tc.reqs {
+				httpReq := httptest.NewRequest(r.method, r.path, nil)
+				if r.uid != 0 {
+					httpReq.Header.Set("uid", strconv.FormatInt(r.uid, 10))
+				}
+				if r.header != "" {
+					httpReq.Header.Set("X-Api-Key", r.header)
+				}
+				resp = httptest.NewRecorder()
+				server.ServeHTTP(resp, httpReq)
+			}
+			assert.Equal(t, tc.wantCode, resp.Code)
+			assert.Equal(t, tc.wantRemaining, resp.Header().Get("RateLimit-Remaining"))
+			assert.Equal(t, tc.wantRetryAfter, resp.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestNewRuleBuilder(t *testing.T) {
+	_, err := NewRuleBuilder(nil, nil, logger.NewNopLogger(), []RuleConfig{
+		{Name: "header", Path: "/**", Key: KeyByHeader, Limiter: limiter.Config{Interval: time.Second, Rate: 1}},
+	})
+	assert.Error(t, err)
+	_, err = NewRuleBuilder(nil, nil, logger.NewNopLogger(), []RuleConfig{
+		{Name: "bad", Path: "/[", Limiter: limiter.Config{Interval: time.Second, Rate: 1}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,335 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"os"
+	"strings"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+	// TypAccessToken RFC 9068 规定的 access token 类型
+	TypAccessToken = "at+jwt"
+)
+
+var (
+	ErrUnknownKid = errors.New("jwtx: 未知的 kid")
+	ErrNoSignKey  = errors.New("jwtx: 没有可以用来签名的私钥")
+	// ErrTypeMismatch token 头部的 typ 不对，说明拿别的用途的 token 来冒充
+	ErrTypeMismatch = errors.New("jwtx: token 类型不匹配")
+)
+
+// KeyConfig 一把密钥，私钥和公钥都可以直接写 PEM，也可以指定文件。
+// 只配置了公钥的密钥只用来验证，轮换的时候旧的密钥保留一段时间，等用它签发的 token 都过期了再删掉
+type KeyConfig struct {
+	Kid            string
+	Alg            string
+	PrivateKey     string
+	PrivateKeyFile string
+	PublicKey      string
+	PublicKeyFile  string
+}
+
+// Config Signing 是用来签名的密钥的 kid，其它的密钥只用来验证
+type Config struct {
+	Signing string
+	Keys    []KeyConfig
+}
+
+type key struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// KeySet 用一把密钥签名，用所有的密钥验证，按照 token 头部的 kid 找密钥
+type KeySet struct {
+	signing *key
+	keys    map[string]*key
+	// order 保持配置的顺序，JWKS 的输出要稳定
+	order []string
+}
+
+func NewKeySet(cfg Config) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*key, len(cfg.Keys))}
+	for _, kc := range cfg.Keys {
+		k, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("jwtx: 加载密钥 %s 失败 %w", kc.Kid, err)
+		}
+		if _, ok := ks.keys[k.kid]; ok {
+			return nil, fmt.Errorf("jwtx: kid %s 重复", k.kid)
+		}
+		ks.keys[k.kid] = k
+		ks.order = append(ks.order, k.kid)
+	}
+	k, ok := ks.keys[cfg.Signing]
+	if !ok {
+		return nil, fmt.Errorf("%w: 签名的 kid %s", ErrUnknownKid, cfg.Signing)
+	}
+	if k.private == nil {
+		return nil, ErrNoSignKey
+	}
+	ks.signing = k
+	return ks, nil
+}
+
+// NewEphemeralKeySet 随机生成一把 Ed25519 密钥，只适合开发环境和测试，重启之后之前的 token 都会失效
+func NewEphemeralKeySet(kid string) (*KeySet, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	k := &key{kid: kid, method: jwt.SigningMethodEdDSA, private: priv, public: priv.Public()}
+	return &KeySet{signing: k, keys: map[string]*key{kid: k}, order: []string{kid}}, nil
+}
+
+func loadKey(kc KeyConfig) (*key, error) {
+	if kc.Kid == "" {
+		return nil, errors.New("kid 不能为空")
+	}
+	k := &key{kid: kc.Kid}
+	switch kc.Alg {
+	case AlgRS256:
+		k.method = jwt.SigningMethodRS256
+	case AlgEdDSA:
+		k.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("不支持的算法 %s", kc.Alg)
+	}
+	privPEM, err := readPEM(kc.PrivateKey, kc.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if privPEM != nil {
+		k.private, err = parsePrivateKey(kc.Alg, privPEM)
+		if err != nil {
+			return nil, err
+		}
+		k.public = k.private.Public()
+		return k, nil
+	}
+	pubPEM, err := readPEM(kc.PublicKey, kc.PublicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if pubPEM == nil {
+		return nil, errors.New("私钥和公钥至少要配置一个")
+	}
+	k.public, err = parsePublicKey(kc.Alg, pubPEM)
+	return k, err
+}
+
+func readPEM(inline, file string) ([]byte, error) {
+	if inline != "" {
+		return []byte(inline), nil
+	}
+	if file == "" {
+		return nil, nil
+	}
+	return os.ReadFile(file)
+}
+
+func parsePrivateKey(alg string, data []byte) (crypto.Signer, error) {
+	switch alg {
+	case AlgRS256:
+		return jwt.ParseRSAPrivateKeyFromPEM(data)
+	default:
+		pk, err := jwt.ParseEdPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return pk.(crypto.Signer), nil
+	}
+}
+
+func parsePublicKey(alg string, data []byte) (crypto.PublicKey, error) {
+	switch alg {
+	case AlgRS256:
+		return jwt.ParseRSAPublicKeyFromPEM(data)
+	default:
+		return jwt.ParseEdPublicKeyFromPEM(data)
+	}
+}
+
+// Sign 用当前的签名密钥签名，头部带上 kid 和 typ。
+// 同一套密钥会签发好几种 token，typ 用来区分，避免一种 token 被当成另一种来用
+func (ks *KeySet) Sign(typ string, claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	token.Header["kid"] = ks.signing.kid
+	token.Header["typ"] = typ
+	return token.SignedString(ks.signing.private)
+}
+
+// Keyfunc 给 jwt.Parse 用，按照 kid 找公钥，并且检查算法和密钥匹配
+func (ks *KeySet) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKid
+	}
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("jwtx: kid %s 的算法是 %s，token 用的是 %s", kid, k.method.Alg(), token.Method.Alg())
+	}
+	return k.public, nil
+}
+
+// Methods 所有密钥用到的算法，传给 jwt.WithValidMethods，避免 alg 被篡改成 none 或者 HS256
+func (ks *KeySet) Methods() []string {
+	seen := map[string]bool{}
+	var res []string
+	for _, kid := range ks.order {
+		alg := ks.keys[kid].method.Alg()
+		if !seen[alg] {
+			seen[alg] = true
+			res = append(res, alg)
+		}
+	}
+	return res
+}
+
+// Parse 解析并且验证 token，头部的 typ 必须是 typ，iss 和 aud 之类的通过 opts 检查
+func (ks *KeySet) Parse(tokenStr, typ string, claims jwt.Claims, opts ...jwt.ParserOption) (*jwt.Token, error) {
+	opts = append(opts, jwt.WithValidMethods(ks.Methods()))
+	return jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (any, error) {
+		// RFC 8725 建议比较的时候不区分大小写
+		t, _ := token.Header["typ"].(string)
+		if !strings.EqualFold(t, typ) {
+			return nil, fmt.Errorf("%w: 期望 %s，实际是 %s", ErrTypeMismatch, typ, t)
+		}
+		return ks.Keyfunc(token)
+	}, opts...)
+}
+
+// JWK RFC 7517，只包含公钥
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 和 EC，EC 才有 Y
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// PublicKey 解析别人发布的 JWK，验证第三方签发的 token 的时候用
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("jwtx: kid %s 的 RSA 指数不合法", k.Kid)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwtx: 不支持的曲线 %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("jwtx: kid %s 的公钥不在曲线上", k.Kid)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("jwtx: 不支持的曲线 %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwtx: kid %s 的 Ed25519 公钥长度不对", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("jwtx: 不支持的密钥类型 %s", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, errors.New("jwtx: JWK 缺少字段")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 所有用来验证的公钥，其它服务拿去验证 token
+func (ks *KeySet) JWKS() JWKS {
+	res := JWKS{Keys: make([]JWK, 0, len(ks.order))}
+	for _, kid := range ks.order {
+		k := ks.keys[kid]
+		jwk := JWK{Kid: kid, Use: "sig", Alg: k.method.Alg()}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		res.Keys = append(res.Keys, jwk)
+	}
+	return res
+}
+
+// MarshalPrivateKey 把私钥编码成 PKCS #8 的 PEM，生成密钥文件的时候用
+func MarshalPrivateKey(priv crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
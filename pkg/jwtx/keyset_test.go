@@ -0,0 +1,299 @@
+package jwtx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKeySet_Rotate(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	// 旧的 RSA 密钥签发的 token
+	old, err := NewKeySet(Config{
+		Signing: "old",
+		Keys:    []KeyConfig{{Kid: "old", Alg: AlgRS256, PrivateKey: privatePEM(t, rsaKey)}},
+	})
+	require.NoError(t, err)
+	oldToken, err := old.Sign(testTyp, claims())
+	require.NoError(t, err)
+
+	// 轮换之后用 Ed25519 签名，旧的密钥只保留公钥，私钥放在文件里面
+	dir := t.TempDir()
+	privFile := filepath.Join(dir, "new.pem")
+	require.NoError(t, os.WriteFile(privFile, []byte(privatePEM(t, edKey)), 0o600))
+	ks, err := NewKeySet(Config{
+		Signing: "new",
+		Keys: []KeyConfig{
+			{Kid: "new", Alg: AlgEdDSA, PrivateKeyFile: privFile},
+			{Kid: "old", Alg: AlgRS256, PublicKey: publicPEM(t, rsaKey.Public())},
+		},
+	})
+	require.NoError(t, err)
+	newToken, err := ks.Sign(testTyp, claims())
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantKid string
+		wantErr bool
+	}{
+		{name: "新密钥签发", token: newToken, wantKid: "new"},
+		{name: "旧密钥签发，还能验证", token: oldToken, wantKid: "old"},
+		{name: "被篡改", token: newToken[:len(newToken)-2] + "AA", wantErr: true},
+		{name: "HS256 冒充", token: hsToken(t, "old"), wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var rc jwt.RegisteredClaims
+			token, err := ks.Parse(tc.token, testTyp, &rc)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantKid, token.Header["kid"])
+			assert.Equal(t, "123", rc.Subject)
+		})
+	}
+
+	// 旧的密钥删掉之后，旧的 token 就验证不了了
+	removed, err := NewKeySet(Config{
+		Signing: "new",
+		Keys:    []KeyConfig{{Kid: "new", Alg: AlgEdDSA, PrivateKeyFile: privFile}},
+	})
+	require.NoError(t, err)
+	_, err = removed.Parse(oldToken, testTyp, &jwt.RegisteredClaims{})
+	assert.Error(t, err)
+}
+
+func TestKeySet_Parse(t *testing.T) {
+	ks, err := NewEphemeralKeySet("test")
+	require.NoError(t, err)
+	sign := func(typ string, rc jwt.RegisteredClaims) string {
+		str, err := ks.Sign(typ, rc)
+		require.NoError(t, err)
+		return str
+	}
+	rc := jwt.RegisteredClaims{
+		Subject:   "123",
+		Issuer:    "webook",
+		Audience:  jwt.ClaimStrings{"webook"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "类型一致", token: sign(TypAccessToken, rc)},
+		{name: "类型不区分大小写", token: sign("AT+JWT", rc)},
+		{name: "别的类型", token: sign(testTyp, rc), wantErr: ErrTypeMismatch},
+		{name: "没有类型", token: sign("", rc), wantErr: ErrTypeMismatch},
+		{name: "签发方不对", token: sign(TypAccessToken, jwt.RegisteredClaims{
+			Subject:   "123",
+			Issuer:    "other",
+			Audience:  jwt.ClaimStrings{"webook"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		}), wantErr: jwt.ErrTokenInvalidIssuer},
+		{name: "受众不对", token: sign(TypAccessToken, jwt.RegisteredClaims{
+			Subject:   "123",
+			Issuer:    "webook",
+			Audience:  jwt.ClaimStrings{"other"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		}), wantErr: jwt.ErrTokenInvalidAudience},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var res jwt.RegisteredClaims
+			_, err := ks.Parse(tc.token, TypAccessToken, &res,
+				jwt.WithIssuer("webook"), jwt.WithAudience("webook"))
+			assert.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr == nil {
+				assert.Equal(t, "123", res.Subject)
+			}
+		})
+	}
+}
+
+func TestNewKeySet(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	testCases := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{
+			name: "签名的 kid 不存在",
+			cfg: Config{Signing: "a", Keys: []KeyConfig{
+				{Kid: "b", Alg: AlgRS256, PrivateKey: privatePEM(t, rsaKey)},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "签名的密钥只有公钥",
+			cfg: Config{Signing: "a", Keys: []KeyConfig{
+				{Kid: "a", Alg: AlgRS256, PublicKey: publicPEM(t, rsaKey.Public())},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "算法和密钥不匹配",
+			cfg: Config{Signing: "a", Keys: []KeyConfig{
+				{Kid: "a", Alg: AlgEdDSA, PrivateKey: privatePEM(t, rsaKey)},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "不支持的算法",
+			cfg: Config{Signing: "a", Keys: []KeyConfig{
+				{Kid: "a", Alg: "HS512", PrivateKey: privatePEM(t, rsaKey)},
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewKeySet(tc.cfg)
+			assert.Equal(t, tc.wantErr, err != nil)
+		})
+	}
+}
+
+func TestKeySet_JWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ks, err := NewKeySet(Config{
+		Signing: "ed",
+		Keys: []KeyConfig{
+			{Kid: "ed", Alg: AlgEdDSA, PrivateKey: privatePEM(t, edKey)},
+			{Kid: "rsa", Alg: AlgRS256, PublicKey: publicPEM(t, rsaKey.Public())},
+		},
+	})
+	require.NoError(t, err)
+	jwks := ks.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, JWK{Kty: "OKP", Kid: "ed", Use: "sig", Alg: "EdDSA", Crv: "Ed25519",
+		X: base64.RawURLEncoding.EncodeToString(edPub)}, jwks.Keys[0])
+
+	// 用 JWKS 里面的 n 和 e 还原公钥
+	rj := jwks.Keys[1]
+	assert.Equal(t, "RSA", rj.Kty)
+	n, err := base64.RawURLEncoding.DecodeString(rj.N)
+	require.NoError(t, err)
+	e, err := base64.RawURLEncoding.DecodeString(rj.E)
+	require.NoError(t, err)
+	assert.Equal(t, rsaKey.N, new(big.Int).SetBytes(n))
+	assert.Equal(t, int64(rsaKey.E), new(big.Int).SetBytes(e).Int64())
+	assert.Equal(t, []string{"EdDSA", "RS256"}, ks.Methods())
+}
+
+func TestJWK_PublicKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	testCases := []struct {
+		name    string
+		jwk     JWK
+		wantKey crypto.PublicKey
+		wantErr bool
+	}{
+		{
+			name:    "RSA",
+			jwk:     JWK{Kty: "RSA", N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			wantKey: &rsaKey.PublicKey,
+		},
+		{
+			name:    "Ed25519",
+			jwk:     JWK{Kty: "OKP", Crv: "Ed25519", X: b64(edPub)},
+			wantKey: edPub,
+		},
+		{
+			name:    "EC P-256",
+			jwk:     JWK{Kty: "EC", Crv: "P-256", X: b64(ecKey.X.Bytes()), Y: b64(ecKey.Y.Bytes())},
+			wantKey: &ecKey.PublicKey,
+		},
+		{
+			name:    "点不在曲线上",
+			jwk:     JWK{Kty: "EC", Crv: "P-256", X: b64(ecKey.X.Bytes()), Y: b64(ecKey.X.Bytes())},
+			wantErr: true,
+		},
+		{
+			name:    "缺少字段",
+			jwk:     JWK{Kty: "RSA", N: b64(rsaKey.N.Bytes())},
+			wantErr: true,
+		},
+		{
+			name:    "不支持的类型",
+			jwk:     JWK{Kty: "oct", X: "abc"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, err := tc.jwk.PublicKey()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantKey, key)
+		})
+	}
+}
+
+const testTyp = "test+jwt"
+
+func claims() jwt.Claims {
+	return jwt.RegisteredClaims{
+		Subject:   "123",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func hsToken(t *testing.T, kid string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+	token.Header["kid"] = kid
+	token.Header["typ"] = testTyp
+	str, err := token.SignedString([]byte("secret"))
+	require.NoError(t, err)
+	return str
+}
+
+func privatePEM(t *testing.T, priv crypto.Signer) string {
+	data, err := MarshalPrivateKey(priv)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func publicPEM(t *testing.T, pub crypto.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
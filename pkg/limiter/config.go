@@ -0,0 +1,82 @@
+package limiter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+var ErrUnknownAlgorithm = errors.New("未知的限流算法")
+
+type Algorithm string
+
+const (
+	// AlgorithmSlidingWindow 精确，但是每个请求都要在 ZSET 里面占一个元素，高 QPS 的时候很占 Redis 内存
+	AlgorithmSlidingWindow Algorithm = "sliding_window"
+	// AlgorithmTokenBucket 允许突发 Burst 个请求，之后按照速率放行，每个 key 只占一个 hash
+	AlgorithmTokenBucket Algorithm = "token_bucket"
+	// AlgorithmLeakyBucket 按照固定速率放行，Burst 是桶里最多能积压多少请求，适合保护下游
+	AlgorithmLeakyBucket Algorithm = "leaky_bucket"
+	// AlgorithmLocalTokenBucket 进程内的令牌桶，不依赖 Redis，阈值按照单个实例算
+	AlgorithmLocalTokenBucket Algorithm = "local_token_bucket"
+	// AlgorithmLocalFixedWindow 进程内的固定窗口
+	AlgorithmLocalFixedWindow Algorithm = "local_fixed_window"
+)
+
+// Config 一个使用场景的限流配置，Interval 内允许 Rate 个请求
+type Config struct {
+	Algorithm Algorithm     `yaml:"algorithm"`
+	Interval  time.Duration `yaml:"interval"`
+	Rate      int           `yaml:"rate"`
+	// Burst 令牌桶或者漏桶的容量，不设置的时候等于 Rate
+	Burst int `yaml:"burst"`
+	// MaxKeys 本地限流最多记录多少个 key
+	MaxKeys int `yaml:"maxKeys"`
+}
+
+// New 按照配置创建限流器，本地限流的时候 cmd 可以是 nil
+func New(cmd redis.Cmdable, cfg Config) (QuotaLimiter, error) {
+	if cfg.Interval <= 0 || cfg.Rate <= 0 {
+		return nil, fmt.Errorf("限流配置错误, interval: %s, rate: %d", cfg.Interval, cfg.Rate)
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = cfg.Rate
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = 100000
+	}
+	switch cfg.Algorithm {
+	case AlgorithmSlidingWindow, "":
+		return NewRedisSlidingWindowLimiter(cmd, cfg.Interval, cfg.Rate), nil
+	case AlgorithmTokenBucket:
+		return NewRedisTokenBucketLimiter(cmd, cfg.Interval, cfg.Rate, cfg.Burst), nil
+	case AlgorithmLeakyBucket:
+		return NewRedisLeakyBucketLimiter(cmd, cfg.Interval, cfg.Rate, cfg.Burst), nil
+	case AlgorithmLocalTokenBucket:
+		return NewLocalTokenBucketLimiter(cfg.Interval, cfg.Rate, cfg.Burst, cfg.MaxKeys), nil
+	case AlgorithmLocalFixedWindow:
+		return NewLocalFixedWindowLimiter(cfg.Interval, cfg.Rate, cfg.MaxKeys), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, cfg.Algorithm)
+	}
+}
+
+// perMilli 每毫秒补充的配额
+func perMilli(interval time.Duration, rate int) float64 {
+	return float64(rate) / float64(interval.Milliseconds())
+}
+
+// parseResult 解析 Lua 脚本返回的 {limited, remaining, retryAfter, reset}，时间都是毫秒
+func parseResult(vals []int64, limit int) (Result, error) {
+	if len(vals) != 4 {
+		return Result{}, fmt.Errorf("限流脚本返回值错误: %v", vals)
+	}
+	return Result{
+		Limited:    vals[0] == 1,
+		Limit:      limit,
+		Remaining:  int(vals[1]),
+		RetryAfter: time.Duration(vals[2]) * time.Millisecond,
+		Reset:      time.Duration(vals[3]) * time.Millisecond,
+	}, nil
+}
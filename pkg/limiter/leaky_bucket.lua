@@ -0,0 +1,29 @@
+-- 漏桶，记录桶里的水位和上次漏水的时间，请求按照固定速率流出
+local key = KEYS[1]
+-- 桶里最多积压多少请求
+local capacity = tonumber(ARGV[1])
+-- 每毫秒漏掉多少
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local vals = redis.call('HMGET', key, 'level', 'ts')
+local level = tonumber(vals[1]) or 0
+local ts = tonumber(vals[2]) or now
+if now > ts then
+    level = math.max(0, level - (now - ts) * rate)
+    ts = now
+end
+
+local limited = 1
+local retry = 0
+if level + 1 <= capacity then
+    level = level + 1
+    limited = 0
+else
+    retry = math.ceil((level + 1 - capacity) / rate)
+end
+redis.call('HSET', key, 'level', tostring(level), 'ts', ts)
+-- 漏空之后就可以过期了
+local reset = math.ceil(level / rate)
+redis.call('PEXPIRE', key, math.max(reset, 1))
+return {limited, math.floor(capacity - level), retry, reset}
@@ -0,0 +1,58 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+	localMemCache "webok/pkg"
+)
+
+type fixedWindow struct {
+	start time.Time
+	cnt   int
+}
+
+// LocalFixedWindowLimiter 进程内的固定窗口限流，Redis 不可用的时候兜底。
+// 每个实例单独计数，所以整个集群的阈值是 rate 乘以实例数
+type LocalFixedWindowLimiter struct {
+	mu       sync.Mutex
+	windows  *localMemCache.Cache[string, *fixedWindow]
+	interval time.Duration
+	rate     int
+	now      func() time.Time
+}
+
+// NewLocalFixedWindowLimiter maxKeys 限制最多记录多少个 key，超过的时候淘汰最久没有访问的
+func NewLocalFixedWindowLimiter(interval time.Duration, rate int, maxKeys int) *LocalFixedWindowLimiter {
+	return &LocalFixedWindowLimiter{
+		windows: localMemCache.NewCache[string, *fixedWindow](localMemCache.Config[string, *fixedWindow]{
+			MaxEntries: maxKeys,
+			DefaultTTL: interval,
+		}),
+		interval: interval,
+		rate:     rate,
+		now:      time.Now,
+	}
+}
+
+func (l *LocalFixedWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := l.Allow(ctx, key)
+	return res.Limited, err
+}
+
+func (l *LocalFixedWindowLimiter) Allow(_ context.Context, key string) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	w, ok := l.windows.Get(key)
+	if !ok || now.Sub(w.start) >= l.interval {
+		w = &fixedWindow{start: now}
+		l.windows.Set(key, w, l.interval)
+	}
+	reset := w.start.Add(l.interval).Sub(now)
+	if w.cnt >= l.rate {
+		return Result{Limited: true, Limit: l.rate, RetryAfter: reset, Reset: reset}, nil
+	}
+	w.cnt++
+	return Result{Limit: l.rate, Remaining: l.rate - w.cnt, Reset: reset}, nil
+}
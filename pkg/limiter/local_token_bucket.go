@@ -0,0 +1,72 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+	localMemCache "webok/pkg"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// LocalTokenBucketLimiter 进程内的令牌桶，算法和 RedisTokenBucketLimiter 一样，
+// 不需要访问 Redis，适合单机部署或者只保护本实例的场景
+type LocalTokenBucketLimiter struct {
+	mu      sync.Mutex
+	buckets *localMemCache.Cache[string, *bucket]
+	// 每毫秒补充多少令牌
+	rate  float64
+	burst int
+	now   func() time.Time
+}
+
+// NewLocalTokenBucketLimiter interval 内补充 rate 个令牌，桶里最多 burst 个
+func NewLocalTokenBucketLimiter(interval time.Duration, rate int, burst int, maxKeys int) *LocalTokenBucketLimiter {
+	return &LocalTokenBucketLimiter{
+		buckets: localMemCache.NewCache[string, *bucket](localMemCache.Config[string, *bucket]{
+			MaxEntries: maxKeys,
+		}),
+		rate:  perMilli(interval, rate),
+		burst: burst,
+		now:   time.Now,
+	}
+}
+
+func (l *LocalTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := l.Allow(ctx, key)
+	return res.Limited, err
+}
+
+func (l *LocalTokenBucketLimiter) Allow(_ context.Context, key string) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b, ok := l.buckets.Get(key)
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(l.burst), b.tokens+float64(elapsed)/float64(time.Millisecond)*l.rate)
+		b.last = now
+	}
+	res := Result{Limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+	} else {
+		res.Limited = true
+		res.RetryAfter = l.millis((1 - b.tokens) / l.rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = l.millis((float64(l.burst) - b.tokens) / l.rate)
+	// 补满之后和不存在没有区别，可以淘汰
+	l.buckets.Set(key, b, res.Reset+time.Millisecond)
+	return res, nil
+}
+
+func (l *LocalTokenBucketLimiter) millis(ms float64) time.Duration {
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
@@ -0,0 +1,73 @@
+package limiter
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLocalTokenBucketLimiter_Allow(t *testing.T) {
+	testCases := []struct {
+		name string
+		// 每次请求之前过去的时间
+		steps []time.Duration
+
+		want Result
+	}{
+		{
+			name:  "第一个请求",
+			steps: []time.Duration{0},
+			want:  Result{Limit: 3, Remaining: 2, Reset: time.Millisecond * 100},
+		},
+		{
+			name:  "突发用完",
+			steps: []time.Duration{0, 0, 0, 0},
+			want: Result{Limited: true, Limit: 3, Remaining: 0,
+				RetryAfter: time.Millisecond * 100, Reset: time.Millisecond * 300},
+		},
+		{
+			name:  "补充令牌",
+			steps: []time.Duration{0, 0, 0, time.Millisecond * 150},
+			want:  Result{Limit: 3, Remaining: 0, Reset: time.Millisecond * 250},
+		},
+		{
+			name:  "最多补满",
+			steps: []time.Duration{0, 0, 0, time.Second},
+			want:  Result{Limit: 3, Remaining: 2, Reset: time.Millisecond * 100},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 每秒 10 个，最多突发 3 个
+			l := NewLocalTokenBucketLimiter(time.Second, 10, 3, 100)
+			now := time.UnixMilli(1000)
+			l.now = func() time.Time { return now }
+			var res Result
+			var err error
+			for _, step := range tc.steps {
+				now = now.Add(step)
+				res, err = l.Allow(context.Background(), "key")
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.want, res)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, Config{Algorithm: "unknown", Interval: time.Second, Rate: 1})
+	assert.ErrorIs(t, err, ErrUnknownAlgorithm)
+	_, err = New(nil, Config{Algorithm: AlgorithmTokenBucket})
+	assert.Error(t, err)
+	l, err := New(nil, Config{Algorithm: AlgorithmLocalTokenBucket, Interval: time.Second, Rate: 1})
+	require.NoError(t, err)
+	limited, err := l.Limit(context.Background(), "key")
+	require.NoError(t, err)
+	assert.False(t, limited)
+	limited, err = l.Limit(context.Background(), "key")
+	require.NoError(t, err)
+	assert.True(t, limited)
+}
@@ -12,6 +12,7 @@ package limitmocks
 import (
 	context "context"
 	reflect "reflect"
+	limiter "webok/pkg/limiter"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -54,3 +55,57 @@ func (mr *MockLimiterMockRecorder) Limit(ctx, key any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Limit", reflect.TypeOf((*MockLimiter)(nil).Limit), ctx, key)
 }
+
+// MockQuotaLimiter is a mock of QuotaLimiter interface.
+type MockQuotaLimiter struct {
+	ctrl     *gomock.Controller
+	recorder *MockQuotaLimiterMockRecorder
+	isgomock struct{}
+}
+
+// MockQuotaLimiterMockRecorder is the mock recorder for MockQuotaLimiter.
+type MockQuotaLimiterMockRecorder struct {
+	mock *MockQuotaLimiter
+}
+
+// NewMockQuotaLimiter creates a new mock instance.
+func NewMockQuotaLimiter(ctrl *gomock.Controller) *MockQuotaLimiter {
+	mock := &MockQuotaLimiter{ctrl: ctrl}
+	mock.recorder = &MockQuotaLimiterMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockQuotaLimiter) EXPECT() *MockQuotaLimiterMockRecorder {
+	return m.recorder
+}
+
+// Allow mocks base method.
+func (m *MockQuotaLimiter) Allow(ctx context.Context, key string) (limiter.Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Allow", ctx, key)
+	ret0, _ := ret[0].(limiter.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Allow indicates an expected call of Allow.
+func (mr *MockQuotaLimiterMockRecorder) Allow(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Allow", reflect.TypeOf((*MockQuotaLimiter)(nil).Allow), ctx, key)
+}
+
+// Limit mocks base method.
+func (m *MockQuotaLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Limit", ctx, key)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Limit indicates an expected call of Limit.
+func (mr *MockQuotaLimiterMockRecorder) Limit(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Limit", reflect.TypeOf((*MockQuotaLimiter)(nil).Limit), ctx, key)
+}
@@ -0,0 +1,88 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// 每秒 10 个，令牌桶最多突发 3 个，漏桶最多积压 3 个，两种算法在这些场景下结果一样
+var redisBucketCases = []struct {
+	name string
+	// 每次请求之前过去的时间
+	steps []time.Duration
+
+	want Result
+	// 最后一次请求之后 key 的过期时间
+	wantTTL time.Duration
+}{
+	{
+		name:    "第一个请求",
+		steps:   []time.Duration{0},
+		want:    Result{Limit: 3, Remaining: 2, Reset: time.Millisecond * 100},
+		wantTTL: time.Millisecond * 100,
+	},
+	{
+		name:  "突发用完",
+		steps: []time.Duration{0, 0, 0, 0},
+		want: Result{Limited: true, Limit: 3, Remaining: 0,
+			RetryAfter: time.Millisecond * 100, Reset: time.Millisecond * 300},
+		wantTTL: time.Millisecond * 300,
+	},
+	{
+		name:    "过一段时间恢复一部分",
+		steps:   []time.Duration{0, 0, 0, time.Millisecond * 150},
+		want:    Result{Limit: 3, Remaining: 0, Reset: time.Millisecond * 250},
+		wantTTL: time.Millisecond * 250,
+	},
+	{
+		name:    "最多恢复到上限",
+		steps:   []time.Duration{0, 0, 0, time.Second},
+		want:    Result{Limit: 3, Remaining: 2, Reset: time.Millisecond * 100},
+		wantTTL: time.Millisecond * 100,
+	},
+}
+
+func TestRedisTokenBucketLimiter_Allow(t *testing.T) {
+	for _, tc := range redisBucketCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			l := NewRedisTokenBucketLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Second, 10, 3)
+			now := time.UnixMilli(1000)
+			l.now = func() time.Time { return now }
+			var res Result
+			var err error
+			for _, step := range tc.steps {
+				now = now.Add(step)
+				res, err = l.Allow(context.Background(), "key")
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.want, res)
+			assert.Equal(t, tc.wantTTL, mr.TTL("key"))
+		})
+	}
+}
+
+func TestRedisLeakyBucketLimiter_Allow(t *testing.T) {
+	for _, tc := range redisBucketCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			l := NewRedisLeakyBucketLimiter(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Second, 10, 3)
+			now := time.UnixMilli(1000)
+			l.now = func() time.Time { return now }
+			var res Result
+			var err error
+			for _, step := range tc.steps {
+				now = now.Add(step)
+				res, err = l.Allow(context.Background(), "key")
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.want, res)
+			assert.Equal(t, tc.wantTTL, mr.TTL("key"))
+		})
+	}
+}
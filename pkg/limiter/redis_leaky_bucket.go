@@ -0,0 +1,45 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed leaky_bucket.lua
+var luaLeakyBucket string
+
+// RedisLeakyBucketLimiter 漏桶，请求按照固定速率放行，capacity 越小流量越平滑
+type RedisLeakyBucketLimiter struct {
+	cmd      redis.Cmdable
+	interval time.Duration
+	rate     int
+	capacity int
+	now      func() time.Time
+}
+
+// NewRedisLeakyBucketLimiter interval 内漏掉 rate 个请求，桶里最多积压 capacity 个
+func NewRedisLeakyBucketLimiter(cmd redis.Cmdable, interval time.Duration, rate int, capacity int) *RedisLeakyBucketLimiter {
+	return &RedisLeakyBucketLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+		capacity: capacity,
+		now:      time.Now,
+	}
+}
+
+func (r *RedisLeakyBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := r.Allow(ctx, key)
+	return res.Limited, err
+}
+
+func (r *RedisLeakyBucketLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	vals, err := r.cmd.Eval(ctx, luaLeakyBucket, []string{key},
+		r.capacity, perMilli(r.interval, r.rate), r.now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return parseResult(vals, r.capacity)
+}
@@ -3,6 +3,7 @@ package limiter
 import (
 	"context"
 	_ "embed"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"time"
 )
@@ -17,9 +18,18 @@ type RedisSlidingWindowLimiter struct {
 }
 
 func (r *RedisSlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
-	return r.cmd.Eval(ctx, luaScript, []string{key},
-		r.interval.Milliseconds(), r.rate, time.Now().UnixMilli()).
-		Bool()
+	res, err := r.Allow(ctx, key)
+	return res.Limited, err
+}
+
+func (r *RedisSlidingWindowLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	vals, err := r.cmd.Eval(ctx, luaScript, []string{key},
+		r.interval.Milliseconds(), r.rate, time.Now().UnixMilli(), uuid.New().String()).
+		Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return parseResult(vals, r.rate)
 }
 
 func NewRedisSlidingWindowLimiter(cmd redis.Cmdable, interval time.Duration, rate int) *RedisSlidingWindowLimiter {
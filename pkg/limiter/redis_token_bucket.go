@@ -0,0 +1,45 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed token_bucket.lua
+var luaTokenBucket string
+
+// RedisTokenBucketLimiter 令牌桶，每个 key 只占一个 hash，不随 QPS 增长
+type RedisTokenBucketLimiter struct {
+	cmd      redis.Cmdable
+	interval time.Duration
+	rate     int
+	burst    int
+	now      func() time.Time
+}
+
+// NewRedisTokenBucketLimiter interval 内补充 rate 个令牌，桶里最多 burst 个
+func NewRedisTokenBucketLimiter(cmd redis.Cmdable, interval time.Duration, rate int, burst int) *RedisTokenBucketLimiter {
+	return &RedisTokenBucketLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+		burst:    burst,
+		now:      time.Now,
+	}
+}
+
+func (r *RedisTokenBucketLimiter) Limit(ctx context.Context, key string) (bool, error) {
+	res, err := r.Allow(ctx, key)
+	return res.Limited, err
+}
+
+func (r *RedisTokenBucketLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	vals, err := r.cmd.Eval(ctx, luaTokenBucket, []string{key},
+		r.burst, perMilli(r.interval, r.rate), r.now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return parseResult(vals, r.burst)
+}
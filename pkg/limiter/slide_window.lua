@@ -9,6 +9,8 @@ local window = tonumber(ARGV[1])
 -- 阈值
 local threshold = tonumber( ARGV[2])
 local now = tonumber(ARGV[3])
+-- 同一毫秒可能有多个请求，member 不能直接用 now
+local member = ARGV[4]
 -- 窗口的起始时间
 local min = now - window
 
@@ -16,11 +18,12 @@ redis.call('ZREMRANGEBYSCORE', key, '-inf', min)
 local cnt = redis.call('ZCOUNT', key, '-inf', '+inf')
 -- local cnt = redis.call('ZCOUNT', key, min, '+inf')
 if cnt >= threshold then
-    -- 执行限流
-    return "true"
+    -- 执行限流，最早的请求移出窗口之后才能重试
+    local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+    local retry = tonumber(oldest[2]) + window - now
+    return {1, 0, retry, window}
 else
-    -- 把 score 和 member 都设置成 now
-    redis.call('ZADD', key, now, now)
+    redis.call('ZADD', key, now, member)
     redis.call('PEXPIRE', key, window)
-    return "false"
-end
\ No newline at end of file
+    return {0, threshold - cnt - 1, 0, window}
+end
@@ -0,0 +1,33 @@
+-- 令牌桶，只记录剩余令牌数和上次补充的时间
+local key = KEYS[1]
+-- 桶的容量
+local capacity = tonumber(ARGV[1])
+-- 每毫秒补充多少令牌
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local vals = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(vals[1])
+local ts = tonumber(vals[2])
+if tokens == nil then
+    tokens = capacity
+    ts = now
+end
+if now > ts then
+    tokens = math.min(capacity, tokens + (now - ts) * rate)
+    ts = now
+end
+
+local limited = 1
+local retry = 0
+if tokens >= 1 then
+    tokens = tokens - 1
+    limited = 0
+else
+    retry = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', ts)
+-- 补满之后桶里的状态和不存在没有区别，可以直接过期
+local reset = math.ceil((capacity - tokens) / rate)
+redis.call('PEXPIRE', key, math.max(reset, 1))
+return {limited, math.floor(tokens), retry, reset}
@@ -1,8 +1,29 @@
 package limiter
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 //go:generate mockgen -source=types.go -package=limitmocks -destination=./mock/limit.mock.go
 type Limiter interface {
 	Limit(ctx context.Context, key string) (bool, error)
 }
+
+// QuotaLimiter 除了是否限流，还返回剩余配额，调用方可以据此设置 RateLimit-* 和 Retry-After 响应头
+type QuotaLimiter interface {
+	Limiter
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+type Result struct {
+	Limited bool
+	// Limit 配额上限
+	Limit int
+	// Remaining 这次请求之后还剩多少配额
+	Remaining int
+	// RetryAfter 被限流的时候，至少过多久再重试
+	RetryAfter time.Duration
+	// Reset 配额完全恢复需要多久
+	Reset time.Duration
+}
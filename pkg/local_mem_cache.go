@@ -1,8 +1,9 @@
 package localMemCache
 
 import (
+	"container/heap"
+	"container/list"
 	"errors"
-	"log"
 	"sync"
 	"time"
 )
@@ -10,79 +11,423 @@ import (
 var ErrKeyAlreadyExists = errors.New("key already exists")
 var ErrKeyNotFound = errors.New("key not found")
 
-type Item struct {
-	Expire time.Time
-	Data   any
-}
-type LocalMemCache struct {
-	sync.Mutex
+// Policy 超过容量的时候淘汰哪个 key
+type Policy uint8
+
+const (
+	// PolicyLRU 淘汰最久没有访问的
+	PolicyLRU Policy = iota
+	// PolicyLFU 淘汰访问次数最少的，次数一样的淘汰最久没有访问的
+	PolicyLFU
+)
+
+// EvictReason key 被移除的原因
+type EvictReason uint8
+
+const (
+	EvictReasonExpired EvictReason = iota + 1
+	EvictReasonCapacity
+	EvictReasonDeleted
+)
+
+type Config[K comparable, V any] struct {
+	// MaxEntries 最多保存多少个 key，0 表示不限制
+	MaxEntries int
+	// MaxBytes 所有 value 加起来最多多大，0 表示不限制，不为 0 的时候必须设置 SizeOf
+	MaxBytes int64
+	SizeOf   func(key K, val V) int64
+	Policy   Policy
+	// DefaultTTL Set 的时候没有指定过期时间就用这个，0 表示不过期
+	DefaultTTL time.Duration
+	// CleanInterval 后台清理过期 key 的间隔，0 表示只在访问的时候检查
 	CleanInterval time.Duration
-	cache         map[string]Item
+	// OnEvict key 被移除的时候调用，不会持有锁，可以在里面访问缓存
+	OnEvict func(key K, val V, reason EvictReason)
+}
+
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Expired   uint64
+}
+
+type entry[K comparable, V any] struct {
+	key    K
+	val    V
+	expire time.Time
+	size   int64
+
+	// LRU 用
+	elem *list.Element
+	// LFU 用
+	freq  uint64
+	tick  uint64
+	index int
+}
+
+func (e *entry[K, V]) expired(now time.Time) bool {
+	return !e.expire.IsZero() && e.expire.Before(now)
+}
+
+// Cache 有容量上限的本地缓存，并发安全。用完之后记得 Close
+type Cache[K comparable, V any] struct {
+	mu      sync.Mutex
+	cfg     Config[K, V]
+	items   map[K]*entry[K, V]
+	policy  policy[K, V]
+	bytes   int64
+	stats   Stats
+	stop    chan struct{}
+	done    chan struct{}
+	closeMu sync.Once
 }
 
-func NewLocalMemCache(cleanInterval time.Duration) *LocalMemCache {
-	cache := LocalMemCache{
-		CleanInterval: cleanInterval,
-		cache:         make(map[string]Item),
+func NewCache[K comparable, V any](cfg Config[K, V]) *Cache[K, V] {
+	if cfg.MaxBytes > 0 && cfg.SizeOf == nil {
+		panic("localMemCache: 设置了 MaxBytes 就必须设置 SizeOf")
 	}
-	go func(c *LocalMemCache) {
-		for {
-			time.Sleep(10 * time.Second)
-			cache.Clean()
-		}
+	c := &Cache[K, V]{
+		cfg:   cfg,
+		items: make(map[K]*entry[K, V]),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	switch cfg.Policy {
+	case PolicyLFU:
+		c.policy = &lfu[K, V]{}
+	default:
+		c.policy = &lru[K, V]{ll: list.New()}
+	}
+	if cfg.CleanInterval > 0 {
+		go c.cleanLoop()
+	} else {
+		close(c.done)
+	}
+	return c
+}
 
-	}(&cache)
-	return &cache
+// Get 返回 key 对应的值，过期的当成不存在
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	val, _, ok := c.GetWithTTL(key)
+	return val, ok
 }
 
-func (c *LocalMemCache) Add(key string, value any, expire time.Duration) error {
-	c.Lock()
-	defer c.Unlock()
-	_, ok := c.cache[key]
-	if ok {
+// GetWithTTL 同时返回剩余的过期时间，不过期的 key 返回 0
+func (c *Cache[K, V]) GetWithTTL(key K) (V, time.Duration, bool) {
+	var evicted []*entry[K, V]
+	defer func() { c.notify(evicted, EvictReasonExpired) }()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	e, ok := c.items[key]
+	if ok && e.expired(now) {
+		c.remove(e)
+		c.stats.Expired++
+		evicted = append(evicted, e)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		var v V
+		return v, 0, false
+	}
+	c.stats.Hits++
+	c.policy.touch(e)
+	var ttl time.Duration
+	if !e.expire.IsZero() {
+		ttl = e.expire.Sub(now)
+	}
+	return e.val, ttl, true
+}
+
+// Set 写入或者覆盖，ttl 为 0 的时候使用 DefaultTTL
+func (c *Cache[K, V]) Set(key K, val V, ttl time.Duration) {
+	var evicted []*entry[K, V]
+	defer func() { c.notify(evicted, EvictReasonCapacity) }()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	evicted = c.set(key, val, ttl)
+}
+
+// Add key 已经存在并且没有过期的时候返回 ErrKeyAlreadyExists
+func (c *Cache[K, V]) Add(key K, val V, ttl time.Duration) error {
+	var evicted []*entry[K, V]
+	defer func() { c.notify(evicted, EvictReasonCapacity) }()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok && !e.expired(time.Now()) {
 		return ErrKeyAlreadyExists
 	}
-	c.cache[key] = Item{
-		Expire: time.Now().Add(expire),
-		Data:   value,
+	evicted = c.set(key, val, ttl)
+	return nil
+}
+
+// Update 修改值，保留原本的过期时间
+func (c *Cache[K, V]) Update(key K, val V) error {
+	var evicted []*entry[K, V]
+	defer func() { c.notify(evicted, EvictReasonCapacity) }()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok || e.expired(time.Now()) {
+		return ErrKeyNotFound
 	}
+	c.resize(e, val)
+	e.val = val
+	c.policy.touch(e)
+	evicted = c.evict()
 	return nil
 }
 
-func (c *LocalMemCache) Update(key string, value any) error {
-	c.Lock()
-	defer c.Unlock()
-	res, ok := c.cache[key]
+// Delete 删除 key，返回 key 是否存在
+func (c *Cache[K, V]) Delete(key K) bool {
+	var evicted []*entry[K, V]
+	defer func() { c.notify(evicted, EvictReasonDeleted) }()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
 	if !ok {
-		return ErrKeyNotFound
+		return false
 	}
-	c.cache[key] = Item{
-		Expire: res.Expire,
-		Data:   value,
+	c.remove(e)
+	evicted = append(evicted, e)
+	return true
+}
+
+// Purge 清空缓存，不会触发 OnEvict
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range c.items {
+		c.policy.remove(e)
 	}
+	c.items = make(map[K]*entry[K, V])
+	c.bytes = 0
+}
+
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Clean 删除所有过期的 key
+func (c *Cache[K, V]) Clean() {
+	var evicted []*entry[K, V]
+	defer func() { c.notify(evicted, EvictReasonExpired) }()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for _, e := range c.items {
+		if e.expired(now) {
+			c.remove(e)
+			c.stats.Expired++
+			evicted = append(evicted, e)
+		}
+	}
+}
+
+// Close 停止后台清理，等正在进行的清理结束之后才返回，可以重复调用
+func (c *Cache[K, V]) Close() error {
+	c.closeMu.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
 	return nil
 }
 
-func (c *LocalMemCache) Del(key string) {
-	c.Lock()
-	defer c.Unlock()
-	delete(c.cache, key)
+func (c *Cache[K, V]) cleanLoop() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.cfg.CleanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.Clean()
+		case <-c.stop:
+			return
+		}
+	}
 }
 
-func (c *LocalMemCache) Clean() {
-	c.Lock()
-	defer c.Unlock()
-	for k, v := range c.cache {
-		if v.Expire.Before(time.Now()) {
-			delete(c.cache, k)
+func (c *Cache[K, V]) set(key K, val V, ttl time.Duration) []*entry[K, V] {
+	if ttl == 0 {
+		ttl = c.cfg.DefaultTTL
+	}
+	var expire time.Time
+	if ttl > 0 {
+		expire = time.Now().Add(ttl)
+	}
+	if e, ok := c.items[key]; ok {
+		c.resize(e, val)
+		e.val = val
+		e.expire = expire
+		c.policy.touch(e)
+		return c.evict()
+	}
+	e := &entry[K, V]{key: key, val: val, expire: expire}
+	if c.cfg.SizeOf != nil {
+		e.size = c.cfg.SizeOf(key, val)
+	}
+	if c.cfg.MaxBytes > 0 && e.size > c.cfg.MaxBytes {
+		// 本身就超过上限，不缓存
+		c.stats.Evictions++
+		return []*entry[K, V]{e}
+	}
+	// 先腾出空间再放进去，不然 LFU 总是会把刚放进去的淘汰掉
+	evicted := c.evictFor(1, e.size)
+	c.items[key] = e
+	c.policy.add(e)
+	c.bytes += e.size
+	return evicted
+}
+
+func (c *Cache[K, V]) resize(e *entry[K, V], val V) {
+	if c.cfg.SizeOf == nil {
+		return
+	}
+	size := c.cfg.SizeOf(e.key, val)
+	c.bytes += size - e.size
+	e.size = size
+}
+
+// evict 超过容量的时候按照策略淘汰
+func (c *Cache[K, V]) evict() []*entry[K, V] {
+	return c.evictFor(0, 0)
+}
+
+// evictFor 按照策略淘汰，直到能够再放下 n 个 key、size 大小的数据
+func (c *Cache[K, V]) evictFor(n int, size int64) []*entry[K, V] {
+	var evicted []*entry[K, V]
+	for c.overflow(n, size) {
+		e := c.policy.victim()
+		if e == nil {
+			break
 		}
-		log.Printf("[%v]:%v", k, v.Data)
+		c.remove(e)
+		c.stats.Evictions++
+		evicted = append(evicted, e)
+	}
+	return evicted
+}
+
+func (c *Cache[K, V]) overflow(n int, size int64) bool {
+	return (c.cfg.MaxEntries > 0 && len(c.items)+n > c.cfg.MaxEntries) ||
+		(c.cfg.MaxBytes > 0 && c.bytes+size > c.cfg.MaxBytes)
+}
+
+func (c *Cache[K, V]) remove(e *entry[K, V]) {
+	c.policy.remove(e)
+	delete(c.items, e.key)
+	c.bytes -= e.size
+}
+
+func (c *Cache[K, V]) notify(evicted []*entry[K, V], reason EvictReason) {
+	if c.cfg.OnEvict == nil {
+		return
+	}
+	for _, e := range evicted {
+		c.cfg.OnEvict(e.key, e.val, reason)
+	}
+}
+
+type policy[K comparable, V any] interface {
+	add(e *entry[K, V])
+	touch(e *entry[K, V])
+	remove(e *entry[K, V])
+	// victim 下一个要淘汰的
+	victim() *entry[K, V]
+}
+
+type lru[K comparable, V any] struct {
+	ll *list.List
+}
+
+func (l *lru[K, V]) add(e *entry[K, V]) {
+	e.elem = l.ll.PushFront(e)
+}
+
+func (l *lru[K, V]) touch(e *entry[K, V]) {
+	l.ll.MoveToFront(e.elem)
+}
+
+func (l *lru[K, V]) remove(e *entry[K, V]) {
+	l.ll.Remove(e.elem)
+}
+
+func (l *lru[K, V]) victim() *entry[K, V] {
+	back := l.ll.Back()
+	if back == nil {
+		return nil
 	}
+	return back.Value.(*entry[K, V])
+}
+
+// lfu 用最小堆，堆顶是访问次数最少的
+type lfu[K comparable, V any] struct {
+	entries []*entry[K, V]
+	tick    uint64
+}
+
+func (l *lfu[K, V]) add(e *entry[K, V]) {
+	l.tick++
+	e.freq = 1
+	e.tick = l.tick
+	heap.Push(l, e)
+}
+
+func (l *lfu[K, V]) touch(e *entry[K, V]) {
+	l.tick++
+	e.freq++
+	e.tick = l.tick
+	heap.Fix(l, e.index)
+}
+
+func (l *lfu[K, V]) remove(e *entry[K, V]) {
+	heap.Remove(l, e.index)
+}
+
+func (l *lfu[K, V]) victim() *entry[K, V] {
+	if len(l.entries) == 0 {
+		return nil
+	}
+	return l.entries[0]
+}
+
+func (l *lfu[K, V]) Len() int {
+	return len(l.entries)
+}
+
+func (l *lfu[K, V]) Less(i, j int) bool {
+	if l.entries[i].freq != l.entries[j].freq {
+		return l.entries[i].freq < l.entries[j].freq
+	}
+	return l.entries[i].tick < l.entries[j].tick
+}
+
+func (l *lfu[K, V]) Swap(i, j int) {
+	l.entries[i], l.entries[j] = l.entries[j], l.entries[i]
+	l.entries[i].index = i
+	l.entries[j].index = j
+}
+
+func (l *lfu[K, V]) Push(x any) {
+	e := x.(*entry[K, V])
+	e.index = len(l.entries)
+	l.entries = append(l.entries, e)
 }
 
-func (c *LocalMemCache) Get(key string) (Item, bool) {
-	c.Lock()
-	defer c.Unlock()
-	item, ok := c.cache[key]
-	return item, ok
+func (l *lfu[K, V]) Pop() any {
+	n := len(l.entries)
+	e := l.entries[n-1]
+	l.entries[n-1] = nil
+	l.entries = l.entries[:n-1]
+	return e
 }
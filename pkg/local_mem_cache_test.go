@@ -0,0 +1,127 @@
+package localMemCache
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type evicted struct {
+	key    string
+	reason EvictReason
+}
+
+func TestCache_LRU(t *testing.T) {
+	var got []evicted
+	c := NewCache[string, int](Config[string, int]{
+		MaxEntries: 2,
+		OnEvict: func(key string, val int, reason EvictReason) {
+			got = append(got, evicted{key: key, reason: reason})
+		},
+	})
+	defer c.Close()
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+	// 访问 a 之后，最久没有访问的是 b
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	c.Set("c", 3, 0)
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, []evicted{{key: "b", reason: EvictReasonCapacity}}, got)
+	assert.Equal(t, Stats{Hits: 1, Misses: 1, Evictions: 1}, c.Stats())
+}
+
+func TestCache_LFU(t *testing.T) {
+	c := NewCache[string, int](Config[string, int]{
+		MaxEntries: 2,
+		Policy:     PolicyLFU,
+	})
+	defer c.Close()
+	c.Set("a", 1, 0)
+	c.Set("b", 2, 0)
+	c.Get("a")
+	c.Get("a")
+	c.Get("b")
+	// a 访问了三次，b 两次，淘汰 b
+	c.Set("c", 3, 0)
+	_, ok := c.Get("b")
+	assert.False(t, ok)
+	_, ok = c.Get("a")
+	assert.True(t, ok)
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+}
+
+func TestCache_MaxBytes(t *testing.T) {
+	c := NewCache[string, string](Config[string, string]{
+		MaxBytes: 10,
+		SizeOf: func(key string, val string) int64 {
+			return int64(len(val))
+		},
+	})
+	defer c.Close()
+	c.Set("a", "12345", 0)
+	c.Set("b", "12345", 0)
+	assert.Equal(t, 2, c.Len())
+	c.Set("c", "1", 0)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	// 比上限还大的直接淘汰
+	c.Set("d", "12345678901", 0)
+	_, ok = c.Get("d")
+	assert.False(t, ok)
+
+	require.NoError(t, c.Update("b", "1234567890"))
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestCache_TTL(t *testing.T) {
+	var got []evicted
+	c := NewCache[string, int](Config[string, int]{
+		DefaultTTL:    time.Minute,
+		CleanInterval: time.Millisecond * 10,
+		OnEvict: func(key string, val int, reason EvictReason) {
+			got = append(got, evicted{key: key, reason: reason})
+		},
+	})
+	defer c.Close()
+	c.Set("a", 1, time.Millisecond)
+	c.Set("b", 2, 0)
+
+	_, ttl, ok := c.GetWithTTL("b")
+	assert.True(t, ok)
+	assert.Greater(t, ttl, time.Second*59)
+
+	// 后台清理
+	assert.Eventually(t, func() bool {
+		return c.Len() == 1
+	}, time.Second, time.Millisecond*10)
+	c.Close()
+	assert.Equal(t, []evicted{{key: "a", reason: EvictReasonExpired}}, got)
+	assert.Equal(t, uint64(1), c.Stats().Expired)
+}
+
+func TestCache_AddUpdateDelete(t *testing.T) {
+	c := NewCache[string, int](Config[string, int]{})
+	defer c.Close()
+	require.NoError(t, c.Add("a", 1, time.Minute))
+	assert.Equal(t, ErrKeyAlreadyExists, c.Add("a", 2, time.Minute))
+	assert.Equal(t, ErrKeyNotFound, c.Update("b", 2))
+
+	require.NoError(t, c.Update("a", 2))
+	val, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, val)
+
+	assert.True(t, c.Delete("a"))
+	assert.False(t, c.Delete("a"))
+
+	// 过期的 key 可以重新 Add
+	require.NoError(t, c.Add("c", 1, time.Millisecond))
+	time.Sleep(time.Millisecond * 2)
+	require.NoError(t, c.Add("c", 2, time.Minute))
+}
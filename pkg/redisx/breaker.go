@@ -0,0 +1,179 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"net"
+	"sync"
+	"time"
+	"webok/pkg/logger"
+)
+
+//go:generate mockgen -source=breaker.go -package=redisxmocks -destination=./mock/breaker.mock.go
+type HealthMonitor interface {
+	// Healthy Redis 是否可用，返回 false 的时候调用方应该降级，不再访问 Redis
+	Healthy() bool
+}
+
+type state uint8
+
+const (
+	stateClosed state = iota
+	stateOpen
+	// stateHalfOpen 熔断一段时间之后放一部分请求过去试探
+	stateHalfOpen
+)
+
+// Breaker 按照错误率熔断，作为 go-redis 的 Hook 统计所有命令的结果。
+// 熔断之后过了 cooldown 进入半开状态，连续成功 probes 次就恢复，失败就继续熔断
+type Breaker struct {
+	mu    sync.Mutex
+	state state
+
+	window      time.Duration
+	windowStart time.Time
+	total       int
+	failed      int
+	minRequests int
+	threshold   float64
+
+	cooldown  time.Duration
+	openedAt  time.Time
+	probes    int
+	succeeded int
+
+	l   logger.Logger
+	now func() time.Time
+}
+
+type Option func(b *Breaker)
+
+// WithThreshold window 内至少有 minRequests 个请求，并且错误率达到 threshold 才熔断
+func WithThreshold(window time.Duration, minRequests int, threshold float64) Option {
+	return func(b *Breaker) {
+		b.window = window
+		b.minRequests = minRequests
+		b.threshold = threshold
+	}
+}
+
+// WithCooldown 熔断多久之后开始试探，试探连续成功 probes 次恢复
+func WithCooldown(cooldown time.Duration, probes int) Option {
+	return func(b *Breaker) {
+		b.cooldown = cooldown
+		b.probes = probes
+	}
+}
+
+func NewBreaker(l logger.Logger, opts ...Option) *Breaker {
+	b := &Breaker{
+		window:      time.Second * 10,
+		minRequests: 20,
+		threshold:   0.5,
+		cooldown:    time.Second * 5,
+		probes:      5,
+		l:           l,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	b.windowStart = b.now()
+	return b
+}
+
+func (b *Breaker) Healthy() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == stateOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.state = stateHalfOpen
+		b.succeeded = 0
+		b.l.Info("Redis 熔断结束，开始试探")
+	}
+	return b.state != stateOpen
+}
+
+// Report 报告一次 Redis 调用的结果
+func (b *Breaker) Report(err error) {
+	failed := isFailure(err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case stateOpen:
+		// 熔断期间还有零星的请求，不影响状态
+		return
+	case stateHalfOpen:
+		if failed {
+			b.open()
+			return
+		}
+		b.succeeded++
+		if b.succeeded >= b.probes {
+			b.state = stateClosed
+			b.reset()
+			b.l.Info("Redis 已经恢复")
+		}
+		return
+	}
+	now := b.now()
+	if now.Sub(b.windowStart) >= b.window {
+		b.reset()
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+	if b.total >= b.minRequests && float64(b.failed)/float64(b.total) >= b.threshold {
+		b.open()
+	}
+}
+
+func (b *Breaker) open() {
+	b.state = stateOpen
+	b.openedAt = b.now()
+	b.l.Warn("Redis 错误太多，开始熔断",
+		logger.Int("total", b.total),
+		logger.Int("failed", b.failed))
+	b.reset()
+}
+
+func (b *Breaker) reset() {
+	b.windowStart = b.now()
+	b.total = 0
+	b.failed = 0
+}
+
+// isFailure redis.Nil 之类的业务结果，还有调用方自己取消的都不算 Redis 出问题
+func isFailure(err error) bool {
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var redisErr redis.Error
+	// Redis 正常返回的错误，比如 Lua 脚本报错，WRONGTYPE 之类的
+	return !errors.As(err, &redisErr)
+}
+
+func (b *Breaker) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		b.Report(err)
+		return conn, err
+	}
+}
+
+func (b *Breaker) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		b.Report(err)
+		return err
+	}
+}
+
+func (b *Breaker) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		b.Report(err)
+		return err
+	}
+}
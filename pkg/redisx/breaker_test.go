@@ -0,0 +1,54 @@
+package redisx
+
+import (
+	"context"
+	"errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"webok/pkg/logger"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := NewBreaker(logger.NewNopLogger(),
+		WithThreshold(time.Second, 4, 0.6),
+		WithCooldown(time.Second, 2))
+	b.now = func() time.Time {
+		return now
+	}
+	netErr := errors.New("dial tcp: connection refused")
+
+	// 请求数不够，不熔断
+	b.Report(netErr)
+	b.Report(netErr)
+	assert.True(t, b.Healthy())
+	// redis.Nil 和取消都不算错误
+	b.Report(redis.Nil)
+	b.Report(context.Canceled)
+	assert.True(t, b.Healthy())
+	b.Report(netErr)
+	assert.False(t, b.Healthy())
+
+	// 冷却之后进入半开，试探失败继续熔断
+	now = now.Add(time.Second)
+	assert.True(t, b.Healthy())
+	b.Report(netErr)
+	assert.False(t, b.Healthy())
+
+	// 试探连续成功之后恢复
+	now = now.Add(time.Second)
+	assert.True(t, b.Healthy())
+	b.Report(nil)
+	b.Report(nil)
+	assert.Equal(t, stateClosed, b.state)
+
+	// 旧的窗口过期之后重新统计
+	b.Report(netErr)
+	b.Report(netErr)
+	b.Report(netErr)
+	now = now.Add(time.Second)
+	b.Report(netErr)
+	assert.True(t, b.Healthy())
+}
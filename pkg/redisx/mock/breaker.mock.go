@@ -0,0 +1,54 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: breaker.go
+//
+// Generated by this command:
+//
+//	mockgen -source=breaker.go -package=redisxmocks -destination=./mock/breaker.mock.go
+//
+
+// Package redisxmocks is a generated GoMock package.
+package redisxmocks
+
+import (
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockHealthMonitor is a mock of HealthMonitor interface.
+type MockHealthMonitor struct {
+	ctrl     *gomock.Controller
+	recorder *MockHealthMonitorMockRecorder
+	isgomock struct{}
+}
+
+// MockHealthMonitorMockRecorder is the mock recorder for MockHealthMonitor.
+type MockHealthMonitorMockRecorder struct {
+	mock *MockHealthMonitor
+}
+
+// NewMockHealthMonitor creates a new mock instance.
+func NewMockHealthMonitor(ctrl *gomock.Controller) *MockHealthMonitor {
+	mock := &MockHealthMonitor{ctrl: ctrl}
+	mock.recorder = &MockHealthMonitorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHealthMonitor) EXPECT() *MockHealthMonitorMockRecorder {
+	return m.recorder
+}
+
+// Healthy mocks base method.
+func (m *MockHealthMonitor) Healthy() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Healthy")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Healthy indicates an expected call of Healthy.
+func (mr *MockHealthMonitorMockRecorder) Healthy() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Healthy", reflect.TypeOf((*MockHealthMonitor)(nil).Healthy))
+}
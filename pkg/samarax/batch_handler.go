@@ -2,7 +2,6 @@ package samarax
 
 import (
 	"context"
-	"encoding/json"
 	"github.com/IBM/sarama"
 	"log"
 	"time"
@@ -10,12 +9,18 @@ import (
 )
 
 type BatchHandler[T any] struct {
-	fn func(msgs []*sarama.ConsumerMessage, ts []T) error
-	l  logger.Logger
+	fn     func(msgs []*sarama.ConsumerMessage, ts []T) error
+	decode func(msg *sarama.ConsumerMessage) (T, error)
+	l      logger.Logger
 }
 
 func NewBatchHandler[T any](l logger.Logger, fn func(msgs []*sarama.ConsumerMessage, ts []T) error) *BatchHandler[T] {
-	return &BatchHandler[T]{fn: fn, l: l}
+	return &BatchHandler[T]{fn: fn, decode: jsonDecoder[T], l: l}
+}
+
+// NewEnvelopeBatchHandler 消息体是 Envelope，按照注册的类型解析并且升级到最新版本
+func NewEnvelopeBatchHandler[T any](l logger.Logger, r *Registry, fn func(msgs []*sarama.ConsumerMessage, ts []T) error) *BatchHandler[T] {
+	return &BatchHandler[T]{fn: fn, decode: EnvelopeDecoder[T](r), l: l}
 }
 
 func (b *BatchHandler[T]) Setup(session sarama.ConsumerGroupSession) error {
@@ -31,6 +36,8 @@ func (b *BatchHandler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 	const batchSize = 10
 	for {
 		log.Println("一个批次开始")
+		// all 用来提交，batch 和 ts 一一对应，交给业务处理
+		all := make([]*sarama.ConsumerMessage, 0, batchSize)
 		batch := make([]*sarama.ConsumerMessage, 0, batchSize)
 		ts := make([]T, 0, batchSize)
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
@@ -45,9 +52,8 @@ func (b *BatchHandler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 					cancel()
 					return nil
 				}
-				batch = append(batch, msg)
-				var t T
-				err := json.Unmarshal(msg.Value, &t)
+				all = append(all, msg)
+				t, err := b.decode(msg)
 				if err != nil {
 					b.l.Error("反序列消息体失败",
 						logger.String("topic", msg.Topic),
@@ -62,13 +68,15 @@ func (b *BatchHandler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 		}
 		cancel()
 		// 凑够了一批，然后你就处理
-		err := b.fn(batch, ts)
-		if err != nil {
-			b.l.Error("处理消息失败",
-				// 把真个 msgs 都记录下来
-				logger.Error(err))
+		if len(batch) > 0 {
+			err := b.fn(batch, ts)
+			if err != nil {
+				b.l.Error("处理消息失败",
+					// 把真个 msgs 都记录下来
+					logger.Error(err))
+			}
 		}
-		for _, msg := range batch {
+		for _, msg := range all {
 			session.MarkMessage(msg, "")
 		}
 	}
@@ -0,0 +1,26 @@
+package samarax
+
+import "github.com/IBM/sarama"
+
+// Broker 屏蔽掉消息的真正来源，Kafka 和内存总线都实现了这个接口，
+// 生产者和消费者只和 sarama 的接口打交道
+type Broker interface {
+	SyncProducer() (sarama.SyncProducer, error)
+	ConsumerGroup(groupId string) (sarama.ConsumerGroup, error)
+}
+
+type SaramaBroker struct {
+	client sarama.Client
+}
+
+func NewSaramaBroker(client sarama.Client) *SaramaBroker {
+	return &SaramaBroker{client: client}
+}
+
+func (s *SaramaBroker) SyncProducer() (sarama.SyncProducer, error) {
+	return sarama.NewSyncProducerFromClient(s.client)
+}
+
+func (s *SaramaBroker) ConsumerGroup(groupId string) (sarama.ConsumerGroup, error) {
+	return sarama.NewConsumerGroupFromClient(groupId, s.client)
+}
@@ -0,0 +1,80 @@
+package samarax
+
+import (
+	"github.com/IBM/sarama"
+	"webok/pkg/logger"
+)
+
+// DispatchHandler 一个 topic 上有多种事件的时候，按照 Envelope 里面的类型分发
+type DispatchHandler struct {
+	l      logger.Logger
+	r      *Registry
+	routes map[string]func(msg *sarama.ConsumerMessage, env Envelope) error
+}
+
+func NewDispatchHandler(l logger.Logger, r *Registry) *DispatchHandler {
+	return &DispatchHandler{
+		l:      l,
+		r:      r,
+		routes: make(map[string]func(msg *sarama.ConsumerMessage, env Envelope) error),
+	}
+}
+
+// Route 登记 T 对应事件类型的处理函数，T 必须已经在 Registry 里面注册过
+func Route[T any](d *DispatchHandler, fn func(msg *sarama.ConsumerMessage, event T) error) *DispatchHandler {
+	var t T
+	typ, err := d.r.TypeOf(t)
+	if err != nil {
+		panic(err)
+	}
+	d.routes[typ] = func(msg *sarama.ConsumerMessage, env Envelope) error {
+		evt, er := Decode[T](d.r, env)
+		if er != nil {
+			return er
+		}
+		return fn(msg, evt)
+	}
+	return d
+}
+
+func (d *DispatchHandler) Setup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (d *DispatchHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (d *DispatchHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		d.handle(msg)
+		session.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+func (d *DispatchHandler) handle(msg *sarama.ConsumerMessage) {
+	env, err := d.r.Unwrap(msg.Topic, msg.Value)
+	if err != nil {
+		d.l.Error("反序列消息体失败",
+			logger.String("topic", msg.Topic),
+			logger.Int32("partition", msg.Partition),
+			logger.Int64("offset", msg.Offset),
+			logger.Error(err))
+		return
+	}
+	fn, ok := d.routes[env.Type]
+	if !ok {
+		// 不关心的事件直接跳过
+		return
+	}
+	err = fn(msg, env)
+	if err != nil {
+		d.l.Error("处理消息失败",
+			logger.String("topic", msg.Topic),
+			logger.String("type", env.Type),
+			logger.String("id", env.Id),
+			logger.Int64("offset", msg.Offset),
+			logger.Error(err))
+	}
+}
@@ -0,0 +1,238 @@
+package samarax
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/IBM/sarama"
+	"github.com/google/uuid"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	HeaderEventType    = "event_type"
+	HeaderEventVersion = "event_version"
+)
+
+var (
+	ErrUnknownEventType   = errors.New("未注册的事件类型")
+	ErrEventTypeMismatch  = errors.New("事件类型和期望的不一致")
+	ErrUnsupportedVersion = errors.New("事件版本比当前支持的新")
+	ErrMissingUpcaster    = errors.New("缺少升级旧版本事件的函数")
+)
+
+// Envelope 所有事件的外层结构，Payload 才是具体的事件
+type Envelope struct {
+	Id      string `json:"id"`
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	// OccurredAt 毫秒数
+	OccurredAt int64           `json:"occurred_at"`
+	Producer   string          `json:"producer"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// Upcaster 把 payload 从某个版本升级到下一个版本
+type Upcaster func(payload json.RawMessage) (json.RawMessage, error)
+
+type schema struct {
+	// 最新的版本，发送的时候总是用最新版本
+	version   int
+	goType    reflect.Type
+	upcasters map[int]Upcaster
+}
+
+// Registry 事件类型的注册中心，记录每种事件的 Go 类型、最新版本和升级函数。
+// 新增事件或者修改事件结构的时候，都要在这里登记
+type Registry struct {
+	producer string
+
+	mu      sync.RWMutex
+	schemas map[string]*schema
+	types   map[reflect.Type]string
+	// topic 上引入 Envelope 之前的消息，直接就是 payload
+	legacy map[string]string
+}
+
+func NewRegistry(producer string) *Registry {
+	return &Registry{
+		producer: producer,
+		schemas:  make(map[string]*schema),
+		types:    make(map[reflect.Type]string),
+		legacy:   make(map[string]string),
+	}
+}
+
+// Register 登记事件类型，version 是当前 T 对应的版本。
+// 重复登记属于代码错误，直接 panic
+func Register[T any](r *Registry, typ string, version int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	goType := reflect.TypeOf((*T)(nil)).Elem()
+	if _, ok := r.schemas[typ]; ok {
+		panic(fmt.Sprintf("samarax: 事件类型 %s 重复注册", typ))
+	}
+	if _, ok := r.types[goType]; ok {
+		panic(fmt.Sprintf("samarax: %s 重复注册", goType))
+	}
+	if version < 1 {
+		panic(fmt.Sprintf("samarax: 事件类型 %s 的版本必须从 1 开始", typ))
+	}
+	r.schemas[typ] = &schema{
+		version:   version,
+		goType:    goType,
+		upcasters: make(map[int]Upcaster),
+	}
+	r.types[goType] = typ
+}
+
+// RegisterUpcaster 登记从 from 版本升级到 from+1 版本的函数
+func (r *Registry) RegisterUpcaster(typ string, from int, fn Upcaster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.schemas[typ]
+	if !ok {
+		panic(fmt.Sprintf("samarax: 事件类型 %s 没有注册", typ))
+	}
+	s.upcasters[from] = fn
+}
+
+// RegisterLegacy topic 上没有 Envelope 的老消息，当成 typ 的第一个版本处理
+func (r *Registry) RegisterLegacy(topic string, typ string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.legacy[topic] = typ
+}
+
+// TypeOf 返回 payload 对应的事件类型
+func (r *Registry) TypeOf(payload any) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	typ, ok := r.types[reflect.TypeOf(payload)]
+	if !ok {
+		return "", fmt.Errorf("%w: %T", ErrUnknownEventType, payload)
+	}
+	return typ, nil
+}
+
+// Wrap 用最新版本把 payload 包装起来
+func (r *Registry) Wrap(payload any) (Envelope, error) {
+	typ, err := r.TypeOf(payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, err
+	}
+	r.mu.RLock()
+	version := r.schemas[typ].version
+	r.mu.RUnlock()
+	return Envelope{
+		Id:         uuid.New().String(),
+		Type:       typ,
+		Version:    version,
+		OccurredAt: time.Now().UnixMilli(),
+		Producer:   r.producer,
+		Payload:    data,
+	}, nil
+}
+
+// Unwrap 解析消息，并且升级到最新版本
+func (r *Registry) Unwrap(topic string, data []byte) (Envelope, error) {
+	var env Envelope
+	err := json.Unmarshal(data, &env)
+	if err != nil || env.Type == "" {
+		r.mu.RLock()
+		typ, ok := r.legacy[topic]
+		r.mu.RUnlock()
+		if !ok {
+			if err != nil {
+				return Envelope{}, err
+			}
+			return Envelope{}, fmt.Errorf("%w: topic %s 上的消息没有类型", ErrUnknownEventType, topic)
+		}
+		env = Envelope{Type: typ, Version: 1, Payload: data}
+	}
+	return r.Upcast(env)
+}
+
+// Upcast 依次调用升级函数，把 env 升级到最新版本
+func (r *Registry) Upcast(env Envelope) (Envelope, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.schemas[env.Type]
+	if !ok {
+		return Envelope{}, fmt.Errorf("%w: %s", ErrUnknownEventType, env.Type)
+	}
+	if env.Version > s.version {
+		return Envelope{}, fmt.Errorf("%w: %s v%d", ErrUnsupportedVersion, env.Type, env.Version)
+	}
+	for env.Version < s.version {
+		fn, ok := s.upcasters[env.Version]
+		if !ok {
+			return Envelope{}, fmt.Errorf("%w: %s v%d", ErrMissingUpcaster, env.Type, env.Version)
+		}
+		payload, err := fn(env.Payload)
+		if err != nil {
+			return Envelope{}, err
+		}
+		env.Payload = payload
+		env.Version++
+	}
+	return env, nil
+}
+
+// Decode 把已经升级过的 env 解析成 T
+func Decode[T any](r *Registry, env Envelope) (T, error) {
+	var t T
+	r.mu.RLock()
+	typ, ok := r.types[reflect.TypeOf((*T)(nil)).Elem()]
+	r.mu.RUnlock()
+	if !ok {
+		return t, fmt.Errorf("%w: %T", ErrUnknownEventType, t)
+	}
+	if typ != env.Type {
+		return t, fmt.Errorf("%w: 期望 %s，实际 %s", ErrEventTypeMismatch, typ, env.Type)
+	}
+	err := json.Unmarshal(env.Payload, &t)
+	return t, err
+}
+
+// NewEnvelopeMessage 构造发给 Kafka 的消息，事件 ID 等信息也放一份到消息头里面
+func NewEnvelopeMessage(topic string, env Envelope) (*sarama.ProducerMessage, error) {
+	val, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	return &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.ByteEncoder(val),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(HeaderEventId), Value: []byte(env.Id)},
+			{Key: []byte(HeaderEventType), Value: []byte(env.Type)},
+			{Key: []byte(HeaderEventVersion), Value: []byte(strconv.Itoa(env.Version))},
+		},
+	}, nil
+}
+
+// EnvelopeDecoder 给 Handler 和 BatchHandler 用的解析函数
+func EnvelopeDecoder[T any](r *Registry) func(msg *sarama.ConsumerMessage) (T, error) {
+	return func(msg *sarama.ConsumerMessage) (T, error) {
+		env, err := r.Unwrap(msg.Topic, msg.Value)
+		if err != nil {
+			var t T
+			return t, err
+		}
+		return Decode[T](r, env)
+	}
+}
+
+func jsonDecoder[T any](msg *sarama.ConsumerMessage) (T, error) {
+	var t T
+	err := json.Unmarshal(msg.Value, &t)
+	return t, err
+}
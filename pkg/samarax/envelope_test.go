@@ -0,0 +1,135 @@
+package samarax
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// testEventV3 第一版只有 Aid，第二版把 Aid 改名成 ArticleId，第三版加了 Biz
+type testEventV3 struct {
+	ArticleId int64  `json:"article_id"`
+	Biz       string `json:"biz"`
+}
+
+type otherEvent struct {
+	Name string `json:"name"`
+}
+
+func newTestRegistry() *Registry {
+	r := NewRegistry("test")
+	Register[testEventV3](r, "test.event", 3)
+	Register[otherEvent](r, "test.other", 1)
+	r.RegisterUpcaster("test.event", 1, func(payload json.RawMessage) (json.RawMessage, error) {
+		var v1 struct {
+			Aid int64
+		}
+		if err := json.Unmarshal(payload, &v1); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]any{"article_id": v1.Aid})
+	})
+	r.RegisterUpcaster("test.event", 2, func(payload json.RawMessage) (json.RawMessage, error) {
+		var v2 map[string]any
+		if err := json.Unmarshal(payload, &v2); err != nil {
+			return nil, err
+		}
+		v2["biz"] = "article"
+		return json.Marshal(v2)
+	})
+	r.RegisterLegacy("legacy_topic", "test.event")
+	return r
+}
+
+func TestRegistry_Unwrap(t *testing.T) {
+	r := newTestRegistry()
+	testCases := []struct {
+		name  string
+		topic string
+		data  func(t *testing.T) []byte
+
+		wantEvt testEventV3
+		wantErr error
+	}{
+		{
+			name:  "最新版本",
+			topic: "test_topic",
+			data: func(t *testing.T) []byte {
+				env, err := r.Wrap(testEventV3{ArticleId: 1, Biz: "article"})
+				require.NoError(t, err)
+				assert.Equal(t, 3, env.Version)
+				assert.Equal(t, "test", env.Producer)
+				data, err := json.Marshal(env)
+				require.NoError(t, err)
+				return data
+			},
+			wantEvt: testEventV3{ArticleId: 1, Biz: "article"},
+		},
+		{
+			name:  "从第一版逐级升级",
+			topic: "test_topic",
+			data: func(t *testing.T) []byte {
+				return []byte(`{"id":"1","type":"test.event","version":1,"payload":{"Aid":2}}`)
+			},
+			wantEvt: testEventV3{ArticleId: 2, Biz: "article"},
+		},
+		{
+			name:  "没有 Envelope 的老消息",
+			topic: "legacy_topic",
+			data: func(t *testing.T) []byte {
+				return []byte(`{"Aid":3}`)
+			},
+			wantEvt: testEventV3{ArticleId: 3, Biz: "article"},
+		},
+		{
+			name:  "比当前支持的版本新",
+			topic: "test_topic",
+			data: func(t *testing.T) []byte {
+				return []byte(`{"id":"1","type":"test.event","version":4,"payload":{}}`)
+			},
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name:  "未知类型",
+			topic: "test_topic",
+			data: func(t *testing.T) []byte {
+				return []byte(`{"id":"1","type":"unknown","version":1,"payload":{}}`)
+			},
+			wantErr: ErrUnknownEventType,
+		},
+		{
+			name:  "类型不匹配",
+			topic: "test_topic",
+			data: func(t *testing.T) []byte {
+				env, err := r.Wrap(otherEvent{Name: "a"})
+				require.NoError(t, err)
+				data, err := json.Marshal(env)
+				require.NoError(t, err)
+				return data
+			},
+			wantErr: ErrEventTypeMismatch,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decode := EnvelopeDecoder[testEventV3](r)
+			evt, err := decode(&sarama.ConsumerMessage{Topic: tc.topic, Value: tc.data(t)})
+			assert.True(t, errors.Is(err, tc.wantErr), "err: %v", err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantEvt, evt)
+		})
+	}
+}
+
+func TestRegistry_MissingUpcaster(t *testing.T) {
+	r := NewRegistry("test")
+	Register[otherEvent](r, "test.other", 2)
+	_, err := r.Upcast(Envelope{Type: "test.other", Version: 1, Payload: []byte(`{}`)})
+	assert.ErrorIs(t, err, ErrMissingUpcaster)
+}
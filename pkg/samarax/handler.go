@@ -1,18 +1,24 @@
 package samarax
 
 import (
-	"encoding/json"
 	"github.com/IBM/sarama"
 	"webok/pkg/logger"
 )
 
 type Handler[T any] struct {
-	l  logger.Logger
-	fn func(msg *sarama.ConsumerMessage, event T) error
+	l      logger.Logger
+	fn     func(msg *sarama.ConsumerMessage, event T) error
+	decode func(msg *sarama.ConsumerMessage) (T, error)
 }
 
+// NewHandler 消息体直接就是 T 的 JSON
 func NewHandler[T any](l logger.Logger, fn func(msg *sarama.ConsumerMessage, event T) error) *Handler[T] {
-	return &Handler[T]{l: l, fn: fn}
+	return &Handler[T]{l: l, fn: fn, decode: jsonDecoder[T]}
+}
+
+// NewEnvelopeHandler 消息体是 Envelope，按照注册的类型解析并且升级到最新版本
+func NewEnvelopeHandler[T any](l logger.Logger, r *Registry, fn func(msg *sarama.ConsumerMessage, event T) error) *Handler[T] {
+	return &Handler[T]{l: l, fn: fn, decode: EnvelopeDecoder[T](r)}
 }
 
 func (h *Handler[T]) Setup(session sarama.ConsumerGroupSession) error {
@@ -27,8 +33,7 @@ func (h *Handler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sar
 	msgs := claim.Messages()
 	for msg := range msgs {
 		// 在这里调用业务处理逻辑
-		var t T
-		err := json.Unmarshal(msg.Value, &t)
+		t, err := h.decode(msg)
 		if err != nil {
 			// 你也可以在这里引入重试的逻辑
 			h.l.Error("反序列消息体失败",
@@ -36,6 +41,8 @@ func (h *Handler[T]) ConsumeClaim(session sarama.ConsumerGroupSession, claim sar
 				logger.Int32("partition", msg.Partition),
 				logger.Int64("offset", msg.Offset),
 				logger.Error(err))
+			session.MarkMessage(msg, "")
+			continue
 		}
 		err = h.fn(msg, t)
 		if err != nil {
@@ -0,0 +1,161 @@
+package samarax
+
+import (
+	"context"
+	"fmt"
+	"github.com/IBM/sarama"
+	"github.com/redis/go-redis/v9"
+	"time"
+	"webok/pkg/logger"
+)
+
+// HeaderEventId 生产者放在消息头里面的事件 ID
+const HeaderEventId = "event_id"
+
+// IdempotencyStore 记录已经处理过的消息
+//
+//go:generate mockgen -source=idempotent.go -package=samaraxmocks -destination=./mock/idempotent.mock.go
+type IdempotencyStore interface {
+	// Processed 返回和 keys 一一对应的结果，true 表示已经处理过
+	Processed(ctx context.Context, keys []string) ([]bool, error)
+	// Mark 标记 keys 已经处理
+	Mark(ctx context.Context, keys []string) error
+}
+
+// EventId 优先使用生产者生成的事件 ID，
+// 没有的话（比如老版本生产者发的消息）退化成 topic:partition:offset，至少能挡住重复投递
+func EventId(msg *sarama.ConsumerMessage) string {
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == HeaderEventId && len(h.Value) > 0 {
+			return string(h.Value)
+		}
+	}
+	return fmt.Sprintf("%s:%d:%d", msg.Topic, msg.Partition, msg.Offset)
+}
+
+type RedisIdempotencyStore struct {
+	cmd        redis.Cmdable
+	prefix     string
+	expiration time.Duration
+}
+
+func NewRedisIdempotencyStore(cmd redis.Cmdable, expiration time.Duration) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{
+		cmd:        cmd,
+		prefix:     "kafka:processed",
+		expiration: expiration,
+	}
+}
+
+func (r *RedisIdempotencyStore) Processed(ctx context.Context, keys []string) ([]bool, error) {
+	res := make([]bool, len(keys))
+	if len(keys) == 0 {
+		return res, nil
+	}
+	redisKeys := make([]string, 0, len(keys))
+	for _, k := range keys {
+		redisKeys = append(redisKeys, r.key(k))
+	}
+	vals, err := r.cmd.MGet(ctx, redisKeys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, val := range vals {
+		res[i] = val != nil
+	}
+	return res, nil
+}
+
+func (r *RedisIdempotencyStore) Mark(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := r.cmd.Pipeline()
+	for _, k := range keys {
+		pipe.Set(ctx, r.key(k), 1, r.expiration)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisIdempotencyStore) key(k string) string {
+	return fmt.Sprintf("%s:%s", r.prefix, k)
+}
+
+// Idempotent 包装单条消费的业务逻辑，重复投递的消息直接跳过。
+// group 用来区分不同的消费者，同一条消息每个消费者都要处理一次
+func Idempotent[T any](store IdempotencyStore, group string, l logger.Logger,
+	fn func(msg *sarama.ConsumerMessage, event T) error) func(msg *sarama.ConsumerMessage, event T) error {
+	return func(msg *sarama.ConsumerMessage, event T) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		key := group + ":" + EventId(msg)
+		processed, err := store.Processed(ctx, []string{key})
+		if err != nil {
+			// 查不到就当没处理过，宁可重复也不要丢
+			l.Warn("查询消息是否处理过失败", logger.String("key", key), logger.Error(err))
+		} else if processed[0] {
+			l.Debug("重复消息，跳过", logger.String("key", key))
+			return nil
+		}
+		err = fn(msg, event)
+		if err != nil {
+			return err
+		}
+		// 业务处理成功但是标记失败，下次还是会重复处理，这里只能尽量缩小这个窗口
+		er := store.Mark(ctx, []string{key})
+		if er != nil {
+			l.Error("标记消息已处理失败", logger.String("key", key), logger.Error(er))
+		}
+		return nil
+	}
+}
+
+// BatchIdempotent 和 Idempotent 一样，只不过作用在批量消费上。
+// 同一批次里重复的消息也会被过滤掉
+func BatchIdempotent[T any](store IdempotencyStore, group string, l logger.Logger,
+	fn func(msgs []*sarama.ConsumerMessage, ts []T) error) func(msgs []*sarama.ConsumerMessage, ts []T) error {
+	return func(msgs []*sarama.ConsumerMessage, ts []T) error {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		keys := make([]string, 0, len(msgs))
+		for _, msg := range msgs {
+			keys = append(keys, group+":"+EventId(msg))
+		}
+		processed, err := store.Processed(ctx, keys)
+		if err != nil {
+			l.Warn("查询消息是否处理过失败", logger.Int("size", len(keys)), logger.Error(err))
+			processed = make([]bool, len(keys))
+		}
+
+		seen := make(map[string]struct{}, len(keys))
+		newMsgs := make([]*sarama.ConsumerMessage, 0, len(msgs))
+		newTs := make([]T, 0, len(ts))
+		newKeys := make([]string, 0, len(keys))
+		for i, key := range keys {
+			if _, ok := seen[key]; ok || processed[i] {
+				continue
+			}
+			seen[key] = struct{}{}
+			newMsgs = append(newMsgs, msgs[i])
+			newTs = append(newTs, ts[i])
+			newKeys = append(newKeys, key)
+		}
+		if len(newMsgs) == 0 {
+			return nil
+		}
+		if len(newMsgs) < len(msgs) {
+			l.Debug("过滤重复消息", logger.Int("total", len(msgs)), logger.Int("left", len(newMsgs)))
+		}
+
+		err = fn(newMsgs, newTs)
+		if err != nil {
+			return err
+		}
+		er := store.Mark(ctx, newKeys)
+		if er != nil {
+			l.Error("标记消息已处理失败", logger.Int("size", len(newKeys)), logger.Error(er))
+		}
+		return nil
+	}
+}
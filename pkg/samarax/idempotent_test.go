@@ -0,0 +1,119 @@
+package samarax
+
+import (
+	"errors"
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"webok/pkg/logger"
+	samaraxmocks "webok/pkg/samarax/mock"
+)
+
+func TestBatchIdempotent(t *testing.T) {
+	msgWithId := func(id string, offset int64) *sarama.ConsumerMessage {
+		return &sarama.ConsumerMessage{
+			Topic:  "test",
+			Offset: offset,
+			Headers: []*sarama.RecordHeader{
+				{Key: []byte(HeaderEventId), Value: []byte(id)},
+			},
+		}
+	}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) IdempotencyStore
+		msgs []*sarama.ConsumerMessage
+		ts   []int
+		// 业务处理返回的错误
+		fnErr error
+
+		wantTs  []int
+		wantErr error
+	}{
+		{
+			name: "全部是新消息",
+			mock: func(ctrl *gomock.Controller) IdempotencyStore {
+				store := samaraxmocks.NewMockIdempotencyStore(ctrl)
+				store.EXPECT().Processed(gomock.Any(), []string{"g:a", "g:b"}).
+					Return([]bool{false, false}, nil)
+				store.EXPECT().Mark(gomock.Any(), []string{"g:a", "g:b"}).Return(nil)
+				return store
+			},
+			msgs:   []*sarama.ConsumerMessage{msgWithId("a", 1), msgWithId("b", 2)},
+			ts:     []int{1, 2},
+			wantTs: []int{1, 2},
+		},
+		{
+			name: "跳过已经处理过的和同批次重复的",
+			mock: func(ctrl *gomock.Controller) IdempotencyStore {
+				store := samaraxmocks.NewMockIdempotencyStore(ctrl)
+				store.EXPECT().Processed(gomock.Any(), []string{"g:a", "g:b", "g:b"}).
+					Return([]bool{true, false, false}, nil)
+				store.EXPECT().Mark(gomock.Any(), []string{"g:b"}).Return(nil)
+				return store
+			},
+			msgs:   []*sarama.ConsumerMessage{msgWithId("a", 1), msgWithId("b", 2), msgWithId("b", 3)},
+			ts:     []int{1, 2, 3},
+			wantTs: []int{2},
+		},
+		{
+			name: "没有事件 ID，退化成 offset",
+			mock: func(ctrl *gomock.Controller) IdempotencyStore {
+				store := samaraxmocks.NewMockIdempotencyStore(ctrl)
+				store.EXPECT().Processed(gomock.Any(), []string{"g:test:0:7"}).
+					Return([]bool{false}, nil)
+				store.EXPECT().Mark(gomock.Any(), []string{"g:test:0:7"}).Return(nil)
+				return store
+			},
+			msgs:   []*sarama.ConsumerMessage{{Topic: "test", Offset: 7}},
+			ts:     []int{7},
+			wantTs: []int{7},
+		},
+		{
+			name: "查询失败，照常处理",
+			mock: func(ctrl *gomock.Controller) IdempotencyStore {
+				store := samaraxmocks.NewMockIdempotencyStore(ctrl)
+				store.EXPECT().Processed(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("redis error"))
+				store.EXPECT().Mark(gomock.Any(), []string{"g:a"}).Return(nil)
+				return store
+			},
+			msgs:   []*sarama.ConsumerMessage{msgWithId("a", 1)},
+			ts:     []int{1},
+			wantTs: []int{1},
+		},
+		{
+			name: "业务处理失败，不标记",
+			mock: func(ctrl *gomock.Controller) IdempotencyStore {
+				store := samaraxmocks.NewMockIdempotencyStore(ctrl)
+				store.EXPECT().Processed(gomock.Any(), gomock.Any()).
+					Return([]bool{false}, nil)
+				return store
+			},
+			msgs:    []*sarama.ConsumerMessage{msgWithId("a", 1)},
+			ts:      []int{1},
+			fnErr:   errors.New("db error"),
+			wantTs:  []int{1},
+			wantErr: errors.New("db error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var gotTs []int
+			fn := BatchIdempotent[int](tc.mock(ctrl), "g", logger.NewNopLogger(),
+				func(msgs []*sarama.ConsumerMessage, ts []int) error {
+					assert.Equal(t, len(msgs), len(ts))
+					gotTs = ts
+					return tc.fnErr
+				})
+			err := fn(tc.msgs, tc.ts)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantTs, gotTs)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package membus
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"sync"
+	"time"
+)
+
+// Bus 进程内的消息总线，语义上模拟 Kafka，用来跑测试或者单机部署：
+// 每个 topic 只有一个分区，消息追加写入；
+// 每个消费者组各自维护提交的 offset，组内同一时刻只有一个成员在消费一个 topic；
+// 会话结束时还没有提交的消息，下一次 Consume 会重新投递，也就是至少一次。
+type Bus struct {
+	mu     sync.Mutex
+	topics map[string]*topic
+	groups map[string]*group
+	// 每个 topic 最多保留的消息数，超过之后丢弃最老的，和 Kafka 的保留策略一样
+	retention int
+}
+
+type topic struct {
+	// base 是 msgs[0] 的 offset
+	base int64
+	msgs []*sarama.ConsumerMessage
+	// 每次有新消息就关闭并且换一个新的，用来唤醒等待中的消费者
+	notify chan struct{}
+}
+
+type group struct {
+	offsets map[string]int64
+	// 大小为 1 的信号量，拿到了才能消费对应的 topic
+	claims map[string]chan struct{}
+	// 每一次 Consume 都算一代
+	generation int32
+}
+
+type Option func(b *Bus)
+
+func WithRetention(n int) Option {
+	return func(b *Bus) {
+		b.retention = n
+	}
+}
+
+func NewBus(opts ...Option) *Bus {
+	b := &Bus{
+		topics:    make(map[string]*topic),
+		groups:    make(map[string]*group),
+		retention: 10000,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func (b *Bus) SyncProducer() (sarama.SyncProducer, error) {
+	return &syncProducer{bus: b}, nil
+}
+
+func (b *Bus) ConsumerGroup(groupId string) (sarama.ConsumerGroup, error) {
+	return newConsumerGroup(b, groupId), nil
+}
+
+func (b *Bus) publish(pm *sarama.ProducerMessage) (int32, int64, error) {
+	var (
+		key, val []byte
+		err      error
+	)
+	if pm.Key != nil {
+		key, err = pm.Key.Encode()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if pm.Value != nil {
+		val, err = pm.Value.Encode()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	headers := make([]*sarama.RecordHeader, 0, len(pm.Headers))
+	for i := range pm.Headers {
+		h := pm.Headers[i]
+		headers = append(headers, &h)
+	}
+	ts := pm.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topicLocked(pm.Topic)
+	offset := t.base + int64(len(t.msgs))
+	t.msgs = append(t.msgs, &sarama.ConsumerMessage{
+		Headers:   headers,
+		Timestamp: ts,
+		Key:       key,
+		Value:     val,
+		Topic:     pm.Topic,
+		Partition: 0,
+		Offset:    offset,
+	})
+	if b.retention > 0 && len(t.msgs) > b.retention {
+		drop := len(t.msgs) - b.retention
+		t.msgs = append([]*sarama.ConsumerMessage(nil), t.msgs[drop:]...)
+		t.base += int64(drop)
+	}
+	close(t.notify)
+	t.notify = make(chan struct{})
+	pm.Partition = 0
+	pm.Offset = offset
+	return 0, offset, nil
+}
+
+// next 阻塞直到 offset 处有消息，或者 ctx 结束。
+// offset 已经被清理掉的话，从最老的一条开始
+func (b *Bus) next(ctx context.Context, name string, offset int64) (*sarama.ConsumerMessage, bool) {
+	for {
+		b.mu.Lock()
+		t := b.topicLocked(name)
+		if offset < t.base {
+			offset = t.base
+		}
+		if idx := offset - t.base; idx < int64(len(t.msgs)) {
+			msg := t.msgs[idx]
+			b.mu.Unlock()
+			return msg, true
+		}
+		notify := t.notify
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-notify:
+		}
+	}
+}
+
+func (b *Bus) highWaterMark(name string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := b.topicLocked(name)
+	return t.base + int64(len(t.msgs))
+}
+
+func (b *Bus) committed(groupId, name string) int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.groupLocked(groupId).offsets[name]
+}
+
+// commit 只会往前推进
+func (b *Bus) commit(groupId, name string, offset int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	g := b.groupLocked(groupId)
+	if offset > g.offsets[name] {
+		g.offsets[name] = offset
+	}
+}
+
+func (b *Bus) reset(groupId, name string, offset int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.groupLocked(groupId).offsets[name] = offset
+}
+
+// acquire 拿到组内对这些 topic 的消费权，topics 需要是排好序的，避免死锁
+func (b *Bus) acquire(ctx context.Context, groupId string, topics []string) (func(), int32, error) {
+	b.mu.Lock()
+	g := b.groupLocked(groupId)
+	sems := make([]chan struct{}, 0, len(topics))
+	for _, name := range topics {
+		sem, ok := g.claims[name]
+		if !ok {
+			sem = make(chan struct{}, 1)
+			g.claims[name] = sem
+		}
+		sems = append(sems, sem)
+	}
+	b.mu.Unlock()
+
+	release := func(acquired []chan struct{}) {
+		for _, sem := range acquired {
+			<-sem
+		}
+	}
+	for i, sem := range sems {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			release(sems[:i])
+			return nil, 0, ctx.Err()
+		}
+	}
+
+	b.mu.Lock()
+	g.generation++
+	generation := g.generation
+	b.mu.Unlock()
+	return func() {
+		release(sems)
+	}, generation, nil
+}
+
+func (b *Bus) topicLocked(name string) *topic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &topic{notify: make(chan struct{})}
+		b.topics[name] = t
+	}
+	return t
+}
+
+func (b *Bus) groupLocked(id string) *group {
+	g, ok := b.groups[id]
+	if !ok {
+		g = &group{
+			offsets: make(map[string]int64),
+			claims:  make(map[string]chan struct{}),
+		}
+		b.groups[id] = g
+	}
+	return g
+}
@@ -0,0 +1,116 @@
+package membus
+
+import (
+	"context"
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// collectHandler 收到 n 条消息之后退出，mark 决定是否提交
+type collectHandler struct {
+	n    int
+	mark bool
+	got  []string
+}
+
+func (h *collectHandler) Setup(_ sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *collectHandler) Cleanup(_ sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (h *collectHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		h.got = append(h.got, string(msg.Value))
+		if h.mark {
+			session.MarkMessage(msg, "")
+		}
+		if len(h.got) >= h.n {
+			return nil
+		}
+	}
+	return nil
+}
+
+func consume(t *testing.T, bus *Bus, groupId string, h *collectHandler) {
+	cg, err := bus.ConsumerGroup(groupId)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = cg.Consume(ctx, []string{"test_topic"}, h)
+	require.NoError(t, err)
+}
+
+func publish(t *testing.T, bus *Bus, vals ...string) {
+	p, err := bus.SyncProducer()
+	require.NoError(t, err)
+	for _, val := range vals {
+		_, _, err = p.SendMessage(&sarama.ProducerMessage{
+			Topic: "test_topic",
+			Value: sarama.StringEncoder(val),
+		})
+		require.NoError(t, err)
+	}
+}
+
+func TestBus_ConsumerGroups(t *testing.T) {
+	bus := NewBus()
+	publish(t, bus, "a", "b")
+
+	// 不同的组各自消费全部消息
+	h1 := &collectHandler{n: 2, mark: true}
+	consume(t, bus, "g1", h1)
+	assert.Equal(t, []string{"a", "b"}, h1.got)
+	h2 := &collectHandler{n: 2, mark: true}
+	consume(t, bus, "g2", h2)
+	assert.Equal(t, []string{"a", "b"}, h2.got)
+
+	// 同一个组从提交的位置继续
+	publish(t, bus, "c")
+	h3 := &collectHandler{n: 1, mark: true}
+	consume(t, bus, "g1", h3)
+	assert.Equal(t, []string{"c"}, h3.got)
+}
+
+func TestBus_AtLeastOnce(t *testing.T) {
+	bus := NewBus()
+	publish(t, bus, "a", "b")
+
+	// 没有提交，下一次会话重新投递
+	h1 := &collectHandler{n: 2}
+	consume(t, bus, "g", h1)
+	assert.Equal(t, []string{"a", "b"}, h1.got)
+
+	h2 := &collectHandler{n: 1, mark: true}
+	consume(t, bus, "g", h2)
+	assert.Equal(t, []string{"a"}, h2.got)
+
+	h3 := &collectHandler{n: 1, mark: true}
+	consume(t, bus, "g", h3)
+	assert.Equal(t, []string{"b"}, h3.got)
+}
+
+func TestBus_WaitForMessage(t *testing.T) {
+	bus := NewBus()
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		publish(t, bus, "late")
+	}()
+	h := &collectHandler{n: 1, mark: true}
+	consume(t, bus, "g", h)
+	assert.Equal(t, []string{"late"}, h.got)
+}
+
+func TestBus_Retention(t *testing.T) {
+	bus := NewBus(WithRetention(2))
+	publish(t, bus, "a", "b", "c")
+
+	h := &collectHandler{n: 2, mark: true}
+	consume(t, bus, "g", h)
+	assert.Equal(t, []string{"b", "c"}, h.got)
+}
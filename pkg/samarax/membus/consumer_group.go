@@ -0,0 +1,229 @@
+package membus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/IBM/sarama"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+var memberSeq atomic.Int64
+
+// consumerGroup 实现 sarama.ConsumerGroup，
+// 这样 samarax 里面的 Handler 和 BatchHandler 不用改就能用
+type consumerGroup struct {
+	bus      *Bus
+	groupId  string
+	memberId string
+
+	errs      chan error
+	closing   chan struct{}
+	closeOnce sync.Once
+}
+
+func newConsumerGroup(bus *Bus, groupId string) *consumerGroup {
+	return &consumerGroup{
+		bus:      bus,
+		groupId:  groupId,
+		memberId: fmt.Sprintf("membus-%s-%d", groupId, memberSeq.Add(1)),
+		errs:     make(chan error, 64),
+		closing:  make(chan struct{}),
+	}
+}
+
+// Consume 和 sarama 一样，一次调用就是一个会话，
+// 任意一个 ConsumeClaim 返回、ctx 结束或者 Close 都会结束会话
+func (c *consumerGroup) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	select {
+	case <-c.closing:
+		return sarama.ErrClosedConsumerGroup
+	default:
+	}
+	if len(topics) == 0 {
+		return errors.New("membus: no topics provided")
+	}
+	topics = uniqueSorted(topics)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	release, generation, err := c.bus.acquire(ctx, c.groupId, topics)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	sess := &session{
+		ctx:        ctx,
+		group:      c,
+		generation: generation,
+		topics:     topics,
+	}
+	if err = handler.Setup(sess); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, name := range topics {
+		cl := &claim{
+			topic:  name,
+			offset: c.bus.committed(c.groupId, name),
+			hwm:    func() int64 { return c.bus.highWaterMark(name) },
+			msgs:   make(chan *sarama.ConsumerMessage),
+		}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			c.feed(ctx, cl)
+		}()
+		go func() {
+			defer wg.Done()
+			defer cancel()
+			if er := handler.ConsumeClaim(sess, cl); er != nil {
+				c.handleError(er)
+			}
+		}()
+	}
+	wg.Wait()
+	return handler.Cleanup(sess)
+}
+
+// feed 把消息推给 claim，会话结束的时候关闭 channel
+func (c *consumerGroup) feed(ctx context.Context, cl *claim) {
+	defer close(cl.msgs)
+	offset := cl.offset
+	for {
+		msg, ok := c.bus.next(ctx, cl.topic, offset)
+		if !ok {
+			return
+		}
+		select {
+		case cl.msgs <- msg:
+			offset = msg.Offset + 1
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *consumerGroup) handleError(err error) {
+	// 没人读的话就丢掉，不能阻塞消费
+	select {
+	case c.errs <- err:
+	default:
+	}
+}
+
+// Errors 这个 channel 不会被关闭
+func (c *consumerGroup) Errors() <-chan error {
+	return c.errs
+}
+
+func (c *consumerGroup) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closing)
+	})
+	return nil
+}
+
+// Pause 单分区的内存实现不支持暂停，下同
+func (c *consumerGroup) Pause(_ map[string][]int32) {}
+
+func (c *consumerGroup) Resume(_ map[string][]int32) {}
+
+func (c *consumerGroup) PauseAll() {}
+
+func (c *consumerGroup) ResumeAll() {}
+
+type session struct {
+	ctx        context.Context
+	group      *consumerGroup
+	generation int32
+	topics     []string
+}
+
+func (s *session) Claims() map[string][]int32 {
+	res := make(map[string][]int32, len(s.topics))
+	for _, t := range s.topics {
+		res[t] = []int32{0}
+	}
+	return res
+}
+
+func (s *session) MemberID() string {
+	return s.group.memberId
+}
+
+func (s *session) GenerationID() int32 {
+	return s.generation
+}
+
+func (s *session) MarkOffset(topic string, _ int32, offset int64, _ string) {
+	s.group.bus.commit(s.group.groupId, topic, offset)
+}
+
+// Commit 标记就是提交，不需要额外处理
+func (s *session) Commit() {}
+
+func (s *session) ResetOffset(topic string, _ int32, offset int64, _ string) {
+	s.group.bus.reset(s.group.groupId, topic, offset)
+}
+
+func (s *session) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+
+func (s *session) Context() context.Context {
+	return s.ctx
+}
+
+type claim struct {
+	topic  string
+	offset int64
+	hwm    func() int64
+	msgs   chan *sarama.ConsumerMessage
+}
+
+func (c *claim) Topic() string {
+	return c.topic
+}
+
+func (c *claim) Partition() int32 {
+	return 0
+}
+
+func (c *claim) InitialOffset() int64 {
+	return c.offset
+}
+
+func (c *claim) HighWaterMarkOffset() int64 {
+	return c.hwm()
+}
+
+func (c *claim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.msgs
+}
+
+func uniqueSorted(topics []string) []string {
+	set := make(map[string]struct{}, len(topics))
+	res := make([]string, 0, len(topics))
+	for _, t := range topics {
+		if _, ok := set[t]; ok {
+			continue
+		}
+		set[t] = struct{}{}
+		res = append(res, t)
+	}
+	sort.Strings(res)
+	return res
+}
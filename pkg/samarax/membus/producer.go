@@ -0,0 +1,55 @@
+package membus
+
+import (
+	"github.com/IBM/sarama"
+)
+
+// syncProducer 实现 sarama.SyncProducer，不支持事务
+type syncProducer struct {
+	bus *Bus
+}
+
+func (s *syncProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	return s.bus.publish(msg)
+}
+
+func (s *syncProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	for _, msg := range msgs {
+		if _, _, err := s.bus.publish(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syncProducer) Close() error {
+	return nil
+}
+
+func (s *syncProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return sarama.ProducerTxnFlagReady
+}
+
+func (s *syncProducer) IsTransactional() bool {
+	return false
+}
+
+func (s *syncProducer) BeginTxn() error {
+	return sarama.ErrNonTransactedProducer
+}
+
+func (s *syncProducer) CommitTxn() error {
+	return sarama.ErrNonTransactedProducer
+}
+
+func (s *syncProducer) AbortTxn() error {
+	return sarama.ErrNonTransactedProducer
+}
+
+func (s *syncProducer) AddOffsetsToTxn(_ map[string][]*sarama.PartitionOffsetMetadata, _ string) error {
+	return sarama.ErrNonTransactedProducer
+}
+
+func (s *syncProducer) AddMessageToTxn(_ *sarama.ConsumerMessage, _ string, _ *string) error {
+	return sarama.ErrNonTransactedProducer
+}
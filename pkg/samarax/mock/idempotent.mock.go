@@ -0,0 +1,70 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: idempotent.go
+//
+// Generated by this command:
+//
+//	mockgen -source=idempotent.go -package=samaraxmocks -destination=./mock/idempotent.mock.go
+//
+
+// Package samaraxmocks is a generated GoMock package.
+package samaraxmocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockIdempotencyStore is a mock of IdempotencyStore interface.
+type MockIdempotencyStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockIdempotencyStoreMockRecorder
+	isgomock struct{}
+}
+
+// MockIdempotencyStoreMockRecorder is the mock recorder for MockIdempotencyStore.
+type MockIdempotencyStoreMockRecorder struct {
+	mock *MockIdempotencyStore
+}
+
+// NewMockIdempotencyStore creates a new mock instance.
+func NewMockIdempotencyStore(ctrl *gomock.Controller) *MockIdempotencyStore {
+	mock := &MockIdempotencyStore{ctrl: ctrl}
+	mock.recorder = &MockIdempotencyStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockIdempotencyStore) EXPECT() *MockIdempotencyStoreMockRecorder {
+	return m.recorder
+}
+
+// Mark mocks base method.
+func (m *MockIdempotencyStore) Mark(ctx context.Context, keys []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Mark", ctx, keys)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Mark indicates an expected call of Mark.
+func (mr *MockIdempotencyStoreMockRecorder) Mark(ctx, keys any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Mark", reflect.TypeOf((*MockIdempotencyStore)(nil).Mark), ctx, keys)
+}
+
+// Processed mocks base method.
+func (m *MockIdempotencyStore) Processed(ctx context.Context, keys []string) ([]bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Processed", ctx, keys)
+	ret0, _ := ret[0].([]bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Processed indicates an expected call of Processed.
+func (mr *MockIdempotencyStoreMockRecorder) Processed(ctx, keys any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Processed", reflect.TypeOf((*MockIdempotencyStore)(nil).Processed), ctx, keys)
+}
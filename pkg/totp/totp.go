@@ -0,0 +1,152 @@
+// Package totp 实现 RFC 4226 的 HOTP 和 RFC 6238 的 TOTP
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Algorithm string
+
+const (
+	SHA1   Algorithm = "SHA1"
+	SHA256 Algorithm = "SHA256"
+	SHA512 Algorithm = "SHA512"
+)
+
+func (a Algorithm) hash() func() hash.Hash {
+	switch a {
+	case SHA256:
+		return sha256.New
+	case SHA512:
+		return sha512.New
+	default:
+		return sha1.New
+	}
+}
+
+// Options 默认值和 Google Authenticator 一致，SHA1，6 位，30 秒一个周期
+type Options struct {
+	Algorithm Algorithm
+	Digits    int
+	Period    time.Duration
+	// Skew 允许前后偏差几个周期，用来容忍手机时间不准
+	Skew int
+}
+
+func (o Options) withDefaults() Options {
+	if o.Algorithm == "" {
+		o.Algorithm = SHA1
+	}
+	if o.Digits <= 0 {
+		o.Digits = 6
+	}
+	if o.Period <= 0 {
+		o.Period = time.Second * 30
+	}
+	return o
+}
+
+// RFC 4226 规定验证码是 6 到 8 位，太短容易猜中，超过 9 位截断之后的 31 位整数不够用
+const (
+	MinDigits = 6
+	MaxDigits = 8
+)
+
+var pow10 = [...]uint32{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000, 100000000}
+
+// HOTP RFC 4226 5.3，digits 不在 6 到 8 之间是调用方写错了配置，直接 panic
+func HOTP(secret []byte, counter uint64, digits int, alg Algorithm) string {
+	if digits < MinDigits || digits > MaxDigits {
+		panic(fmt.Sprintf("totp: 验证码位数必须在 %d 到 %d 之间，现在是 %d", MinDigits, MaxDigits, digits))
+	}
+	mac := hmac.New(alg.hash(), secret)
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], counter)
+	mac.Write(buf[:])
+	sum := mac.Sum(nil)
+	// 动态截断
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, code%pow10[digits])
+}
+
+// Step t 所在的周期
+func Step(t time.Time, opts Options) uint64 {
+	opts = opts.withDefaults()
+	return uint64(t.Unix()) / uint64(opts.Period.Seconds())
+}
+
+// Generate RFC 6238，t 时刻的验证码
+func Generate(secret []byte, t time.Time, opts Options) string {
+	opts = opts.withDefaults()
+	return HOTP(secret, Step(t, opts), opts.Digits, opts.Algorithm)
+}
+
+// Validate 校验验证码，通过的时候返回命中的周期，调用方要记下来防止同一个验证码被重复使用
+func Validate(code string, secret []byte, t time.Time, opts Options) (uint64, bool) {
+	opts = opts.withDefaults()
+	// 配置错了的时候一律不通过，不在请求里面 panic
+	if opts.Digits < MinDigits || opts.Digits > MaxDigits || len(code) != opts.Digits {
+		return 0, false
+	}
+	step := Step(t, opts)
+	for i := -opts.Skew; i <= opts.Skew; i++ {
+		s := step + uint64(i)
+		if i < 0 && step < uint64(-i) {
+			continue
+		}
+		want := HOTP(secret, s, opts.Digits, opts.Algorithm)
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret 生成 size 字节的随机密钥，RFC 4226 建议至少 160 位
+func NewSecret(size int) ([]byte, error) {
+	secret := make([]byte, size)
+	_, err := rand.Read(secret)
+	return secret, err
+}
+
+// EncodeSecret 验证器 App 要求 base32 编码，不带填充
+func EncodeSecret(secret []byte) string {
+	return b32.EncodeToString(secret)
+}
+
+func DecodeSecret(s string) ([]byte, error) {
+	return b32.DecodeString(strings.ToUpper(strings.TrimRight(s, "=")))
+}
+
+// ProvisioningURI otpauth:// 格式的链接，前端把它渲染成二维码给验证器 App 扫
+func ProvisioningURI(issuer, account string, secret []byte, opts Options) string {
+	opts = opts.withDefaults()
+	q := url.Values{}
+	q.Set("secret", EncodeSecret(secret))
+	q.Set("issuer", issuer)
+	q.Set("algorithm", string(opts.Algorithm))
+	q.Set("digits", fmt.Sprintf("%d", opts.Digits))
+	q.Set("period", fmt.Sprintf("%d", int(opts.Period.Seconds())))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
@@ -0,0 +1,118 @@
+package totp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// RFC 4226 附录 D
+func TestHOTP(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	want := []string{"755224", "287082", "359152", "969429", "338314",
+		"254676", "287922", "162583", "399871", "520489"}
+	for i, code := range want {
+		assert.Equal(t, code, HOTP(secret, uint64(i), 6, SHA1))
+	}
+}
+
+func TestHOTP_Digits(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	for _, digits := range []int{0, 5, 9, 10, 11} {
+		assert.Panics(t, func() { HOTP(secret, 0, digits, SHA1) }, "digits=%d", digits)
+	}
+	assert.Len(t, HOTP(secret, 0, 8, SHA1), 8)
+
+	// Validate 不能因为配置错了在请求里面 panic
+	for _, digits := range []int{5, 9} {
+		_, ok := Validate("12345", secret, time.Now(), Options{Digits: digits})
+		assert.False(t, ok)
+		_, ok = Validate("123456789", secret, time.Now(), Options{Digits: digits})
+		assert.False(t, ok)
+	}
+}
+
+// RFC 6238 附录 B，三种算法的种子长度不一样
+func TestGenerate(t *testing.T) {
+	seeds := map[Algorithm][]byte{
+		SHA1:   []byte("12345678901234567890"),
+		SHA256: []byte("12345678901234567890123456789012"),
+		SHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	testCases := []struct {
+		unix int64
+		alg  Algorithm
+		want string
+	}{
+		{59, SHA1, "94287082"},
+		{59, SHA256, "46119246"},
+		{59, SHA512, "90693936"},
+		{1111111109, SHA1, "07081804"},
+		{1111111109, SHA256, "68084774"},
+		{1111111109, SHA512, "25091201"},
+		{1111111111, SHA1, "14050471"},
+		{1111111111, SHA256, "67062674"},
+		{1111111111, SHA512, "99943326"},
+		{1234567890, SHA1, "89005924"},
+		{1234567890, SHA256, "91819424"},
+		{1234567890, SHA512, "93441116"},
+		{2000000000, SHA1, "69279037"},
+		{2000000000, SHA256, "90698825"},
+		{2000000000, SHA512, "38618901"},
+		{20000000000, SHA1, "65353130"},
+		{20000000000, SHA256, "77737706"},
+		{20000000000, SHA512, "47863826"},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.alg), func(t *testing.T) {
+			got := Generate(seeds[tc.alg], time.Unix(tc.unix, 0), Options{Algorithm: tc.alg, Digits: 8})
+			assert.Equal(t, tc.want, got, "T=%d", tc.unix)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1111111111, 0)
+	opts := Options{Skew: 1}
+	code := Generate(secret, now, opts)
+
+	testCases := []struct {
+		name     string
+		code     string
+		at       time.Time
+		wantStep uint64
+		wantOk   bool
+	}{
+		{name: "当前周期", code: code, at: now, wantStep: Step(now, opts), wantOk: true},
+		{name: "手机慢了一个周期", code: code, at: now.Add(time.Second * 30), wantStep: Step(now, opts), wantOk: true},
+		{name: "超出偏差", code: code, at: now.Add(time.Minute * 2)},
+		{name: "位数不对", code: code[:5], at: now},
+		{name: "错误的验证码", code: "000000", at: now},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := Validate(tc.code, secret, tc.at, opts)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantStep, step)
+		})
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	uri := ProvisioningURI("webook", "123@qq.com", secret, Options{})
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/webook:123@qq.com", u.Path)
+	assert.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", u.Query().Get("secret"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+
+	decoded, err := DecodeSecret(u.Query().Get("secret"))
+	require.NoError(t, err)
+	assert.Equal(t, secret, decoded)
+}
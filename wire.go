@@ -17,26 +17,43 @@ import (
 func InitWebServer() *App {
 	wire.Build(
 		//第三方依赖
-		ioc.InitDB, ioc.InitRedis, ioc.InitLogger,
-		ioc.InitSaramaClient,
+		ioc.InitDB, ioc.InitRedis, ioc.InitRedisHealth, ioc.InitLogger,
+		ioc.InitBroker,
 		ioc.InitSyncProducer,
+		ioc.InitIdempotencyStore,
+		ioc.InitEventRegistry,
+		ioc.InitInvalidator,
 
 		article.NewSaramaSyncProducer,
 		article.NewInteractiveReadEventConsumer,
+		article.NewCacheInvalidationConsumer,
 		ioc.InitConsumers,
 		// DAO
-		dao.NewGormUserDAO, dao.NewArticleGORMDAO, dao.NewInteractiveGORMDAO,
+		dao.NewGormUserDAO, dao.NewArticleGORMDAO, dao.NewInteractiveGORMDAO, dao.NewGORMCodeAuditDAO,
+		ioc.InitTwoFactorCipher, dao.NewGORMTwoFactorDAO, dao.NewGORMLoginAuditDAO,
+		dao.NewGORMSecurityEventDAO,
 		// CACHE
-		cache.NewCodeRedisCache, cache.NewUserCache, cache.NewArticleRedisCache,
-		cache.NewRedisInteractiveCache,
+		cache.NewCodeRedisCache, cache.NewUserCache, cache.NewArticleRedisCache, cache.NewArticleBloomFilters,
+		cache.NewRedisInteractiveCache, cache.NewCodeQuotaRedisCache, cache.NewCaptchaRedisCache,
+		cache.NewLoginAttemptRedisCache,
 		// REPO
 		repository.NewCachedUserRepository, repository.NewCodeRepository, repository.NewCachedArticleRepository,
-		repository.NewCachedInteractiveRepository,
+		repository.NewCachedInteractiveRepository, repository.NewCodeQuotaRepository, repository.NewCaptchaRepository,
+		repository.NewTwoFactorRepository, repository.NewLoginAttemptRepository,
+		repository.NewSecurityEventRepository,
+		repository.NewPubArticleCache, repository.NewUserProfileCache, repository.NewInteractiveLocalCache,
 		// Service
-		ioc.InitSMSService, service.NewNormalUserService, service.NewCodeService,
-		ioc.InitWechatService, service.NewArticleService, service.NewInteractiveService,
+		ioc.InitSMSService, ioc.InitEmailService, ioc.InitChannels, ioc.InitCodeQuotaConfig, ioc.InitCodePolicyConfig, service.NewNormalUserService, service.NewCodeService,
+		ioc.InitWechatProvider, service.NewArticleService, service.NewInteractiveService,
+		ioc.InitCaptchaConfig, service.NewCaptchaService,
+		ioc.InitTwoFactorConfig, service.NewTwoFactorService,
+		ioc.InitLoginPolicy, ioc.InitLoginChallenge, service.NewLoginGuardService,
+		ioc.InitSecurityEventConfig, service.NewSecurityEventService,
+		ioc.InitOAuth2Registry,
 		// Handler
-		ijwt.NewRedisHandler, web.NewUserHandler, web.NewOAuth2WechatHandler, web.NewArticleHandler,
+		ioc.InitJWTConfig, ioc.InitJWTKeySet, ijwt.NewRedisHandler, web.NewUserHandler, web.NewOAuth2WechatHandler,
+		web.NewArticleHandler, web.NewCaptchaHandler, web.NewJWKSHandler, web.NewTwoFactorHandler,
+		ioc.InitAdminMiddleware, web.NewSecurityEventHandler, web.NewOAuth2Handler,
 		ioc.InitGinMiddlewares, ioc.InitWebServer,
 		wire.Struct(new(App), "*"),
 	)
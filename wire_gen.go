@@ -25,39 +25,83 @@ import (
 
 func InitWebServer() *App {
 	cmdable := ioc.InitRedis()
-	handler := jwt.NewRedisHandler(cmdable)
 	logger := ioc.InitLogger()
-	v := ioc.InitGinMiddlewares(cmdable, handler, logger)
+	healthMonitor := ioc.InitRedisHealth(cmdable, logger)
+	keySet := ioc.InitJWTKeySet(logger)
+	config := ioc.InitJWTConfig()
+	handler := jwt.NewRedisHandler(cmdable, healthMonitor, keySet, config, logger)
+	v := ioc.InitGinMiddlewares(cmdable, healthMonitor, handler, logger)
 	db := ioc.InitDB(logger)
 	userDAO := dao.NewGormUserDAO(db)
 	userCache := cache.NewUserCache(cmdable)
-	userRepository := repository.NewCachedUserRepository(userDAO, userCache)
-	userService := service.NewNormalUserService(userRepository)
-	codeCache := cache.NewCodeRedisCache(cmdable)
-	codeRepository := repository.NewCodeRepository(codeCache)
-	smsService := ioc.InitSMSService()
-	codeService := service.NewCodeService(codeRepository, smsService)
-	userHandler := web.NewUserHandler(userService, codeService, handler, logger)
-	wechatService := ioc.InitWechatService()
-	oAuth2WechatHandler := web.NewOAuth2WechatHandler(wechatService, userService, handler, logger)
+	invalidator := ioc.InitInvalidator(cmdable, logger)
+	twoLevel := repository.NewUserProfileCache(invalidator, logger)
+	userRepository := repository.NewCachedUserRepository(userDAO, userCache, twoLevel, healthMonitor)
 	articleDAO := dao.NewArticleGORMDAO(db)
 	articleCache := cache.NewArticleRedisCache(cmdable)
-	articleRepository := repository.NewCachedArticleRepository(articleDAO, db, articleCache, userRepository)
-	client := ioc.InitSaramaClient()
-	syncProducer := ioc.InitSyncProducer(client)
-	producer := article.NewSaramaSyncProducer(syncProducer)
-	articleService := service.NewArticleService(articleRepository, producer)
+	cachexTwoLevel := repository.NewPubArticleCache(cmdable, healthMonitor, invalidator, logger)
+	articleBloomFilters := cache.NewArticleBloomFilters(cmdable)
+	articleRepository := repository.NewCachedArticleRepository(articleDAO, db, articleCache, userRepository, cachexTwoLevel, articleBloomFilters)
 	interactiveDao := dao.NewInteractiveGORMDAO(db)
 	interactiveCache := cache.NewRedisInteractiveCache(cmdable)
-	interactiveRepository := repository.NewCachedInteractiveRepository(interactiveDao, interactiveCache, logger)
-	interactiveService := service.NewInteractiveService(interactiveRepository, logger)
+	twoLevel2 := repository.NewInteractiveLocalCache(invalidator, logger)
+	interactiveRepository := repository.NewCachedInteractiveRepository(interactiveDao, interactiveCache, twoLevel2, logger)
+	userService := service.NewNormalUserService(userRepository, articleRepository, interactiveRepository, logger)
+	codeCache := cache.NewCodeRedisCache(cmdable)
+	codeRepository := repository.NewCodeRepository(codeCache)
+	codeQuotaCache := cache.NewCodeQuotaRedisCache(cmdable)
+	codeAuditDAO := dao.NewGORMCodeAuditDAO(db)
+	codeQuotaRepository := repository.NewCodeQuotaRepository(codeQuotaCache, codeAuditDAO)
+	smsService := ioc.InitSMSService()
+	emailService := ioc.InitEmailService()
+	v2 := ioc.InitChannels(smsService, emailService)
+	codeQuotaConfig := ioc.InitCodeQuotaConfig()
+	codePolicyConfig := ioc.InitCodePolicyConfig()
+	codeService := service.NewCodeService(codeRepository, codeQuotaRepository, v2, codeQuotaConfig, codePolicyConfig, logger)
+	captchaCache := cache.NewCaptchaRedisCache(cmdable)
+	captchaRepository := repository.NewCaptchaRepository(captchaCache)
+	captchaConfig := ioc.InitCaptchaConfig()
+	captchaService := service.NewCaptchaService(captchaRepository, captchaConfig, logger)
+	cipher := ioc.InitTwoFactorCipher(logger)
+	twoFactorDAO := dao.NewGORMTwoFactorDAO(db, cipher)
+	twoFactorRepository := repository.NewTwoFactorRepository(twoFactorDAO)
+	twoFactorConfig := ioc.InitTwoFactorConfig()
+	twoFactorService := service.NewTwoFactorService(twoFactorRepository, twoFactorConfig)
+	loginAttemptCache := cache.NewLoginAttemptRedisCache(cmdable)
+	loginAuditDAO := dao.NewGORMLoginAuditDAO(db)
+	loginAttemptRepository := repository.NewLoginAttemptRepository(loginAttemptCache, loginAuditDAO)
+	loginChallenge := ioc.InitLoginChallenge(captchaService)
+	loginPolicy := ioc.InitLoginPolicy()
+	loginGuardService := service.NewLoginGuardService(loginAttemptRepository, loginChallenge, loginPolicy, logger)
+	securityEventDAO := dao.NewGORMSecurityEventDAO(db)
+	securityEventRepository := repository.NewSecurityEventRepository(securityEventDAO)
+	securityEventConfig := ioc.InitSecurityEventConfig()
+	securityEventService := service.NewSecurityEventService(securityEventRepository, userRepository, securityEventConfig, logger)
+	userHandler := web.NewUserHandler(userService, codeService, captchaService, twoFactorService, loginGuardService, securityEventService, handler, logger)
+	provider := ioc.InitWechatProvider()
+	oAuth2WechatHandler := web.NewOAuth2WechatHandler(provider, userService, twoFactorService, loginGuardService, handler, keySet, securityEventService, logger)
+	broker := ioc.InitBroker()
+	syncProducer := ioc.InitSyncProducer(broker)
+	registry := ioc.InitEventRegistry()
+	producer := article.NewSaramaSyncProducer(syncProducer, registry)
+	articleService := service.NewArticleService(articleRepository, producer, logger)
+	interactiveService := service.NewInteractiveService(interactiveRepository, producer, logger)
 	articleHandler := web.NewArticleHandler(articleService, logger, interactiveService)
-	engine := ioc.InitWebServer(v, userHandler, oAuth2WechatHandler, articleHandler)
-	interactiveReadEventConsumer := article.NewInteractiveReadEventConsumer(interactiveRepository, client, logger)
-	v2 := ioc.InitConsumers(interactiveReadEventConsumer)
+	captchaHandler := web.NewCaptchaHandler(captchaService, logger)
+	jwksHandler := web.NewJWKSHandler(keySet)
+	twoFactorHandler := web.NewTwoFactorHandler(twoFactorService, userService, loginGuardService, securityEventService, handler, logger)
+	adminMiddlewareBuilder := ioc.InitAdminMiddleware()
+	securityEventHandler := web.NewSecurityEventHandler(securityEventService, adminMiddlewareBuilder)
+	outh2Registry := ioc.InitOAuth2Registry(provider, logger)
+	oAuth2Handler := web.NewOAuth2Handler(outh2Registry, userService, twoFactorService, loginGuardService, handler, keySet, securityEventService, logger)
+	engine := ioc.InitWebServer(v, userHandler, oAuth2WechatHandler, articleHandler, captchaHandler, jwksHandler, twoFactorHandler, securityEventHandler, oAuth2Handler)
+	idempotencyStore := ioc.InitIdempotencyStore(cmdable)
+	interactiveReadEventConsumer := article.NewInteractiveReadEventConsumer(interactiveRepository, broker, registry, idempotencyStore, logger)
+	cacheInvalidationConsumer := article.NewCacheInvalidationConsumer(articleCache, cachexTwoLevel, broker, registry, logger)
+	v3 := ioc.InitConsumers(interactiveReadEventConsumer, cacheInvalidationConsumer)
 	app := &App{
 		server:    engine,
-		consumers: v2,
+		consumers: v3,
 	}
 	return app
 }